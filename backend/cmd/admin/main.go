@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 
+	"easycart/internal/catalog"
 	"easycart/internal/config"
 	"easycart/internal/database"
 	"easycart/internal/models"
@@ -16,6 +22,8 @@ func main() {
 		fmt.Println("Commands:")
 		fmt.Println("  create-admin - Create the first admin user")
 		fmt.Println("  reset-db     - Reset database (WARNING: Destructive)")
+		fmt.Println("  import-catalog [--format=easycart|shopify|woocommerce] [--dry-run] <file.csv>")
+		fmt.Println("               - Import products from a CSV file")
 		os.Exit(1)
 	}
 
@@ -32,6 +40,8 @@ func main() {
 		createAdmin()
 	case "reset-db":
 		resetDatabase()
+	case "import-catalog":
+		importCatalog(os.Args[2:])
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		os.Exit(1)
@@ -100,6 +110,99 @@ func createAdmin() {
 	fmt.Printf("Role: %s\n", admin.Role)
 }
 
+func importCatalog(args []string) {
+	flags := flag.NewFlagSet("import-catalog", flag.ExitOnError)
+	format := flags.String("format", catalog.FormatNative, "File format: easycart, shopify or woocommerce")
+	dryRun := flags.Bool("dry-run", false, "Only report what the import would do")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: admin import-catalog [--format=easycart|shopify|woocommerce] [--dry-run] <file.csv>")
+		os.Exit(1)
+	}
+	path := flags.Arg(0)
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open file: %v", err)
+	}
+	content, err := io.ReadAll(io.LimitReader(file, catalog.MaxFileSize+1))
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to read file: %v", err)
+	}
+	if len(content) > catalog.MaxFileSize {
+		log.Fatalf("The file is larger than %d MB; split it into smaller files", catalog.MaxFileSize>>20)
+	}
+
+	batch, err := catalog.Parse(*format, bytes.NewReader(content))
+	if err != nil {
+		log.Fatalf("Failed to read file: %v", err)
+	}
+	preview, err := catalog.Validate(database.DB, batch)
+	if err != nil {
+		log.Fatalf("Failed to validate import: %v", err)
+	}
+
+	fmt.Printf("Rows: %d\n", preview.Rows)
+	fmt.Printf("Products: %d (%d new, %d updated) with %d variants\n", preview.Products, preview.Creates, preview.Updates, preview.Variants)
+	if len(preview.NewCategories) > 0 {
+		fmt.Printf("New categories: %v\n", preview.NewCategories)
+	}
+	if len(preview.UnmappedColumns) > 0 {
+		fmt.Printf("Unmapped columns (ignored): %v\n", preview.UnmappedColumns)
+	}
+	printImportErrors(preview.Errors)
+	if preview.Skipped > 0 {
+		fmt.Printf("Skipped products: %d\n", preview.Skipped)
+	}
+
+	if *dryRun {
+		return
+	}
+	if len(batch.Products) == 0 {
+		fmt.Println("The file has no valid products")
+		os.Exit(1)
+	}
+
+	imp := models.ProductImport{
+		Filename:      filepath.Base(path),
+		Format:        batch.Format,
+		Content:       string(content),
+		TotalProducts: len(batch.Products),
+	}
+	if err := database.DB.Create(&imp).Error; err != nil {
+		log.Fatalf("Failed to create import: %v", err)
+	}
+	if err := catalog.RunImport(context.Background(), database.DB, imp.ID); err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+	if err := database.DB.First(&imp, "id = ?", imp.ID).Error; err != nil {
+		log.Fatalf("Failed to load import: %v", err)
+	}
+	if imp.Status == models.ProductImportFailed {
+		log.Fatalf("Import failed: %s", imp.Error)
+	}
+
+	fmt.Printf("✅ Import finished: %d created, %d updated, %d failed\n", imp.Created, imp.Updated, imp.Failed)
+	if imp.Failed > 0 {
+		printImportErrors(imp.Errors)
+	}
+}
+
+func printImportErrors(errs []models.ImportRowError) {
+	if len(errs) == 0 {
+		return
+	}
+	fmt.Printf("Errors (%d):\n", len(errs))
+	for _, e := range errs {
+		field := ""
+		if e.Field != "" {
+			field = " " + e.Field + ":"
+		}
+		fmt.Printf("  row %d %s:%s %s\n", e.Row, e.SKU, field, e.Message)
+	}
+}
+
 func resetDatabase() {
 	fmt.Print("⚠️  This will delete ALL data! Type 'CONFIRM' to proceed: ")
 	var confirmation string
@@ -112,13 +215,46 @@ func resetDatabase() {
 
 	// Drop all tables
 	err := database.DB.Migrator().DropTable(
+		&models.ProductAlert{},
+		&models.CartItem{},
+		&models.Cart{},
+		&models.WishlistItem{},
+		&models.Wishlist{},
+		&models.ReviewVote{},
+		&models.ReviewImage{},
+		&models.Review{},
 		&models.OrderItem{},
 		&models.Order{},
+		&models.AttributeValue{},
+		&models.AttributeDefinition{},
+		&models.CollectionProduct{},
+		&models.Collection{},
+		&models.ProductTag{},
+		&models.ProductVariantOptionValue{},
+		"product_variant_images",
+		&models.ProductVariant{},
+		&models.ProductOptionValue{},
+		&models.ProductOption{},
 		&models.Media{},
 		&models.Product{},
 		&models.Category{},
 		&models.Settings{},
+		&models.ProductImport{},
+		&models.OutboxEvent{},
+		&models.WebhookDelivery{},
+		&models.WebhookEndpoint{},
+		&models.JobSchedule{},
+		&models.Job{},
+		&models.EmailMessage{},
+		&models.EmailTemplate{},
+		&models.AuditEvent{},
+		&models.StaffInvitation{},
+		&models.OIDCLoginState{},
+		&models.Address{},
+		&models.UserIdentity{},
+		&models.APIKey{},
 		&models.User{},
+		&models.Role{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to drop tables: %v", err)
@@ -126,17 +262,58 @@ func resetDatabase() {
 
 	// Re-create tables
 	err = database.DB.AutoMigrate(
+		&models.Role{},
 		&models.User{},
+		&models.APIKey{},
+		&models.UserIdentity{},
+		&models.Address{},
+		&models.OIDCLoginState{},
+		&models.StaffInvitation{},
+		&models.AuditEvent{},
+		&models.EmailTemplate{},
+		&models.EmailMessage{},
+		&models.Job{},
+		&models.JobSchedule{},
+		&models.WebhookEndpoint{},
+		&models.WebhookDelivery{},
+		&models.OutboxEvent{},
+		&models.ProductImport{},
 		&models.Settings{},
 		&models.Category{},
 		&models.Product{},
 		&models.Media{},
+		&models.ProductOption{},
+		&models.ProductOptionValue{},
+		&models.ProductVariant{},
+		&models.ProductVariantOptionValue{},
+		&models.ProductTag{},
+		&models.Collection{},
+		&models.CollectionProduct{},
+		&models.AttributeDefinition{},
+		&models.AttributeValue{},
 		&models.Order{},
 		&models.OrderItem{},
+		&models.Review{},
+		&models.ReviewImage{},
+		&models.ReviewVote{},
+		&models.Wishlist{},
+		&models.WishlistItem{},
+		&models.Cart{},
+		&models.CartItem{},
+		&models.ProductAlert{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
+	if err := database.ProtectAuditEvents(database.DB); err != nil {
+		log.Fatalf("Failed to protect audit log: %v", err)
+	}
+	if err := database.SetupProductSearch(database.DB); err != nil {
+		log.Fatalf("Failed to set up product search: %v", err)
+	}
+	if err := database.SetupCartItems(database.DB); err != nil {
+		log.Fatalf("Failed to set up cart items: %v", err)
+	}
 
 	fmt.Println("✅ Database reset successfully!")
 }
\ No newline at end of file
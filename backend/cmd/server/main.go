@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"log"
 
+	"easycart/internal/background"
 	"easycart/internal/config"
 	"easycart/internal/database"
+	"easycart/internal/feed"
 	"easycart/internal/handlers"
 	"easycart/internal/middleware"
+	"easycart/internal/models"
+	"easycart/internal/notifications"
+	"easycart/internal/oidc"
 	"easycart/internal/services"
 	"easycart/internal/validator"
 	"github.com/labstack/echo/v4"
@@ -30,13 +36,35 @@ func main() {
 		log.Fatalf("Failed to connect to MinIO: %v", err)
 	}
 	log.Println("MinIO connected successfully")
+
+	// Outgoing email and background jobs are queued in the database; they are
+	// processed here unless a separate cmd/worker does it
+	if cfg.RunWorkers {
+		go func() {
+			if err := background.Run(context.Background(), database.DB, cfg); err != nil {
+				log.Fatalf("Background workers failed: %v", err)
+			}
+		}()
+	}
+	// Live admin feed: messages from every instance arrive via Postgres NOTIFY
+	eventHub := feed.NewHub(500)
+	go feed.Listen(context.Background(), database.DSN(cfg), eventHub)
+	notifier := notifications.NewNotifier()
 	
 	e := echo.New()
 	
 	// Set validator
 	e.Validator = validator.New()
 	
+	// Client IPs for rate limits and the audit log
+	ipExtractor, err := middleware.IPExtractor(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	e.IPExtractor = ipExtractor
+	
 	// Middleware
+	e.Use(echomiddleware.RequestID())
 	e.Use(echomiddleware.Logger())
 	e.Use(echomiddleware.Recover())
 	e.Use(middleware.CORS())
@@ -46,10 +74,42 @@ func main() {
 	settingsHandler := handlers.NewSettingsHandler(database.DB)
 	productHandler := handlers.NewProductHandler(database.DB)
 	categoryHandler := handlers.NewCategoryHandler(database.DB)
+	collectionHandler := handlers.NewCollectionHandler(database.DB)
+	attributeHandler := handlers.NewAttributeHandler(database.DB)
+	reviewHandler := handlers.NewReviewHandler(database.DB)
+	wishlistHandler := handlers.NewWishlistHandler(database.DB)
+	cartHandler := handlers.NewCartHandler(database.DB)
+	productAlertHandler := handlers.NewProductAlertHandler(database.DB, cfg.FrontendURL, notifier)
 	uploadHandler := handlers.NewUploadHandler(minioService)
-	orderHandler := handlers.NewOrderHandler(database.DB)
+	orderHandler := handlers.NewOrderHandler(database.DB, cfg.JWTSecret, cfg.FrontendURL, notifier)
 	adminHandler := handlers.NewAdminHandler(database.DB)
-	storefrontHandler := handlers.NewStorefrontHandler(database.DB)
+	storefrontHandler := handlers.NewStorefrontHandler(database.DB, cfg.JWTSecret, cfg.FrontendURL, notifier)
+	roleHandler := handlers.NewRoleHandler(database.DB)
+	apiKeyHandler := handlers.NewAPIKeyHandler(database.DB)
+
+	var oidcProviders []*oidc.Provider
+	for _, provider := range cfg.OIDCProviders {
+		oidcProviders = append(oidcProviders, oidc.NewProvider(oidc.Config{
+			Name:         provider.Name,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+			Scopes:       provider.Scopes,
+		}, nil))
+	}
+	oidcHandler := handlers.NewOIDCHandler(database.DB, cfg.JWTSecret, oidcProviders)
+	invitationHandler := handlers.NewInvitationHandler(database.DB, cfg.JWTSecret, cfg.FrontendURL, notifier)
+	impersonationHandler := handlers.NewImpersonationHandler(database.DB, cfg.JWTSecret)
+	auditHandler := handlers.NewAuditHandler(database.DB)
+	emailTemplateHandler := handlers.NewEmailTemplateHandler(database.DB)
+	accountHandler := handlers.NewAccountHandler(database.DB, cfg.JWTSecret, cfg.FrontendURL, notifier)
+	jobHandler := handlers.NewJobHandler(database.DB)
+	webhookHandler := handlers.NewWebhookHandler(database.DB)
+	outboxHandler := handlers.NewOutboxHandler(database.DB)
+	eventStreamHandler := handlers.NewEventStreamHandler(eventHub, cfg.JWTSecret)
+	reportHandler := handlers.NewReportHandler(database.DB)
+	productImportHandler := handlers.NewProductImportHandler(database.DB)
 	
 	// Routes
 	api := e.Group("/api/v1")
@@ -60,56 +120,227 @@ func main() {
 	auth.POST("/login", authHandler.Login)
 	auth.POST("/register", authHandler.Register) // Customer registration
 
+	// OpenID Connect sign-in for customers
+	auth.GET("/oidc/providers", oidcHandler.GetProviders)
+	auth.POST("/oidc/:provider/start", oidcHandler.Start)
+	auth.POST("/oidc/:provider/callback", oidcHandler.Callback)
+
+	// Staff invitation acceptance
+	auth.GET("/invitations/:token", invitationHandler.GetInvitation)
+	auth.POST("/invitations/accept", invitationHandler.AcceptInvitation)
+
+	// Email verification links
+	auth.POST("/verify-email", accountHandler.VerifyEmail)
+
+	// Password reset by email
+	auth.POST("/password/forgot", accountHandler.ForgotPassword, middleware.RateLimit(5))
+	auth.POST("/password/reset", accountHandler.ResetPassword, middleware.RateLimit(10))
+
 	// Protected routes
 	protected := api.Group("")
 	protected.Use(middleware.JWTMiddleware(cfg.JWTSecret))
+	protected.Use(middleware.AuditImpersonation())
 
 	// User routes
 	protected.GET("/profile", authHandler.GetProfile)
 
+	// Customer account portal
+	me := protected.Group("/me")
+	me.GET("/profile", authHandler.GetProfile)
+	me.PUT("/profile", accountHandler.UpdateProfile)
+	me.PUT("/password", accountHandler.ChangePassword, middleware.BlockImpersonation())
+	me.POST("/email/verification", accountHandler.RequestEmailVerification)
+	me.GET("/orders", accountHandler.GetOrders)
+	me.GET("/orders/:id", accountHandler.GetOrder)
+	me.GET("/addresses", accountHandler.GetAddresses)
+	me.POST("/addresses", accountHandler.CreateAddress)
+	me.PUT("/addresses/:id", accountHandler.UpdateAddress)
+	me.DELETE("/addresses/:id", accountHandler.DeleteAddress)
+	me.GET("/checkout", accountHandler.GetCheckoutDefaults)
+	me.GET("/reviews", reviewHandler.GetMyReviews)
+	me.POST("/reviews", reviewHandler.CreateReview, middleware.RateLimit(10))
+	me.DELETE("/reviews/:id", reviewHandler.DeleteMyReview)
+	me.POST("/reviews/:id/helpful", reviewHandler.MarkHelpful, middleware.RateLimit(60))
+	me.DELETE("/reviews/:id/helpful", reviewHandler.UnmarkHelpful)
+	me.POST("/review-images", uploadHandler.UploadReviewImage, middleware.RateLimit(20))
+	me.GET("/wishlists", wishlistHandler.GetWishlists)
+	me.POST("/wishlists", wishlistHandler.CreateWishlist)
+	me.GET("/wishlists/:id", wishlistHandler.GetWishlist)
+	me.PUT("/wishlists/:id", wishlistHandler.UpdateWishlist)
+	me.DELETE("/wishlists/:id", wishlistHandler.DeleteWishlist)
+	me.POST("/wishlists/:id/items", wishlistHandler.AddWishlistItem)
+	me.DELETE("/wishlists/:id/items/:itemId", wishlistHandler.RemoveWishlistItem)
+	me.POST("/wishlists/:id/share", wishlistHandler.ShareWishlist)
+	me.DELETE("/wishlists/:id/share", wishlistHandler.UnshareWishlist)
+	me.POST("/wishlists/:id/move-to-cart", wishlistHandler.MoveToCart)
+	me.GET("/cart", cartHandler.GetCart)
+	me.POST("/cart/items", cartHandler.AddCartItem)
+	me.PUT("/cart/items/:id", cartHandler.UpdateCartItem)
+	me.DELETE("/cart/items/:id", cartHandler.RemoveCartItem)
+
 	// Admin routes (require admin/manager role)
 	admin := api.Group("/admin")
-	admin.Use(middleware.JWTMiddleware(cfg.JWTSecret))
-	admin.Use(middleware.AdminMiddleware()) // New middleware for role checking
+	admin.Use(middleware.AdminAuthMiddleware(cfg.JWTSecret)) // JWTs, or API keys limited to their scopes
+	admin.Use(middleware.AuditImpersonation())
+	admin.Use(middleware.BlockImpersonation()) // Support sessions never reach the admin API
+	admin.Use(middleware.AdminMiddleware()) // Loads the staff user and their permissions
+
+	// Permission discovery
+	admin.GET("/permissions", roleHandler.GetPermissions, middleware.RequirePermission(models.PermissionRolesRead))
+	admin.GET("/permissions/me", roleHandler.GetMyPermissions)
 
 	// Settings routes
-	admin.GET("/settings", settingsHandler.GetSettings)
-	admin.PUT("/settings", settingsHandler.UpdateSettings)
-
-	// User management (admin only)
-	admin.GET("/users", adminHandler.GetUsers)
-	admin.POST("/users", adminHandler.CreateUser)
-	admin.PUT("/users/:id", adminHandler.UpdateUser)
-	admin.DELETE("/users/:id", adminHandler.DeleteUser)
-	
-	// Admin management routes (admin/manager access)
-	admin.POST("/uploads", uploadHandler.UploadFile)
+	admin.GET("/settings", settingsHandler.GetSettings, middleware.RequirePermission(models.PermissionSettingsRead))
+	admin.PUT("/settings", settingsHandler.UpdateSettings, middleware.RequirePermission(models.PermissionSettingsWrite))
+
+	// User management
+	admin.GET("/users", adminHandler.GetUsers, middleware.RequireAnyPermission(models.PermissionUsersRead, models.PermissionCustomersRead))
+	admin.POST("/users", adminHandler.CreateUser, middleware.RequirePermission(models.PermissionUsersWrite))
+	admin.PUT("/users/:id", adminHandler.UpdateUser, middleware.RequirePermission(models.PermissionUsersWrite))
+	admin.DELETE("/users/:id", adminHandler.DeleteUser, middleware.RequirePermission(models.PermissionUsersWrite))
+	admin.POST("/users/:id/impersonate", impersonationHandler.ImpersonateUser, middleware.RequirePermission(models.PermissionUsersImpersonate))
+
+	// Staff invitations
+	admin.GET("/invitations", invitationHandler.GetInvitations, middleware.RequirePermission(models.PermissionUsersRead))
+	admin.POST("/invitations", invitationHandler.CreateInvitation, middleware.RequirePermission(models.PermissionUsersWrite))
+	admin.DELETE("/invitations/:id", invitationHandler.RevokeInvitation, middleware.RequirePermission(models.PermissionUsersWrite))
+
+	// Role management
+	admin.GET("/roles", roleHandler.GetRoles, middleware.RequirePermission(models.PermissionRolesRead))
+	admin.POST("/roles", roleHandler.CreateRole, middleware.RequirePermission(models.PermissionRolesWrite))
+	admin.PUT("/roles/:id", roleHandler.UpdateRole, middleware.RequirePermission(models.PermissionRolesWrite))
+	admin.DELETE("/roles/:id", roleHandler.DeleteRole, middleware.RequirePermission(models.PermissionRolesWrite))
+
+	// API keys for server-to-server integrations
+	admin.GET("/api-keys", apiKeyHandler.GetAPIKeys, middleware.RequirePermission(models.PermissionAPIKeysManage))
+	admin.POST("/api-keys", apiKeyHandler.CreateAPIKey, middleware.RequirePermission(models.PermissionAPIKeysManage))
+	admin.DELETE("/api-keys/:id", apiKeyHandler.RevokeAPIKey, middleware.RequirePermission(models.PermissionAPIKeysManage))
+
+	// Email templates
+	admin.GET("/email-templates", emailTemplateHandler.GetEmailTemplates, middleware.RequirePermission(models.PermissionSettingsRead))
+	admin.GET("/email-templates/:key", emailTemplateHandler.GetEmailTemplate, middleware.RequirePermission(models.PermissionSettingsRead))
+	admin.PUT("/email-templates/:key", emailTemplateHandler.UpdateEmailTemplate, middleware.RequirePermission(models.PermissionSettingsWrite))
+	admin.DELETE("/email-templates/:key", emailTemplateHandler.ResetEmailTemplate, middleware.RequirePermission(models.PermissionSettingsWrite))
+	admin.POST("/email-templates/:key/preview", emailTemplateHandler.PreviewEmailTemplate, middleware.RequirePermission(models.PermissionSettingsRead))
+
+	// Audit log
+	admin.GET("/audit", auditHandler.GetAuditEvents, middleware.RequirePermission(models.PermissionAuditRead))
+	admin.GET("/audit/export", auditHandler.ExportAuditEvents, middleware.RequirePermission(models.PermissionAuditRead))
+
+	// Background jobs
+	admin.GET("/jobs", jobHandler.GetJobs, middleware.RequirePermission(models.PermissionJobsManage))
+	admin.GET("/jobs/stats", jobHandler.GetJobStats, middleware.RequirePermission(models.PermissionJobsManage))
+	admin.GET("/jobs/:id", jobHandler.GetJob, middleware.RequirePermission(models.PermissionJobsManage))
+	admin.POST("/jobs/:id/retry", jobHandler.RetryJob, middleware.RequirePermission(models.PermissionJobsManage))
+	admin.POST("/jobs/:id/cancel", jobHandler.CancelJob, middleware.RequirePermission(models.PermissionJobsManage))
+
+	// Domain event outbox
+	admin.GET("/outbox", outboxHandler.GetOutboxEvents, middleware.RequirePermission(models.PermissionJobsManage))
+	admin.POST("/outbox/:id/retry", outboxHandler.RetryOutboxEvent, middleware.RequirePermission(models.PermissionJobsManage))
+
+	// Sales reports
+	admin.GET("/reports/sales", reportHandler.GetSalesReport, middleware.RequirePermission(models.PermissionReportsRead))
+	admin.GET("/reports/products", reportHandler.GetTopProducts, middleware.RequirePermission(models.PermissionReportsRead))
+	admin.GET("/reports/categories", reportHandler.GetTopCategories, middleware.RequirePermission(models.PermissionReportsRead))
+	admin.GET("/reports/customers", reportHandler.GetCustomerReport, middleware.RequirePermission(models.PermissionReportsRead))
+	admin.GET("/reports/refunds", reportHandler.GetRefundReport, middleware.RequirePermission(models.PermissionReportsRead))
+	admin.GET("/reports/funnel", reportHandler.GetFunnelReport, middleware.RequirePermission(models.PermissionReportsRead))
+	admin.GET("/reports/wishlists", reportHandler.GetWishlistReport, middleware.RequirePermission(models.PermissionReportsRead))
+
+	// Live event stream; the ticket lets browsers open it with EventSource
+	admin.POST("/events/stream/ticket", eventStreamHandler.IssueStreamTicket, middleware.RequirePermission(models.PermissionOrdersRead))
+	stream := api.Group("/admin/events/stream")
+	stream.Use(middleware.StreamTicketMiddleware(cfg.JWTSecret))
+	stream.Use(middleware.BlockImpersonation())
+	stream.Use(middleware.AdminMiddleware())
+	stream.GET("", eventStreamHandler.Stream, middleware.RequirePermission(models.PermissionOrdersRead))
+
+	// Outgoing webhooks
+	admin.GET("/webhooks", webhookHandler.GetWebhooks, middleware.RequirePermission(models.PermissionWebhooksManage))
+	admin.GET("/webhooks/events", webhookHandler.GetWebhookEvents, middleware.RequirePermission(models.PermissionWebhooksManage))
+	admin.POST("/webhooks", webhookHandler.CreateWebhook, middleware.RequirePermission(models.PermissionWebhooksManage))
+	admin.GET("/webhooks/:id", webhookHandler.GetWebhook, middleware.RequirePermission(models.PermissionWebhooksManage))
+	admin.PUT("/webhooks/:id", webhookHandler.UpdateWebhook, middleware.RequirePermission(models.PermissionWebhooksManage))
+	admin.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook, middleware.RequirePermission(models.PermissionWebhooksManage))
+	admin.POST("/webhooks/:id/rotate-secret", webhookHandler.RotateWebhookSecret, middleware.RequirePermission(models.PermissionWebhooksManage))
+	admin.POST("/webhooks/:id/test", webhookHandler.TestWebhook, middleware.RequirePermission(models.PermissionWebhooksManage))
+	admin.GET("/webhooks/:id/deliveries", webhookHandler.GetWebhookDeliveries, middleware.RequirePermission(models.PermissionWebhooksManage))
+	admin.GET("/webhooks/deliveries/:id", webhookHandler.GetWebhookDelivery, middleware.RequirePermission(models.PermissionWebhooksManage))
+	admin.POST("/webhooks/deliveries/:id/redeliver", webhookHandler.RedeliverWebhook, middleware.RequirePermission(models.PermissionWebhooksManage))
+
+	// Media uploads
+	admin.POST("/uploads", uploadHandler.UploadFile, middleware.RequirePermission(models.PermissionMediaWrite))
 
 	// Category management
-	admin.GET("/categories", categoryHandler.GetCategories)
-	admin.GET("/categories/:id", categoryHandler.GetCategory)
-	admin.POST("/categories", categoryHandler.CreateCategory)
-	admin.PUT("/categories/:id", categoryHandler.UpdateCategory)
-	admin.DELETE("/categories/:id", categoryHandler.DeleteCategory)
+	admin.GET("/categories", categoryHandler.GetCategories, middleware.RequirePermission(models.PermissionCategoriesRead))
+	admin.GET("/categories/:id", categoryHandler.GetCategory, middleware.RequirePermission(models.PermissionCategoriesRead))
+	admin.POST("/categories", categoryHandler.CreateCategory, middleware.RequirePermission(models.PermissionCategoriesWrite))
+	admin.PUT("/categories/:id", categoryHandler.UpdateCategory, middleware.RequirePermission(models.PermissionCategoriesWrite))
+	admin.DELETE("/categories/:id", categoryHandler.DeleteCategory, middleware.RequirePermission(models.PermissionCategoriesWrite))
+	admin.GET("/categories/tree", categoryHandler.GetCategoryTree, middleware.RequirePermission(models.PermissionCategoriesRead))
+	admin.POST("/categories/:id/move", categoryHandler.MoveCategory, middleware.RequirePermission(models.PermissionCategoriesWrite))
+
+	// Collections
+	admin.GET("/collections", collectionHandler.GetCollections, middleware.RequirePermission(models.PermissionProductsRead))
+	admin.GET("/collections/:id", collectionHandler.GetCollection, middleware.RequirePermission(models.PermissionProductsRead))
+	admin.POST("/collections", collectionHandler.CreateCollection, middleware.RequirePermission(models.PermissionProductsWrite))
+	admin.PUT("/collections/:id", collectionHandler.UpdateCollection, middleware.RequirePermission(models.PermissionProductsWrite))
+	admin.PUT("/collections/:id/products", collectionHandler.SetCollectionProducts, middleware.RequirePermission(models.PermissionProductsWrite))
+	admin.DELETE("/collections/:id", collectionHandler.DeleteCollection, middleware.RequirePermission(models.PermissionProductsWrite))
+
+	// Product attributes
+	admin.GET("/attributes", attributeHandler.GetAttributes, middleware.RequirePermission(models.PermissionProductsRead))
+	admin.GET("/attributes/:id", attributeHandler.GetAttribute, middleware.RequirePermission(models.PermissionProductsRead))
+	admin.POST("/attributes", attributeHandler.CreateAttribute, middleware.RequirePermission(models.PermissionProductsWrite))
+	admin.PUT("/attributes/:id", attributeHandler.UpdateAttribute, middleware.RequirePermission(models.PermissionProductsWrite))
+	admin.DELETE("/attributes/:id", attributeHandler.DeleteAttribute, middleware.RequirePermission(models.PermissionProductsWrite))
+
+	// Review moderation
+	admin.GET("/reviews", reviewHandler.GetReviews, middleware.RequirePermission(models.PermissionReviewsModerate))
+	admin.GET("/reviews/:id", reviewHandler.GetReview, middleware.RequirePermission(models.PermissionReviewsModerate))
+	admin.POST("/reviews/:id/approve", reviewHandler.ApproveReview, middleware.RequirePermission(models.PermissionReviewsModerate))
+	admin.POST("/reviews/:id/reject", reviewHandler.RejectReview, middleware.RequirePermission(models.PermissionReviewsModerate))
+	admin.PUT("/reviews/:id/response", reviewHandler.RespondToReview, middleware.RequirePermission(models.PermissionReviewsModerate))
+	admin.DELETE("/reviews/:id", reviewHandler.DeleteReview, middleware.RequirePermission(models.PermissionReviewsModerate))
 
 	// Product management
-	admin.GET("/products", productHandler.GetProducts)
-	admin.GET("/products/:id", productHandler.GetProduct)
-	admin.POST("/products", productHandler.CreateProduct)
-	admin.PUT("/products/:id", productHandler.UpdateProduct)
-	admin.DELETE("/products/:id", productHandler.DeleteProduct)
+	admin.GET("/products", productHandler.GetProducts, middleware.RequirePermission(models.PermissionProductsRead))
+	admin.GET("/products/export.csv", productImportHandler.ExportProducts, middleware.RequirePermission(models.PermissionProductsRead))
+	admin.POST("/products/import", productImportHandler.ImportProducts, middleware.RequirePermission(models.PermissionProductsWrite))
+	admin.GET("/products/imports", productImportHandler.GetProductImports, middleware.RequirePermission(models.PermissionProductsRead))
+	admin.GET("/products/imports/:id", productImportHandler.GetProductImport, middleware.RequirePermission(models.PermissionProductsRead))
+	admin.GET("/products/alerts", productAlertHandler.GetAlertDemand, middleware.RequirePermission(models.PermissionProductsRead))
+	admin.GET("/products/:id", productHandler.GetProduct, middleware.RequirePermission(models.PermissionProductsRead))
+	admin.POST("/products", productHandler.CreateProduct, middleware.RequirePermission(models.PermissionProductsWrite))
+	admin.PUT("/products/:id", productHandler.UpdateProduct, middleware.RequirePermission(models.PermissionProductsWrite))
+	admin.DELETE("/products/:id", productHandler.DeleteProduct, middleware.RequirePermission(models.PermissionProductsWrite))
 
 	// Order management
-	admin.GET("/orders", orderHandler.GetOrders)
-	admin.GET("/orders/:id", orderHandler.GetOrder)
-	admin.PUT("/orders/:id/status", orderHandler.UpdateOrderStatus)
+	admin.GET("/orders", orderHandler.GetOrders, middleware.RequirePermission(models.PermissionOrdersRead))
+	admin.GET("/orders/:id", orderHandler.GetOrder, middleware.RequirePermission(models.PermissionOrdersRead))
+	admin.PUT("/orders/:id/status", orderHandler.UpdateOrderStatus, middleware.RequirePermission(models.PermissionOrdersWrite))
 	
 	// Public storefront routes (single shop)
 	api.GET("/store", storefrontHandler.GetShop)
 	api.GET("/store/products", storefrontHandler.GetShopProducts)
+	api.GET("/store/products/compare", storefrontHandler.CompareProducts)
 	api.GET("/store/products/:productId", storefrontHandler.GetShopProduct)
+	api.GET("/store/products/:productId/reviews", reviewHandler.GetProductReviews)
+	api.POST("/store/products/:productId/alerts", productAlertHandler.Subscribe, middleware.RateLimit(10))
+	api.POST("/store/alerts/confirm", productAlertHandler.Confirm, middleware.RateLimit(30))
+	api.POST("/store/alerts/unsubscribe", productAlertHandler.Unsubscribe, middleware.RateLimit(30))
+	api.GET("/store/search/suggest", storefrontHandler.SearchSuggest, middleware.RateLimit(300))
 	api.GET("/store/categories", storefrontHandler.GetShopCategories)
-	api.POST("/store/orders", storefrontHandler.CreatePublicOrder)
+	api.GET("/store/categories/tree", storefrontHandler.GetShopCategoryTree)
+	api.GET("/store/collections", storefrontHandler.GetShopCollections)
+	api.GET("/store/collections/:slug", storefrontHandler.GetShopCollection)
+	api.GET("/store/collections/:slug/products", storefrontHandler.GetShopCollectionProducts)
+	api.GET("/store/wishlists/:token", wishlistHandler.GetSharedWishlist, middleware.RateLimit(60))
+	api.POST("/store/orders", storefrontHandler.CreatePublicOrder, middleware.OptionalJWTMiddleware(cfg.JWTSecret), middleware.AuditImpersonation())
+
+	// Guest order access; lookups are rate limited to stop enumeration
+	api.GET("/store/orders/lookup", storefrontHandler.LookupOrder, middleware.RateLimit(10))
+	api.GET("/store/orders/status", storefrontHandler.GetOrderStatus, middleware.RateLimit(30))
 	
 	log.Printf("Starting server on port %s", cfg.Port)
 	if err := e.Start(":" + cfg.Port); err != nil {
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"easycart/internal/background"
+	"easycart/internal/config"
+	"easycart/internal/database"
+)
+
+// The worker runs email delivery and background jobs without serving HTTP.
+// Start the server with RUN_WORKERS=false when jobs should only run here.
+func main() {
+	cfg := config.Load()
+
+	log.Println("Attempting to connect to database...")
+	if err := database.Connect(cfg); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Worker started with concurrency %d", cfg.WorkerConcurrency)
+	if err := background.Run(ctx, database.DB, cfg); err != nil {
+		log.Fatalf("Worker failed: %v", err)
+	}
+	log.Println("Worker stopped")
+}
@@ -0,0 +1,137 @@
+// Package audit writes AuditEvent records for admin mutations. Call Record
+// with the same transaction that performs the change so the event is only
+// stored if the change commits.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// ignoredFields never show up in diffs because they change on every write
+var ignoredFields = map[string]bool{
+	"updated_at": true,
+}
+
+// Record writes an audit event for a change to an entity. before is nil for
+// creates and after is nil for deletes; otherwise only changed fields are kept.
+func Record(tx *gorm.DB, c echo.Context, action, entityType string, entityID interface{}, before, after interface{}) error {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return fmt.Errorf("failed to diff %s: %w", entityType, err)
+	}
+
+	event := newEvent(c, action, entityType, fmt.Sprint(entityID))
+	event.Changes = changes
+	return tx.Create(&event).Error
+}
+
+// RecordFor writes an audit event for a change made in the background on
+// behalf of a user, such as a queued import
+func RecordFor(tx *gorm.DB, actorID *uuid.UUID, action, entityType string, entityID interface{}, before, after interface{}) error {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return fmt.Errorf("failed to diff %s: %w", entityType, err)
+	}
+
+	event := newEvent(nil, action, entityType, fmt.Sprint(entityID))
+	event.ActorID = actorID
+	event.Changes = changes
+	return tx.Create(&event).Error
+}
+
+// RecordAction writes an audit event for something that is not a field change,
+// such as an impersonated request or an accepted invitation
+func RecordAction(tx *gorm.DB, c echo.Context, action, entityType string, entityID interface{}, metadata models.JSONMap) error {
+	event := newEvent(c, action, entityType, fmt.Sprint(entityID))
+	event.Metadata = metadata
+	return tx.Create(&event).Error
+}
+
+func newEvent(c echo.Context, action, entityType, entityID string) models.AuditEvent {
+	event := models.AuditEvent{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+
+	if c == nil {
+		return event
+	}
+
+	if userID, ok := c.Get("user_id").(uuid.UUID); ok {
+		event.ActorID = &userID
+	}
+	if impersonatorID, ok := c.Get("impersonator_id").(uuid.UUID); ok {
+		event.ImpersonatorID = &impersonatorID
+	}
+	if apiKeyID, ok := c.Get("api_key_id").(uuid.UUID); ok {
+		event.APIKeyID = &apiKeyID
+	}
+	// Only believes X-Forwarded-For from the proxies trusted by e.IPExtractor
+	event.IP = c.RealIP()
+	event.UserAgent = c.Request().UserAgent()
+	event.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	if event.RequestID == "" {
+		event.RequestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	return event
+}
+
+// Diff compares the JSON representations of before and after and returns the
+// changed top-level fields as {"field": {"before": ..., "after": ...}}
+func Diff(before, after interface{}) (models.JSONMap, error) {
+	beforeFields, err := toFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := toFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := models.JSONMap{}
+	for field, afterValue := range afterFields {
+		if ignoredFields[field] {
+			continue
+		}
+		beforeValue, existed := beforeFields[field]
+		if !existed || !reflect.DeepEqual(beforeValue, afterValue) {
+			changes[field] = map[string]interface{}{"before": beforeValue, "after": afterValue}
+		}
+	}
+	for field, beforeValue := range beforeFields {
+		if ignoredFields[field] {
+			continue
+		}
+		if _, exists := afterFields[field]; !exists {
+			changes[field] = map[string]interface{}{"before": beforeValue, "after": nil}
+		}
+	}
+	return changes, nil
+}
+
+func toFields(v interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v == nil {
+		return fields, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"testing"
+	"time"
+)
+
+type widget struct {
+	Name      string    `json:"name"`
+	Price     float64   `json:"price"`
+	Secret    string    `json:"-"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func TestDiff(t *testing.T) {
+	t.Run("update keeps only changed fields", func(t *testing.T) {
+		before := widget{Name: "Mug", Price: 10, Secret: "a", UpdatedAt: time.Unix(1, 0)}
+		after := widget{Name: "Mug", Price: 12.5, Secret: "b", UpdatedAt: time.Unix(2, 0)}
+
+		changes, err := Diff(before, after)
+		if err != nil {
+			t.Fatalf("Diff() error = %v", err)
+		}
+		if len(changes) != 1 {
+			t.Fatalf("Expected only price to change, got %v", changes)
+		}
+		price, ok := changes["price"].(map[string]interface{})
+		if !ok || price["before"] != 10.0 || price["after"] != 12.5 {
+			t.Errorf("Unexpected price change %v", changes["price"])
+		}
+	})
+
+	t.Run("create records every field", func(t *testing.T) {
+		changes, err := Diff(nil, widget{Name: "Mug", Price: 10})
+		if err != nil {
+			t.Fatalf("Diff() error = %v", err)
+		}
+		if _, ok := changes["name"]; !ok {
+			t.Errorf("Expected name in create diff, got %v", changes)
+		}
+		if _, ok := changes["updated_at"]; ok {
+			t.Error("updated_at should be ignored")
+		}
+	})
+
+	t.Run("delete records before values", func(t *testing.T) {
+		var none *widget
+		changes, err := Diff(&widget{Name: "Mug"}, none)
+		if err != nil {
+			t.Fatalf("Diff() error = %v", err)
+		}
+		name, ok := changes["name"].(map[string]interface{})
+		if !ok || name["before"] != "Mug" || name["after"] != nil {
+			t.Errorf("Unexpected name change %v", changes["name"])
+		}
+	})
+}
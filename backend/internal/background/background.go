@@ -0,0 +1,63 @@
+// Package background wires up the processes that run outside of requests:
+// email delivery, the domain event dispatcher and the jobs worker, with every
+// subscriber and job handler registered.
+// Both cmd/server and cmd/worker use it.
+package background
+
+import (
+	"context"
+	"sync"
+
+	"easycart/internal/catalog"
+	"easycart/internal/config"
+	"easycart/internal/database"
+	"easycart/internal/events"
+	"easycart/internal/feed"
+	"easycart/internal/jobs"
+	"easycart/internal/notifications"
+	"easycart/internal/webhooks"
+	"gorm.io/gorm"
+)
+
+// Run starts the background workers and blocks until ctx is cancelled and
+// in-flight work has stopped
+func Run(ctx context.Context, db *gorm.DB, cfg *config.Config) error {
+	mailer, err := notifications.NewMailer(cfg)
+	if err != nil {
+		return err
+	}
+
+	notifier := notifications.NewNotifier()
+
+	worker := jobs.NewWorker(db)
+	worker.SetConcurrency(cfg.WorkerConcurrency)
+	notifier.RegisterJobs(worker, db, cfg.FrontendURL)
+	webhooks.NewDeliverer(db).Register(worker)
+	catalog.RegisterJobs(worker, db)
+	if err := events.RegisterJobs(worker, db); err != nil {
+		return err
+	}
+
+	dispatcher := events.NewDispatcher(db)
+	if cfg.OutboxListen {
+		dispatcher.Listen(database.DSN(cfg))
+	}
+	notifier.Subscribe(dispatcher)
+	webhooks.Subscribe(dispatcher)
+	feed.Subscribe(dispatcher)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		notifications.NewQueue(db, mailer).Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		dispatcher.Run(ctx)
+	}()
+
+	err = worker.Run(ctx)
+	wg.Wait()
+	return err
+}
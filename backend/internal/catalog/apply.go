@@ -0,0 +1,572 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"easycart/internal/audit"
+	"easycart/internal/events"
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNoShop is returned for image URLs when there is no shop to own the media
+var ErrNoShop = errors.New("image URLs need a shop to own the images")
+
+// Importer writes products into the catalog
+type Importer struct {
+	// ActorID is recorded as the author of the changes in the audit log
+	ActorID *uuid.UUID
+	// ShopID owns the media records created for image URLs
+	ShopID *uuid.UUID
+}
+
+// NewImporter prepares an import on behalf of actorID, with images owned by
+// the actor's shop or else the first shop
+func NewImporter(db *gorm.DB, actorID *uuid.UUID) (*Importer, error) {
+	im := &Importer{ActorID: actorID}
+
+	var shop models.Shop
+	query := db.Select("id")
+	if actorID != nil {
+		query = query.Order(clause.OrderBy{Expression: clause.Expr{SQL: "user_id = ? DESC", Vars: []interface{}{*actorID}, WithoutParentheses: true}})
+	}
+	err := query.Order("created_at ASC").Take(&shop).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	if err == nil {
+		im.ShopID = &shop.ID
+	}
+	return im, nil
+}
+
+// Apply creates or updates one product and its images and variants, matched
+// by SKU. Validation problems are returned as row errors with nothing written;
+// err is for database failures. Call it in a transaction.
+func (im *Importer) Apply(tx *gorm.DB, input *ProductInput) (created bool, rowErrs []models.ImportRowError, err error) {
+	c, err := loadChecker(tx, []*ProductInput{input})
+	if err != nil {
+		return false, nil, err
+	}
+	if rowErrs := c.check(input); len(rowErrs) > 0 {
+		return false, rowErrs, nil
+	}
+	if len(input.ImageURLs) > 0 && input.Has(ColImageURLs) && im.ShopID == nil {
+		return false, []models.ImportRowError{{Row: input.Row, SKU: input.SKU, Field: ColImageURLs, Message: ErrNoShop.Error()}}, nil
+	}
+
+	var product models.Product
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("sku = ?", input.SKU).Take(&product).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return false, nil, err
+	}
+	created = err == gorm.ErrRecordNotFound
+	before := product
+
+	if created {
+		product = models.Product{SKU: input.SKU, IsActive: true}
+	}
+	if err := im.applyFields(tx, c, input, &product, created); err != nil {
+		return false, nil, err
+	}
+
+	changed := created || productChanged(before, product)
+	if created {
+		err = tx.Create(&product).Error
+	} else if changed {
+		err = tx.Save(&product).Error
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	if input.Has(ColImageURLs) {
+		imagesChanged, err := im.syncImages(tx, &product, input.ImageURLs)
+		if err != nil {
+			return false, nil, err
+		}
+		changed = changed || imagesChanged
+	}
+	if input.Has(ColOptions) {
+		variantsChanged, err := syncVariants(tx, &product, input)
+		if err != nil {
+			return false, nil, err
+		}
+		changed = changed || variantsChanged
+	}
+	if input.Has(ColTags) {
+		tagsChanged, err := models.SetProductTags(tx, product.ID, input.Tags)
+		if err != nil {
+			return false, nil, err
+		}
+		changed = changed || tagsChanged
+	}
+	if len(input.Attributes) > 0 {
+		values := make(map[string]interface{}, len(input.Attributes))
+		for code, raw := range input.Attributes {
+			if raw == "" {
+				values[code] = nil
+			} else {
+				values[code] = raw
+			}
+		}
+		attributesChanged, err := models.SetProductAttributes(tx, product.ID, values)
+		if err != nil {
+			return false, nil, err
+		}
+		changed = changed || attributesChanged
+	}
+	if !changed {
+		return false, nil, nil
+	}
+
+	if created {
+		err = audit.RecordFor(tx, im.ActorID, "product.create", "product", product.ID, nil, product)
+	} else {
+		err = audit.RecordFor(tx, im.ActorID, "product.update", "product", product.ID, before, product)
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	if !created && product.Stock != before.Stock {
+		if err := events.Publish(tx, events.NewStockChanged(&product, before.Stock, events.StockReasonImport)); err != nil {
+			return false, nil, err
+		}
+	}
+	if !created && product.Price != before.Price {
+		if err := events.Publish(tx, events.NewPriceChanged(&product, before.Price)); err != nil {
+			return false, nil, err
+		}
+	}
+	var saved models.Product
+	if err := tx.Preload("Category").Preload("Images").Preload("Tags").First(&saved, product.ID).Error; err != nil {
+		return false, nil, err
+	}
+	if err := models.AttachDetails(tx, &saved); err != nil {
+		return false, nil, err
+	}
+	if created {
+		err = events.Publish(tx, events.ProductCreated{Product: saved.ToResponse()})
+	} else {
+		err = events.Publish(tx, events.ProductUpdated{Product: saved.ToResponse()})
+	}
+	return created, nil, err
+}
+
+// applyFields copies the columns the file provides onto product
+func (im *Importer) applyFields(tx *gorm.DB, c *checker, input *ProductInput, product *models.Product, created bool) error {
+	if input.Has(ColName) {
+		product.Name = input.Name
+	}
+	if input.Has(ColDescription) {
+		product.Description = input.Description
+	}
+	if input.Has(ColCategory) {
+		product.CategoryID = nil
+		if c.createsCategory(input) {
+			if err := im.createCategory(tx, c, input); err != nil {
+				return err
+			}
+		}
+		if input.CategorySlug != "" {
+			id := c.categories[input.CategorySlug]
+			product.CategoryID = &id
+		}
+	}
+	if input.Has(ColPrice) {
+		product.Price = input.Price
+	}
+	if input.Has(ColComparePrice) {
+		product.ComparePrice = input.ComparePrice
+	}
+	if input.Has(ColStock) {
+		product.Stock = input.Stock
+	}
+	if input.Has(ColMinStock) {
+		product.MinStock = input.MinStock
+	}
+	if input.Has(ColWeight) {
+		product.Weight = input.Weight
+	}
+	if input.Has(ColIsActive) {
+		product.IsActive = input.IsActive
+	}
+	if input.Has(ColIsFeatured) {
+		product.IsFeatured = input.IsFeatured
+	}
+
+	slug := ""
+	if input.Handle != "" {
+		slug = Slugify(input.Handle)
+	} else if created {
+		slug = Slugify(product.Name)
+	}
+	if slug == "" || slug == product.Slug {
+		return nil
+	}
+	unique, err := uniqueSlug(tx, slug, product.ID)
+	if err != nil {
+		return err
+	}
+	product.Slug = unique
+	return nil
+}
+
+// createCategory adds the category named by a product from another platform
+func (im *Importer) createCategory(tx *gorm.DB, c *checker, input *ProductInput) error {
+	// Imported categories are new roots, listed after the existing ones
+	position, err := models.NextCategoryPosition(tx, nil)
+	if err != nil {
+		return err
+	}
+	category := models.Category{Name: input.CategoryName, Slug: input.CategorySlug, IsActive: true, Position: position}
+	if err := tx.Create(&category).Error; err != nil {
+		return err
+	}
+	c.categories[category.Slug] = category.ID
+	return audit.RecordFor(tx, im.ActorID, "category.create", "category", category.ID, nil, category)
+}
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify turns a name into a URL slug, as the product handlers do
+func Slugify(name string) string {
+	return strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+func uniqueSlug(tx *gorm.DB, slug string, productID uuid.UUID) (string, error) {
+	candidate := slug
+	for counter := 1; ; counter++ {
+		var count int64
+		query := tx.Model(&models.Product{}).Where("slug = ?", candidate)
+		if productID != uuid.Nil {
+			query = query.Where("id <> ?", productID)
+		}
+		if err := query.Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", slug, counter)
+	}
+}
+
+func productChanged(before, after models.Product) bool {
+	before.UpdatedAt = after.UpdatedAt
+	return !reflect.DeepEqual(before, after)
+}
+
+// syncImages makes urls the product's images, in order. Images already
+// uploaded under the same URL are reused; others become media records that
+// point at the URL.
+func (im *Importer) syncImages(tx *gorm.DB, product *models.Product, urls []string) (bool, error) {
+	var current []models.Media
+	if err := tx.Where("product_id = ?", product.ID).Order("sort_order ASC").Find(&current).Error; err != nil {
+		return false, err
+	}
+	currentURLs := make([]string, len(current))
+	for i, media := range current {
+		currentURLs[i] = media.URL
+	}
+	if reflect.DeepEqual(currentURLs, urls) || (len(currentURLs) == 0 && len(urls) == 0) {
+		return false, nil
+	}
+
+	keep := map[uuid.UUID]bool{}
+	for i, url := range urls {
+		var media models.Media
+		err := tx.Where("url = ? AND (product_id = ? OR product_id IS NULL)", url, product.ID).
+			Order("product_id IS NULL ASC").Take(&media).Error
+		switch {
+		case err == nil:
+			err = tx.Model(&media).Updates(map[string]interface{}{"product_id": product.ID, "sort_order": i}).Error
+		case err == gorm.ErrRecordNotFound:
+			if im.ShopID == nil {
+				return false, ErrNoShop
+			}
+			media = newURLMedia(*im.ShopID, product, url, i)
+			err = tx.Create(&media).Error
+		}
+		if err != nil {
+			return false, err
+		}
+		keep[media.ID] = true
+	}
+
+	for _, media := range current {
+		if keep[media.ID] {
+			continue
+		}
+		err := tx.Model(&models.Media{}).Where("id = ?", media.ID).
+			Updates(map[string]interface{}{"product_id": nil, "sort_order": 0}).Error
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func newURLMedia(shopID uuid.UUID, product *models.Product, url string, position int) models.Media {
+	filename := path.Base(strings.SplitN(url, "?", 2)[0])
+	mimeType := mime.TypeByExtension(path.Ext(filename))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return models.Media{
+		ShopID:    shopID,
+		ProductID: &product.ID,
+		Filename:  filename,
+		URL:       url,
+		MimeType:  mimeType,
+		Alt:       product.Name,
+		SortOrder: position,
+	}
+}
+
+// syncVariants makes the input's variants the product's variants. Options are
+// rebuilt only when their names or values change; variants are matched by
+// SKU and those missing from the input are deleted.
+func syncVariants(tx *gorm.DB, product *models.Product, input *ProductInput) (bool, error) {
+	var options []models.ProductOption
+	if err := tx.Where("product_id = ?", product.ID).Order("position ASC").Preload("Values", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Find(&options).Error; err != nil {
+		return false, err
+	}
+	var variants []models.ProductVariant
+	if err := tx.Where("product_id = ?", product.ID).Preload("OptionValues").Find(&variants).Error; err != nil {
+		return false, err
+	}
+
+	changed := false
+	wanted := wantedOptions(input)
+	if !sameOptions(options, wanted) {
+		if err := deleteOptions(tx, product.ID); err != nil {
+			return false, err
+		}
+		var err error
+		if options, err = createOptions(tx, product.ID, wanted); err != nil {
+			return false, err
+		}
+		for i := range variants {
+			variants[i].OptionValues = nil
+		}
+		changed = true
+	}
+
+	// Option value IDs by option name and value
+	valueIDs := map[string]map[string]uuid.UUID{}
+	for _, option := range options {
+		valueIDs[option.Name] = map[string]uuid.UUID{}
+		for _, value := range option.Values {
+			valueIDs[option.Name][value.Value] = value.ID
+		}
+	}
+
+	bySKU := map[string]*models.ProductVariant{}
+	for i := range variants {
+		bySKU[variants[i].SKU] = &variants[i]
+	}
+
+	seen := map[string]bool{}
+	for i, in := range input.Variants {
+		seen[in.SKU] = true
+		variant, exists := bySKU[in.SKU]
+		if !exists {
+			variant = &models.ProductVariant{ProductID: product.ID, SKU: in.SKU}
+		}
+		before := *variant
+		variant.Price = in.Price
+		variant.ComparePrice = in.ComparePrice
+		variant.Stock = in.Stock
+		variant.Weight = in.Weight
+		variant.IsActive = in.IsActive
+		variant.IsDefault = i == 0
+
+		var err error
+		if !exists {
+			err = tx.Omit("OptionValues", "Images", "Product").Create(variant).Error
+			changed = true
+		} else if variantChanged(before, *variant) {
+			err = tx.Model(variant).Select("price", "compare_price", "stock", "weight", "is_active", "is_default").Updates(variant).Error
+			changed = true
+		}
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			if err := publishVariantChanges(tx, product, &before, variant); err != nil {
+				return false, err
+			}
+		}
+
+		var ids []uuid.UUID
+		for _, option := range in.Options {
+			ids = append(ids, valueIDs[option.Name][option.Value])
+		}
+		linked, err := linkOptionValues(tx, variant, ids)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || linked
+	}
+
+	var removed []uuid.UUID
+	for _, variant := range variants {
+		if !seen[variant.SKU] {
+			removed = append(removed, variant.ID)
+		}
+	}
+	if len(removed) > 0 {
+		if err := deleteVariants(tx, removed); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// wantedOption is an option with its values in order of first use
+type wantedOption struct {
+	name   string
+	values []string
+}
+
+func wantedOptions(input *ProductInput) []wantedOption {
+	var wanted []wantedOption
+	for _, name := range input.OptionNames() {
+		option := wantedOption{name: name}
+		seen := map[string]bool{}
+		for _, variant := range input.Variants {
+			for _, value := range variant.Options {
+				if value.Name == name && !seen[value.Value] {
+					seen[value.Value] = true
+					option.values = append(option.values, value.Value)
+				}
+			}
+		}
+		wanted = append(wanted, option)
+	}
+	return wanted
+}
+
+func sameOptions(current []models.ProductOption, wanted []wantedOption) bool {
+	if len(current) != len(wanted) {
+		return false
+	}
+	for i, option := range current {
+		if option.Name != wanted[i].name || len(option.Values) != len(wanted[i].values) {
+			return false
+		}
+		for j, value := range option.Values {
+			if value.Value != wanted[i].values[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func createOptions(tx *gorm.DB, productID uuid.UUID, wanted []wantedOption) ([]models.ProductOption, error) {
+	options := make([]models.ProductOption, len(wanted))
+	for i, w := range wanted {
+		options[i] = models.ProductOption{ProductID: productID, Name: w.name, Position: i}
+		if err := tx.Omit("Product", "Values").Create(&options[i]).Error; err != nil {
+			return nil, err
+		}
+		for j, value := range w.values {
+			optionValue := models.ProductOptionValue{OptionID: options[i].ID, Value: value, Position: j}
+			if err := tx.Omit("Option").Create(&optionValue).Error; err != nil {
+				return nil, err
+			}
+			options[i].Values = append(options[i].Values, optionValue)
+		}
+	}
+	return options, nil
+}
+
+func deleteOptions(tx *gorm.DB, productID uuid.UUID) error {
+	optionIDs := tx.Model(&models.ProductOption{}).Select("id").Where("product_id = ?", productID)
+	valueIDs := tx.Model(&models.ProductOptionValue{}).Select("id").Where("option_id IN (?)", optionIDs)
+	if err := tx.Where("option_value_id IN (?)", valueIDs).Delete(&models.ProductVariantOptionValue{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("option_id IN (?)", optionIDs).Delete(&models.ProductOptionValue{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("product_id = ?", productID).Delete(&models.ProductOption{}).Error
+}
+
+func deleteVariants(tx *gorm.DB, ids []uuid.UUID) error {
+	if err := tx.Where("variant_id IN ?", ids).Delete(&models.ProductVariantOptionValue{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM product_variant_images WHERE product_variant_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&models.ProductVariant{}).Error
+}
+
+// linkOptionValues points variant at exactly the given option values
+func linkOptionValues(tx *gorm.DB, variant *models.ProductVariant, valueIDs []uuid.UUID) (bool, error) {
+	current := map[uuid.UUID]bool{}
+	for _, link := range variant.OptionValues {
+		current[link.OptionValueID] = true
+	}
+	if len(current) == len(valueIDs) {
+		same := true
+		for _, id := range valueIDs {
+			if !current[id] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return false, nil
+		}
+	}
+
+	if err := tx.Where("variant_id = ?", variant.ID).Delete(&models.ProductVariantOptionValue{}).Error; err != nil {
+		return false, err
+	}
+	for _, id := range valueIDs {
+		link := models.ProductVariantOptionValue{VariantID: variant.ID, OptionValueID: id}
+		if err := tx.Omit("Variant", "OptionValue").Create(&link).Error; err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// publishVariantChanges publishes the stock and price changes of an
+// existing variant
+func publishVariantChanges(tx *gorm.DB, product *models.Product, before, after *models.ProductVariant) error {
+	if after.Stock != before.Stock {
+		if err := events.Publish(tx, events.NewVariantStockChanged(product, after, before.Stock, events.StockReasonImport)); err != nil {
+			return err
+		}
+	}
+	if previous := models.CurrentPrice(product, before); models.CurrentPrice(product, after) != previous {
+		return events.Publish(tx, events.NewVariantPriceChanged(product, after, previous))
+	}
+	return nil
+}
+
+func variantChanged(before, after models.ProductVariant) bool {
+	return !reflect.DeepEqual(before.Price, after.Price) ||
+		!reflect.DeepEqual(before.ComparePrice, after.ComparePrice) ||
+		before.Stock != after.Stock ||
+		!reflect.DeepEqual(before.Weight, after.Weight) ||
+		before.IsActive != after.IsActive ||
+		before.IsDefault != after.IsDefault
+}
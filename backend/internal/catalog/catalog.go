@@ -0,0 +1,163 @@
+// Package catalog imports and exports products as CSV.
+//
+// The native format has one row per product followed by one row per variant,
+// grouped by handle (the product slug):
+//
+//	handle,sku,name,description,category,price,compare_price,stock,min_stock,
+//	weight,is_active,is_featured,image_urls,tags,
+//	option1_name,option1_value,option2_name,option2_value,option3_name,option3_value,
+//	attr:<code>...
+//
+// The first row of a handle is the product and carries no option values; the
+// following rows of the same handle are its variants. Products and variants
+// are matched by SKU, so importing a file again updates instead of duplicating.
+// Prices are decimals ("12.50"), category is a category slug and image_urls
+// and tags are separated by "|". Each attr:<code> column holds the product's
+// own value of an attribute; a blank cell removes it.
+//
+// Columns missing from the header are left unchanged on existing products, so
+// a file with only sku and stock updates stock levels. Blank cells in present
+// columns clear optional fields and use the defaults for the rest.
+//
+// Product exports from Shopify (FormatShopify) and WooCommerce
+// (FormatWooCommerce) are read by adapters into the same ProductInput, so
+// they are validated and applied like native files. Their categories are
+// names rather than slugs and are created when missing.
+package catalog
+
+import (
+	"fmt"
+
+	"easycart/internal/models"
+)
+
+// FormatNative is this package's own format, also used by exports
+const FormatNative = "easycart"
+
+// Native columns
+const (
+	ColHandle       = "handle"
+	ColSKU          = "sku"
+	ColName         = "name"
+	ColDescription  = "description"
+	ColCategory     = "category"
+	ColPrice        = "price"
+	ColComparePrice = "compare_price"
+	ColStock        = "stock"
+	ColMinStock     = "min_stock"
+	ColWeight       = "weight"
+	ColIsActive     = "is_active"
+	ColIsFeatured   = "is_featured"
+	ColImageURLs    = "image_urls"
+	ColTags         = "tags"
+	ColOptions      = "options" // Stands for the optionN_name/optionN_value pairs
+)
+
+// maxOptions is how many option name/value column pairs a file may have
+const maxOptions = 3
+
+var nativeColumns = []string{
+	ColHandle, ColSKU, ColName, ColDescription, ColCategory, ColPrice, ColComparePrice,
+	ColStock, ColMinStock, ColWeight, ColIsActive, ColIsFeatured, ColImageURLs, ColTags,
+}
+
+func optionColumns(n int) (name, value string) {
+	return fmt.Sprintf("option%d_name", n), fmt.Sprintf("option%d_value", n)
+}
+
+// attributePrefix starts the columns of attribute values, e.g. attr:color
+const attributePrefix = "attr:"
+
+func attributeColumn(code string) string {
+	return attributePrefix + code
+}
+
+// ProductInput is one product read from a file, whatever its format
+type ProductInput struct {
+	Row          int // The row the product starts on
+	Handle       string
+	SKU          string
+	Name         string
+	Description  string
+	CategorySlug string
+	CategoryName string // Creates the category when no category has CategorySlug
+	Price        int
+	ComparePrice *int
+	Stock        int
+	MinStock     int
+	Weight       *float64
+	IsActive     bool
+	IsFeatured   bool
+	ImageURLs    []string
+	Tags         []string
+	Variants     []VariantInput
+
+	// Attributes are the raw cells of attribute columns by code; blank
+	// removes the product's own value
+	Attributes map[string]string
+
+	// Fields are the columns the file provides; others are left unchanged
+	// when the product already exists
+	Fields map[string]bool
+}
+
+// Has reports whether the file provides column
+func (p *ProductInput) Has(column string) bool {
+	return p.Fields[column]
+}
+
+// OptionNames are the option names used by the variants, in order
+func (p *ProductInput) OptionNames() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, variant := range p.Variants {
+		for _, option := range variant.Options {
+			if !seen[option.Name] {
+				seen[option.Name] = true
+				names = append(names, option.Name)
+			}
+		}
+	}
+	return names
+}
+
+type VariantInput struct {
+	Row          int
+	SKU          string
+	Options      []OptionValue
+	Price        *int // Nil uses the product price
+	ComparePrice *int
+	Stock        int
+	Weight       *float64
+	IsActive     bool
+}
+
+type OptionValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Batch is a parsed file. Products with errors are left out of Products.
+type Batch struct {
+	Format   string                  `json:"format"`
+	Rows     int                     `json:"rows"`
+	Products []*ProductInput         `json:"-"`
+	Errors   []models.ImportRowError `json:"errors"`
+	Skipped  int                     `json:"skipped"` // Products left out because of errors
+
+	// UnmappedColumns are header columns the format does not use
+	UnmappedColumns []string `json:"unmapped_columns"`
+}
+
+// Variants counts the variants of the batch's products
+func (b *Batch) Variants() int {
+	count := 0
+	for _, product := range b.Products {
+		count += len(product.Variants)
+	}
+	return count
+}
+
+func (b *Batch) addError(row int, sku, field, message string) {
+	b.Errors = append(b.Errors, models.ImportRowError{Row: row, SKU: sku, Field: field, Message: message})
+}
@@ -0,0 +1,288 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestParsePrice(t *testing.T) {
+	tests := map[string]int{"12.50": 1250, "12.5": 1250, "12": 1200, "$0.99": 99, ".5": 50}
+	for input, want := range tests {
+		got, err := ParsePrice(input)
+		if err != nil || got != want {
+			t.Errorf("ParsePrice(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "abc", "1.234", "-1.00", "1,50"} {
+		if _, err := ParsePrice(input); err == nil {
+			t.Errorf("ParsePrice(%q) should fail", input)
+		}
+	}
+	if FormatDecimal(1205) != "12.05" {
+		t.Errorf("FormatDecimal(1205) = %s", FormatDecimal(1205))
+	}
+}
+
+func TestParseNativeGroupsVariants(t *testing.T) {
+	file := "\ufeffHandle,SKU,Name,Price,Stock,Category,option1_name,option1_value,option2_name,option2_value,Vendor\n" +
+		"tee,TEE,T-Shirt,19.99,0,apparel,,,,,Acme\n" +
+		"tee,TEE-S-RED,,,5,,Size,S,Color,Red,\n" +
+		"tee,TEE-M-RED,,21.00,3,,,M,,Red,\n" +
+		"mug,MUG,Mug,9.50,12,,,,,,\n"
+
+	batch, err := Parse(FormatNative, strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Errors) != 0 {
+		t.Fatalf("unexpected errors %+v", batch.Errors)
+	}
+	if len(batch.Products) != 2 || batch.Variants() != 2 {
+		t.Fatalf("got %d products and %d variants, want 2 and 2", len(batch.Products), batch.Variants())
+	}
+	if len(batch.UnmappedColumns) != 1 || batch.UnmappedColumns[0] != "vendor" {
+		t.Errorf("unmapped columns = %v, want [vendor]", batch.UnmappedColumns)
+	}
+
+	tee := batch.Products[0]
+	if tee.Price != 1999 || tee.CategorySlug != "apparel" || !tee.IsActive {
+		t.Errorf("unexpected product %+v", tee)
+	}
+	if tee.Has(ColDescription) || !tee.Has(ColOptions) {
+		t.Error("fields should follow the header")
+	}
+	medium := tee.Variants[1]
+	if medium.Options[0].Name != "Size" || medium.Options[1].Name != "Color" {
+		t.Errorf("blank option names should be taken from the first variant, got %+v", medium.Options)
+	}
+	if medium.Price == nil || *medium.Price != 2100 || tee.Variants[0].Price != nil {
+		t.Error("variant prices should be optional")
+	}
+	if names := tee.OptionNames(); len(names) != 2 || names[0] != "Size" {
+		t.Errorf("OptionNames() = %v", names)
+	}
+}
+
+func TestParseNativeRowErrors(t *testing.T) {
+	file := "handle,sku,name,price,stock,option1_name,option1_value\n" +
+		"a,A,Alpha,abc,1,,\n" + // Bad price
+		"a,A-1,,,1,Size,S\n" + // Variant of a failed product
+		"b,B,Beta,5.00,-2,,\n" + // Bad stock
+		"c,C,Gamma,5.00,1,,\n" +
+		"c,C-1,,,1,,\n" + // Variant without options
+		"d,A,Delta,5.00,1,,\n" + // Duplicate SKU
+		",E,Epsilon,5.00,1,Size,S\n" + // Options without a handle
+		"f,F,Zeta,5.00,1,,\n"
+
+	batch, err := Parse(FormatNative, strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Products) != 1 || batch.Products[0].SKU != "F" {
+		t.Fatalf("only F should be valid, got %d products", len(batch.Products))
+	}
+	if batch.Skipped != 5 {
+		t.Errorf("Skipped = %d, want 5", batch.Skipped)
+	}
+
+	rows := map[int]string{}
+	for _, err := range batch.Errors {
+		rows[err.Row] = err.Field
+	}
+	want := map[int]string{2: ColPrice, 4: ColStock, 6: ColOptions, 7: ColSKU, 8: ColHandle}
+	for row, field := range want {
+		if rows[row] != field {
+			t.Errorf("row %d: got error on %q, want %q", row, rows[row], field)
+		}
+	}
+}
+
+func TestParseRejectsUnreadableFiles(t *testing.T) {
+	if _, err := Parse(FormatNative, strings.NewReader("")); err != ErrEmptyFile {
+		t.Errorf("expected ErrEmptyFile, got %v", err)
+	}
+	if _, err := Parse(FormatNative, strings.NewReader("name,price\nA,1\n")); err == nil {
+		t.Error("a file without a sku column should be rejected")
+	}
+	if _, err := Parse("magento", strings.NewReader("sku\nA\n")); err != ErrUnknownFormat {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestExportRowsRoundTrip(t *testing.T) {
+	compare := 2500
+	weight := 180.5
+	optionID := uuid.New()
+	product := models.Product{
+		Slug: "tee", SKU: "TEE", Name: "T-Shirt", Description: "Soft, \"organic\" cotton",
+		Price: 1999, ComparePrice: &compare, Stock: 8, MinStock: 2, Weight: &weight, IsActive: true,
+		Category: &models.Category{Slug: "apparel"},
+		Images:   []*models.Media{{URL: "https://cdn.example.com/a.jpg"}, {URL: "https://cdn.example.com/b.jpg"}},
+		Options:  []models.ProductOption{{ID: optionID, Name: "Size"}},
+		Variants: []models.ProductVariant{{
+			SKU: "TEE-S", Stock: 3, IsActive: true,
+			OptionValues: []models.ProductVariantOptionValue{{OptionValue: models.ProductOptionValue{OptionID: optionID, Value: "S"}}},
+		}},
+		Tags: []models.ProductTag{{Tag: "summer"}, {Tag: "organic"}},
+	}
+	material := "Cotton"
+	attributes := []models.AttributeDefinition{
+		{ID: uuid.New(), Code: "material", Type: models.AttributeText},
+		{ID: uuid.New(), Code: "fit", Type: models.AttributeEnum, Options: []string{"Slim", "Regular"}},
+	}
+	values := map[uuid.UUID]models.AttributeValue{attributes[0].ID: {Text: &material}}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(ExportHeader(attributes))
+	writer.WriteAll(exportRows(&product, attributes, values))
+
+	batch, err := Parse(FormatNative, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Errors) != 0 || len(batch.Products) != 1 {
+		t.Fatalf("export should import cleanly, got %+v", batch.Errors)
+	}
+	p := batch.Products[0]
+	if p.Name != product.Name || p.Description != product.Description || p.Price != 1999 ||
+		*p.ComparePrice != 2500 || *p.Weight != 180.5 || p.MinStock != 2 || p.CategorySlug != "apparel" {
+		t.Errorf("product did not round-trip: %+v", p)
+	}
+	if len(p.ImageURLs) != 2 || p.ImageURLs[1] != "https://cdn.example.com/b.jpg" {
+		t.Errorf("images did not round-trip: %v", p.ImageURLs)
+	}
+	if len(p.Variants) != 1 || p.Variants[0].SKU != "TEE-S" || p.Variants[0].Options[0] != (OptionValue{Name: "Size", Value: "S"}) {
+		t.Errorf("variants did not round-trip: %+v", p.Variants)
+	}
+	if strings.Join(p.Tags, ",") != "summer,organic" {
+		t.Errorf("tags did not round-trip: %v", p.Tags)
+	}
+	// A blank cell removes the value, so attributes without one are cleared
+	if len(p.Attributes) != 2 || p.Attributes["material"] != "Cotton" || p.Attributes["fit"] != "" {
+		t.Errorf("attributes did not round-trip: %v", p.Attributes)
+	}
+}
+
+func TestWantedOptions(t *testing.T) {
+	input := &ProductInput{Variants: []VariantInput{
+		{Options: []OptionValue{{"Size", "M"}, {"Color", "Red"}}},
+		{Options: []OptionValue{{"Size", "S"}, {"Color", "Red"}}},
+		{Options: []OptionValue{{"Size", "M"}, {"Color", "Blue"}}},
+	}}
+	wanted := wantedOptions(input)
+	if len(wanted) != 2 || strings.Join(wanted[0].values, ",") != "M,S" || strings.Join(wanted[1].values, ",") != "Red,Blue" {
+		t.Errorf("unexpected options %+v", wanted)
+	}
+
+	current := []models.ProductOption{
+		{Name: "Size", Values: []models.ProductOptionValue{{Value: "M"}, {Value: "S"}}},
+		{Name: "Color", Values: []models.ProductOptionValue{{Value: "Red"}, {Value: "Blue"}}},
+	}
+	if !sameOptions(current, wanted) {
+		t.Error("identical options should not be rebuilt")
+	}
+	current[1].Values[1].Value = "Green"
+	if sameOptions(current, wanted) {
+		t.Error("changed values should be rebuilt")
+	}
+}
+
+func TestParseShopify(t *testing.T) {
+	file := "Handle,Title,Body (HTML),Vendor,Type,Tags,Published,Option1 Name,Option1 Value,Option2 Name,Option2 Value,Variant SKU,Variant Grams,Variant Inventory Qty,Variant Price,Variant Compare At Price,Image Src,Image Position,Status\n" +
+		"classic-tee,Classic Tee,<p>Soft</p>,Acme,Shirts,summer,true,Size,S,Color,Red,TEE-S-RED,200,4,20.00,25.00,https://cdn.shopify.com/tee-1.jpg,1,active\n" +
+		"classic-tee,,,,,,,,M,,Red,,210,-3,22.00,,https://cdn.shopify.com/tee-2.jpg,2,\n" +
+		"classic-tee,,,,,,,,,,,,,,,,https://cdn.shopify.com/tee-3.jpg,3,\n" +
+		"gift-card,Gift Card,,Acme,,,true,Title,Default Title,,,GIFT,0,100,50.00,,,,draft\n"
+
+	batch, err := Parse(FormatShopify, strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Errors) != 0 || len(batch.Products) != 2 {
+		t.Fatalf("got %d products and errors %+v", len(batch.Products), batch.Errors)
+	}
+	if strings.Join(batch.UnmappedColumns, ",") != "vendor,image position" {
+		t.Errorf("unmapped columns = %v", batch.UnmappedColumns)
+	}
+
+	tee := batch.Products[0]
+	if tee.SKU != "classic-tee" || tee.Name != "Classic Tee" || tee.CategoryName != "Shirts" || tee.CategorySlug != "shirts" {
+		t.Errorf("unexpected product %+v", tee)
+	}
+	if tee.Price != 2000 || *tee.ComparePrice != 2500 || tee.Stock != 4 || !tee.IsActive {
+		t.Errorf("price and stock should come from the variants, got %+v", tee)
+	}
+	if len(tee.Tags) != 1 || tee.Tags[0] != "summer" {
+		t.Errorf("tags = %v", tee.Tags)
+	}
+	if len(tee.ImageURLs) != 3 {
+		t.Errorf("images = %v", tee.ImageURLs)
+	}
+	if len(tee.Variants) != 2 {
+		t.Fatalf("got %d variants", len(tee.Variants))
+	}
+	medium := tee.Variants[1]
+	if medium.SKU != "classic-tee-m-red" || medium.Options[0] != (OptionValue{"Size", "M"}) || medium.Options[1] != (OptionValue{"Color", "Red"}) {
+		t.Errorf("unexpected variant %+v", medium)
+	}
+	if medium.Price == nil || *medium.Price != 2200 || tee.Variants[0].Price != nil || medium.Stock != 0 {
+		t.Errorf("unexpected variant prices or stock %+v", medium)
+	}
+
+	card := batch.Products[1]
+	if card.SKU != "GIFT" || len(card.Variants) != 0 || card.Price != 5000 || card.Stock != 100 || card.IsActive {
+		t.Errorf("a default variant should become the product, got %+v", card)
+	}
+	if !card.Has(ColCategory) || card.CategorySlug != "" {
+		t.Error("a blank type should clear the category")
+	}
+}
+
+func TestParseWooCommerce(t *testing.T) {
+	file := "ID,Type,SKU,Name,Published,Is featured?,Description,Stock,Low stock amount,Sale price,Regular price,Categories,Images,Parent,Weight (kg),Tax status,Attribute 1 name,Attribute 1 value(s),Attribute 1 visible\n" +
+		"10,\"simple, virtual\",MUG,Mug,1,1,Big mug,7,2,8.00,10.00,\"Kitchen > Mugs, Sale\",\"https://shop.example/a.jpg, https://shop.example/b.jpg\",,0.35,taxable,,,\n" +
+		"11,variable,,Hoodie,1,0,,,,,,Clothing > Hoodies,,,,taxable,Size,\"S, M\",1\n" +
+		"12,variation,HOOD-S,Hoodie - S,1,0,,3,,,40.00,,,id:11,0.5,taxable,Size,S,\n" +
+		"13,variation,,Hoodie - M,1,0,,5,,,45.00,,,id:11,0.5,taxable,Size,M,\n" +
+		"14,simple,TOTE,Tote,-1,0,,,,,15.00,,,,,taxable,,,\n" +
+		"15,grouped,SET,Set,1,0,,,,,,,,,,taxable,,,\n" +
+		"16,variation,ORPHAN,Orphan,1,0,,1,,,5.00,,,id:99,,taxable,Size,L,\n"
+
+	batch, err := Parse(FormatWooCommerce, strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Products) != 3 || batch.Skipped != 2 || len(batch.Errors) != 2 {
+		t.Fatalf("got %d products, %d skipped and errors %+v", len(batch.Products), batch.Skipped, batch.Errors)
+	}
+	if strings.Join(batch.UnmappedColumns, ",") != "tax status,attribute 1 visible" {
+		t.Errorf("unmapped columns = %v", batch.UnmappedColumns)
+	}
+
+	mug := batch.Products[0]
+	if mug.Price != 800 || *mug.ComparePrice != 1000 || mug.Stock != 7 || mug.MinStock != 2 || *mug.Weight != 350 {
+		t.Errorf("unexpected simple product %+v", mug)
+	}
+	if mug.CategoryName != "Mugs" || !mug.IsFeatured || len(mug.ImageURLs) != 2 {
+		t.Errorf("unexpected simple product %+v", mug)
+	}
+
+	hoodie := batch.Products[1]
+	if hoodie.SKU != "woo-11" || hoodie.Price != 4000 || hoodie.Stock != 8 || len(hoodie.Variants) != 2 {
+		t.Fatalf("unexpected variable product %+v", hoodie)
+	}
+	if hoodie.Variants[1].SKU != "woo-11-m" || *hoodie.Variants[1].Price != 4500 || hoodie.Variants[0].Price != nil {
+		t.Errorf("unexpected variations %+v", hoodie.Variants)
+	}
+
+	tote := batch.Products[2]
+	if tote.IsActive || tote.Has(ColStock) || !mug.Has(ColStock) {
+		t.Errorf("drafts are inactive and untracked stock is left alone, got %+v", tote)
+	}
+}
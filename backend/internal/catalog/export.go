@@ -0,0 +1,157 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is how many products are loaded at a time
+const exportBatchSize = 200
+
+// ExportHeader is the header row of exported files, ending with a column
+// for each attribute
+func ExportHeader(attributes []models.AttributeDefinition) []string {
+	header := append([]string{}, nativeColumns...)
+	for n := 1; n <= maxOptions; n++ {
+		name, value := optionColumns(n)
+		header = append(header, name, value)
+	}
+	for _, attribute := range attributes {
+		header = append(header, attributeColumn(attribute.Code))
+	}
+	return header
+}
+
+// Export writes every product in the native format, oldest first, so the
+// file can be edited and imported again
+func Export(db *gorm.DB, w io.Writer) error {
+	var attributes []models.AttributeDefinition
+	if err := db.Order("position ASC, code ASC").Find(&attributes).Error; err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(ExportHeader(attributes)); err != nil {
+		return err
+	}
+
+	var products []models.Product
+	result := db.
+		Preload("Category").
+		Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("sort_order ASC") }).
+		Preload("Options", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("is_default DESC, created_at ASC") }).
+		Preload("Variants.OptionValues.OptionValue").
+		Preload("Tags").
+		Order("created_at ASC, id ASC").
+		FindInBatches(&products, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			// Only the products' own values; category values stay inherited
+			ids := make([]uuid.UUID, len(products))
+			for i := range products {
+				ids[i] = products[i].ID
+			}
+			var values []models.AttributeValue
+			if err := db.Where("product_id IN ?", ids).Find(&values).Error; err != nil {
+				return err
+			}
+			own := map[uuid.UUID]map[uuid.UUID]models.AttributeValue{}
+			for _, value := range values {
+				if own[*value.ProductID] == nil {
+					own[*value.ProductID] = map[uuid.UUID]models.AttributeValue{}
+				}
+				own[*value.ProductID][value.AttributeID] = value
+			}
+
+			for i := range products {
+				for _, row := range exportRows(&products[i], attributes, own[products[i].ID]) {
+					if err := writer.Write(row); err != nil {
+						return err
+					}
+				}
+			}
+			writer.Flush()
+			return writer.Error()
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportRows returns the product row followed by a row per variant. values
+// are the product's own attribute values by attribute ID.
+func exportRows(p *models.Product, attributes []models.AttributeDefinition, values map[uuid.UUID]models.AttributeValue) [][]string {
+	width := len(nativeColumns) + 2*maxOptions + len(attributes)
+	category := ""
+	if p.Category != nil {
+		category = p.Category.Slug
+	}
+	urls := make([]string, len(p.Images))
+	for i, image := range p.Images {
+		urls[i] = image.URL
+	}
+
+	row := []string{
+		p.Slug, p.SKU, p.Name, p.Description, category,
+		FormatDecimal(p.Price), formatOptionalPrice(p.ComparePrice),
+		strconv.Itoa(p.Stock), strconv.Itoa(p.MinStock), formatWeight(p.Weight),
+		strconv.FormatBool(p.IsActive), strconv.FormatBool(p.IsFeatured), strings.Join(urls, "|"),
+		strings.Join(models.TagNames(p.Tags), "|"),
+	}
+	row = padRow(row, len(nativeColumns)+2*maxOptions)
+	for i := range attributes {
+		row = append(row, attributes[i].Text(values[attributes[i].ID]))
+	}
+	rows := [][]string{row}
+
+	for _, variant := range p.Variants {
+		row := []string{
+			p.Slug, variant.SKU, "", "", "",
+			formatOptionalPrice(variant.Price), formatOptionalPrice(variant.ComparePrice),
+			strconv.Itoa(variant.Stock), "", formatWeight(variant.Weight),
+			strconv.FormatBool(variant.IsActive), "", "", "",
+		}
+		// Option values in the order of the product's options
+		values := map[string]string{}
+		for _, link := range variant.OptionValues {
+			values[link.OptionValue.OptionID.String()] = link.OptionValue.Value
+		}
+		for i, option := range p.Options {
+			if i == maxOptions {
+				break
+			}
+			row = append(row, option.Name, values[option.ID.String()])
+		}
+		rows = append(rows, padRow(row, width))
+	}
+	return rows
+}
+
+func padRow(row []string, width int) []string {
+	for len(row) < width {
+		row = append(row, "")
+	}
+	return row
+}
+
+func formatOptionalPrice(cents *int) string {
+	if cents == nil {
+		return ""
+	}
+	return FormatDecimal(*cents)
+}
+
+func formatWeight(weight *float64) string {
+	if weight == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*weight, 'f', -1, 64)
+}
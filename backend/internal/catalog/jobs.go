@@ -0,0 +1,175 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"easycart/internal/jobs"
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// JobImport processes a queued ProductImport
+const JobImport = "catalog.import"
+
+const (
+	// importSlice is how long one job run imports before handing over to a
+	// new job, keeping each run well inside the worker's job timeout
+	importSlice = 3 * time.Minute
+
+	// maxStoredErrors caps the row errors kept on an import
+	maxStoredErrors = 1000
+)
+
+type importPayload struct {
+	ImportID uuid.UUID `json:"import_id"`
+}
+
+// QueueImport schedules imp to be processed by the jobs worker
+func QueueImport(tx *gorm.DB, imp *models.ProductImport) error {
+	_, err := jobs.Enqueue(tx, JobImport, importPayload{ImportID: imp.ID})
+	return err
+}
+
+// RegisterJobs adds the import job handler to w
+func RegisterJobs(w *jobs.Worker, db *gorm.DB) {
+	w.Register(JobImport, jobs.Handle(func(ctx context.Context, payload importPayload) error {
+		return runImport(ctx, db.WithContext(ctx), payload.ImportID, importSlice)
+	}))
+}
+
+// RunImport processes a pending import to the end without the jobs worker,
+// for imports started from the command line
+func RunImport(ctx context.Context, db *gorm.DB, importID uuid.UUID) error {
+	return runImport(ctx, db.WithContext(ctx), importID, 0)
+}
+
+// rowErrorsFound rolls back a product that failed validation
+type rowErrorsFound []models.ImportRowError
+
+func (rowErrorsFound) Error() string { return "row errors" }
+
+// runImport continues imp from the last processed product. Each product is
+// written in its own transaction together with the import's progress, so a
+// retried or resumed run never applies a product twice. After slice, when
+// set, the rest is queued as a new job.
+func runImport(ctx context.Context, db *gorm.DB, importID uuid.UUID, slice time.Duration) error {
+	var imp models.ProductImport
+	if err := db.First(&imp, "id = ?", importID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+	if imp.Status == models.ProductImportCompleted || imp.Status == models.ProductImportFailed {
+		return nil
+	}
+
+	batch, err := Parse(imp.Format, strings.NewReader(imp.Content))
+	if err != nil {
+		return finishImport(db, &imp, err)
+	}
+
+	if imp.Status == models.ProductImportPending {
+		now := time.Now()
+		imp.Status = models.ProductImportRunning
+		imp.StartedAt = &now
+		imp.TotalProducts = len(batch.Products)
+		imp.Failed = batch.Skipped
+		imp.Errors = nil
+		addErrors(&imp, batch.Errors)
+		if err := db.Model(&imp).Select("status", "started_at", "total_products", "failed", "errors").Updates(&imp).Error; err != nil {
+			return err
+		}
+	}
+
+	importer, err := NewImporter(db, imp.CreatedByID)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(slice)
+	for imp.Processed < len(batch.Products) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if slice > 0 && time.Now().After(deadline) {
+			// Carry on in a fresh job rather than run into the job timeout
+			return QueueImport(db, &imp)
+		}
+
+		input := batch.Products[imp.Processed]
+		next := imp
+		next.Processed++
+		applied := next
+		err := db.Transaction(func(tx *gorm.DB) error {
+			created, rowErrs, err := importer.Apply(tx, input)
+			if err != nil {
+				return err
+			}
+			if len(rowErrs) > 0 {
+				return rowErrorsFound(rowErrs)
+			}
+			if created {
+				applied.Created++
+			} else {
+				applied.Updated++
+			}
+			return saveProgress(tx, &applied)
+		})
+
+		var found rowErrorsFound
+		switch {
+		case err == nil:
+			next = applied
+		case errors.As(err, &found):
+			next.Failed++
+			addErrors(&next, found)
+			if err := saveProgress(db, &next); err != nil {
+				return err
+			}
+		case ctx.Err() != nil:
+			return ctx.Err()
+		default:
+			next.Failed++
+			addErrors(&next, []models.ImportRowError{{Row: input.Row, SKU: input.SKU, Message: "failed to import: " + err.Error()}})
+			if err := saveProgress(db, &next); err != nil {
+				return err
+			}
+		}
+		imp = next
+	}
+
+	return finishImport(db, &imp, nil)
+}
+
+func saveProgress(tx *gorm.DB, imp *models.ProductImport) error {
+	return tx.Model(imp).Select("processed", "created", "updated", "failed", "errors").Updates(imp).Error
+}
+
+func addErrors(imp *models.ProductImport, errs []models.ImportRowError) {
+	// Copy so the slice is never shared with the previous progress
+	all := append([]models.ImportRowError{}, imp.Errors...)
+	for _, err := range errs {
+		if len(all) >= maxStoredErrors {
+			break
+		}
+		all = append(all, err)
+	}
+	imp.Errors = all
+}
+
+// finishImport marks imp completed, or failed when the file could not be read
+func finishImport(db *gorm.DB, imp *models.ProductImport, fatal error) error {
+	now := time.Now()
+	imp.Status = models.ProductImportCompleted
+	if fatal != nil {
+		imp.Status = models.ProductImportFailed
+		imp.Error = fatal.Error()
+	}
+	imp.FinishedAt = &now
+	return db.Model(imp).Select("status", "error", "finished_at").Updates(imp).Error
+}
@@ -0,0 +1,523 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxFileSize caps uploaded files; split larger catalogs
+	MaxFileSize = 20 << 20
+	maxRows     = 50000
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown import format")
+	ErrEmptyFile     = errors.New("the file has no header row")
+	ErrTooManyRows   = fmt.Errorf("files are limited to %d rows", maxRows)
+)
+
+// Parse reads a catalog file in the given format. Problems with single rows
+// are reported in the batch; the error is for files that cannot be read.
+func Parse(format string, r io.Reader) (*Batch, error) {
+	t, err := readTable(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "", FormatNative:
+		return parseNative(t)
+	case FormatShopify:
+		return parseShopify(t)
+	case FormatWooCommerce:
+		return parseWooCommerce(t)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// table is a CSV file with its header mapped to column positions
+type table struct {
+	columns []string
+	index   map[string]int
+	rows    [][]string
+}
+
+func readTable(r io.Reader) (*table, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrEmptyFile
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	t := &table{index: map[string]int{}}
+	for i, column := range header {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff") // Spreadsheet apps like to add a BOM
+		}
+		column = strings.ToLower(strings.TrimSpace(column))
+		t.columns = append(t.columns, column)
+		if _, ok := t.index[column]; !ok {
+			t.index[column] = i
+		}
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if len(t.rows) >= maxRows {
+			return nil, ErrTooManyRows
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t, nil
+}
+
+func (t *table) has(column string) bool {
+	_, ok := t.index[column]
+	return ok
+}
+
+func (t *table) get(row []string, column string) string {
+	i, ok := t.index[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// attributeCodes lists the codes of the attribute columns
+func (t *table) attributeCodes() []string {
+	var codes []string
+	for _, column := range t.columns {
+		if code := strings.TrimPrefix(column, attributePrefix); code != column && code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// unmapped lists the columns not in known
+func (t *table) unmapped(known map[string]bool) []string {
+	columns := []string{}
+	for _, column := range t.columns {
+		if column != "" && !known[column] {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+func blank(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// rowNumber is the spreadsheet row of the i-th data row
+func rowNumber(i int) int {
+	return i + 2
+}
+
+// ParsePrice reads a decimal amount such as "12.50" into cents
+func ParsePrice(value string) (int, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "$")
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" && fraction == "" {
+		return 0, errors.New("invalid price")
+	}
+	if len(fraction) > 2 {
+		return 0, errors.New("prices have at most two decimals")
+	}
+	for len(fraction) < 2 {
+		fraction += "0"
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	units, err := strconv.Atoi(whole)
+	if err != nil || units < 0 {
+		return 0, errors.New("invalid price")
+	}
+	cents, err := strconv.Atoi(fraction)
+	if err != nil || cents < 0 {
+		return 0, errors.New("invalid price")
+	}
+	return units*100 + cents, nil
+}
+
+// FormatDecimal writes cents as a decimal amount, the inverse of ParsePrice
+func FormatDecimal(cents int) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
+
+func parseBool(value string, fallback bool) (bool, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return fallback, nil
+	case "true", "yes", "y", "1":
+		return true, nil
+	case "false", "no", "n", "0":
+		return false, nil
+	}
+	return false, errors.New("must be true or false")
+}
+
+func parseCount(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errors.New("must be a whole number of at least 0")
+	}
+	return n, nil
+}
+
+func parseWeight(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	weight, err := strconv.ParseFloat(value, 64)
+	if err != nil || weight < 0 {
+		return nil, errors.New("must be a number of grams")
+	}
+	return &weight, nil
+}
+
+func parseOptionalPrice(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	price, err := ParsePrice(value)
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// parseTags splits a cell of tags
+func parseTags(value, separator string) []string {
+	var tags []string
+	for _, part := range strings.Split(value, separator) {
+		if part = strings.TrimSpace(part); part != "" {
+			tags = append(tags, part)
+		}
+	}
+	return tags
+}
+
+func parseImageURLs(value string) ([]string, error) {
+	var urls []string
+	for _, part := range strings.Split(value, "|") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		u, err := url.Parse(part)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%q is not an http(s) URL", part)
+		}
+		urls = append(urls, part)
+	}
+	return urls, nil
+}
+
+// group collects the rows of one product while a file is parsed
+type group struct {
+	product *ProductInput
+	failed  bool
+}
+
+// groups keeps products in file order and fails those with bad rows
+type groups struct {
+	batch    *Batch
+	order    []*group
+	byHandle map[string]*group
+	skus     map[string]int // SKU to the row that used it first
+}
+
+func newGroups(batch *Batch) *groups {
+	return &groups{batch: batch, byHandle: map[string]*group{}, skus: map[string]int{}}
+}
+
+func (g *groups) add(handle string, product *ProductInput) *group {
+	grp := &group{product: product}
+	g.order = append(g.order, grp)
+	if handle != "" {
+		g.byHandle[handle] = grp
+	}
+	return grp
+}
+
+// claimSKU reports a SKU used twice in the file
+func (g *groups) claimSKU(row int, sku string) bool {
+	key := strings.ToLower(sku)
+	if first, ok := g.skus[key]; ok {
+		g.batch.addError(row, sku, ColSKU, fmt.Sprintf("SKU is already used on row %d", first))
+		return false
+	}
+	g.skus[key] = row
+	return true
+}
+
+// finish moves the products without errors into the batch
+func (g *groups) finish() {
+	for _, grp := range g.order {
+		if grp.failed {
+			g.batch.Skipped++
+		} else {
+			g.batch.Products = append(g.batch.Products, grp.product)
+		}
+	}
+}
+
+// rowErrors collects the field errors of one row
+type rowErrors struct {
+	batch *Batch
+	row   int
+	sku   string
+	count int
+}
+
+func (e *rowErrors) add(field string, err error) {
+	if err == nil {
+		return
+	}
+	e.batch.addError(e.row, e.sku, field, err.Error())
+	e.count++
+}
+
+func parseNative(t *table) (*Batch, error) {
+	if !t.has(ColSKU) {
+		return nil, errors.New("the file needs a sku column")
+	}
+
+	known := map[string]bool{}
+	for _, column := range nativeColumns {
+		known[column] = true
+	}
+	fields := map[string]bool{}
+	for _, column := range nativeColumns {
+		if t.has(column) {
+			fields[column] = true
+		}
+	}
+	for n := 1; n <= maxOptions; n++ {
+		name, value := optionColumns(n)
+		known[name], known[value] = true, true
+		if t.has(name) || t.has(value) {
+			fields[ColOptions] = true
+		}
+	}
+	for _, code := range t.attributeCodes() {
+		known[attributeColumn(code)] = true
+		fields[attributeColumn(code)] = true
+	}
+
+	batch := &Batch{Format: FormatNative, Rows: len(t.rows), UnmappedColumns: t.unmapped(known)}
+	groups := newGroups(batch)
+
+	for i, row := range t.rows {
+		if blank(row) {
+			continue
+		}
+		rowNum := rowNumber(i)
+		handle := strings.ToLower(t.get(row, ColHandle))
+		sku := t.get(row, ColSKU)
+		errs := &rowErrors{batch: batch, row: rowNum, sku: sku}
+
+		var options []OptionValue
+		for n := 1; n <= maxOptions; n++ {
+			nameCol, valueCol := optionColumns(n)
+			name, value := t.get(row, nameCol), t.get(row, valueCol)
+			if value == "" {
+				continue
+			}
+			options = append(options, OptionValue{Name: name, Value: value})
+		}
+
+		if sku == "" {
+			errs.add(ColSKU, errors.New("SKU is required"))
+		} else if !groups.claimSKU(rowNum, sku) {
+			errs.count++
+		}
+
+		grp, isVariant := groups.byHandle[handle]
+		isVariant = isVariant && handle != ""
+		if !isVariant {
+			product := &ProductInput{Row: rowNum, Handle: handle, SKU: sku, Fields: fields}
+			grp = groups.add(handle, product)
+			if len(options) > 0 {
+				if handle == "" {
+					errs.add(ColHandle, errors.New("variant rows need the handle of their product"))
+				} else {
+					errs.add(ColOptions, errors.New("the first row of a handle is the product and cannot have option values"))
+				}
+			}
+			parseNativeProduct(t, row, product, errs)
+			if errs.count > 0 {
+				grp.failed = true
+			}
+			continue
+		}
+
+		// A variant of the product started by an earlier row
+		if len(options) == 0 {
+			errs.add(ColOptions, errors.New("variant rows need at least one option value"))
+		}
+		variant := VariantInput{Row: rowNum, SKU: sku, Options: options}
+		if len(grp.product.Variants) > 0 {
+			// Option names may be left blank after the first variant
+			first := grp.product.Variants[0].Options
+			for j := range variant.Options {
+				if variant.Options[j].Name == "" && j < len(first) {
+					variant.Options[j].Name = first[j].Name
+				}
+			}
+		}
+		for _, option := range variant.Options {
+			if option.Name == "" {
+				errs.add(ColOptions, fmt.Errorf("option value %q has no option name", option.Value))
+			}
+		}
+		var err error
+		variant.Price, err = parseOptionalPrice(t.get(row, ColPrice))
+		errs.add(ColPrice, err)
+		variant.ComparePrice, err = parseOptionalPrice(t.get(row, ColComparePrice))
+		errs.add(ColComparePrice, err)
+		variant.Stock, err = parseCount(t.get(row, ColStock))
+		errs.add(ColStock, err)
+		variant.Weight, err = parseWeight(t.get(row, ColWeight))
+		errs.add(ColWeight, err)
+		variant.IsActive, err = parseBool(t.get(row, ColIsActive), true)
+		errs.add(ColIsActive, err)
+		if urls, err := parseImageURLs(t.get(row, ColImageURLs)); err != nil {
+			errs.add(ColImageURLs, err)
+		} else {
+			grp.product.ImageURLs = appendMissing(grp.product.ImageURLs, urls...)
+		}
+
+		grp.product.Variants = append(grp.product.Variants, variant)
+		if errs.count > 0 {
+			grp.failed = true
+		}
+	}
+
+	groups.finish()
+	return batch, nil
+}
+
+func parseNativeProduct(t *table, row []string, p *ProductInput, errs *rowErrors) {
+	var err error
+	p.Name = t.get(row, ColName)
+	if p.Has(ColName) && p.Name == "" {
+		errs.add(ColName, errors.New("name is required"))
+	}
+	p.Description = t.get(row, ColDescription)
+	p.CategorySlug = strings.ToLower(t.get(row, ColCategory))
+
+	if p.Has(ColPrice) {
+		p.Price, err = ParsePrice(t.get(row, ColPrice))
+		if t.get(row, ColPrice) == "" {
+			err = errors.New("price is required")
+		}
+		errs.add(ColPrice, err)
+	}
+	p.ComparePrice, err = parseOptionalPrice(t.get(row, ColComparePrice))
+	errs.add(ColComparePrice, err)
+	p.Stock, err = parseCount(t.get(row, ColStock))
+	errs.add(ColStock, err)
+	p.MinStock, err = parseCount(t.get(row, ColMinStock))
+	errs.add(ColMinStock, err)
+	p.Weight, err = parseWeight(t.get(row, ColWeight))
+	errs.add(ColWeight, err)
+	p.IsActive, err = parseBool(t.get(row, ColIsActive), true)
+	errs.add(ColIsActive, err)
+	p.IsFeatured, err = parseBool(t.get(row, ColIsFeatured), false)
+	errs.add(ColIsFeatured, err)
+	p.ImageURLs, err = parseImageURLs(t.get(row, ColImageURLs))
+	errs.add(ColImageURLs, err)
+	p.Tags = parseTags(t.get(row, ColTags), "|")
+
+	for _, code := range t.attributeCodes() {
+		if p.Attributes == nil {
+			p.Attributes = map[string]string{}
+		}
+		p.Attributes[code] = t.get(row, attributeColumn(code))
+	}
+}
+
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// settleVariants sets the price and stock of a product read from a format
+// that prices every variant: the product gets the lowest variant price and
+// variants only keep prices that differ from it
+func settleVariants(p *ProductInput) {
+	if len(p.Variants) == 0 {
+		return
+	}
+	cheapest := 0
+	for i, variant := range p.Variants {
+		if *variant.Price < *p.Variants[cheapest].Price {
+			cheapest = i
+		}
+	}
+	p.Price = *p.Variants[cheapest].Price
+	p.ComparePrice = p.Variants[cheapest].ComparePrice
+	p.Stock = 0
+	for i := range p.Variants {
+		if *p.Variants[i].Price == p.Price {
+			p.Variants[i].Price = nil
+		}
+		p.Stock += p.Variants[i].Stock
+	}
+}
+
+// derivedSKU makes a stable SKU for a variant exported without one
+func derivedSKU(base string, options []OptionValue) string {
+	parts := []string{base}
+	for _, option := range options {
+		parts = append(parts, option.Value)
+	}
+	return Slugify(strings.Join(parts, "-"))
+}
+
+// lastSegment returns "Shirts" for a category path like "Apparel > Shirts"
+func lastSegment(path string) string {
+	segments := strings.Split(path, ">")
+	return strings.TrimSpace(segments[len(segments)-1])
+}
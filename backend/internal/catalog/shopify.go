@@ -0,0 +1,272 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FormatShopify is Shopify's product CSV export. Rows are grouped by Handle:
+// the first row carries the product and its first variant, the following rows
+// further variants or only more images. Products without options have a
+// single "Title: Default Title" variant, which becomes the product itself.
+const FormatShopify = "shopify"
+
+// Shopify columns, lower-cased as they are read
+const (
+	shopifyHandle          = "handle"
+	shopifyTitle           = "title"
+	shopifyBody            = "body (html)"
+	shopifyType            = "type"
+	shopifyProductCategory = "product category"
+	shopifyTags            = "tags"
+	shopifyPublished       = "published"
+	shopifyStatus          = "status"
+	shopifyVariantSKU      = "variant sku"
+	shopifyVariantGrams    = "variant grams"
+	shopifyVariantQty      = "variant inventory qty"
+	shopifyVariantPrice    = "variant price"
+	shopifyVariantCompare  = "variant compare at price"
+	shopifyImageSrc        = "image src"
+	shopifyVariantImage    = "variant image"
+)
+
+func shopifyOptionColumns(n int) (name, value string) {
+	return fmt.Sprintf("option%d name", n), fmt.Sprintf("option%d value", n)
+}
+
+// shopifyProduct is a product while its rows are read
+type shopifyProduct struct {
+	group  *group
+	handle string
+}
+
+func parseShopify(t *table) (*Batch, error) {
+	if !t.has(shopifyHandle) {
+		return nil, errors.New("the file needs a Handle column")
+	}
+
+	mapped := map[string]string{
+		shopifyTitle:           ColName,
+		shopifyBody:            ColDescription,
+		shopifyType:            ColCategory,
+		shopifyProductCategory: ColCategory,
+		shopifyTags:            ColTags,
+		shopifyVariantPrice:    ColPrice,
+		shopifyVariantCompare:  ColComparePrice,
+		shopifyVariantQty:      ColStock,
+		shopifyVariantGrams:    ColWeight,
+		shopifyPublished:       ColIsActive,
+		shopifyStatus:          ColIsActive,
+		shopifyImageSrc:        ColImageURLs,
+		shopifyVariantImage:    ColImageURLs,
+		shopifyVariantSKU:      ColOptions,
+	}
+	known := map[string]bool{shopifyHandle: true}
+	fields := map[string]bool{}
+	for column, field := range mapped {
+		known[column] = true
+		if t.has(column) {
+			fields[field] = true
+		}
+	}
+	for n := 1; n <= maxOptions; n++ {
+		name, value := shopifyOptionColumns(n)
+		known[name], known[value] = true, true
+		if t.has(name) || t.has(value) {
+			fields[ColOptions] = true
+		}
+	}
+
+	batch := &Batch{Format: FormatShopify, Rows: len(t.rows), UnmappedColumns: t.unmapped(known)}
+	groups := newGroups(batch)
+	var products []*shopifyProduct
+
+	for i, row := range t.rows {
+		if blank(row) {
+			continue
+		}
+		rowNum := rowNumber(i)
+		handle := strings.ToLower(t.get(row, shopifyHandle))
+		errs := &rowErrors{batch: batch, row: rowNum, sku: t.get(row, shopifyVariantSKU)}
+
+		grp, ok := groups.byHandle[handle]
+		if !ok || handle == "" {
+			product := &ProductInput{Row: rowNum, Handle: handle, Fields: fields}
+			grp = groups.add(handle, product)
+			products = append(products, &shopifyProduct{group: grp, handle: handle})
+			if handle == "" {
+				errs.add(ColHandle, errors.New("handle is required"))
+			}
+			parseShopifyProduct(t, row, product, errs)
+		}
+
+		if urls, err := parseImageURLs(t.get(row, shopifyImageSrc)); err != nil {
+			errs.add(ColImageURLs, err)
+		} else {
+			grp.product.ImageURLs = appendMissing(grp.product.ImageURLs, urls...)
+		}
+
+		// Rows after the first without variant columns only add images
+		_, firstValue := shopifyOptionColumns(1)
+		imageOnly := ok && t.get(row, shopifyVariantSKU) == "" && t.get(row, shopifyVariantPrice) == "" &&
+			t.get(row, firstValue) == ""
+		if !imageOnly {
+			parseShopifyVariant(t, row, grp.product, errs)
+		}
+		if errs.count > 0 {
+			grp.failed = true
+		}
+	}
+
+	for _, sp := range products {
+		finishShopifyProduct(groups, sp)
+	}
+	groups.finish()
+	return batch, nil
+}
+
+func parseShopifyProduct(t *table, row []string, p *ProductInput, errs *rowErrors) {
+	p.Name = t.get(row, shopifyTitle)
+	if p.Has(ColName) && p.Name == "" {
+		errs.add(ColName, errors.New("title is required"))
+	}
+	p.Description = t.get(row, shopifyBody)
+
+	category := t.get(row, shopifyType)
+	if category == "" {
+		category = lastSegment(t.get(row, shopifyProductCategory))
+	}
+	p.CategoryName = category
+	p.CategorySlug = Slugify(category)
+	p.Tags = parseTags(t.get(row, shopifyTags), ",")
+
+	var err error
+	if t.has(shopifyStatus) {
+		switch status := strings.ToLower(t.get(row, shopifyStatus)); status {
+		case "", "active":
+			p.IsActive = true
+		case "draft", "archived":
+			p.IsActive = false
+		default:
+			errs.add(ColIsActive, fmt.Errorf("unknown status %q", status))
+		}
+	} else {
+		p.IsActive, err = parseBool(t.get(row, shopifyPublished), true)
+		errs.add(ColIsActive, err)
+	}
+}
+
+func parseShopifyVariant(t *table, row []string, p *ProductInput, errs *rowErrors) {
+	variant := VariantInput{Row: errs.row, SKU: t.get(row, shopifyVariantSKU), IsActive: true}
+	for n := 1; n <= maxOptions; n++ {
+		nameCol, valueCol := shopifyOptionColumns(n)
+		name, value := t.get(row, nameCol), t.get(row, valueCol)
+		if value == "" {
+			continue
+		}
+		// Option names are only on the first row of a product
+		if name == "" && len(p.Variants) > 0 && len(p.Variants[0].Options) >= n {
+			name = p.Variants[0].Options[n-1].Name
+		}
+		if name == "" {
+			errs.add(ColOptions, fmt.Errorf("option value %q has no option name", value))
+		}
+		variant.Options = append(variant.Options, OptionValue{Name: name, Value: value})
+	}
+	if len(p.Variants) > 0 && len(variant.Options) != len(p.Variants[0].Options) {
+		errs.add(ColOptions, errors.New("every variant needs a value for each option"))
+	}
+
+	var err error
+	if p.Has(ColPrice) {
+		price, err := ParsePrice(t.get(row, shopifyVariantPrice))
+		if t.get(row, shopifyVariantPrice) == "" {
+			err = errors.New("variant price is required")
+		}
+		errs.add(ColPrice, err)
+		variant.Price = &price
+	} else {
+		variant.Price = new(int)
+	}
+	variant.ComparePrice, err = parseOptionalPrice(t.get(row, shopifyVariantCompare))
+	errs.add(ColComparePrice, err)
+	variant.Stock, err = parseShopifyQuantity(t.get(row, shopifyVariantQty))
+	errs.add(ColStock, err)
+	variant.Weight, err = parseWeight(t.get(row, shopifyVariantGrams))
+	errs.add(ColWeight, err)
+	if urls, err := parseImageURLs(t.get(row, shopifyVariantImage)); err != nil {
+		errs.add(ColImageURLs, err)
+	} else {
+		p.ImageURLs = appendMissing(p.ImageURLs, urls...)
+	}
+
+	p.Variants = append(p.Variants, variant)
+}
+
+// parseShopifyQuantity reads an inventory count; Shopify lets oversold
+// variants go below zero, which is out of stock here
+func parseShopifyQuantity(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("must be a whole number")
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n, nil
+}
+
+// finishShopifyProduct turns a product's rows into the product and its
+// variants and assigns the SKUs
+func finishShopifyProduct(groups *groups, sp *shopifyProduct) {
+	p := sp.group.product
+
+	if isShopifyDefaultVariant(p.Variants) {
+		variant := p.Variants[0]
+		p.Variants = nil
+		p.SKU = variant.SKU
+		if p.SKU == "" {
+			p.SKU = sp.handle
+		}
+		p.Price = *variant.Price
+		p.ComparePrice = variant.ComparePrice
+		p.Stock = variant.Stock
+		p.Weight = variant.Weight
+		if p.SKU != "" && !groups.claimSKU(p.Row, p.SKU) {
+			sp.group.failed = true
+		}
+		return
+	}
+
+	// Shopify products have no SKU of their own; the handle stands in
+	p.SKU = sp.handle
+	if p.SKU != "" && !groups.claimSKU(p.Row, p.SKU) {
+		sp.group.failed = true
+	}
+	for i := range p.Variants {
+		variant := &p.Variants[i]
+		if variant.SKU == "" {
+			variant.SKU = derivedSKU(sp.handle, variant.Options)
+		}
+		if !groups.claimSKU(variant.Row, variant.SKU) {
+			sp.group.failed = true
+		}
+	}
+	if len(p.Variants) > 0 {
+		p.Weight = p.Variants[0].Weight
+	}
+	settleVariants(p)
+}
+
+func isShopifyDefaultVariant(variants []VariantInput) bool {
+	if len(variants) != 1 {
+		return false
+	}
+	options := variants[0].Options
+	return len(options) == 0 || (len(options) == 1 && options[0].Value == "Default Title")
+}
@@ -0,0 +1,209 @@
+package catalog
+
+import (
+	"sort"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Preview is the outcome of a dry run: what an import of the batch would do
+type Preview struct {
+	Format          string                  `json:"format"`
+	Rows            int                     `json:"rows"`
+	Products        int                     `json:"products"`
+	Variants        int                     `json:"variants"`
+	Creates         int                     `json:"creates"`
+	Updates         int                     `json:"updates"`
+	Skipped         int                     `json:"skipped"` // Products left out because of errors
+	NewCategories   []string                `json:"new_categories"`
+	Valid           bool                    `json:"valid"`
+	Errors          []models.ImportRowError `json:"errors"`
+	UnmappedColumns []string                `json:"unmapped_columns"`
+}
+
+// Validate checks the batch against the database without changing anything
+func Validate(db *gorm.DB, batch *Batch) (*Preview, error) {
+	preview := &Preview{
+		Format:          batch.Format,
+		Rows:            batch.Rows,
+		Errors:          append([]models.ImportRowError{}, batch.Errors...),
+		UnmappedColumns: batch.UnmappedColumns,
+		NewCategories:   []string{},
+	}
+	if preview.UnmappedColumns == nil {
+		preview.UnmappedColumns = []string{}
+	}
+	preview.Skipped = batch.Skipped
+
+	c, err := loadChecker(db, batch.Products)
+	if err != nil {
+		return nil, err
+	}
+	newCategories := map[string]bool{}
+	for _, product := range batch.Products {
+		if errs := c.check(product); len(errs) > 0 {
+			preview.Errors = append(preview.Errors, errs...)
+			preview.Skipped++
+			continue
+		}
+		if c.createsCategory(product) && !newCategories[product.CategorySlug] {
+			newCategories[product.CategorySlug] = true
+			preview.NewCategories = append(preview.NewCategories, product.CategoryName)
+		}
+		preview.Products++
+		preview.Variants += len(product.Variants)
+		if _, ok := c.existing[product.SKU]; ok {
+			preview.Updates++
+		} else {
+			preview.Creates++
+		}
+	}
+
+	preview.Valid = len(preview.Errors) == 0
+	return preview, nil
+}
+
+// checker holds what validating products needs from the database
+type checker struct {
+	categories    map[string]uuid.UUID                   // Slug to ID
+	existing      map[string]uuid.UUID                   // Product SKU to ID
+	variantOwners map[string]uuid.UUID                   // Variant SKU to product ID
+	attributes    map[string]*models.AttributeDefinition // By code
+}
+
+func loadChecker(tx *gorm.DB, products []*ProductInput) (*checker, error) {
+	c := &checker{
+		categories:    map[string]uuid.UUID{},
+		existing:      map[string]uuid.UUID{},
+		variantOwners: map[string]uuid.UUID{},
+		attributes:    map[string]*models.AttributeDefinition{},
+	}
+
+	var slugs, skus, variantSKUs []string
+	codes := map[string]bool{}
+	for _, product := range products {
+		for code := range product.Attributes {
+			codes[code] = true
+		}
+		if product.CategorySlug != "" {
+			slugs = append(slugs, product.CategorySlug)
+		}
+		skus = append(skus, product.SKU)
+		for _, variant := range product.Variants {
+			variantSKUs = append(variantSKUs, variant.SKU)
+		}
+	}
+
+	// Large files are looked up in chunks to stay under parameter limits
+	const chunk = 1000
+	for _, part := range chunks(slugs, chunk) {
+		var categories []models.Category
+		if err := tx.Select("id, slug").Where("slug IN ?", part).Find(&categories).Error; err != nil {
+			return nil, err
+		}
+		for _, category := range categories {
+			if _, ok := c.categories[category.Slug]; !ok {
+				c.categories[category.Slug] = category.ID
+			}
+		}
+	}
+	for _, part := range chunks(skus, chunk) {
+		var found []models.Product
+		if err := tx.Select("id, sku").Where("sku IN ?", part).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		for _, product := range found {
+			c.existing[product.SKU] = product.ID
+		}
+	}
+	for _, part := range chunks(variantSKUs, chunk) {
+		var found []models.ProductVariant
+		if err := tx.Select("id, sku, product_id").Where("sku IN ?", part).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		for _, variant := range found {
+			c.variantOwners[variant.SKU] = variant.ProductID
+		}
+	}
+	if len(codes) > 0 {
+		var definitions []models.AttributeDefinition
+		if err := tx.Where("code IN ?", sortedKeys(codes)).Find(&definitions).Error; err != nil {
+			return nil, err
+		}
+		for i := range definitions {
+			c.attributes[definitions[i].Code] = &definitions[i]
+		}
+	}
+	return c, nil
+}
+
+func (c *checker) check(p *ProductInput) []models.ImportRowError {
+	var errs []models.ImportRowError
+	add := func(row int, sku, field, message string) {
+		errs = append(errs, models.ImportRowError{Row: row, SKU: sku, Field: field, Message: message})
+	}
+
+	productID, exists := c.existing[p.SKU]
+	if !exists {
+		if !p.Has(ColName) {
+			add(p.Row, p.SKU, ColName, "name is required for new products")
+		}
+		if !p.Has(ColPrice) {
+			add(p.Row, p.SKU, ColPrice, "price is required for new products")
+		}
+	}
+	if p.Has(ColCategory) && p.CategorySlug != "" && p.CategoryName == "" {
+		if _, ok := c.categories[p.CategorySlug]; !ok {
+			add(p.Row, p.SKU, ColCategory, "no category with slug "+p.CategorySlug)
+		}
+	}
+	for _, code := range sortedKeys(p.Attributes) {
+		definition, ok := c.attributes[code]
+		if !ok {
+			add(p.Row, p.SKU, attributeColumn(code), "no attribute with code "+code)
+		} else if raw := p.Attributes[code]; raw != "" {
+			if _, err := definition.Parse(raw); err != nil {
+				add(p.Row, p.SKU, attributeColumn(code), err.Error())
+			}
+		}
+	}
+	for _, variant := range p.Variants {
+		owner, ok := c.variantOwners[variant.SKU]
+		if ok && (!exists || owner != productID) {
+			add(variant.Row, variant.SKU, ColSKU, "SKU belongs to a variant of another product")
+		}
+	}
+	return errs
+}
+
+// createsCategory reports whether importing p adds its category
+func (c *checker) createsCategory(p *ProductInput) bool {
+	if !p.Has(ColCategory) || p.CategorySlug == "" || p.CategoryName == "" {
+		return false
+	}
+	_, ok := c.categories[p.CategorySlug]
+	return !ok
+}
+
+func chunks(values []string, size int) [][]string {
+	var parts [][]string
+	for len(values) > size {
+		parts = append(parts, values[:size])
+		values = values[size:]
+	}
+	if len(values) > 0 {
+		parts = append(parts, values)
+	}
+	return parts
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
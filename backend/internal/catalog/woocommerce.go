@@ -0,0 +1,360 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// FormatWooCommerce is WooCommerce's product CSV export. Simple and variable
+// products have a row each; variations follow with their parent in the Parent
+// column, as "id:<ID>" or the parent's SKU, and their option values in the
+// attribute columns. Grouped and external products are not supported.
+const FormatWooCommerce = "woocommerce"
+
+// WooCommerce columns, lower-cased as they are read
+const (
+	wooID           = "id"
+	wooType         = "type"
+	wooSKU          = "sku"
+	wooName         = "name"
+	wooPublished    = "published"
+	wooFeatured     = "is featured?"
+	wooDescription  = "description"
+	wooStock        = "stock"
+	wooLowStock     = "low stock amount"
+	wooSalePrice    = "sale price"
+	wooRegularPrice = "regular price"
+	wooCategories   = "categories"
+	wooTags         = "tags"
+	wooImages       = "images"
+	wooParent       = "parent"
+)
+
+// WooCommerce product types
+const (
+	wooSimple    = "simple"
+	wooVariable  = "variable"
+	wooVariation = "variation"
+	wooGrouped   = "grouped"
+	wooExternal  = "external"
+)
+
+// wooWeightUnits converts the store's weight unit, named in the weight
+// column's header, to grams
+var wooWeightUnits = map[string]float64{
+	"weight (g)":   1,
+	"weight (kg)":  1000,
+	"weight (oz)":  28.349523125,
+	"weight (lbs)": 453.59237,
+}
+
+func wooAttributeColumns(n int) (name, value string) {
+	return fmt.Sprintf("attribute %d name", n), fmt.Sprintf("attribute %d value(s)", n)
+}
+
+// woo is the state of a WooCommerce file while it is read
+type woo struct {
+	t      *table
+	batch  *Batch
+	groups *groups
+	fields map[string]bool
+
+	weightColumn string
+	weightGrams  float64
+
+	parents map[string]*group // By "id:<ID>" and lower-cased SKU
+}
+
+func parseWooCommerce(t *table) (*Batch, error) {
+	if !t.has(wooName) || !t.has(wooType) {
+		return nil, errors.New("the file needs Type and Name columns")
+	}
+
+	mapped := map[string]string{
+		wooID:           "",
+		wooType:         "",
+		wooParent:       "",
+		wooSKU:          ColSKU,
+		wooName:         ColName,
+		wooDescription:  ColDescription,
+		wooCategories:   ColCategory,
+		wooTags:         ColTags,
+		wooRegularPrice: ColPrice,
+		wooSalePrice:    ColComparePrice,
+		wooStock:        ColStock,
+		wooLowStock:     ColMinStock,
+		wooPublished:    ColIsActive,
+		wooFeatured:     ColIsFeatured,
+		wooImages:       ColImageURLs,
+	}
+	w := &woo{t: t, fields: map[string]bool{ColOptions: true}, parents: map[string]*group{}}
+	known := map[string]bool{}
+	for column, field := range mapped {
+		known[column] = true
+		if t.has(column) && field != "" {
+			w.fields[field] = true
+		}
+	}
+	for column, grams := range wooWeightUnits {
+		if t.has(column) && w.weightColumn == "" {
+			known[column] = true
+			w.weightColumn, w.weightGrams = column, grams
+			w.fields[ColWeight] = true
+		}
+	}
+	for n := 1; ; n++ {
+		name, value := wooAttributeColumns(n)
+		if !t.has(name) && !t.has(value) {
+			break
+		}
+		known[name], known[value] = true, true
+	}
+	// A sale price makes the regular price the compare price
+	if w.fields[ColComparePrice] {
+		w.fields[ColPrice] = true
+	}
+
+	w.batch = &Batch{Format: FormatWooCommerce, Rows: len(t.rows), UnmappedColumns: t.unmapped(known)}
+	w.groups = newGroups(w.batch)
+	var variable []*group
+
+	for i, row := range t.rows {
+		if blank(row) {
+			continue
+		}
+		rowNum := rowNumber(i)
+		errs := &rowErrors{batch: w.batch, row: rowNum, sku: t.get(row, wooSKU)}
+
+		switch kind := wooProductType(t.get(row, wooType)); kind {
+		case wooSimple, wooVariable:
+			grp := w.addProduct(row, errs)
+			if kind == wooVariable {
+				variable = append(variable, grp)
+			}
+		case wooVariation:
+			w.addVariation(row, errs)
+		default:
+			grp := w.groups.add("", &ProductInput{Row: rowNum, SKU: errs.sku, Fields: w.fields})
+			if kind == wooGrouped || kind == wooExternal {
+				errs.add(wooType, fmt.Errorf("%s products are not supported", kind))
+			} else {
+				errs.add(wooType, fmt.Errorf("unknown product type %q", t.get(row, wooType)))
+			}
+			grp.failed = true
+		}
+	}
+
+	for _, grp := range variable {
+		settleVariants(grp.product)
+	}
+	w.groups.finish()
+	return w.batch, nil
+}
+
+// wooProductType picks the product type from a Type cell such as
+// "simple, virtual"
+func wooProductType(value string) string {
+	for _, part := range strings.Split(strings.ToLower(value), ",") {
+		switch part = strings.TrimSpace(part); part {
+		case wooSimple, wooVariable, wooVariation, wooGrouped, wooExternal:
+			return part
+		}
+	}
+	return ""
+}
+
+func (w *woo) addProduct(row []string, errs *rowErrors) *group {
+	t := w.t
+	id := t.get(row, wooID)
+	p := &ProductInput{Row: errs.row, SKU: t.get(row, wooSKU), Fields: w.fields}
+	if p.SKU == "" && id != "" {
+		// Stores without SKUs are matched by their WooCommerce ID
+		p.SKU = "woo-" + id
+	}
+	grp := w.groups.add("", p)
+	if id != "" {
+		w.parents["id:"+id] = grp
+	}
+	if p.SKU != "" {
+		w.parents[strings.ToLower(p.SKU)] = grp
+	}
+
+	if p.SKU == "" {
+		errs.add(ColSKU, errors.New("SKU or ID is required"))
+	} else if !w.groups.claimSKU(errs.row, p.SKU) {
+		errs.count++
+	}
+
+	p.Name = t.get(row, wooName)
+	if p.Name == "" {
+		errs.add(ColName, errors.New("name is required"))
+	}
+	p.Description = t.get(row, wooDescription)
+	p.CategoryName = wooCategory(t.get(row, wooCategories))
+	p.CategorySlug = Slugify(p.CategoryName)
+	p.Tags = parseTags(t.get(row, wooTags), ",")
+
+	var err error
+	isVariable := wooProductType(t.get(row, wooType)) == wooVariable
+	if !isVariable {
+		var price *int
+		price, p.ComparePrice, err = w.prices(row)
+		if err == nil && price == nil && p.Has(ColPrice) {
+			err = errors.New("regular price is required")
+		}
+		errs.add(ColPrice, err)
+		if price != nil {
+			p.Price = *price
+		}
+	}
+	if stock := t.get(row, wooStock); stock != "" || isVariable {
+		p.Stock, err = parseCount(stock)
+		errs.add(ColStock, err)
+	} else if p.Has(ColStock) {
+		// Products that don't track stock keep their stock level
+		p.Fields = withoutField(p.Fields, ColStock)
+	}
+	p.MinStock, err = parseCount(t.get(row, wooLowStock))
+	errs.add(ColMinStock, err)
+	p.Weight, err = w.weight(row)
+	errs.add(ColWeight, err)
+	p.IsActive, err = parseWooPublished(t.get(row, wooPublished))
+	errs.add(ColIsActive, err)
+	p.IsFeatured, err = parseBool(t.get(row, wooFeatured), false)
+	errs.add(ColIsFeatured, err)
+	p.ImageURLs, err = parseImageURLs(strings.ReplaceAll(t.get(row, wooImages), ",", "|"))
+	errs.add(ColImageURLs, err)
+
+	if errs.count > 0 {
+		grp.failed = true
+	}
+	return grp
+}
+
+func (w *woo) addVariation(row []string, errs *rowErrors) {
+	t := w.t
+	parentRef := t.get(row, wooParent)
+	grp, ok := w.parents[strings.ToLower(parentRef)]
+	if !ok {
+		grp = w.groups.add("", &ProductInput{Row: errs.row, SKU: errs.sku, Fields: w.fields})
+		grp.failed = true
+		if parentRef == "" {
+			errs.add(wooParent, errors.New("variations need their parent product"))
+		} else {
+			errs.add(wooParent, fmt.Errorf("parent %q is not an earlier product in the file", parentRef))
+		}
+		return
+	}
+	p := grp.product
+
+	variant := VariantInput{Row: errs.row, SKU: t.get(row, wooSKU)}
+	for n := 1; ; n++ {
+		nameCol, valueCol := wooAttributeColumns(n)
+		if !t.has(nameCol) {
+			break
+		}
+		name, value := t.get(row, nameCol), t.get(row, valueCol)
+		if name == "" {
+			continue
+		}
+		if value == "" {
+			errs.add(ColOptions, fmt.Errorf("%s needs a value; variations for any %s are not supported", name, name))
+			continue
+		}
+		variant.Options = append(variant.Options, OptionValue{Name: name, Value: value})
+	}
+	if len(variant.Options) == 0 {
+		errs.add(ColOptions, errors.New("variations need at least one attribute value"))
+	}
+	if len(variant.Options) > maxOptions {
+		errs.add(ColOptions, fmt.Errorf("variants have at most %d options", maxOptions))
+	}
+	if variant.SKU == "" {
+		variant.SKU = derivedSKU(p.SKU, variant.Options)
+	}
+	errs.sku = variant.SKU
+	if !w.groups.claimSKU(errs.row, variant.SKU) {
+		errs.count++
+	}
+
+	var err error
+	variant.Price, variant.ComparePrice, err = w.prices(row)
+	if err == nil && variant.Price == nil && p.Has(ColPrice) {
+		err = errors.New("regular price is required")
+	}
+	errs.add(ColPrice, err)
+	if variant.Price == nil {
+		variant.Price = new(int)
+	}
+	variant.Stock, err = parseCount(t.get(row, wooStock))
+	errs.add(ColStock, err)
+	variant.Weight, err = w.weight(row)
+	errs.add(ColWeight, err)
+	variant.IsActive, err = parseWooPublished(t.get(row, wooPublished))
+	errs.add(ColIsActive, err)
+	if urls, err := parseImageURLs(strings.ReplaceAll(t.get(row, wooImages), ",", "|")); err != nil {
+		errs.add(ColImageURLs, err)
+	} else {
+		p.ImageURLs = appendMissing(p.ImageURLs, urls...)
+	}
+
+	p.Variants = append(p.Variants, variant)
+	if errs.count > 0 {
+		grp.failed = true
+	}
+}
+
+// prices returns the selling price and, during a sale, the regular price as
+// the compare price
+func (w *woo) prices(row []string) (price, compare *int, err error) {
+	regular, err := parseOptionalPrice(w.t.get(row, wooRegularPrice))
+	if err != nil {
+		return nil, nil, err
+	}
+	sale, err := parseOptionalPrice(w.t.get(row, wooSalePrice))
+	if err != nil {
+		return nil, nil, err
+	}
+	if sale != nil && regular != nil {
+		return sale, regular, nil
+	}
+	return regular, nil, nil
+}
+
+func (w *woo) weight(row []string) (*float64, error) {
+	if w.weightColumn == "" {
+		return nil, nil
+	}
+	weight, err := parseWeight(w.t.get(row, w.weightColumn))
+	if err != nil || weight == nil {
+		return nil, err
+	}
+	grams := math.Round(*weight*w.weightGrams*100) / 100
+	return &grams, nil
+}
+
+// parseWooPublished reads Published, where -1 is a draft
+func parseWooPublished(value string) (bool, error) {
+	if value == "-1" {
+		return false, nil
+	}
+	return parseBool(value, true)
+}
+
+// wooCategory picks the product's category from a Categories cell such as
+// "Clothing > Shirts, Sale": the most specific level of the first category
+func wooCategory(value string) string {
+	value = strings.ReplaceAll(value, `\,`, "\x00") // Commas in names are escaped
+	first, _, _ := strings.Cut(value, ",")
+	return strings.ReplaceAll(lastSegment(first), "\x00", ",")
+}
+
+func withoutField(fields map[string]bool, field string) map[string]bool {
+	copied := make(map[string]bool, len(fields))
+	for name, ok := range fields {
+		copied[name] = ok && name != field
+	}
+	return copied
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -17,6 +19,42 @@ type Config struct {
 	MinIOUseSSL   bool
 	JWTSecret     string
 	Port          string
+	FrontendURL   string // Base URL used in links sent to users
+
+	// OpenID Connect providers for customer sign-in
+	OIDCProviders []OIDCProviderConfig
+
+	// Outgoing email: MailDriver is "smtp", "file" (writes .eml files to
+	// MailDir) or "log" (the default, prints emails to the server log)
+	MailDriver   string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	// Background workers (email delivery and the jobs queue) run inside the
+	// server unless RunWorkers is false, e.g. when cmd/worker runs them instead
+	RunWorkers        bool
+	WorkerConcurrency int
+
+	// OutboxListen makes the domain event dispatcher use Postgres LISTEN/NOTIFY
+	// instead of only polling the outbox
+	OutboxListen bool
+
+	// TrustedProxies are the IPs or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For header is believed; with none it is ignored
+	TrustedProxies []string
+}
+
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
 }
 
 func Load() *Config {
@@ -33,7 +71,46 @@ func Load() *Config {
 		MinIOUseSSL:   getEnv("MINIO_USE_SSL", "false") == "true",
 		JWTSecret:     getEnv("JWT_SECRET", "your-secret-key"),
 		Port:          getEnv("PORT", "8080"),
+		FrontendURL:   strings.TrimSuffix(getEnv("FRONTEND_URL", "http://localhost:3000"), "/"),
+		OIDCProviders: loadOIDCProviders(),
+		MailDriver:    getEnv("MAIL_DRIVER", "log"),
+		MailFrom:      getEnv("MAIL_FROM", "EasyCart <no-reply@localhost>"),
+		MailDir:       getEnv("MAIL_DIR", "tmp/mail"),
+		SMTPHost:      getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:      getEnv("SMTP_PORT", "587"),
+		SMTPUsername:  os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:  os.Getenv("SMTP_PASSWORD"),
+		RunWorkers:    getEnv("RUN_WORKERS", "true") == "true",
+		WorkerConcurrency: getEnvInt("WORKER_CONCURRENCY", 4),
+		OutboxListen:  getEnv("OUTBOX_LISTEN", "true") == "true",
+		TrustedProxies: strings.Fields(strings.ReplaceAll(os.Getenv("TRUSTED_PROXIES"), ",", " ")),
+	}
+}
+
+// loadOIDCProviders reads OIDC_PROVIDERS (e.g. "google,okta") and, for each
+// name, OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and _SCOPES
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			provider.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+		providers = append(providers, provider)
 	}
+	return providers
 }
 
 func getEnv(key, defaultValue string) string {
@@ -41,4 +118,10 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
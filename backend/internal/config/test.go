@@ -13,6 +13,9 @@ func LoadTestConfig() *Config {
 		DBName:     getEnvOrDefault("TEST_DB_NAME", "easycart_test"),
 		JWTSecret:  "test-jwt-secret-key",
 		Port:       "8081",
+		FrontendURL: "http://localhost:3000",
+		MailDriver:  "log",
+		MailFrom:    "EasyCart <no-reply@localhost>",
 		
 		// MinIO test config
 		MinIOEndpoint:   "localhost:9000",
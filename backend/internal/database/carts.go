@@ -0,0 +1,29 @@
+package database
+
+import (
+	"strconv"
+
+	"easycart/internal/models"
+	"gorm.io/gorm"
+)
+
+// SetupCartItems gives each product and variant at most one line per cart,
+// which AddToCart upserts into. Duplicate lines left from before the index
+// are merged into the oldest first.
+func SetupCartItems(db *gorm.DB) error {
+	return db.Exec(`
+WITH lines AS (
+	SELECT id,
+		SUM(quantity) OVER line AS total,
+		ROW_NUMBER() OVER (line ORDER BY created_at, id) AS n
+	FROM cart_items
+	WINDOW line AS (PARTITION BY cart_id, product_id, variant_id)
+), merged AS (
+	UPDATE cart_items SET quantity = LEAST(lines.total, ` + strconv.Itoa(models.MaxCartQuantity) + `)
+	FROM lines WHERE cart_items.id = lines.id AND lines.n = 1 AND lines.total <> cart_items.quantity
+)
+DELETE FROM cart_items USING lines WHERE cart_items.id = lines.id AND lines.n > 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_line ON cart_items (` + models.CartLineSQL + `);
+`).Error
+}
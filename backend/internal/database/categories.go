@@ -0,0 +1,9 @@
+package database
+
+import "gorm.io/gorm"
+
+// BackfillCategoryPaths makes categories created before the category tree
+// roots
+func BackfillCategoryPaths(db *gorm.DB) error {
+	return db.Exec(`UPDATE categories SET path = '/' || id || '/', depth = 0 WHERE path = ''`).Error
+}
@@ -12,13 +12,16 @@ import (
 
 var DB *gorm.DB
 
-func Connect(cfg *config.Config) error {
-	dsn := fmt.Sprintf(
+// DSN returns the Postgres connection string for cfg
+func DSN(cfg *config.Config) string {
+	return fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
 		cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort,
 	)
+}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func Connect(cfg *config.Config) error {
+	db, err := gorm.Open(postgres.Open(DSN(cfg)), &gorm.Config{})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -27,22 +30,84 @@ func Connect(cfg *config.Config) error {
 
 	// Auto-migrate models
 	err = db.AutoMigrate(
+		&models.Role{},
 		&models.User{},
+		&models.APIKey{},
+		&models.UserIdentity{},
+		&models.Address{},
+		&models.OIDCLoginState{},
+		&models.StaffInvitation{},
+		&models.AuditEvent{},
+		&models.EmailTemplate{},
+		&models.EmailMessage{},
+		&models.Job{},
+		&models.JobSchedule{},
+		&models.WebhookEndpoint{},
+		&models.WebhookDelivery{},
+		&models.OutboxEvent{},
+		&models.ProductImport{},
 		&models.Settings{},
 		&models.Category{},
 		&models.Product{},
 		&models.Media{},
+		&models.ProductOption{},
+		&models.ProductOptionValue{},
+		&models.ProductVariant{},
+		&models.ProductVariantOptionValue{},
+		&models.ProductTag{},
+		&models.Collection{},
+		&models.CollectionProduct{},
+		&models.AttributeDefinition{},
+		&models.AttributeValue{},
 		&models.Order{},
 		&models.OrderItem{},
+		&models.Review{},
+		&models.ReviewImage{},
+		&models.ReviewVote{},
+		&models.Wishlist{},
+		&models.WishlistItem{},
+		&models.Cart{},
+		&models.CartItem{},
+		&models.ProductAlert{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if err := ProtectAuditEvents(db); err != nil {
+		return fmt.Errorf("failed to protect audit log: %w", err)
+	}
+	if err := SetupProductSearch(db); err != nil {
+		return fmt.Errorf("failed to set up product search: %w", err)
+	}
+	if err := BackfillCategoryPaths(db); err != nil {
+		return fmt.Errorf("failed to backfill category paths: %w", err)
+	}
+	if err := SetupCartItems(db); err != nil {
+		return fmt.Errorf("failed to set up cart items: %w", err)
+	}
+
 	log.Println("Database connected and migrated successfully")
 	return nil
 }
 
 func GetDB() *gorm.DB {
 	return DB
-}
\ No newline at end of file
+}
+
+// ProtectAuditEvents makes audit_events append-only at the database level so
+// raw SQL and other clients cannot rewrite history either
+func ProtectAuditEvents(db *gorm.DB) error {
+	return db.Exec(`
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+	BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+`).Error
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const listenReconnectWait = 5 * time.Second
+
+// Listen runs LISTEN channel on a dedicated connection to dsn and calls
+// handle with each notification payload until ctx is cancelled. It reconnects
+// after errors; onConnect, if set, is called after every (re)connect so the
+// caller can catch up on anything sent while it was not listening.
+func Listen(ctx context.Context, dsn, channel string, onConnect func(), handle func(payload string)) {
+	for ctx.Err() == nil {
+		err := listenOnce(ctx, dsn, channel, onConnect, handle)
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+		log.Printf("LISTEN %s failed, reconnecting: %v", channel, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(listenReconnectWait):
+		}
+	}
+}
+
+func listenOnce(ctx context.Context, dsn, channel string, onConnect func(), handle func(payload string)) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	if onConnect != nil {
+		onConnect()
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handle(notification.Payload)
+	}
+}
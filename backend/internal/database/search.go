@@ -0,0 +1,57 @@
+package database
+
+import "gorm.io/gorm"
+
+// SetupProductSearch adds the full-text search column of products and keeps
+// it current with triggers, so every writer, raw SQL included, updates it.
+// Names weigh most, then SKUs and category names, then descriptions. The
+// pg_trgm indexes serve fuzzy matches for misspelled searches.
+func SetupProductSearch(db *gorm.DB) error {
+	return db.Exec(`
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION product_search_vector(name text, description text, sku text, category_id uuid)
+RETURNS tsvector AS $$
+	SELECT setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('simple', coalesce(sku, '')), 'B') ||
+		setweight(to_tsvector('english', coalesce((SELECT c.name FROM categories c WHERE c.id = category_id), '')), 'B') ||
+		setweight(to_tsvector('english', coalesce(description, '')), 'C')
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION products_search_vector_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector := product_search_vector(NEW.name, NEW.description, NEW.sku, NEW.category_id);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_search_vector_update ON products;
+CREATE TRIGGER products_search_vector_update
+	BEFORE INSERT OR UPDATE OF name, description, sku, category_id ON products
+	FOR EACH ROW EXECUTE FUNCTION products_search_vector_update();
+
+CREATE OR REPLACE FUNCTION categories_search_vector_update() RETURNS trigger AS $$
+BEGIN
+	UPDATE products
+	SET search_vector = product_search_vector(name, description, sku, category_id)
+	WHERE category_id = NEW.id;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS categories_search_vector_update ON categories;
+CREATE TRIGGER categories_search_vector_update
+	AFTER UPDATE OF name ON categories
+	FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name)
+	EXECUTE FUNCTION categories_search_vector_update();
+
+CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING gin (name gin_trgm_ops);
+
+UPDATE products
+SET search_vector = product_search_vector(name, description, sku, category_id)
+WHERE search_vector IS NULL;
+`).Error
+}
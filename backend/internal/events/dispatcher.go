@@ -0,0 +1,199 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"easycart/internal/database"
+	"easycart/internal/jobs"
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxAttempts is how many times an event is dispatched before it is marked failed
+	maxAttempts = 10
+
+	pollInterval = 2 * time.Second
+
+	// listenPollInterval is the safety-net poll used while LISTEN is working
+	listenPollInterval = 15 * time.Second
+
+	// JobPrune deletes dispatched events past their retention
+	JobPrune   = "events.prune"
+	keepEvents = 7 * 24 * time.Hour
+)
+
+// Handler reacts to an event inside the dispatching transaction. Returning an
+// error rolls back every subscriber's work for the event and retries it later.
+type Handler func(ctx context.Context, tx *gorm.DB, event Event) error
+
+type subscription struct {
+	name    string
+	types   map[string]bool // Empty means every type
+	handler Handler
+}
+
+// Dispatcher delivers outbox events to in-process subscribers. Several
+// dispatchers may share the outbox; events are claimed with SKIP LOCKED.
+type Dispatcher struct {
+	db            *gorm.DB
+	subscriptions []subscription
+	listenDSN     string
+	wake          chan struct{}
+}
+
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+	return &Dispatcher{db: db, wake: make(chan struct{}, 1)}
+}
+
+// Subscribe registers handler for events of the given types, or for every
+// event when no types are given. name identifies the subscriber in errors.
+func (d *Dispatcher) Subscribe(name string, handler Handler, types ...string) {
+	sub := subscription{name: name, types: map[string]bool{}, handler: handler}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+	d.subscriptions = append(d.subscriptions, sub)
+}
+
+// Listen makes Run wait on Postgres LISTEN/NOTIFY using a dedicated
+// connection to dsn, so events are dispatched right after commit
+func (d *Dispatcher) Listen(dsn string) {
+	d.listenDSN = dsn
+}
+
+// Run dispatches events until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	interval := pollInterval
+	if d.listenDSN != "" {
+		interval = listenPollInterval
+		go database.Listen(ctx, d.listenDSN, NotifyChannel, d.signal, func(string) { d.signal() })
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			dispatched, err := d.DispatchNext(ctx)
+			if err != nil {
+				log.Printf("Event dispatcher error: %v", err)
+				break
+			}
+			if !dispatched {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DispatchNext delivers the oldest due event and reports whether there was one
+func (d *Dispatcher) DispatchNext(ctx context.Context) (bool, error) {
+	var row models.OutboxEvent
+	var handlerErr error
+
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dispatched_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Order("created_at ASC").
+			Limit(1).
+			Find(&row)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		event := Event{
+			ID:            row.ID,
+			Type:          row.Type,
+			AggregateType: row.AggregateType,
+			AggregateID:   row.AggregateID,
+			Payload:       row.Payload,
+			CreatedAt:     row.CreatedAt,
+		}
+		if handlerErr = d.deliver(ctx, tx, event); handlerErr != nil {
+			return handlerErr
+		}
+		return tx.Model(&row).Updates(map[string]interface{}{
+			"dispatched_at": time.Now(),
+			"attempts":      row.Attempts + 1,
+			"last_error":    "",
+		}).Error
+	})
+
+	if row.ID == uuid.Nil {
+		return false, err
+	}
+	if handlerErr != nil {
+		return true, d.recordFailure(&row, handlerErr)
+	}
+	return true, err
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, tx *gorm.DB, event Event) error {
+	for _, sub := range d.subscriptions {
+		if len(sub.types) > 0 && !sub.types[event.Type] {
+			continue
+		}
+		if err := d.invoke(ctx, tx, sub, event); err != nil {
+			return fmt.Errorf("%s: %w", sub.name, err)
+		}
+	}
+	return nil
+}
+
+// invoke runs one subscriber, turning a panic into an error
+func (d *Dispatcher) invoke(ctx context.Context, tx *gorm.DB, sub subscription, event Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return sub.handler(ctx, tx, event)
+}
+
+// recordFailure schedules a retry, or gives up after maxAttempts
+func (d *Dispatcher) recordFailure(row *models.OutboxEvent, handlerErr error) error {
+	attempts := row.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"last_error":      handlerErr.Error(),
+		"next_attempt_at": time.Now().Add(jobs.Backoff(attempts)),
+	}
+	if attempts >= maxAttempts {
+		updates["failed_at"] = time.Now()
+		log.Printf("Giving up on %s event %s after %d attempts: %v", row.Type, row.ID, attempts, handlerErr)
+	}
+	return d.db.Model(row).Updates(updates).Error
+}
+
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RegisterJobs adds the outbox pruning job to w
+func RegisterJobs(w *jobs.Worker, db *gorm.DB) error {
+	w.Register(JobPrune, func(ctx context.Context, job *models.Job) error {
+		return db.WithContext(ctx).
+			Where("dispatched_at < ?", time.Now().Add(-keepEvents)).
+			Delete(&models.OutboxEvent{}).Error
+	})
+	return w.Schedule(JobPrune, "37 3 * * *", JobPrune, nil)
+}
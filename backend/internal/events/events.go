@@ -0,0 +1,235 @@
+// Package events is the shop's domain event bus, built on a transactional
+// outbox.
+//
+// Handlers call Publish with the transaction that changes an order or
+// product; the event row commits or rolls back with the change. A Dispatcher
+// then hands each committed event to the subscribers registered for its type.
+// Subscribers run inside the transaction that marks the event dispatched, so
+// their database writes (queuing a job, a webhook delivery, an email) happen
+// exactly once; anything they do outside the database happens at least once,
+// and should be idempotent or pushed onto the jobs queue.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Event types
+const (
+	TypeOrderPlaced        = "order.placed"
+	TypeOrderStatusChanged = "order.status_changed"
+	TypeProductCreated     = "product.created"
+	TypeProductUpdated     = "product.updated"
+	TypeProductDeleted     = "product.deleted"
+	TypeStockChanged       = "stock.changed"
+	TypePriceChanged       = "price.changed"
+)
+
+// NotifyChannel is the Postgres channel Publish notifies so listening
+// dispatchers pick events up without waiting for their next poll
+const NotifyChannel = "outbox_events"
+
+// Payload is the data of one kind of domain event
+type Payload interface {
+	EventType() string
+	// Aggregate names the entity the event is about, e.g. ("order", id)
+	Aggregate() (string, uuid.UUID)
+}
+
+// OrderPlaced is published when a customer checks out
+type OrderPlaced struct {
+	Order *models.Order `json:"order"`
+}
+
+func (OrderPlaced) EventType() string                { return TypeOrderPlaced }
+func (e OrderPlaced) Aggregate() (string, uuid.UUID) { return "order", e.Order.ID }
+
+// OrderStatusChanged is published when an order's status or payment status changes
+type OrderStatusChanged struct {
+	Order                 *models.Order        `json:"order"`
+	PreviousStatus        models.OrderStatus   `json:"previous_status"`
+	PreviousPaymentStatus models.PaymentStatus `json:"previous_payment_status"`
+}
+
+func (OrderStatusChanged) EventType() string                { return TypeOrderStatusChanged }
+func (e OrderStatusChanged) Aggregate() (string, uuid.UUID) { return "order", e.Order.ID }
+
+// ProductCreated is published when a product is added to the catalog
+type ProductCreated struct {
+	Product models.ProductResponse `json:"product"`
+}
+
+func (ProductCreated) EventType() string                { return TypeProductCreated }
+func (e ProductCreated) Aggregate() (string, uuid.UUID) { return "product", e.Product.ID }
+
+// ProductUpdated is published when a product is edited
+type ProductUpdated struct {
+	Product models.ProductResponse `json:"product"`
+}
+
+func (ProductUpdated) EventType() string                { return TypeProductUpdated }
+func (e ProductUpdated) Aggregate() (string, uuid.UUID) { return "product", e.Product.ID }
+
+// ProductDeleted is published when a product is removed from the catalog
+type ProductDeleted struct {
+	ProductID uuid.UUID `json:"product_id"`
+	SKU       string    `json:"sku"`
+}
+
+func (ProductDeleted) EventType() string                { return TypeProductDeleted }
+func (e ProductDeleted) Aggregate() (string, uuid.UUID) { return "product", e.ProductID }
+
+// Stock change reasons
+const (
+	StockReasonOrder      = "order"
+	StockReasonAdjustment = "adjustment"
+	StockReasonImport     = "import"
+)
+
+// StockChanged is published whenever the stock level of a product, or of
+// one of its variants, changes
+type StockChanged struct {
+	ProductID     uuid.UUID  `json:"product_id"`
+	VariantID     *uuid.UUID `json:"variant_id,omitempty"` // Set for a variant's stock
+	SKU           string     `json:"sku"`
+	Name          string     `json:"name"`
+	PreviousStock int        `json:"previous_stock"`
+	Stock         int        `json:"stock"`
+	MinStock      int        `json:"min_stock"`
+	Reason        string     `json:"reason"`
+	OrderID       *uuid.UUID `json:"order_id,omitempty"`
+}
+
+func (StockChanged) EventType() string                { return TypeStockChanged }
+func (e StockChanged) Aggregate() (string, uuid.UUID) { return "product", e.ProductID }
+
+// NewStockChanged describes a change of product's stock from previous
+func NewStockChanged(product *models.Product, previous int, reason string) StockChanged {
+	return StockChanged{
+		ProductID:     product.ID,
+		SKU:           product.SKU,
+		Name:          product.Name,
+		PreviousStock: previous,
+		Stock:         product.Stock,
+		MinStock:      product.MinStock,
+		Reason:        reason,
+	}
+}
+
+// NewVariantStockChanged describes a change of variant's stock from
+// previous. Variants have no minimum stock of their own.
+func NewVariantStockChanged(product *models.Product, variant *models.ProductVariant, previous int, reason string) StockChanged {
+	id := variant.ID
+	return StockChanged{
+		ProductID:     product.ID,
+		VariantID:     &id,
+		SKU:           variant.SKU,
+		Name:          product.Name,
+		PreviousStock: previous,
+		Stock:         variant.Stock,
+		Reason:        reason,
+	}
+}
+
+// FellBelowMinimum reports whether this change took a product's stock from
+// above its minimum to at or below it
+func (e StockChanged) FellBelowMinimum() bool {
+	return e.VariantID == nil && e.PreviousStock > e.MinStock && e.Stock <= e.MinStock
+}
+
+// Restocked reports whether this change brought stock back from none
+func (e StockChanged) Restocked() bool {
+	return e.PreviousStock <= 0 && e.Stock > 0
+}
+
+// PriceChanged is published when the price of a product, or of one of its
+// variants, changes
+type PriceChanged struct {
+	ProductID     uuid.UUID  `json:"product_id"`
+	VariantID     *uuid.UUID `json:"variant_id,omitempty"` // Set for a variant's own price
+	SKU           string     `json:"sku"`
+	Name          string     `json:"name"`
+	PreviousPrice int        `json:"previous_price"`
+	Price         int        `json:"price"`
+}
+
+func (PriceChanged) EventType() string                { return TypePriceChanged }
+func (e PriceChanged) Aggregate() (string, uuid.UUID) { return "product", e.ProductID }
+
+// NewPriceChanged describes a change of product's price from previous
+func NewPriceChanged(product *models.Product, previous int) PriceChanged {
+	return PriceChanged{
+		ProductID:     product.ID,
+		SKU:           product.SKU,
+		Name:          product.Name,
+		PreviousPrice: previous,
+		Price:         product.Price,
+	}
+}
+
+// NewVariantPriceChanged describes a change of variant's price from
+// previous; a variant without its own price has the product's
+func NewVariantPriceChanged(product *models.Product, variant *models.ProductVariant, previous int) PriceChanged {
+	id := variant.ID
+	return PriceChanged{
+		ProductID:     product.ID,
+		VariantID:     &id,
+		SKU:           variant.SKU,
+		Name:          product.Name,
+		PreviousPrice: previous,
+		Price:         models.CurrentPrice(product, variant),
+	}
+}
+
+// Dropped reports whether the price went down
+func (e PriceChanged) Dropped() bool {
+	return e.Price < e.PreviousPrice
+}
+
+// Event is a committed event as seen by subscribers
+type Event struct {
+	ID            uuid.UUID       `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// Decode unmarshals the event payload into v, usually the Payload type
+// matching Type
+func (e Event) Decode(v interface{}) error {
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return fmt.Errorf("failed to decode %s event %s: %w", e.Type, e.ID, err)
+	}
+	return nil
+}
+
+// Publish writes payload to the outbox. tx must be the transaction making
+// the change the event describes.
+func Publish(tx *gorm.DB, payload Payload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", payload.EventType(), err)
+	}
+
+	aggregateType, aggregateID := payload.Aggregate()
+	event := models.OutboxEvent{
+		Type:          payload.EventType(),
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID.String(),
+		Payload:       data,
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return err
+	}
+
+	// Delivered by Postgres only when the transaction commits
+	return tx.Exec("SELECT pg_notify(?, ?)", NotifyChannel, event.ID.String()).Error
+}
@@ -0,0 +1,114 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestStockChanged_FellBelowMinimum(t *testing.T) {
+	cases := []struct {
+		previous, stock, min int
+		want                 bool
+	}{
+		{10, 5, 5, true},
+		{10, 2, 5, true},
+		{5, 4, 5, false}, // Already at the minimum before
+		{10, 6, 5, false},
+		{2, 10, 5, false}, // Restocked
+	}
+	for _, tc := range cases {
+		e := StockChanged{PreviousStock: tc.previous, Stock: tc.stock, MinStock: tc.min}
+		if got := e.FellBelowMinimum(); got != tc.want {
+			t.Errorf("%d -> %d (min %d): FellBelowMinimum() = %v, want %v", tc.previous, tc.stock, tc.min, got, tc.want)
+		}
+	}
+}
+
+func TestStockChanged_Variant(t *testing.T) {
+	product := &models.Product{ID: uuid.New(), Name: "T-shirt", MinStock: 5}
+	variant := &models.ProductVariant{ID: uuid.New(), SKU: "TS-M", Stock: 3}
+	e := NewVariantStockChanged(product, variant, 0, StockReasonImport)
+	if e.VariantID == nil || *e.VariantID != variant.ID || e.SKU != "TS-M" {
+		t.Errorf("NewVariantStockChanged() = %+v, want the variant's ID and SKU", e)
+	}
+	if !e.Restocked() {
+		t.Error("0 -> 3: Restocked() = false, want true")
+	}
+
+	e = NewVariantStockChanged(product, &models.ProductVariant{ID: uuid.New()}, 10, StockReasonImport)
+	if e.FellBelowMinimum() {
+		t.Error("variant FellBelowMinimum() = true, want false: variants have no minimum")
+	}
+	if e.Restocked() {
+		t.Error("10 -> 0: Restocked() = true, want false")
+	}
+}
+
+func TestEvent_Decode(t *testing.T) {
+	order := &models.Order{ID: uuid.New(), OrderNumber: "ORD-1"}
+	payload := OrderStatusChanged{Order: order, PreviousStatus: models.OrderStatusPending}
+	if payload.EventType() != TypeOrderStatusChanged {
+		t.Errorf("Unexpected event type %q", payload.EventType())
+	}
+	if kind, id := payload.Aggregate(); kind != "order" || id != order.ID {
+		t.Errorf("Unexpected aggregate %s %s", kind, id)
+	}
+
+	data, _ := json.Marshal(payload)
+	event := Event{ID: uuid.New(), Type: payload.EventType(), Payload: data}
+
+	var decoded OrderStatusChanged
+	if err := event.Decode(&decoded); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.Order.OrderNumber != "ORD-1" || decoded.PreviousStatus != models.OrderStatusPending {
+		t.Errorf("Unexpected decoded payload %+v", decoded)
+	}
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	d := NewDispatcher(nil)
+
+	var calls []string
+	d.Subscribe("orders", func(ctx context.Context, tx *gorm.DB, event Event) error {
+		calls = append(calls, "orders:"+event.Type)
+		return nil
+	}, TypeOrderPlaced)
+	d.Subscribe("all", func(ctx context.Context, tx *gorm.DB, event Event) error {
+		calls = append(calls, "all:"+event.Type)
+		return nil
+	})
+
+	if err := d.deliver(context.Background(), nil, Event{Type: TypeProductUpdated}); err != nil {
+		t.Fatalf("deliver() error = %v", err)
+	}
+	if err := d.deliver(context.Background(), nil, Event{Type: TypeOrderPlaced}); err != nil {
+		t.Fatalf("deliver() error = %v", err)
+	}
+	want := []string{"all:product.updated", "orders:order.placed", "all:order.placed"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("calls = %v, want %v", calls, want)
+			break
+		}
+	}
+
+	d.Subscribe("broken", func(ctx context.Context, tx *gorm.DB, event Event) error {
+		return errors.New("boom")
+	})
+	d.Subscribe("panics", func(ctx context.Context, tx *gorm.DB, event Event) error {
+		panic("unreachable")
+	})
+	if err := d.deliver(context.Background(), nil, Event{Type: TypeStockChanged}); err == nil || err.Error() != "broken: boom" {
+		t.Errorf("Expected the failing subscriber to stop delivery, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Package feed is the live admin activity feed served over Server-Sent
+// Events. Domain events are turned into small feed messages and sent with
+// Postgres NOTIFY, so every server instance receives every message whichever
+// instance dispatched the event. Each instance keeps the latest messages in a
+// bounded buffer so reconnecting clients can resume from Last-Event-ID.
+package feed
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Message types
+const (
+	TypeOrderCreated        = "order.created"
+	TypeOrderStatusChanged  = "order.status_changed"
+	TypeOrderPaymentUpdated = "order.payment_updated"
+	TypeInventoryLow        = "inventory.low"
+)
+
+// Message is one feed entry. ID is stable across instances.
+type Message struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// subscriberBuffer is how many messages a slow client may fall behind by
+// before it is disconnected; it will resume from the replay buffer
+const subscriberBuffer = 64
+
+// Hub fans messages out to the connected clients of one instance
+type Hub struct {
+	mu          sync.Mutex
+	size        int
+	buffer      []Message // Oldest first, at most size entries
+	subscribers map[chan Message]struct{}
+}
+
+func NewHub(size int) *Hub {
+	return &Hub{size: size, subscribers: map[chan Message]struct{}{}}
+}
+
+// Broadcast records msg for replay and sends it to every subscriber
+func (h *Hub) Broadcast(msg Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buffer = append(h.buffer, msg)
+	if len(h.buffer) > h.size {
+		h.buffer = h.buffer[len(h.buffer)-h.size:]
+	}
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- msg:
+		default:
+			// Too slow; closing makes the client reconnect and replay
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the buffered messages after lastEventID and a channel of
+// new ones. complete is false when lastEventID is set but no longer buffered,
+// meaning the client missed messages and should reload. Call cancel when done.
+func (h *Hub) Subscribe(lastEventID string) (replay []Message, messages <-chan Message, complete bool, cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	complete = true
+	if lastEventID != "" {
+		complete = false
+		for i, msg := range h.buffer {
+			if msg.ID == lastEventID {
+				replay = append(replay, h.buffer[i+1:]...)
+				complete = true
+				break
+			}
+		}
+	}
+
+	ch := make(chan Message, subscriberBuffer)
+	h.subscribers[ch] = struct{}{}
+	cancel = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+	return replay, ch, complete, cancel
+}
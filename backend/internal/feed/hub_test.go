@@ -0,0 +1,96 @@
+package feed
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"easycart/internal/events"
+	"easycart/internal/models"
+	"github.com/google/uuid"
+)
+
+func message(i int) Message {
+	return Message{ID: fmt.Sprint(i), Type: TypeOrderCreated, Data: json.RawMessage("{}")}
+}
+
+func TestHubReplaysAfterLastEventID(t *testing.T) {
+	hub := NewHub(3)
+	for i := 1; i <= 4; i++ {
+		hub.Broadcast(message(i))
+	}
+
+	replay, _, complete, cancel := hub.Subscribe("2")
+	defer cancel()
+	if !complete {
+		t.Fatal("expected a complete replay")
+	}
+	if len(replay) != 2 || replay[0].ID != "3" || replay[1].ID != "4" {
+		t.Errorf("unexpected replay %+v", replay)
+	}
+
+	// Message 1 fell out of the buffer
+	if _, _, complete, cancel := hub.Subscribe("1"); complete {
+		t.Error("expected an incomplete replay for an evicted ID")
+	} else {
+		cancel()
+	}
+
+	if replay, _, complete, cancel := hub.Subscribe(""); !complete || len(replay) != 0 {
+		t.Errorf("new subscribers should not replay, got %d messages", len(replay))
+	} else {
+		cancel()
+	}
+}
+
+func TestHubDeliversAndDropsSlowSubscribers(t *testing.T) {
+	hub := NewHub(10)
+	_, messages, _, cancel := hub.Subscribe("")
+	defer cancel()
+
+	hub.Broadcast(message(1))
+	if msg := <-messages; msg.ID != "1" {
+		t.Errorf("got message %s, want 1", msg.ID)
+	}
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		hub.Broadcast(message(i))
+	}
+	drained := 0
+	for range messages {
+		drained++
+	}
+	if drained != subscriberBuffer {
+		t.Errorf("drained %d messages before close, want %d", drained, subscriberBuffer)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	order := &models.Order{ID: uuid.New(), OrderNumber: "ORD-1", Status: models.OrderStatusProcessing, PaymentStatus: models.PaymentStatusPaid}
+	payload, _ := json.Marshal(events.OrderStatusChanged{
+		Order:                 order,
+		PreviousStatus:        models.OrderStatusPending,
+		PreviousPaymentStatus: models.PaymentStatusPending,
+	})
+	event := events.Event{ID: uuid.New(), Type: events.TypeOrderStatusChanged, Payload: payload}
+
+	messages, err := Messages(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	if messages[0].Type != TypeOrderStatusChanged || messages[1].Type != TypeOrderPaymentUpdated {
+		t.Errorf("unexpected types %s, %s", messages[0].Type, messages[1].Type)
+	}
+	if messages[0].ID == messages[1].ID {
+		t.Error("messages from one event need distinct IDs")
+	}
+
+	payload, _ = json.Marshal(events.StockChanged{Stock: 8, PreviousStock: 9, MinStock: 5})
+	messages, err = Messages(events.Event{ID: uuid.New(), Type: events.TypeStockChanged, Payload: payload})
+	if err != nil || len(messages) != 0 {
+		t.Errorf("stock above minimum should not produce messages, got %d (%v)", len(messages), err)
+	}
+}
@@ -0,0 +1,149 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"easycart/internal/database"
+	"easycart/internal/events"
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NotifyChannel carries feed messages between instances
+const NotifyChannel = "admin_feed"
+
+// OrderSummary is the data of order feed messages
+type OrderSummary struct {
+	OrderID               uuid.UUID            `json:"order_id"`
+	OrderNumber           string               `json:"order_number"`
+	CustomerName          string               `json:"customer_name"`
+	Total                 int                  `json:"total"`
+	ItemCount             int                  `json:"item_count,omitempty"`
+	Status                models.OrderStatus   `json:"status"`
+	PreviousStatus        models.OrderStatus   `json:"previous_status,omitempty"`
+	PaymentStatus         models.PaymentStatus `json:"payment_status"`
+	PreviousPaymentStatus models.PaymentStatus `json:"previous_payment_status,omitempty"`
+	CreatedAt             time.Time            `json:"created_at"`
+}
+
+// StockSummary is the data of inventory.low messages
+type StockSummary struct {
+	ProductID uuid.UUID `json:"product_id"`
+	SKU       string    `json:"sku"`
+	Name      string    `json:"name"`
+	Stock     int       `json:"stock"`
+	MinStock  int       `json:"min_stock"`
+}
+
+func summarizeOrder(order *models.Order) OrderSummary {
+	count := 0
+	for _, item := range order.Items {
+		count += item.Quantity
+	}
+	return OrderSummary{
+		OrderID:       order.ID,
+		OrderNumber:   order.OrderNumber,
+		CustomerName:  order.CustomerName,
+		Total:         order.Total,
+		ItemCount:     count,
+		Status:        order.Status,
+		PaymentStatus: order.PaymentStatus,
+		CreatedAt:     order.CreatedAt,
+	}
+}
+
+// Messages turns a domain event into the feed messages it produces, if any
+func Messages(event events.Event) ([]Message, error) {
+	var messages []Message
+	add := func(id, msgType string, data interface{}) error {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, Message{ID: id, Type: msgType, Data: raw})
+		return nil
+	}
+
+	switch event.Type {
+	case events.TypeOrderPlaced:
+		var placed events.OrderPlaced
+		if err := event.Decode(&placed); err != nil {
+			return nil, err
+		}
+		return messages, add(event.ID.String(), TypeOrderCreated, summarizeOrder(placed.Order))
+
+	case events.TypeOrderStatusChanged:
+		var changed events.OrderStatusChanged
+		if err := event.Decode(&changed); err != nil {
+			return nil, err
+		}
+		summary := summarizeOrder(changed.Order)
+		summary.PreviousStatus = changed.PreviousStatus
+		summary.PreviousPaymentStatus = changed.PreviousPaymentStatus
+		if changed.Order.Status != changed.PreviousStatus {
+			if err := add(event.ID.String(), TypeOrderStatusChanged, summary); err != nil {
+				return nil, err
+			}
+		}
+		if changed.Order.PaymentStatus != changed.PreviousPaymentStatus {
+			if err := add(event.ID.String()+"-payment", TypeOrderPaymentUpdated, summary); err != nil {
+				return nil, err
+			}
+		}
+		return messages, nil
+
+	case events.TypeStockChanged:
+		var changed events.StockChanged
+		if err := event.Decode(&changed); err != nil {
+			return nil, err
+		}
+		if !changed.FellBelowMinimum() {
+			return nil, nil
+		}
+		return messages, add(event.ID.String(), TypeInventoryLow, StockSummary{
+			ProductID: changed.ProductID,
+			SKU:       changed.SKU,
+			Name:      changed.Name,
+			Stock:     changed.Stock,
+			MinStock:  changed.MinStock,
+		})
+	}
+	return nil, nil
+}
+
+// Subscribe sends feed messages for domain events to every instance. The
+// notifications go out when the dispatching transaction commits.
+func Subscribe(d *events.Dispatcher) {
+	d.Subscribe("feed", func(ctx context.Context, tx *gorm.DB, event events.Event) error {
+		messages, err := Messages(event)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			payload, err := json.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			if err := tx.Exec("SELECT pg_notify(?, ?)", NotifyChannel, string(payload)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}, events.TypeOrderPlaced, events.TypeOrderStatusChanged, events.TypeStockChanged)
+}
+
+// Listen feeds messages from every instance into hub until ctx is cancelled
+func Listen(ctx context.Context, dsn string, hub *Hub) {
+	database.Listen(ctx, dsn, NotifyChannel, nil, func(payload string) {
+		var msg Message
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			log.Printf("Ignoring malformed feed message: %v", err)
+			return
+		}
+		hub.Broadcast(msg)
+	})
+}
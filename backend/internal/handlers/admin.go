@@ -3,7 +3,10 @@ package handlers
 import (
 	"net/http"
 
+	"easycart/internal/audit"
+	"easycart/internal/middleware"
 	"easycart/internal/models"
+	"easycart/internal/pagination"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
@@ -17,42 +20,44 @@ func NewAdminHandler(db *gorm.DB) *AdminHandler {
 	return &AdminHandler{db: db}
 }
 
-// GetUsers returns all users (admin/manager only)
+// GetUsers returns all users; callers with only customers.read see customers only
 func (h *AdminHandler) GetUsers(c echo.Context) error {
-	user, ok := c.Get("user").(*models.User)
-	if !ok || !user.IsAdmin() {
-		return echo.NewHTTPError(http.StatusForbidden, "Only admin can view users")
+	params, err := pagination.Parse(c, pagination.NewestFirst("users", 50, 100))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	var users []models.User
-	if err := h.db.Find(&users).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get users: "+err.Error())
+	query := h.db.Preload("StaffRole")
+	if !middleware.HasPermission(c, models.PermissionUsersRead) {
+		query = query.Where("role = ?", models.UserRoleCustomer)
+	} else if role := c.QueryParam("role"); role != "" {
+		query = query.Where("role = ?", role)
 	}
 
-	var response []models.UserResponse
-	for _, u := range users {
-		response = append(response, u.ToResponse())
+	page, err := pagination.Find[models.User](c, query, params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get users: "+err.Error())
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"users": response,
-		"total": len(response),
-	})
+	return c.JSON(http.StatusOK, pagination.Map(page, func(u models.User) models.UserResponse {
+		return u.ToResponse()
+	}))
 }
 
 type CreateUserRequest struct {
-	Email     string          `json:"email" validate:"required,email"`
-	Password  string          `json:"password" validate:"required,min=6"`
-	FirstName string          `json:"first_name" validate:"required"`
-	LastName  string          `json:"last_name" validate:"required"`
-	Role      models.UserRole `json:"role" validate:"required"`
+	Email       string          `json:"email" validate:"required,email"`
+	Password    string          `json:"password" validate:"required,min=6"`
+	FirstName   string          `json:"first_name" validate:"required"`
+	LastName    string          `json:"last_name" validate:"required"`
+	Role        models.UserRole `json:"role" validate:"required"`
+	StaffRoleID *uuid.UUID      `json:"staff_role_id,omitempty"`
 }
 
-// CreateUser creates a new user (admin only)
+// CreateUser creates a new user
 func (h *AdminHandler) CreateUser(c echo.Context) error {
-	user, ok := c.Get("user").(*models.User)
-	if !ok || !user.IsAdmin() {
-		return echo.NewHTTPError(http.StatusForbidden, "Only admin can create users")
+	currentUser, ok := c.Get("user").(*models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid user context")
 	}
 
 	var req CreateUserRequest
@@ -68,6 +73,9 @@ func (h *AdminHandler) CreateUser(c echo.Context) error {
 	if req.Role != models.UserRoleAdmin && req.Role != models.UserRoleManager && req.Role != models.UserRoleCustomer {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid role")
 	}
+	if err := checkRoleChange(currentUser, "", req.Role); err != nil {
+		return err
+	}
 
 	// Check if email already exists
 	var existingUser models.User
@@ -76,18 +84,28 @@ func (h *AdminHandler) CreateUser(c echo.Context) error {
 	}
 
 	newUser := models.User{
-		Email:     req.Email,
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Role:      req.Role,
-		IsActive:  true,
+		Email:       req.Email,
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Role:        req.Role,
+		IsActive:    true,
+		StaffRoleID: req.StaffRoleID,
+	}
+	if err := checkGrantedPermissions(h.db, c, &newUser); err != nil {
+		return err
 	}
 
 	if err := newUser.HashPassword(req.Password); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash password")
 	}
 
-	if err := h.db.Create(&newUser).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "user.create", "user", newUser.ID, nil, newUser.ToResponse())
+	})
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create user: "+err.Error())
 	}
 
@@ -100,13 +118,16 @@ type UpdateUserRequest struct {
 	LastName  string          `json:"last_name"`
 	Role      models.UserRole `json:"role"`
 	IsActive  *bool           `json:"is_active"`
+
+	// StaffRoleID assigns a custom role; send the nil UUID to clear it
+	StaffRoleID *uuid.UUID `json:"staff_role_id,omitempty"`
 }
 
-// UpdateUser updates a user (admin only)
+// UpdateUser updates a user
 func (h *AdminHandler) UpdateUser(c echo.Context) error {
 	currentUser, ok := c.Get("user").(*models.User)
-	if !ok || !currentUser.IsAdmin() {
-		return echo.NewHTTPError(http.StatusForbidden, "Only admin can update users")
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid user context")
 	}
 
 	userID, err := uuid.Parse(c.Param("id"))
@@ -130,11 +151,19 @@ func (h *AdminHandler) UpdateUser(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user: "+err.Error())
 	}
+	if err := checkCanManage(currentUser, &user); err != nil {
+		return err
+	}
 
 	// Prevent admin from deactivating themselves
 	if user.ID == currentUser.ID && req.IsActive != nil && !*req.IsActive {
 		return echo.NewHTTPError(http.StatusBadRequest, "Cannot deactivate yourself")
 	}
+	// Nor change their own role, which could only ever widen their access
+	if user.ID == currentUser.ID && ((req.Role != "" && req.Role != user.Role) || req.StaffRoleID != nil) {
+		return echo.NewHTTPError(http.StatusForbidden, "Cannot change your own role")
+	}
+	before := user.ToResponse()
 
 	// Update fields
 	if req.Email != "" {
@@ -155,24 +184,46 @@ func (h *AdminHandler) UpdateUser(c echo.Context) error {
 		if req.Role != models.UserRoleAdmin && req.Role != models.UserRoleManager && req.Role != models.UserRoleCustomer {
 			return echo.NewHTTPError(http.StatusBadRequest, "Invalid role")
 		}
+		if err := checkRoleChange(currentUser, user.Role, req.Role); err != nil {
+			return err
+		}
 		user.Role = req.Role
 	}
 	if req.IsActive != nil {
 		user.IsActive = *req.IsActive
 	}
+	if req.StaffRoleID != nil {
+		if *req.StaffRoleID == uuid.Nil {
+			user.StaffRoleID = nil
+		} else {
+			user.StaffRoleID = req.StaffRoleID
+		}
+		user.StaffRole = nil
+	}
+	if req.Role != "" || req.IsActive != nil || req.StaffRoleID != nil {
+		if err := checkGrantedPermissions(h.db, c, &user); err != nil {
+			return err
+		}
+	}
 
-	if err := h.db.Save(&user).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "user.update", "user", user.ID, before, user.ToResponse())
+	})
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update user: "+err.Error())
 	}
 
 	return c.JSON(http.StatusOK, user.ToResponse())
 }
 
-// DeleteUser soft deletes a user (admin only)
+// DeleteUser soft deletes a user
 func (h *AdminHandler) DeleteUser(c echo.Context) error {
 	currentUser, ok := c.Get("user").(*models.User)
-	if !ok || !currentUser.IsAdmin() {
-		return echo.NewHTTPError(http.StatusForbidden, "Only admin can delete users")
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid user context")
 	}
 
 	userID, err := uuid.Parse(c.Param("id"))
@@ -192,12 +243,55 @@ func (h *AdminHandler) DeleteUser(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user: "+err.Error())
 	}
+	if err := checkCanManage(currentUser, &user); err != nil {
+		return err
+	}
 
-	if err := h.db.Delete(&user).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "user.delete", "user", user.ID, user.ToResponse(), nil)
+	})
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete user: "+err.Error())
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "User deleted successfully",
 	})
-}
\ No newline at end of file
+}
+
+// checkRoleChange only lets admins grant or revoke the admin role
+func checkRoleChange(currentUser *models.User, from, to models.UserRole) error {
+	if from == to || currentUser.Role == models.UserRoleAdmin {
+		return nil
+	}
+	if from == models.UserRoleAdmin || to == models.UserRoleAdmin {
+		return echo.NewHTTPError(http.StatusForbidden, "Only admins can grant or revoke the admin role")
+	}
+	return nil
+}
+
+// checkCanManage keeps non-admins off admin accounts entirely: changing an
+// admin's email, say, would let a password reset take the account over
+func checkCanManage(currentUser, user *models.User) error {
+	if user.IsAdmin() && !currentUser.IsAdmin() {
+		return echo.NewHTTPError(http.StatusForbidden, "Only admins can manage admin accounts")
+	}
+	return nil
+}
+
+// checkGrantedPermissions checks that user, with its role and staff role as
+// they are about to be saved, holds nothing the caller does not. A manager
+// without a staff role gets DefaultManagerPermissions, so that is checked too.
+func checkGrantedPermissions(db *gorm.DB, c echo.Context, user *models.User) error {
+	permissions, err := user.EffectivePermissions(db)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid staff role")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get role: "+err.Error())
+	}
+	return requireHeld(c, permissions)
+}
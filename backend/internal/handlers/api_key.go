@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"easycart/internal/audit"
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type APIKeyHandler struct {
+	db *gorm.DB
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string              `json:"name" validate:"required"`
+	Scopes    []models.Permission `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time          `json:"expires_at,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKey models.APIKey `json:"api_key"`
+	Key    string        `json:"key"` // Plaintext key, only returned once
+}
+
+func NewAPIKeyHandler(db *gorm.DB) *APIKeyHandler {
+	return &APIKeyHandler{db: db}
+}
+
+// GetAPIKeys returns all API keys, including revoked and expired ones
+func (h *APIKeyHandler) GetAPIKeys(c echo.Context) error {
+	var keys []models.APIKey
+	if err := h.db.Preload("User").Order("created_at DESC").Find(&keys).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get API keys: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"api_keys": keys,
+		"total":    len(keys),
+	})
+}
+
+// CreateAPIKey issues a new API key owned by the caller
+func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid user context")
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if err := validatePermissions(req.Scopes); err != nil {
+		return err
+	}
+
+	// A key must not grant more than its creator holds
+	if err := requireHeld(c, req.Scopes); err != nil {
+		return err
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Expiry must be in the future")
+	}
+
+	apiKey := models.APIKey{
+		UserID:    user.ID,
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	plaintext, err := apiKey.GenerateAPIKey()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate API key")
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&apiKey).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "api_key.create", "api_key", apiKey.ID, nil, apiKey)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create API key: "+err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, CreateAPIKeyResponse{
+		APIKey: apiKey,
+		Key:    plaintext,
+	})
+}
+
+// RevokeAPIKey revokes an API key; the record is kept for auditing
+func (h *APIKeyHandler) RevokeAPIKey(c echo.Context) error {
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid API key ID")
+	}
+
+	var apiKey models.APIKey
+	if err := h.db.First(&apiKey, keyID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get API key: "+err.Error())
+	}
+
+	if apiKey.RevokedAt == nil {
+		before := apiKey
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&apiKey).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, "api_key.revoke", "api_key", apiKey.ID, before, apiKey)
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke API key: "+err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "API key revoked successfully",
+	})
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"easycart/internal/audit"
+	"easycart/internal/models"
+	"easycart/internal/pagination"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// AttributeHandler manages attribute definitions, the specifications
+// products and categories can be given values for
+type AttributeHandler struct {
+	db *gorm.DB
+}
+
+func NewAttributeHandler(db *gorm.DB) *AttributeHandler {
+	return &AttributeHandler{db: db}
+}
+
+type CreateAttributeRequest struct {
+	Code       string               `json:"code" validate:"required"`
+	Name       string               `json:"name" validate:"required"`
+	Type       models.AttributeType `json:"type" validate:"required"`
+	Unit       string               `json:"unit"`
+	Options    []string             `json:"options,omitempty"`
+	Filterable bool                 `json:"filterable"`
+	Position   int                  `json:"position"`
+}
+
+// UpdateAttributeRequest changes the fields that are set. The code stays,
+// since imports and storefront filters name attributes by it.
+type UpdateAttributeRequest struct {
+	Name       *string               `json:"name,omitempty"`
+	Type       *models.AttributeType `json:"type,omitempty"`
+	Unit       *string               `json:"unit,omitempty"`
+	Options    *[]string             `json:"options,omitempty"`
+	Filterable *bool                 `json:"filterable,omitempty"`
+	Position   *int                  `json:"position,omitempty"`
+}
+
+// attributePages lists attributes in display order
+var attributePages = pagination.Options{
+	Key:          "attributes",
+	DefaultLimit: 100,
+	MaxLimit:     500,
+	Keys: []pagination.Key{
+		{Column: "position", Field: "Position", Type: "integer"},
+		{Column: "code", Field: "Code", Type: "text"},
+	},
+}
+
+func (h *AttributeHandler) GetAttributes(c echo.Context) error {
+	params, err := pagination.Parse(c, attributePages)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	query := h.db.Model(&models.AttributeDefinition{})
+	if c.QueryParam("filterable") == "true" {
+		query = query.Where("filterable = ?", true)
+	}
+
+	page, err := pagination.Find[models.AttributeDefinition](c, query, params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch attributes")
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+func (h *AttributeHandler) GetAttribute(c echo.Context) error {
+	attribute, err := h.findAttribute(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, attribute)
+}
+
+func (h *AttributeHandler) CreateAttribute(c echo.Context) error {
+	var req CreateAttributeRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	attribute := models.AttributeDefinition{
+		Code:       req.Code,
+		Name:       req.Name,
+		Type:       req.Type,
+		Unit:       req.Unit,
+		Options:    req.Options,
+		Filterable: req.Filterable,
+		Position:   req.Position,
+	}
+	if err := attribute.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var count int64
+	if err := h.db.Model(&models.AttributeDefinition{}).Where("code = ?", attribute.Code).Count(&count).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check code")
+	}
+	if count > 0 {
+		return echo.NewHTTPError(http.StatusConflict, "code is already used by another attribute")
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attribute).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "attribute.create", "attribute", attribute.ID, nil, attribute)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create attribute")
+	}
+
+	return c.JSON(http.StatusCreated, attribute)
+}
+
+// UpdateAttribute changes a definition. The type of an attribute with
+// values cannot change, nor can enum options that values use be removed.
+func (h *AttributeHandler) UpdateAttribute(c echo.Context) error {
+	attribute, err := h.findAttribute(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	before := *attribute
+
+	var req UpdateAttributeRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Name != nil {
+		attribute.Name = *req.Name
+	}
+	if req.Type != nil {
+		attribute.Type = *req.Type
+	}
+	if req.Unit != nil {
+		attribute.Unit = *req.Unit
+	}
+	if req.Options != nil {
+		attribute.Options = *req.Options
+	}
+	if req.Filterable != nil {
+		attribute.Filterable = *req.Filterable
+	}
+	if req.Position != nil {
+		attribute.Position = *req.Position
+	}
+	if err := attribute.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	values := h.db.Model(&models.AttributeValue{}).Where("attribute_id = ?", attribute.ID)
+	if attribute.Type != before.Type {
+		var count int64
+		if err := values.Count(&count).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to check attribute values")
+		}
+		if count > 0 {
+			return echo.NewHTTPError(http.StatusConflict, "the type of an attribute with values cannot change")
+		}
+	} else if attribute.Type == models.AttributeEnum {
+		var used []string
+		query := values.Distinct("text_value")
+		if len(attribute.Options) > 0 {
+			query = query.Where("text_value NOT IN ?", attribute.Options)
+		}
+		if err := query.Pluck("text_value", &used).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to check attribute values")
+		}
+		if len(used) > 0 {
+			return echo.NewHTTPError(http.StatusConflict, "options still in use: "+strings.Join(used, ", "))
+		}
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(attribute).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "attribute.update", "attribute", attribute.ID, before, attribute)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update attribute")
+	}
+
+	return c.JSON(http.StatusOK, attribute)
+}
+
+// DeleteAttribute deletes a definition with all its values
+func (h *AttributeHandler) DeleteAttribute(c echo.Context) error {
+	attribute, err := h.findAttribute(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(attribute).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "attribute.delete", "attribute", attribute.ID, attribute, nil)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete attribute")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *AttributeHandler) findAttribute(id string) (*models.AttributeDefinition, error) {
+	attributeID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid attribute ID")
+	}
+	var attribute models.AttributeDefinition
+	if err := h.db.Where("id = ?", attributeID).First(&attribute).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "attribute not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch attribute")
+	}
+	return &attribute, nil
+}
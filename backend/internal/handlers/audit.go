@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"easycart/internal/models"
+	"easycart/internal/pagination"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// auditExportLimit caps a single CSV export. A longer export is cut short
+// with an X-Export-Truncated header and X-Export-Next-Cursor holding the
+// cursor that continues it.
+const auditExportLimit = 50000
+
+// auditExportOrder is the export's order, oldest first. Events can share a
+// timestamp, so the cursor carries the ID as well.
+var auditExportOrder = pagination.Options{
+	Key:          "events",
+	DefaultLimit: auditExportLimit,
+	MaxLimit:     auditExportLimit,
+	Keys:         pagination.Newest,
+}
+
+type AuditHandler struct {
+	db *gorm.DB
+}
+
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	return &AuditHandler{db: db}
+}
+
+// GetAuditEvents lists audit events, newest first, filtered by actor_id,
+// entity_type, entity_id, action and a from/to time range
+func (h *AuditHandler) GetAuditEvents(c echo.Context) error {
+	query, err := h.filteredQuery(c)
+	if err != nil {
+		return err
+	}
+
+	params, err := pagination.Parse(c, pagination.NewestFirst("events", 50, 100))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	page, err := pagination.Find[models.AuditEvent](c, query, params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch audit events")
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// ExportAuditEvents streams the filtered audit events as CSV for compliance
+// reviews, oldest first, continuing after cursor when one is given
+func (h *AuditHandler) ExportAuditEvents(c echo.Context) error {
+	query, err := h.filteredQuery(c)
+	if err != nil {
+		return err
+	}
+
+	params, err := pagination.Parse(c, auditExportOrder)
+	if err != nil || (params.Cursor != nil && params.Cursor.Before) {
+		return echo.NewHTTPError(http.StatusBadRequest, pagination.ErrInvalidCursor.Error())
+	}
+	if params.Cursor != nil {
+		query = query.Where("(created_at, id) > (CAST(? AS timestamptz), CAST(? AS uuid))",
+			params.Cursor.Values[0], params.Cursor.Values[1])
+	}
+	query = query.Model(&models.AuditEvent{}).Order("created_at ASC, id ASC")
+
+	// The last event exported and the one after it: if both exist, the next
+	// export continues after the first
+	var last []models.AuditEvent
+	err = query.Session(&gorm.Session{}).Select("created_at", "id").
+		Offset(auditExportLimit - 1).Limit(2).Find(&last).Error
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to export audit events")
+	}
+
+	rows, err := query.Limit(auditExportLimit).Rows()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to export audit events")
+	}
+	defer rows.Close()
+
+	filename := "audit-" + time.Now().Format("20060102-150405") + ".csv"
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	if len(last) == 2 {
+		next := pagination.Cursor{Values: []string{
+			last[0].CreatedAt.UTC().Format(time.RFC3339Nano),
+			last[0].ID.String(),
+		}}
+		c.Response().Header().Set("X-Export-Truncated", "true")
+		c.Response().Header().Set("X-Export-Next-Cursor", next.Encode())
+	}
+	c.Response().WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(c.Response())
+	if err := writer.Write([]string{
+		"id", "created_at", "actor_id", "impersonator_id", "api_key_id", "action",
+		"entity_type", "entity_id", "changes", "metadata", "ip", "user_agent", "request_id",
+	}); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var event models.AuditEvent
+		// The status is already sent, so a failure can only end the file early
+		if err := h.db.ScanRows(rows, &event); err != nil {
+			return err
+		}
+
+		changes, _ := json.Marshal(event.Changes)
+		metadata, _ := json.Marshal(event.Metadata)
+		writer.Write([]string{
+			event.ID.String(),
+			event.CreatedAt.UTC().Format(time.RFC3339),
+			uuidString(event.ActorID),
+			uuidString(event.ImpersonatorID),
+			uuidString(event.APIKeyID),
+			event.Action,
+			event.EntityType,
+			event.EntityID,
+			string(changes),
+			string(metadata),
+			event.IP,
+			event.UserAgent,
+			event.RequestID,
+		})
+		if err := writer.Error(); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (h *AuditHandler) filteredQuery(c echo.Context) (*gorm.DB, error) {
+	query := h.db
+
+	if actorID := c.QueryParam("actor_id"); actorID != "" {
+		id, err := uuid.Parse(actorID)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid actor_id")
+		}
+		query = query.Where("actor_id = ? OR impersonator_id = ?", id, id)
+	}
+	if entityType := c.QueryParam("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.QueryParam("entity_id"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if action := c.QueryParam("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if from := c.QueryParam("from"); from != "" {
+		t, err := parseTimeParam(from)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid from: use RFC 3339 or YYYY-MM-DD")
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if to := c.QueryParam("to"); to != "" {
+		t, err := parseTimeParam(to)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid to: use RFC 3339 or YYYY-MM-DD")
+		}
+		// A bare date includes the whole day
+		if len(to) == len("2006-01-02") {
+			t = t.Add(24 * time.Hour)
+		}
+		query = query.Where("created_at < ?", t)
+	}
+
+	return query, nil
+}
+
+// parseTimeParam accepts RFC 3339 timestamps or plain dates (UTC midnight)
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func uuidString(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
@@ -136,6 +136,11 @@ func (h *AuthHandler) GetProfile(c echo.Context) error {
 }
 
 func (h *AuthHandler) generateToken(userID uuid.UUID, email string) (string, error) {
+	return generateSessionToken(h.JWTSecret, userID, email)
+}
+
+// generateSessionToken issues the standard 24h login token
+func generateSessionToken(jwtSecret string, userID uuid.UUID, email string) (string, error) {
 	claims := &middleware.JWTClaims{
 		UserID: userID,
 		Email:  email,
@@ -146,5 +151,5 @@ func (h *AuthHandler) generateToken(userID uuid.UUID, email string) (string, err
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(h.JWTSecret))
+	return token.SignedString([]byte(jwtSecret))
 }
\ No newline at end of file
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// CartHandler serves the signed-in customer's cart under /me
+type CartHandler struct {
+	db *gorm.DB
+}
+
+func NewCartHandler(db *gorm.DB) *CartHandler {
+	return &CartHandler{db: db}
+}
+
+type CartItemRequest struct {
+	ProductID uuid.UUID  `json:"product_id" validate:"required"`
+	VariantID *uuid.UUID `json:"variant_id,omitempty"`
+	Quantity  int        `json:"quantity" validate:"required,min=1"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity" validate:"required,min=1"`
+}
+
+// cartLine is a cart item with its price
+type cartLine struct {
+	models.CartItem
+	UnitPrice int `json:"unit_price"`
+	Total     int `json:"total"`
+}
+
+type cartResponse struct {
+	ID              uuid.UUID  `json:"id"`
+	Items           []cartLine `json:"items"`
+	Subtotal        int        `json:"subtotal"`
+	SubtotalDisplay string     `json:"subtotal_display"`
+}
+
+func (h *CartHandler) GetCart(c echo.Context) error {
+	return h.respond(c, http.StatusOK)
+}
+
+// AddCartItem adds a quantity of a product or variant, on the line the cart
+// already has for it if any
+func (h *CartHandler) AddCartItem(c echo.Context) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	var req CartItemRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if _, _, err := models.FindPurchasable(h.db, req.ProductID, req.VariantID); err != nil {
+		if errors.Is(err, models.ErrProductUnavailable) || errors.Is(err, models.ErrVariantMismatch) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch product")
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		_, err := models.AddToCart(tx, userID, req.ProductID, req.VariantID, req.Quantity)
+		return err
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to add item")
+	}
+
+	return h.respond(c, http.StatusOK)
+}
+
+// UpdateCartItem sets the quantity of a line
+func (h *CartHandler) UpdateCartItem(c echo.Context) error {
+	var req UpdateCartItemRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Quantity > models.MaxCartQuantity {
+		return echo.NewHTTPError(http.StatusBadRequest, "quantity is too large")
+	}
+
+	query, err := h.itemQuery(c)
+	if err != nil {
+		return err
+	}
+	result := query.Update("quantity", req.Quantity)
+	if result.Error != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update item")
+	}
+	if result.RowsAffected == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "item not found")
+	}
+
+	return h.respond(c, http.StatusOK)
+}
+
+func (h *CartHandler) RemoveCartItem(c echo.Context) error {
+	query, err := h.itemQuery(c)
+	if err != nil {
+		return err
+	}
+	result := query.Delete(&models.CartItem{})
+	if result.Error != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to remove item")
+	}
+	if result.RowsAffected == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "item not found")
+	}
+
+	return h.respond(c, http.StatusOK)
+}
+
+// itemQuery selects the cart item named by the id parameter in the
+// signed-in customer's cart
+func (h *CartHandler) itemQuery(c echo.Context) (*gorm.DB, error) {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid item ID")
+	}
+	return h.db.Model(&models.CartItem{}).
+		Where("id = ? AND cart_id IN (SELECT id FROM carts WHERE user_id = ?)", itemID, userID), nil
+}
+
+// respond returns the signed-in customer's cart with its prices
+func (h *CartHandler) respond(c echo.Context, status int) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	cart, err := models.CartFor(h.db, userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch cart")
+	}
+	var items []models.CartItem
+	err = h.db.Preload("Product.Images").Preload("Variant").
+		Where("cart_id = ?", cart.ID).Order("created_at ASC, id").Find(&items).Error
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch cart")
+	}
+
+	response := cartResponse{ID: cart.ID, Items: []cartLine{}}
+	for _, item := range items {
+		line := cartLine{CartItem: item, UnitPrice: item.UnitPrice()}
+		line.Total = line.UnitPrice * item.Quantity
+		response.Items = append(response.Items, line)
+		response.Subtotal += line.Total
+	}
+	response.SubtotalDisplay = models.FormatPrice(response.Subtotal)
+
+	return c.JSON(status, response)
+}
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
 
+	"easycart/internal/audit"
 	"easycart/internal/models"
+	"easycart/internal/pagination"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
@@ -19,6 +23,9 @@ type CreateCategoryRequest struct {
 	Name        string `json:"name" validate:"required"`
 	Description string `json:"description"`
 	ImageID     *uuid.UUID `json:"image_id,omitempty"`
+	ParentID    *uuid.UUID `json:"parent_id,omitempty"`
+	// Attributes are values for the products in the category, by code
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
 }
 
 type UpdateCategoryRequest struct {
@@ -26,23 +33,53 @@ type UpdateCategoryRequest struct {
 	Description string     `json:"description"`
 	ImageID     *uuid.UUID `json:"image_id,omitempty"`
 	IsActive    *bool      `json:"is_active,omitempty"`
+	// Attributes sets the given values by code; null removes one
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// categoryResponse is a category with the attribute values it gives its
+// products
+type categoryResponse struct {
+	models.Category
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// MoveCategoryRequest places a category below ParentID, or at the roots
+// when it is null, at Position among its siblings; without a position it
+// goes last
+type MoveCategoryRequest struct {
+	ParentID *uuid.UUID `json:"parent_id"`
+	Position *int       `json:"position,omitempty"`
 }
 
 func NewCategoryHandler(db *gorm.DB) *CategoryHandler {
 	return &CategoryHandler{db: db}
 }
 
+// categoryPages lists categories by name. Menus and pickers show every
+// category, so a page holds a whole typical catalog.
+var categoryPages = pagination.Options{
+	Key:          "categories",
+	DefaultLimit: 100,
+	MaxLimit:     500,
+	Keys: []pagination.Key{
+		{Column: "name", Field: "Name", Type: "text"},
+		{Column: "id", Field: "ID", Type: "uuid"},
+	},
+}
+
 func (h *CategoryHandler) GetCategories(c echo.Context) error {
-	db := h.db
+	params, err := pagination.Parse(c, categoryPages)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
-	var categories []models.Category
-	if err := db.Order("name ASC").Find(&categories).Error; err != nil {
+	page, err := pagination.Find[models.Category](c, h.db, params)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch categories")
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"categories": categories,
-	})
+	return c.JSON(http.StatusOK, page)
 }
 
 func (h *CategoryHandler) GetCategory(c echo.Context) error {
@@ -63,7 +100,7 @@ func (h *CategoryHandler) GetCategory(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch category")
 	}
 
-	return c.JSON(http.StatusOK, category)
+	return h.respond(c, http.StatusOK, category)
 }
 
 func (h *CategoryHandler) CreateCategory(c echo.Context) error {
@@ -102,6 +139,17 @@ func (h *CategoryHandler) CreateCategory(c echo.Context) error {
 		}
 	}
 
+	var parent *models.Category
+	if req.ParentID != nil {
+		parent = &models.Category{}
+		if err := db.Where("id = ?", *req.ParentID).First(parent).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return echo.NewHTTPError(http.StatusBadRequest, "parent category not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch parent category")
+		}
+	}
+
 	category := models.Category{
 		Name:        req.Name,
 		Slug:        slug,
@@ -109,12 +157,42 @@ func (h *CategoryHandler) CreateCategory(c echo.Context) error {
 		ImageURL:    imageURL,
 		IsActive:    true,
 	}
+	category.PlaceUnder(parent)
 
-	if err := db.Create(&category).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// The parent may have moved since it was read
+		if parent != nil {
+			if err := models.LockCategoryTree(tx); err != nil {
+				return err
+			}
+			if err := tx.Clauses(lockForUpdate).Where("id = ?", parent.ID).First(parent).Error; err != nil {
+				return err
+			}
+			category.PlaceUnder(parent)
+		}
+
+		position, err := models.NextCategoryPosition(tx, category.ParentID)
+		if err != nil {
+			return err
+		}
+		category.Position = position
+		if err := tx.Create(&category).Error; err != nil {
+			return err
+		}
+		if _, err := models.SetCategoryAttributes(tx, category.ID, req.Attributes); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "category.create", "category", category.ID, nil, category)
+	})
+	var attributeErr *models.AttributeError
+	if errors.As(err, &attributeErr) {
+		return echo.NewHTTPError(http.StatusBadRequest, attributeErr.Error())
+	}
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create category")
 	}
 
-	return c.JSON(http.StatusCreated, category)
+	return h.respond(c, http.StatusCreated, category)
 }
 
 func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
@@ -140,6 +218,7 @@ func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch category")
 	}
+	before := category
 
 	// Update fields
 	if req.Name != "" {
@@ -175,13 +254,30 @@ func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
 		category.IsActive = *req.IsActive
 	}
 
-	if err := db.Save(&category).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&category).Error; err != nil {
+			return err
+		}
+		if _, err := models.SetCategoryAttributes(tx, category.ID, req.Attributes); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "category.update", "category", category.ID, before, category)
+	})
+	var attributeErr *models.AttributeError
+	if errors.As(err, &attributeErr) {
+		return echo.NewHTTPError(http.StatusBadRequest, attributeErr.Error())
+	}
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update category")
 	}
 
-	return c.JSON(http.StatusOK, category)
+	return h.respond(c, http.StatusOK, category)
 }
 
+// DeleteCategory deletes a category. One with subcategories or products
+// needs reassign_to: the ID of a category to move them to, or "parent" for
+// the deleted category's parent, which for a root makes its children roots
+// and its products uncategorized.
 func (h *CategoryHandler) DeleteCategory(c echo.Context) error {
 	categoryID := c.Param("id")
 
@@ -190,26 +286,201 @@ func (h *CategoryHandler) DeleteCategory(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid category ID")
 	}
 
-	db := h.db
-
-	// Check if category has products
-	var productCount int64
-	db.Model(&models.Product{}).Where("category_id = ?", categoryUUID).Count(&productCount)
-	if productCount > 0 {
-		return echo.NewHTTPError(http.StatusBadRequest, "cannot delete category with products")
+	reassignTo := c.QueryParam("reassign_to")
+	var targetID uuid.UUID
+	if reassignTo != "" && reassignTo != "parent" {
+		if targetID, err = uuid.Parse(reassignTo); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "reassign_to must be a category ID or parent")
+		}
 	}
 
-	// Delete category
-	result := db.Where("id = ?", categoryUUID).Delete(&models.Category{})
-	if result.Error != nil {
+	// Everything is read under the tree lock, so a concurrent move cannot
+	// put the target below the deleted category in the meantime
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := models.LockCategoryTree(tx); err != nil {
+			return err
+		}
+
+		var category models.Category
+		if err := tx.Clauses(lockForUpdate).Where("id = ?", categoryUUID).First(&category).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return echo.NewHTTPError(http.StatusNotFound, "category not found")
+			}
+			return err
+		}
+
+		var children []models.Category
+		if err := tx.Where("parent_id = ?", category.ID).Order("position ASC, name ASC").Find(&children).Error; err != nil {
+			return err
+		}
+		var productCount int64
+		if err := tx.Model(&models.Product{}).Where("category_id = ?", category.ID).Count(&productCount).Error; err != nil {
+			return err
+		}
+
+		var target *models.Category
+		switch {
+		case reassignTo == "" && (len(children) > 0 || productCount > 0):
+			return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf(
+				"category has %d subcategories and %d products; pass reassign_to with a category ID, or parent, to move them", len(children), productCount))
+		case reassignTo == "parent":
+			if category.ParentID != nil {
+				target = &models.Category{}
+				if err := tx.Clauses(lockForUpdate).Where("id = ?", *category.ParentID).First(target).Error; err != nil {
+					return err
+				}
+			}
+		case reassignTo != "":
+			target = &models.Category{}
+			if err := tx.Clauses(lockForUpdate).Where("id = ?", targetID).First(target).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return echo.NewHTTPError(http.StatusBadRequest, "reassign_to category not found")
+				}
+				return err
+			}
+			if category.Contains(target) {
+				return echo.NewHTTPError(http.StatusBadRequest, "cannot reassign to the deleted category or one below it")
+			}
+		}
+
+		for i := range children {
+			if err := models.MoveCategory(tx, &children[i], target, nil); err != nil {
+				return err
+			}
+		}
+		if productCount > 0 {
+			var newCategoryID *uuid.UUID
+			if target != nil {
+				newCategoryID = &target.ID
+			}
+			if err := tx.Model(&models.Product{}).Where("category_id = ?", category.ID).Update("category_id", newCategoryID).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&category).Error; err != nil {
+			return err
+		}
+		if err := models.RenumberCategories(tx, category.ParentID); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "category.delete", "category", category.ID, category, nil)
+	})
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete category")
 	}
 
-	if result.RowsAffected == 0 {
-		return echo.NewHTTPError(http.StatusNotFound, "category not found")
+	return c.NoContent(http.StatusNoContent)
+}
+
+// MoveCategory moves a category, with everything below it, to another
+// parent or position
+func (h *CategoryHandler) MoveCategory(c echo.Context) error {
+	categoryUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid category ID")
+	}
+
+	var req MoveCategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if req.Position != nil && *req.Position < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "position cannot be negative")
 	}
 
-	return c.NoContent(http.StatusNoContent)
+	var category models.Category
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// Read under the tree lock, so the cycle check sees current paths
+		if err := models.LockCategoryTree(tx); err != nil {
+			return err
+		}
+		if err := tx.Clauses(lockForUpdate).Where("id = ?", categoryUUID).First(&category).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return echo.NewHTTPError(http.StatusNotFound, "category not found")
+			}
+			return err
+		}
+		before := category
+
+		var parent *models.Category
+		if req.ParentID != nil {
+			parent = &models.Category{}
+			if err := tx.Clauses(lockForUpdate).Where("id = ?", *req.ParentID).First(parent).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return echo.NewHTTPError(http.StatusBadRequest, "parent category not found")
+				}
+				return err
+			}
+		}
+
+		if err := models.MoveCategory(tx, &category, parent, req.Position); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "category.move", "category", category.ID, before, category)
+	})
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	if errors.Is(err, models.ErrCategoryCycle) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to move category")
+	}
+
+	return c.JSON(http.StatusOK, category)
+}
+
+// GetCategoryTree returns every category nested under its parent, inactive
+// ones included
+func (h *CategoryHandler) GetCategoryTree(c echo.Context) error {
+	tree, err := categoryTree(h.db, false)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch categories")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"categories": tree})
+}
+
+// categoryTree nests the categories, counting the products of each subtree.
+// For shoppers an inactive category hides everything below it.
+func categoryTree(db *gorm.DB, activeOnly bool) ([]*models.CategoryNode, error) {
+	query := db.Model(&models.Category{})
+	products := db.Model(&models.Product{}).Where("category_id IS NOT NULL")
+	if activeOnly {
+		query = query.Where("is_active = true")
+		products = products.Where("is_active = true")
+	}
+	var categories []models.Category
+	if err := query.Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		CategoryID uuid.UUID
+		Count      int64
+	}
+	if err := products.Select("category_id, COUNT(*) AS count").Group("category_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.CategoryID] = row.Count
+	}
+	return models.CategoryTree(categories, counts), nil
+}
+
+// respond writes category with its attribute values
+func (h *CategoryHandler) respond(c echo.Context, status int, category models.Category) error {
+	attributes, err := models.CategoryAttributes(h.db, category.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch category attributes")
+	}
+	return c.JSON(status, categoryResponse{Category: category, Attributes: attributes})
 }
 
 func (h *CategoryHandler) generateSlug(name string) string {
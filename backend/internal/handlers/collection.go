@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"easycart/internal/audit"
+	"easycart/internal/catalog"
+	"easycart/internal/models"
+	"easycart/internal/pagination"
+	"easycart/internal/search"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// CollectionHandler manages product collections: hand-picked lists and
+// rule-based sets shown on landing pages
+type CollectionHandler struct {
+	db *gorm.DB
+}
+
+func NewCollectionHandler(db *gorm.DB) *CollectionHandler {
+	return &CollectionHandler{db: db}
+}
+
+type CreateCollectionRequest struct {
+	Title          string                 `json:"title" validate:"required"`
+	Slug           string                 `json:"slug"` // From the title when blank
+	Description    string                 `json:"description"`
+	ImageID        *uuid.UUID             `json:"image_id,omitempty"`
+	SEOTitle       string                 `json:"seo_title"`
+	SEODescription string                 `json:"seo_description"`
+	Type           models.CollectionType  `json:"type"` // manual when blank
+	Rules          models.CollectionRules `json:"rules"`
+	Sort           string                 `json:"sort"`
+	IsActive       *bool                  `json:"is_active,omitempty"`
+	ProductIDs     []uuid.UUID            `json:"product_ids,omitempty"` // Manual collections, in order
+}
+
+// UpdateCollectionRequest changes the fields that are set
+type UpdateCollectionRequest struct {
+	Title          *string                 `json:"title,omitempty"`
+	Slug           *string                 `json:"slug,omitempty"`
+	Description    *string                 `json:"description,omitempty"`
+	ImageID        *uuid.UUID              `json:"image_id,omitempty"`
+	SEOTitle       *string                 `json:"seo_title,omitempty"`
+	SEODescription *string                 `json:"seo_description,omitempty"`
+	Type           *models.CollectionType  `json:"type,omitempty"`
+	Rules          *models.CollectionRules `json:"rules,omitempty"`
+	Sort           *string                 `json:"sort,omitempty"`
+	IsActive       *bool                   `json:"is_active,omitempty"`
+}
+
+type SetCollectionProductsRequest struct {
+	ProductIDs []uuid.UUID `json:"product_ids"`
+}
+
+// collectionResponse adds the hand-picked products of manual collections
+type collectionResponse struct {
+	models.Collection
+	ProductIDs []uuid.UUID `json:"product_ids,omitempty"`
+}
+
+// GetCollections lists collections, newest first
+func (h *CollectionHandler) GetCollections(c echo.Context) error {
+	params, err := pagination.Parse(c, pagination.NewestFirst("collections", 50, 100))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	query := h.db.Model(&models.Collection{})
+	if collectionType := c.QueryParam("type"); collectionType != "" {
+		query = query.Where("type = ?", collectionType)
+	}
+
+	page, err := pagination.Find[models.Collection](c, query, params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch collections")
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetCollection returns a collection with the products of a manual one
+func (h *CollectionHandler) GetCollection(c echo.Context) error {
+	collection, err := h.findCollection(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	response, err := h.response(h.db, collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch collection products")
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *CollectionHandler) CreateCollection(c echo.Context) error {
+	var req CreateCollectionRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	collection := models.Collection{
+		Title:          strings.TrimSpace(req.Title),
+		Description:    req.Description,
+		SEOTitle:       req.SEOTitle,
+		SEODescription: req.SEODescription,
+		Type:           req.Type,
+		Rules:          req.Rules,
+		Sort:           req.Sort,
+		IsActive:       true,
+	}
+	if collection.Type == "" {
+		collection.Type = models.CollectionManual
+	}
+	if req.IsActive != nil {
+		collection.IsActive = *req.IsActive
+	}
+	if err := validateCollection(&collection); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if len(req.ProductIDs) > 0 && collection.Type != models.CollectionManual {
+		return echo.NewHTTPError(http.StatusBadRequest, "only manual collections have product_ids")
+	}
+	if err := h.checkProducts(req.ProductIDs); err != nil {
+		return err
+	}
+	if req.ImageID != nil {
+		imageURL, err := h.imageURL(*req.ImageID)
+		if err != nil {
+			return err
+		}
+		collection.ImageURL = imageURL
+	}
+
+	slug, err := h.uniqueSlug(req.Slug, collection.Title, uuid.Nil)
+	if err != nil {
+		return err
+	}
+	collection.Slug = slug
+
+	var response *collectionResponse
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&collection).Error; err != nil {
+			return err
+		}
+		if err := models.SetCollectionProducts(tx, collection.ID, req.ProductIDs); err != nil {
+			return err
+		}
+		var err error
+		if response, err = h.response(tx, &collection); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "collection.create", "collection", collection.ID, nil, response)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create collection")
+	}
+
+	return c.JSON(http.StatusCreated, response)
+}
+
+func (h *CollectionHandler) UpdateCollection(c echo.Context) error {
+	collection, err := h.findCollection(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	before := *collection
+
+	var req UpdateCollectionRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Title != nil {
+		if title := strings.TrimSpace(*req.Title); title != "" {
+			collection.Title = title
+		} else {
+			return echo.NewHTTPError(http.StatusBadRequest, "title cannot be blank")
+		}
+	}
+	if req.Description != nil {
+		collection.Description = *req.Description
+	}
+	if req.SEOTitle != nil {
+		collection.SEOTitle = *req.SEOTitle
+	}
+	if req.SEODescription != nil {
+		collection.SEODescription = *req.SEODescription
+	}
+	if req.Type != nil {
+		collection.Type = *req.Type
+	}
+	if req.Rules != nil {
+		collection.Rules = *req.Rules
+	}
+	if req.Sort != nil {
+		collection.Sort = *req.Sort
+	}
+	if req.IsActive != nil {
+		collection.IsActive = *req.IsActive
+	}
+	if err := validateCollection(collection); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.ImageID != nil {
+		imageURL, err := h.imageURL(*req.ImageID)
+		if err != nil {
+			return err
+		}
+		collection.ImageURL = imageURL
+	}
+	if req.Slug != nil {
+		slug, err := h.uniqueSlug(*req.Slug, collection.Title, collection.ID)
+		if err != nil {
+			return err
+		}
+		collection.Slug = slug
+	}
+
+	var response *collectionResponse
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Products").Save(collection).Error; err != nil {
+			return err
+		}
+		// Rule-based collections keep no hand-picked products
+		if collection.Type != models.CollectionManual {
+			if err := models.SetCollectionProducts(tx, collection.ID, nil); err != nil {
+				return err
+			}
+		}
+		var err error
+		if response, err = h.response(tx, collection); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "collection.update", "collection", collection.ID, before, collection)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update collection")
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// SetCollectionProducts replaces the products of a manual collection with
+// product_ids, in their order
+func (h *CollectionHandler) SetCollectionProducts(c echo.Context) error {
+	collection, err := h.findCollection(c.Param("id"))
+	if err != nil {
+		return err
+	}
+	if collection.Type != models.CollectionManual {
+		return echo.NewHTTPError(http.StatusBadRequest, "products are only picked for manual collections; automatic ones follow their rules")
+	}
+
+	var req SetCollectionProductsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if err := h.checkProducts(req.ProductIDs); err != nil {
+		return err
+	}
+
+	var response *collectionResponse
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		before, err := h.response(tx, collection)
+		if err != nil {
+			return err
+		}
+		if err := models.SetCollectionProducts(tx, collection.ID, req.ProductIDs); err != nil {
+			return err
+		}
+		if response, err = h.response(tx, collection); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "collection.products", "collection", collection.ID, before, response)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update collection products")
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *CollectionHandler) DeleteCollection(c echo.Context) error {
+	collection, err := h.findCollection(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(collection).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "collection.delete", "collection", collection.ID, collection, nil)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete collection")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *CollectionHandler) findCollection(id string) (*models.Collection, error) {
+	collectionID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid collection ID")
+	}
+	var collection models.Collection
+	if err := h.db.Where("id = ?", collectionID).First(&collection).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "collection not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch collection")
+	}
+	return &collection, nil
+}
+
+func (h *CollectionHandler) response(tx *gorm.DB, collection *models.Collection) (*collectionResponse, error) {
+	response := &collectionResponse{Collection: *collection}
+	if collection.Type != models.CollectionManual {
+		return response, nil
+	}
+	response.ProductIDs = []uuid.UUID{}
+	err := tx.Model(&models.CollectionProduct{}).Where("collection_id = ?", collection.ID).
+		Order("position ASC").Pluck("product_id", &response.ProductIDs).Error
+	return response, err
+}
+
+// checkProducts requires every product ID to exist
+func (h *CollectionHandler) checkProducts(productIDs []uuid.UUID) error {
+	unique := map[uuid.UUID]bool{}
+	for _, id := range productIDs {
+		unique[id] = true
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+	var count int64
+	if err := h.db.Model(&models.Product{}).Where("id IN ?", productIDs).Count(&count).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check products")
+	}
+	if int(count) != len(unique) {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%d of the product_ids are unknown", len(unique)-int(count)))
+	}
+	return nil
+}
+
+func (h *CollectionHandler) imageURL(mediaID uuid.UUID) (string, error) {
+	var media models.Media
+	if err := h.db.Where("id = ?", mediaID).First(&media).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "", echo.NewHTTPError(http.StatusBadRequest, "image not found")
+		}
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch image")
+	}
+	return media.URL, nil
+}
+
+// uniqueSlug checks a chosen slug, or derives one from the title and
+// numbers it until it is free
+func (h *CollectionHandler) uniqueSlug(slug, title string, collectionID uuid.UUID) (string, error) {
+	taken := func(candidate string) (bool, error) {
+		var count int64
+		err := h.db.Model(&models.Collection{}).Where("slug = ? AND id <> ?", candidate, collectionID).Count(&count).Error
+		return count > 0, err
+	}
+
+	if slug = catalog.Slugify(slug); slug != "" {
+		used, err := taken(slug)
+		if err != nil {
+			return "", echo.NewHTTPError(http.StatusInternalServerError, "failed to check slug")
+		}
+		if used {
+			return "", echo.NewHTTPError(http.StatusConflict, "slug is already used by another collection")
+		}
+		return slug, nil
+	}
+
+	base := catalog.Slugify(title)
+	if base == "" {
+		base = "collection"
+	}
+	candidate := base
+	for counter := 2; ; counter++ {
+		used, err := taken(candidate)
+		if err != nil {
+			return "", echo.NewHTTPError(http.StatusInternalServerError, "failed to check slug")
+		}
+		if !used {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, counter)
+	}
+}
+
+// validateCollection checks the type, rules and sort of a collection
+func validateCollection(collection *models.Collection) error {
+	switch collection.Type {
+	case models.CollectionManual:
+		collection.Rules = models.CollectionRules{}
+	case models.CollectionAutomatic:
+		if err := collection.Rules.Validate(); err != nil {
+			return err
+		}
+		collection.Rules.Tags = models.NormalizeTags(collection.Rules.Tags)
+	default:
+		return errors.New("type must be manual or automatic")
+	}
+
+	if collection.Sort == "" {
+		return nil
+	}
+	sort, ok := search.ParseSort(collection.Sort)
+	if !ok || sort == search.SortRelevance {
+		return errors.New("sort must be newest, price_asc, price_desc, name, best_selling or, for manual collections, manual")
+	}
+	if sort == search.SortManual && collection.Type != models.CollectionManual {
+		return errors.New("only manual collections can use the manual sort")
+	}
+	return nil
+}
+
+// collectionSort is the order of a collection's products when the shopper
+// picks none
+func collectionSort(collection *models.Collection) search.Sort {
+	if sort, ok := search.ParseSort(collection.Sort); ok {
+		return sort
+	}
+	if collection.Type == models.CollectionManual {
+		return search.SortManual
+	}
+	return search.SortNewest
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"net/http"
+
+	"easycart/internal/audit"
+	"easycart/internal/models"
+	"easycart/internal/notifications"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type EmailTemplateHandler struct {
+	db *gorm.DB
+}
+
+type EmailTemplateRequest struct {
+	Subject  string `json:"subject" validate:"required"`
+	HTMLBody string `json:"html_body"`
+	TextBody string `json:"text_body" validate:"required"`
+}
+
+type EmailTemplateResponse struct {
+	notifications.Template
+	Customized bool `json:"customized"`
+}
+
+func NewEmailTemplateHandler(db *gorm.DB) *EmailTemplateHandler {
+	return &EmailTemplateHandler{db: db}
+}
+
+// GetEmailTemplates lists every email template with its current content
+func (h *EmailTemplateHandler) GetEmailTemplates(c echo.Context) error {
+	keys := notifications.Keys()
+	templates := make([]EmailTemplateResponse, 0, len(keys))
+	for _, key := range keys {
+		tmpl, customized, err := notifications.Load(h.db, key)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get email templates: "+err.Error())
+		}
+		templates = append(templates, EmailTemplateResponse{Template: tmpl, Customized: customized})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"templates": templates,
+	})
+}
+
+// GetEmailTemplate returns one template; default=true returns the built-in version
+func (h *EmailTemplateHandler) GetEmailTemplate(c echo.Context) error {
+	key := c.Param("key")
+
+	if c.QueryParam("default") == "true" {
+		tmpl, err := notifications.Default(key)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Email template not found")
+		}
+		return c.JSON(http.StatusOK, EmailTemplateResponse{Template: tmpl})
+	}
+
+	tmpl, customized, err := notifications.Load(h.db, key)
+	if err == notifications.ErrUnknownTemplate {
+		return echo.NewHTTPError(http.StatusNotFound, "Email template not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get email template: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, EmailTemplateResponse{Template: tmpl, Customized: customized})
+}
+
+// UpdateEmailTemplate saves a customized template after checking that it renders
+func (h *EmailTemplateHandler) UpdateEmailTemplate(c echo.Context) error {
+	key := c.Param("key")
+	tmpl, err := notifications.Default(key)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Email template not found")
+	}
+
+	var req EmailTemplateRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	tmpl.Subject = req.Subject
+	tmpl.HTMLBody = req.HTMLBody
+	tmpl.TextBody = req.TextBody
+	if err := tmpl.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Template does not render: "+err.Error())
+	}
+
+	var custom models.EmailTemplate
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var before *models.EmailTemplate
+		if err := tx.Where("key = ?", key).First(&custom).Error; err == nil {
+			existing := custom
+			before = &existing
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		custom.Key = key
+		custom.Subject = req.Subject
+		custom.HTMLBody = req.HTMLBody
+		custom.TextBody = req.TextBody
+		if err := tx.Save(&custom).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "email_template.update", "email_template", key, before, custom)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save email template: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, EmailTemplateResponse{Template: tmpl, Customized: true})
+}
+
+// ResetEmailTemplate drops the customization so the built-in template is used again
+func (h *EmailTemplateHandler) ResetEmailTemplate(c echo.Context) error {
+	key := c.Param("key")
+	tmpl, err := notifications.Default(key)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Email template not found")
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var custom models.EmailTemplate
+		if err := tx.Where("key = ?", key).First(&custom).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			return err
+		}
+		if err := tx.Delete(&custom).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "email_template.reset", "email_template", key, custom, nil)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reset email template: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, EmailTemplateResponse{Template: tmpl})
+}
+
+// PreviewEmailTemplate renders a template, saved or from the request body, with sample data
+func (h *EmailTemplateHandler) PreviewEmailTemplate(c echo.Context) error {
+	key := c.Param("key")
+	tmpl, _, err := notifications.Load(h.db, key)
+	if err == notifications.ErrUnknownTemplate {
+		return echo.NewHTTPError(http.StatusNotFound, "Email template not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get email template: "+err.Error())
+	}
+
+	var req EmailTemplateRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if req.Subject != "" || req.TextBody != "" || req.HTMLBody != "" {
+		tmpl.Subject = req.Subject
+		tmpl.HTMLBody = req.HTMLBody
+		tmpl.TextBody = req.TextBody
+	}
+
+	data := notifications.SampleData(key)
+	if settings, err := models.GetSettings(h.db); err == nil {
+		data["Shop"] = notifications.NewShopView(settings)
+	}
+
+	msg, err := tmpl.Render(data)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Template does not render: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"subject":   msg.Subject,
+		"html_body": msg.HTMLBody,
+		"text_body": msg.TextBody,
+	})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"easycart/internal/feed"
+	"easycart/internal/tokens"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	streamTicketTTL   = 10 * time.Minute
+	streamHeartbeat   = 20 * time.Second
+	streamRetryMillis = 3000
+)
+
+type EventStreamHandler struct {
+	hub       *feed.Hub
+	jwtSecret string
+}
+
+func NewEventStreamHandler(hub *feed.Hub, jwtSecret string) *EventStreamHandler {
+	return &EventStreamHandler{hub: hub, jwtSecret: jwtSecret}
+}
+
+// IssueStreamTicket returns a short-lived ticket for opening the event stream
+// from a browser, which cannot send an Authorization header with EventSource
+func (h *EventStreamHandler) IssueStreamTicket(c echo.Context) error {
+	// A ticket would drop the key's scopes, so API keys use the header instead
+	if _, ok := c.Get("api_key_id").(uuid.UUID); ok {
+		return echo.NewHTTPError(http.StatusForbidden, "API keys cannot issue stream tickets")
+	}
+
+	userID, ok := c.Get("user_id").(uuid.UUID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid user context")
+	}
+
+	expiresAt := time.Now().Add(streamTicketTTL)
+	ticket, err := tokens.Issue(h.jwtSecret, tokens.PurposeEventStream, userID.String(), expiresAt)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to issue ticket")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"ticket":     ticket,
+		"expires_at": expiresAt,
+	})
+}
+
+// Stream pushes live order and inventory messages as Server-Sent Events. A
+// client reconnecting with Last-Event-ID receives what it missed; if that is
+// no longer buffered it gets a reset event and should reload its data.
+func (h *EventStreamHandler) Stream(c echo.Context) error {
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("last_event_id")
+	}
+
+	replay, messages, complete, cancel := h.hub.Subscribe(lastEventID)
+	defer cancel()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // Stop nginx buffering the stream
+	res.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(res, "retry: %d\n\n", streamRetryMillis); err != nil {
+		return nil
+	}
+	if !complete {
+		if _, err := fmt.Fprint(res, "event: reset\ndata: {}\n\n"); err != nil {
+			return nil
+		}
+	}
+	for _, msg := range replay {
+		if err := writeStreamMessage(res, msg); err != nil {
+			return nil
+		}
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				// Fell too far behind; the client reconnects and resumes
+				return nil
+			}
+			if err := writeStreamMessage(res, msg); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
+
+func writeStreamMessage(w http.ResponseWriter, msg feed.Message) error {
+	_, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, msg.Data)
+	return err
+}
@@ -2,14 +2,36 @@ package handlers
 
 import (
 	"errors"
+	"net/http"
 
 	"easycart/internal/database"
 	"easycart/internal/models"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// lockForUpdate locks the selected rows until the surrounding transaction ends
+var lockForUpdate = clause.Locking{Strength: "UPDATE"}
+
+// isUniqueViolation reports whether err is Postgres refusing a duplicate in
+// the named unique index
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+}
+
+// rejectAPIKey refuses API key credentials on endpoints that change the
+// account itself, which no key scope covers
+func rejectAPIKey(c echo.Context) error {
+	if _, ok := c.Get("api_key_id").(uuid.UUID); ok {
+		return echo.NewHTTPError(http.StatusForbidden, "API keys cannot manage the account")
+	}
+	return nil
+}
+
 // GetShopIDFromToken extracts the user ID from JWT token context and retrieves the associated shop ID
 func GetShopIDFromToken(c echo.Context) (uuid.UUID, error) {
 	userID := c.Get("user_id").(uuid.UUID)
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"easycart/internal/audit"
+	"easycart/internal/middleware"
+	"easycart/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// impersonationTTL keeps support sessions short
+const impersonationTTL = 15 * time.Minute
+
+type ImpersonationHandler struct {
+	db        *gorm.DB
+	jwtSecret string
+}
+
+type ImpersonationResponse struct {
+	Token          string              `json:"token"`
+	ExpiresAt      time.Time           `json:"expires_at"`
+	User           models.UserResponse `json:"user"`
+	ImpersonatorID uuid.UUID           `json:"impersonator_id"`
+	Impersonation  bool                `json:"impersonation"`
+}
+
+func NewImpersonationHandler(db *gorm.DB, jwtSecret string) *ImpersonationHandler {
+	return &ImpersonationHandler{db: db, jwtSecret: jwtSecret}
+}
+
+// ImpersonateUser issues a short-lived token that lets support see the storefront as a customer
+func (h *ImpersonationHandler) ImpersonateUser(c echo.Context) error {
+	currentUser, ok := c.Get("user").(*models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid user context")
+	}
+
+	// Impersonation tokens cannot be chained
+	if _, impersonating := middleware.ImpersonatorID(c); impersonating {
+		return echo.NewHTTPError(http.StatusForbidden, "not allowed while impersonating")
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user: "+err.Error())
+	}
+
+	if !user.IsCustomer() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Only customer accounts can be impersonated")
+	}
+	if !user.IsActive {
+		return echo.NewHTTPError(http.StatusBadRequest, "Cannot impersonate an inactive user")
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(impersonationTTL)
+	claims := &middleware.JWTClaims{
+		UserID:         user.ID,
+		Email:          user.Email,
+		ImpersonatorID: &currentUser.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.jwtSecret))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate token")
+	}
+
+	metadata := models.JSONMap{"expires_at": expiresAt}
+	if err := audit.RecordAction(h.db, c, "impersonation.start", "user", user.ID, metadata); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to record impersonation: "+err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, ImpersonationResponse{
+		Token:          token,
+		ExpiresAt:      expiresAt,
+		User:           user.ToResponse(),
+		ImpersonatorID: currentUser.ID,
+		Impersonation:  true,
+	})
+}
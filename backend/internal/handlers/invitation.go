@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"easycart/internal/audit"
+	"easycart/internal/models"
+	"easycart/internal/notifications"
+	"easycart/internal/tokens"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// invitationTTL is how long an invite link stays valid
+const invitationTTL = 7 * 24 * time.Hour
+
+type InvitationHandler struct {
+	db          *gorm.DB
+	jwtSecret   string
+	frontendURL string
+	notifier    *notifications.Notifier
+}
+
+type CreateInvitationRequest struct {
+	Email       string          `json:"email" validate:"required,email"`
+	Role        models.UserRole `json:"role" validate:"required"`
+	StaffRoleID *uuid.UUID      `json:"staff_role_id,omitempty"`
+}
+
+type AcceptInvitationRequest struct {
+	Token     string `json:"token" validate:"required"`
+	Password  string `json:"password" validate:"required,min=6"`
+	FirstName string `json:"first_name" validate:"required"`
+	LastName  string `json:"last_name" validate:"required"`
+}
+
+type InvitationResponse struct {
+	models.StaffInvitation
+	Status models.InvitationStatus `json:"status"`
+}
+
+func NewInvitationHandler(db *gorm.DB, jwtSecret, frontendURL string, notifier *notifications.Notifier) *InvitationHandler {
+	return &InvitationHandler{db: db, jwtSecret: jwtSecret, frontendURL: frontendURL, notifier: notifier}
+}
+
+// GetInvitations lists invitations; pending ones only unless status=all
+func (h *InvitationHandler) GetInvitations(c echo.Context) error {
+	now := time.Now()
+	query := h.db.Preload("InvitedBy").Preload("StaffRole").Order("created_at DESC")
+	if c.QueryParam("status") != "all" {
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+	}
+
+	var invitations []models.StaffInvitation
+	if err := query.Find(&invitations).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get invitations: "+err.Error())
+	}
+
+	response := make([]InvitationResponse, len(invitations))
+	for i, invitation := range invitations {
+		response[i] = InvitationResponse{StaffInvitation: invitation, Status: invitation.Status(now)}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"invitations": response,
+		"total":       len(response),
+	})
+}
+
+// CreateInvitation invites a staff member; any pending invite for the same email is replaced
+func (h *InvitationHandler) CreateInvitation(c echo.Context) error {
+	currentUser, ok := c.Get("user").(*models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid user context")
+	}
+
+	var req CreateInvitationRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if req.Role != models.UserRoleAdmin && req.Role != models.UserRoleManager {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invitations are for admin or manager roles")
+	}
+
+	email := strings.ToLower(req.Email)
+
+	var existingUser models.User
+	if err := h.db.Where("email = ?", email).First(&existingUser).Error; err == nil {
+		return echo.NewHTTPError(http.StatusConflict, "Email already exists")
+	}
+
+	if err := checkRoleChange(currentUser, "", req.Role); err != nil {
+		return err
+	}
+	// The invitee gets these permissions on accepting, whoever is signed in then
+	invitee := models.User{Role: req.Role, StaffRoleID: req.StaffRoleID, IsActive: true}
+	if err := checkGrantedPermissions(h.db, c, &invitee); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	invitation := models.StaffInvitation{
+		Email:       email,
+		Role:        req.Role,
+		StaffRoleID: req.StaffRoleID,
+		InvitedByID: &currentUser.ID,
+		ExpiresAt:   now.Add(invitationTTL),
+	}
+
+	var inviteURL string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.StaffInvitation{}).
+			Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", email).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&invitation).Error; err != nil {
+			return err
+		}
+
+		var err error
+		inviteURL, err = h.inviteURL(&invitation)
+		if err != nil {
+			return err
+		}
+		if err := h.notifier.StaffInvitation(tx, &invitation, inviteURL); err != nil {
+			return err
+		}
+
+		return audit.Record(tx, c, "invitation.create", "staff_invitation", invitation.ID, nil, invitation)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create invitation: "+err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"invitation": InvitationResponse{StaffInvitation: invitation, Status: invitation.Status(now)},
+		"invite_url": inviteURL,
+	})
+}
+
+// RevokeInvitation revokes a pending invitation
+func (h *InvitationHandler) RevokeInvitation(c echo.Context) error {
+	invitationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid invitation ID")
+	}
+
+	var invitation models.StaffInvitation
+	if err := h.db.First(&invitation, invitationID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Invitation not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get invitation: "+err.Error())
+	}
+
+	if invitation.AcceptedAt != nil {
+		return echo.NewHTTPError(http.StatusConflict, "Invitation has already been accepted")
+	}
+
+	if invitation.RevokedAt == nil {
+		before := invitation
+		now := time.Now()
+		invitation.RevokedAt = &now
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&invitation).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, c, "invitation.revoke", "staff_invitation", invitation.ID, before, invitation)
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke invitation: "+err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Invitation revoked successfully",
+	})
+}
+
+// GetInvitation returns the public details of an invite link so the invitee can see who they will sign up as
+func (h *InvitationHandler) GetInvitation(c echo.Context) error {
+	invitation, err := h.pendingInvitation(h.db, c.Param("token"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"email":      invitation.Email,
+		"role":       invitation.Role,
+		"expires_at": invitation.ExpiresAt,
+	})
+}
+
+// AcceptInvitation creates the staff account from an invite link and signs the new user in
+func (h *InvitationHandler) AcceptInvitation(c echo.Context) error {
+	req := new(AcceptInvitationRequest)
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var user models.User
+	var invitation *models.StaffInvitation
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invitation, err = h.pendingInvitation(tx.Clauses(lockForUpdate), req.Token)
+		if err != nil {
+			return err
+		}
+
+		var existingUser models.User
+		if err := tx.Where("email = ?", invitation.Email).First(&existingUser).Error; err == nil {
+			return echo.NewHTTPError(http.StatusConflict, "user with this email already exists")
+		}
+
+		user = models.User{
+			Email:       invitation.Email,
+			FirstName:   req.FirstName,
+			LastName:    req.LastName,
+			Role:        invitation.Role,
+			StaffRoleID: invitation.StaffRoleID,
+			IsActive:    true,
+		}
+		// The invite link was delivered to this address
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+
+		if err := user.HashPassword(req.Password); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to hash password")
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		invitation.AcceptedAt = &now
+		invitation.AcceptedUserID = &user.ID
+		invitation.AcceptedIP = c.RealIP()
+		if err := tx.Save(invitation).Error; err != nil {
+			return err
+		}
+		return audit.RecordAction(tx, c, "invitation.accept", "staff_invitation", invitation.ID, models.JSONMap{
+			"user_id": user.ID,
+			"email":   user.Email,
+			"role":    user.Role,
+		})
+	})
+	if err != nil {
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to accept invitation")
+	}
+
+	log.Printf("Staff invitation %s accepted by %s from %s", invitation.ID, user.Email, invitation.AcceptedIP)
+
+	token, err := generateSessionToken(h.jwtSecret, user.ID, user.Email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate token")
+	}
+
+	return c.JSON(http.StatusCreated, AuthResponse{
+		User:  user.ToResponse(),
+		Token: token,
+	})
+}
+
+// pendingInvitation resolves a signed invite token to an invitation that can still be accepted
+func (h *InvitationHandler) pendingInvitation(db *gorm.DB, token string) (*models.StaffInvitation, error) {
+	subject, err := tokens.Verify(h.jwtSecret, tokens.PurposeStaffInvite, token)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid or expired invitation")
+	}
+
+	invitationID, err := uuid.Parse(subject)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid or expired invitation")
+	}
+
+	var invitation models.StaffInvitation
+	if err := db.First(&invitation, invitationID).Error; err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid or expired invitation")
+	}
+
+	if invitation.Status(time.Now()) != models.InvitationStatusPending {
+		return nil, echo.NewHTTPError(http.StatusGone, "invitation is no longer valid")
+	}
+
+	return &invitation, nil
+}
+
+func (h *InvitationHandler) inviteURL(invitation *models.StaffInvitation) (string, error) {
+	token, err := tokens.Issue(h.jwtSecret, tokens.PurposeStaffInvite, invitation.ID.String(), invitation.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+	return h.frontendURL + "/admin/invite?token=" + token, nil
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"easycart/internal/audit"
+	"easycart/internal/models"
+	"easycart/internal/pagination"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type JobHandler struct {
+	db *gorm.DB
+}
+
+func NewJobHandler(db *gorm.DB) *JobHandler {
+	return &JobHandler{db: db}
+}
+
+// GetJobs lists background jobs, newest first, filtered by status and kind
+func (h *JobHandler) GetJobs(c echo.Context) error {
+	query := h.db.Model(&models.Job{})
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kind := c.QueryParam("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	params, err := pagination.Parse(c, pagination.NewestFirst("jobs", 50, 100))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	page, err := pagination.Find[models.Job](c, query, params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch jobs: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetJobStats counts jobs by status and lists the recurring schedules
+func (h *JobHandler) GetJobStats(c echo.Context) error {
+	var rows []struct {
+		Status models.JobStatus
+		Count  int64
+	}
+	if err := h.db.Model(&models.Job{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count jobs: "+err.Error())
+	}
+	counts := map[models.JobStatus]int64{
+		models.JobStatusPending:   0,
+		models.JobStatusRunning:   0,
+		models.JobStatusSucceeded: 0,
+		models.JobStatusDead:      0,
+		models.JobStatusCancelled: 0,
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+
+	var schedules []models.JobSchedule
+	if err := h.db.Order("name ASC").Find(&schedules).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch job schedules: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"counts":    counts,
+		"schedules": schedules,
+	})
+}
+
+func (h *JobHandler) GetJob(c echo.Context) error {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid job ID")
+	}
+
+	var job models.Job
+	if err := h.db.First(&job, "id = ?", jobID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Job not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get job: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// RetryJob queues a dead, cancelled or waiting job to run now with a fresh set of attempts
+func (h *JobHandler) RetryJob(c echo.Context) error {
+	return h.transition(c, "job.retry", func(tx *gorm.DB, job *models.Job) error {
+		if job.Status == models.JobStatusRunning || job.Status == models.JobStatusSucceeded {
+			return echo.NewHTTPError(http.StatusConflict, "Only pending, dead or cancelled jobs can be retried")
+		}
+
+		if job.UniqueKey != nil && job.FinishedAt != nil {
+			var count int64
+			tx.Model(&models.Job{}).Where("unique_key = ? AND finished_at IS NULL", *job.UniqueKey).Count(&count)
+			if count > 0 {
+				return echo.NewHTTPError(http.StatusConflict, "Another job with the same unique key is already queued")
+			}
+		}
+
+		job.Status = models.JobStatusPending
+		job.Attempts = 0
+		job.RunAt = time.Now()
+		job.FinishedAt = nil
+		return nil
+	})
+}
+
+// CancelJob stops a pending job from running; running jobs cannot be interrupted
+func (h *JobHandler) CancelJob(c echo.Context) error {
+	return h.transition(c, "job.cancel", func(tx *gorm.DB, job *models.Job) error {
+		if job.Status != models.JobStatusPending {
+			return echo.NewHTTPError(http.StatusConflict, "Only pending jobs can be cancelled")
+		}
+
+		now := time.Now()
+		job.Status = models.JobStatusCancelled
+		job.FinishedAt = &now
+		return nil
+	})
+}
+
+// transition locks the job, applies change and records it in the audit log
+func (h *JobHandler) transition(c echo.Context, action string, change func(tx *gorm.DB, job *models.Job) error) error {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid job ID")
+	}
+
+	var job models.Job
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(lockForUpdate).First(&job, "id = ?", jobID).Error; err != nil {
+			return err
+		}
+
+		before := job
+		if err := change(tx, &job); err != nil {
+			return err
+		}
+		if err := tx.Save(&job).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, action, "job", job.ID, before, job)
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Job not found")
+		}
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update job: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
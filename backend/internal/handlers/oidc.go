@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"easycart/internal/models"
+	"easycart/internal/oidc"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// oidcStateTTL is how long a customer has to complete sign-in at the provider
+const oidcStateTTL = 10 * time.Minute
+
+// oidcBindingCookie ties a sign-in to the browser that started it, so a state
+// from someone else's sign-in cannot log this browser into their account
+const oidcBindingCookie = "oidc_binding"
+
+var errStaffAccountLink = errors.New("staff accounts cannot be linked to external providers")
+
+type OIDCHandler struct {
+	db        *gorm.DB
+	jwtSecret string
+	providers map[string]*oidc.Provider
+}
+
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+func NewOIDCHandler(db *gorm.DB, jwtSecret string, providers []*oidc.Provider) *OIDCHandler {
+	h := &OIDCHandler{db: db, jwtSecret: jwtSecret, providers: make(map[string]*oidc.Provider)}
+	for _, provider := range providers {
+		h.providers[provider.Name()] = provider
+	}
+	return h
+}
+
+// GetProviders lists the configured sign-in providers
+func (h *OIDCHandler) GetProviders(c echo.Context) error {
+	names := make([]string, 0, len(h.providers))
+	for name := range h.providers {
+		names = append(names, name)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"providers": names,
+	})
+}
+
+// Start begins a sign-in and returns the provider URL the browser should be sent to.
+// The provider redirects back to the frontend, which posts code and state to Callback.
+func (h *OIDCHandler) Start(c echo.Context) error {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "unknown provider")
+	}
+
+	state, err := oidc.RandomString(24)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start sign-in")
+	}
+	nonce, err := oidc.RandomString(24)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start sign-in")
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start sign-in")
+	}
+	binding, err := oidc.RandomString(24)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start sign-in")
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request().Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("OIDC provider %s unavailable: %v", provider.Name(), err)
+		return echo.NewHTTPError(http.StatusBadGateway, "sign-in provider unavailable")
+	}
+
+	// Clean up abandoned sign-ins while we are here
+	h.db.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+
+	loginState := models.OIDCLoginState{
+		State:        state,
+		Provider:     provider.Name(),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		BindingHash:  hashBinding(binding),
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}
+	if err := h.db.Create(&loginState).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start sign-in")
+	}
+	setBindingCookie(c, binding, int(oidcStateTTL/time.Second))
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"authorization_url": authURL,
+		"state":             state,
+	})
+}
+
+// Callback completes a sign-in: it exchanges the code, verifies the ID token and
+// signs the customer in, linking or creating a local account as needed
+func (h *OIDCHandler) Callback(c echo.Context) error {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "unknown provider")
+	}
+
+	req := new(OIDCCallbackRequest)
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validate(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	binding, err := c.Cookie(oidcBindingCookie)
+	if err != nil || binding.Value == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid or expired sign-in state")
+	}
+	setBindingCookie(c, "", -1)
+
+	// Each state can only be used once, and only by the browser that started
+	// it: delete it and read it back in one statement
+	var loginState models.OIDCLoginState
+	result := h.db.Clauses(clause.Returning{}).
+		Where("state = ? AND provider = ? AND binding_hash = ?", req.State, provider.Name(), hashBinding(binding.Value)).
+		Delete(&loginState)
+	if result.Error != nil || result.RowsAffected == 0 || time.Now().After(loginState.ExpiresAt) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid or expired sign-in state")
+	}
+
+	ctx := c.Request().Context()
+	token, err := provider.Exchange(ctx, req.Code, loginState.CodeVerifier)
+	if err != nil {
+		log.Printf("OIDC code exchange with %s failed: %v", provider.Name(), err)
+		return echo.NewHTTPError(http.StatusUnauthorized, "sign-in failed")
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, loginState.Nonce)
+	if err != nil {
+		log.Printf("OIDC ID token from %s rejected: %v", provider.Name(), err)
+		return echo.NewHTTPError(http.StatusUnauthorized, "sign-in failed")
+	}
+
+	user, err := h.resolveUser(provider.Name(), claims)
+	if err != nil {
+		return err
+	}
+
+	jwtToken, err := generateSessionToken(h.jwtSecret, user.ID, user.Email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate token")
+	}
+
+	return c.JSON(http.StatusOK, AuthResponse{
+		User:  user.ToResponse(),
+		Token: jwtToken,
+	})
+}
+
+// resolveUser finds the user for a verified identity: an existing link, then an
+// existing customer with the same verified email, then a new customer account
+func (h *OIDCHandler) resolveUser(providerName string, claims *oidc.Claims) (*models.User, error) {
+	var user models.User
+
+	var identity models.UserIdentity
+	err := h.db.Preload("User").Where("provider = ? AND subject = ?", providerName, claims.Subject).First(&identity).Error
+	if err == nil && identity.User != nil {
+		user = *identity.User
+		if !user.IsCustomer() {
+			// The account may have been promoted since it was linked
+			return nil, echo.NewHTTPError(http.StatusForbidden, "staff accounts must sign in with a password")
+		}
+		if !user.IsActive {
+			return nil, echo.NewHTTPError(http.StatusForbidden, "account is disabled")
+		}
+		return &user, nil
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to look up account")
+	}
+
+	// Only trust the provider's email for linking when it has verified it
+	if claims.Email == "" || !bool(claims.EmailVerified) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "provider did not supply a verified email address")
+	}
+	email := strings.ToLower(claims.Email)
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("email = ?", email).First(&user).Error
+		created := false
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			settings, err := models.GetSettings(tx)
+			if err != nil {
+				return err
+			}
+			if !settings.EnableRegistration {
+				return echo.NewHTTPError(http.StatusForbidden, "registration is disabled")
+			}
+
+			user = models.User{
+				Email:     email,
+				FirstName: claims.GivenName,
+				LastName:  claims.FamilyName,
+				Role:      models.UserRoleCustomer,
+				IsActive:  true,
+			}
+			if user.FirstName == "" {
+				user.FirstName = claims.Name
+			}
+			// OIDC-only accounts get an unguessable password until the customer sets one
+			randomPassword, err := oidc.RandomString(32)
+			if err != nil {
+				return err
+			}
+			if err := user.HashPassword(randomPassword); err != nil {
+				return err
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			created = true
+		case err != nil:
+			return err
+		case !user.IsCustomer():
+			// Linking by email must never hand over a staff account
+			return errStaffAccountLink
+		case !user.IsActive:
+			return echo.NewHTTPError(http.StatusForbidden, "account is disabled")
+		}
+
+		if user.EmailVerifiedAt == nil {
+			// Anyone could have registered an unverified account with this
+			// email, so its password stops working now the owner has proven
+			// it; they can set a new one with a reset link
+			if !created {
+				randomPassword, err := oidc.RandomString(32)
+				if err != nil {
+					return err
+				}
+				if err := user.HashPassword(randomPassword); err != nil {
+					return err
+				}
+				if err := tx.Model(&user).Update("password", user.Password).Error; err != nil {
+					return err
+				}
+			}
+
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+			if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+				return err
+			}
+			if _, err := models.LinkGuestOrders(tx, &user); err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: providerName,
+			Subject:  claims.Subject,
+			Email:    email,
+		}).Error
+	})
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return nil, httpErr
+		}
+		if err == errStaffAccountLink {
+			return nil, echo.NewHTTPError(http.StatusForbidden, "staff accounts must sign in with a password")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to sign in")
+	}
+
+	return &user, nil
+}
+
+// setBindingCookie sets the browser binding cookie, or clears it when maxAge is negative
+func setBindingCookie(c echo.Context, value string, maxAge int) {
+	c.SetCookie(&http.Cookie{
+		Name:     oidcBindingCookie,
+		Value:    value,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func hashBinding(binding string) string {
+	sum := sha256.Sum256([]byte(binding))
+	return hex.EncodeToString(sum[:])
+}
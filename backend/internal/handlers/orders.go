@@ -1,22 +1,31 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 
+	"easycart/internal/audit"
+	"easycart/internal/events"
+	"easycart/internal/middleware"
 	"easycart/internal/models"
+	"easycart/internal/notifications"
+	"easycart/internal/pagination"
 )
 
 type OrderHandler struct {
-	db *gorm.DB
+	db          *gorm.DB
+	jwtSecret   string
+	frontendURL string
+	notifier    *notifications.Notifier
 }
 
-func NewOrderHandler(db *gorm.DB) *OrderHandler {
-	return &OrderHandler{db: db}
+func NewOrderHandler(db *gorm.DB, jwtSecret, frontendURL string, notifier *notifications.Notifier) *OrderHandler {
+	return &OrderHandler{db: db, jwtSecret: jwtSecret, frontendURL: frontendURL, notifier: notifier}
 }
 
 // CreateOrder creates a new order
@@ -161,13 +170,9 @@ func (h *OrderHandler) GetOrders(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
 	}
 
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	if page < 1 {
-		page = 1
-	}
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	if limit < 1 || limit > 100 {
-		limit = 20
+	params, err := pagination.Parse(c, pagination.NewestFirst("orders", 20, 100))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
 	status := c.QueryParam("status")
@@ -184,29 +189,12 @@ func (h *OrderHandler) GetOrders(c echo.Context) error {
 			"%"+search+"%", "%"+search+"%", "%"+search+"%")
 	}
 
-	var total int64
-	query.Model(&models.Order{}).Count(&total)
-
-	var orders []models.Order
-	offset := (page - 1) * limit
-	
-	if err := query.Preload("Items").Order("created_at DESC").Offset(offset).Limit(limit).Find(&orders).Error; err != nil {
+	page, err := pagination.Find[models.Order](c, query.Preload("Items"), params)
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
 	}
 
-	totalPages := int((total + int64(limit) - 1) / int64(limit))
-
-	response := map[string]interface{}{
-		"orders": orders,
-		"pagination": map[string]interface{}{
-			"page":        page,
-			"limit":       limit,
-			"total":       total,
-			"total_pages": totalPages,
-		},
-	}
-
-	return c.JSON(http.StatusOK, response)
+	return c.JSON(http.StatusOK, page)
 }
 
 // GetOrder gets a single order by ID
@@ -247,14 +235,21 @@ func (h *OrderHandler) UpdateOrderStatus(c echo.Context) error {
 	}
 
 	var req struct {
-		Status        string `json:"status"`
-		PaymentStatus string `json:"payment_status"`
+		Status          string `json:"status"`
+		PaymentStatus   string `json:"payment_status"`
+		TrackingCarrier string `json:"tracking_carrier"`
+		TrackingNumber  string `json:"tracking_number"`
 	}
 
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
 
+	// Refunds need their own permission on top of orders.write
+	if models.PaymentStatus(req.PaymentStatus) == models.PaymentStatusRefunded && !middleware.HasPermission(c, models.PermissionOrdersRefund) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Access denied: missing permission " + string(models.PermissionOrdersRefund)})
+	}
+
 	var order models.Order
 	if err := h.db.Where("id = ? AND shop_id = ?", orderID, shopID).First(&order).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -262,6 +257,7 @@ func (h *OrderHandler) UpdateOrderStatus(c echo.Context) error {
 		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
 	}
+	before := order
 
 	// Update status if provided
 	if req.Status != "" {
@@ -270,8 +266,48 @@ func (h *OrderHandler) UpdateOrderStatus(c echo.Context) error {
 	if req.PaymentStatus != "" {
 		order.PaymentStatus = models.PaymentStatus(req.PaymentStatus)
 	}
+	if req.TrackingCarrier != "" {
+		order.TrackingCarrier = req.TrackingCarrier
+	}
+	if req.TrackingNumber != "" {
+		order.TrackingNumber = req.TrackingNumber
+	}
+	if order.Status == models.OrderStatusShipped && order.ShippedAt == nil {
+		now := time.Now()
+		order.ShippedAt = &now
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+
+		if order.Status == models.OrderStatusShipped && before.Status != models.OrderStatusShipped {
+			statusURL, err := orderStatusURL(tx, h.jwtSecret, h.frontendURL, &order)
+			if err != nil {
+				return err
+			}
+			if err := h.notifier.ShippingNotice(tx, &order, statusURL); err != nil {
+				log.Printf("Failed to queue shipping notice for order %s: %v", order.OrderNumber, err)
+			}
+		}
+
+		if order.Status != before.Status || order.PaymentStatus != before.PaymentStatus {
+			if err := tx.Model(&order).Association("Items").Find(&order.Items); err != nil {
+				return err
+			}
+			if err := events.Publish(tx, events.OrderStatusChanged{
+				Order:                 &order,
+				PreviousStatus:        before.Status,
+				PreviousPaymentStatus: before.PaymentStatus,
+			}); err != nil {
+				return err
+			}
+		}
 
-	if err := h.db.Save(&order).Error; err != nil {
+		return audit.Record(tx, c, "order.status_update", "order", order.ID, before, order)
+	})
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update order"})
 	}
 
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"easycart/internal/audit"
+	"easycart/internal/models"
+	"easycart/internal/pagination"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type OutboxHandler struct {
+	db *gorm.DB
+}
+
+func NewOutboxHandler(db *gorm.DB) *OutboxHandler {
+	return &OutboxHandler{db: db}
+}
+
+// GetOutboxEvents lists domain events, newest first. status is pending,
+// dispatched or failed; type and aggregate_id narrow the list further.
+func (h *OutboxHandler) GetOutboxEvents(c echo.Context) error {
+	query := h.db.Model(&models.OutboxEvent{})
+	switch c.QueryParam("status") {
+	case "":
+	case "pending":
+		query = query.Where("dispatched_at IS NULL AND failed_at IS NULL")
+	case "dispatched":
+		query = query.Where("dispatched_at IS NOT NULL")
+	case "failed":
+		query = query.Where("failed_at IS NOT NULL")
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "status must be pending, dispatched or failed")
+	}
+	if eventType := c.QueryParam("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if aggregateID := c.QueryParam("aggregate_id"); aggregateID != "" {
+		query = query.Where("aggregate_id = ?", aggregateID)
+	}
+
+	params, err := pagination.Parse(c, pagination.NewestFirst("events", 50, 100))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	page, err := pagination.Find[models.OutboxEvent](c, query, params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch events: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// RetryOutboxEvent dispatches a failed event again with a fresh set of attempts
+func (h *OutboxHandler) RetryOutboxEvent(c echo.Context) error {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid event ID")
+	}
+
+	var event models.OutboxEvent
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(lockForUpdate).First(&event, "id = ?", eventID).Error; err != nil {
+			return err
+		}
+		if event.FailedAt == nil {
+			return echo.NewHTTPError(http.StatusConflict, "Only failed events can be retried")
+		}
+
+		event.FailedAt = nil
+		event.Attempts = 0
+		event.NextAttemptAt = time.Now()
+		if err := tx.Save(&event).Error; err != nil {
+			return err
+		}
+		return audit.RecordAction(tx, c, "outbox_event.retry", "outbox_event", event.ID, models.JSONMap{
+			"type":       event.Type,
+			"last_error": event.LastError,
+		})
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+		}
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retry event: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, event)
+}
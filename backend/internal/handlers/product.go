@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
-	"strconv"
 	"strings"
 
+	"easycart/internal/audit"
+	"easycart/internal/events"
 	"easycart/internal/models"
+	"easycart/internal/pagination"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
@@ -29,6 +32,8 @@ type CreateProductRequest struct {
 	IsActive     *bool      `json:"is_active,omitempty"`
 	IsFeatured   *bool      `json:"is_featured,omitempty"`
 	ImageIDs     []uuid.UUID `json:"image_ids,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"` // By attribute code
 }
 
 type UpdateProductRequest struct {
@@ -43,6 +48,9 @@ type UpdateProductRequest struct {
 	IsActive     *bool      `json:"is_active,omitempty"`
 	IsFeatured   *bool      `json:"is_featured,omitempty"`
 	ImageIDs     []uuid.UUID `json:"image_ids,omitempty"`
+	Tags         *[]string  `json:"tags,omitempty"` // Replaces all tags when set
+	// Attributes sets the given attribute values by code; null removes one
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
 }
 
 func NewProductHandler(db *gorm.DB) *ProductHandler {
@@ -61,20 +69,17 @@ func (h *ProductHandler) GetProducts(c echo.Context) error {
 	}
 
 	// Parse query parameters
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	if page < 1 {
-		page = 1
-	}
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	if limit < 1 || limit > 100 {
-		limit = 20
+	params, err := pagination.Parse(c, pagination.NewestFirst("products", 20, 100))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 	search := c.QueryParam("search")
 	categoryID := c.QueryParam("category_id")
 
 	query := db.Where("shop_id = ?", shop.ID).
 		Preload("Category").
-		Preload("Images")
+		Preload("Images").
+		Preload("Tags")
 
 	// Apply search filter
 	if search != "" {
@@ -85,36 +90,26 @@ func (h *ProductHandler) GetProducts(c echo.Context) error {
 	// Apply category filter
 	if categoryID != "" {
 		if catUUID, err := uuid.Parse(categoryID); err == nil {
-			query = query.Where("category_id = ?", catUUID)
+			query = query.Where("category_id IN ("+models.CategorySubtreeSQL+")", catUUID)
 		}
 	}
 
-	// Count total records
-	var total int64
-	query.Model(&models.Product{}).Count(&total)
-
-	// Get paginated results
-	var products []models.Product
-	offset := (page - 1) * limit
-	if err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&products).Error; err != nil {
+	page, err := pagination.Find[models.Product](c, query, params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch products")
+	}
+	products := make([]*models.Product, len(page.Items))
+	for i := range page.Items {
+		products[i] = &page.Items[i]
+	}
+	if err := models.AttachDetails(db, products...); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch products")
 	}
 
 	// Convert to response format
-	var productResponses []models.ProductResponse
-	for _, product := range products {
-		productResponses = append(productResponses, product.ToResponse())
-	}
-
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"products": productResponses,
-		"pagination": map[string]interface{}{
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"total_pages": (total + int64(limit) - 1) / int64(limit),
-		},
-	})
+	return c.JSON(http.StatusOK, pagination.Map(page, func(product models.Product) models.ProductResponse {
+		return product.ToResponse()
+	}))
 }
 
 func (h *ProductHandler) GetProduct(c echo.Context) error {
@@ -139,12 +134,16 @@ func (h *ProductHandler) GetProduct(c echo.Context) error {
 	if err := db.Where("id = ? AND shop_id = ?", productUUID, shop.ID).
 		Preload("Category").
 		Preload("Images").
+		Preload("Tags").
 		First(&product).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return echo.NewHTTPError(http.StatusNotFound, "product not found")
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch product")
 	}
+	if err := models.AttachDetails(db, &product); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch product")
+	}
 
 	return c.JSON(http.StatusOK, product.ToResponse())
 }
@@ -227,24 +226,54 @@ func (h *ProductHandler) CreateProduct(c echo.Context) error {
 		counter++
 	}
 
-	if err := db.Create(&product).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create product")
-	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
 
-	// Associate images if provided
-	if len(req.ImageIDs) > 0 {
-		for i, imageID := range req.ImageIDs {
-			db.Model(&models.Media{}).
-				Where("id = ? AND shop_id = ? AND product_id IS NULL", imageID, shop.ID).
-				Updates(map[string]interface{}{
-					"product_id": product.ID,
-					"sort_order": i,
-				})
+		// Associate images if provided
+		if len(req.ImageIDs) > 0 {
+			for i, imageID := range req.ImageIDs {
+				tx.Model(&models.Media{}).
+					Where("id = ? AND shop_id = ? AND product_id IS NULL", imageID, shop.ID).
+					Updates(map[string]interface{}{
+						"product_id": product.ID,
+						"sort_order": i,
+					})
+			}
+		}
+
+		if _, err := models.SetProductTags(tx, product.ID, req.Tags); err != nil {
+			return err
+		}
+		if _, err := models.SetProductAttributes(tx, product.ID, req.Attributes); err != nil {
+			return err
+		}
+
+		if err := audit.Record(tx, c, "product.create", "product", product.ID, nil, product); err != nil {
+			return err
+		}
+
+		var created models.Product
+		if err := tx.Preload("Category").Preload("Images").Preload("Tags").First(&created, product.ID).Error; err != nil {
+			return err
 		}
+		if err := models.AttachDetails(tx, &created); err != nil {
+			return err
+		}
+		return events.Publish(tx, events.ProductCreated{Product: created.ToResponse()})
+	})
+	var attributeErr *models.AttributeError
+	if errors.As(err, &attributeErr) {
+		return echo.NewHTTPError(http.StatusBadRequest, attributeErr.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create product")
 	}
 
 	// Load the created product with associations
-	db.Preload("Category").Preload("Images").First(&product, product.ID)
+	db.Preload("Category").Preload("Images").Preload("Tags").First(&product, product.ID)
+	models.AttachDetails(db, &product)
 
 	return c.JSON(http.StatusCreated, product.ToResponse())
 }
@@ -279,6 +308,7 @@ func (h *ProductHandler) UpdateProduct(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch product")
 	}
+	before := product
 
 	// Update fields
 	if req.Name != "" {
@@ -333,33 +363,75 @@ func (h *ProductHandler) UpdateProduct(c echo.Context) error {
 		product.IsFeatured = *req.IsFeatured
 	}
 
-	if err := db.Save(&product).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update product")
-	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&product).Error; err != nil {
+			return err
+		}
 
-	// Update image associations if provided
-	if len(req.ImageIDs) > 0 {
-		// Clear existing associations
-		db.Model(&models.Media{}).
-			Where("product_id = ?", product.ID).
-			Updates(map[string]interface{}{
-				"product_id": nil,
-				"sort_order": 0,
-			})
-
-		// Set new associations
-		for i, imageID := range req.ImageIDs {
-			db.Model(&models.Media{}).
-				Where("id = ? AND shop_id = ?", imageID, shop.ID).
+		// Update image associations if provided
+		if len(req.ImageIDs) > 0 {
+			// Clear existing associations
+			tx.Model(&models.Media{}).
+				Where("product_id = ?", product.ID).
 				Updates(map[string]interface{}{
-					"product_id": product.ID,
-					"sort_order": i,
+					"product_id": nil,
+					"sort_order": 0,
 				})
+
+			// Set new associations
+			for i, imageID := range req.ImageIDs {
+				tx.Model(&models.Media{}).
+					Where("id = ? AND shop_id = ?", imageID, shop.ID).
+					Updates(map[string]interface{}{
+						"product_id": product.ID,
+						"sort_order": i,
+					})
+			}
+		}
+
+		if req.Tags != nil {
+			if _, err := models.SetProductTags(tx, product.ID, *req.Tags); err != nil {
+				return err
+			}
 		}
+		if _, err := models.SetProductAttributes(tx, product.ID, req.Attributes); err != nil {
+			return err
+		}
+
+		if err := audit.Record(tx, c, "product.update", "product", product.ID, before, product); err != nil {
+			return err
+		}
+
+		if product.Stock != before.Stock {
+			if err := events.Publish(tx, events.NewStockChanged(&product, before.Stock, events.StockReasonAdjustment)); err != nil {
+				return err
+			}
+		}
+		if product.Price != before.Price {
+			if err := events.Publish(tx, events.NewPriceChanged(&product, before.Price)); err != nil {
+				return err
+			}
+		}
+		var updated models.Product
+		if err := tx.Preload("Category").Preload("Images").Preload("Tags").First(&updated, product.ID).Error; err != nil {
+			return err
+		}
+		if err := models.AttachDetails(tx, &updated); err != nil {
+			return err
+		}
+		return events.Publish(tx, events.ProductUpdated{Product: updated.ToResponse()})
+	})
+	var attributeErr *models.AttributeError
+	if errors.As(err, &attributeErr) {
+		return echo.NewHTTPError(http.StatusBadRequest, attributeErr.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update product")
 	}
 
 	// Load updated product with associations
-	db.Preload("Category").Preload("Images").First(&product, product.ID)
+	db.Preload("Category").Preload("Images").Preload("Tags").First(&product, product.ID)
+	models.AttachDetails(db, &product)
 
 	return c.JSON(http.StatusOK, product.ToResponse())
 }
@@ -381,14 +453,26 @@ func (h *ProductHandler) DeleteProduct(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "shop not found")
 	}
 
-	// Delete product (associated images will be unlinked due to FK constraints)
-	result := db.Where("id = ? AND shop_id = ?", productUUID, shop.ID).Delete(&models.Product{})
-	if result.Error != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete product")
+	var product models.Product
+	if err := db.Where("id = ? AND shop_id = ?", productUUID, shop.ID).First(&product).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "product not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch product")
 	}
 
-	if result.RowsAffected == 0 {
-		return echo.NewHTTPError(http.StatusNotFound, "product not found")
+	// Delete product (associated images will be unlinked due to FK constraints)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&product).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, c, "product.delete", "product", product.ID, product, nil); err != nil {
+			return err
+		}
+		return events.Publish(tx, events.ProductDeleted{ProductID: product.ID, SKU: product.SKU})
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete product")
 	}
 
 	return c.NoContent(http.StatusNoContent)
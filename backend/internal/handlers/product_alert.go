@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"easycart/internal/models"
+	"easycart/internal/notifications"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxPendingAlerts caps the unconfirmed alerts an email gets in a day, and
+// so the confirmation emails sent to an address nobody confirms
+const maxPendingAlerts = 5
+
+// ProductAlertHandler serves back-in-stock and price drop alerts: shoppers
+// subscribe in the storefront and admins see the demand
+type ProductAlertHandler struct {
+	db          *gorm.DB
+	frontendURL string
+	notifier    *notifications.Notifier
+}
+
+func NewProductAlertHandler(db *gorm.DB, frontendURL string, notifier *notifications.Notifier) *ProductAlertHandler {
+	return &ProductAlertHandler{db: db, frontendURL: frontendURL, notifier: notifier}
+}
+
+type ProductAlertRequest struct {
+	Email     string                  `json:"email" validate:"required,email"`
+	Kind      models.ProductAlertKind `json:"kind" validate:"required"`
+	VariantID *uuid.UUID              `json:"variant_id,omitempty"`
+}
+
+type AlertTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// alertDemand is the alerts on one product
+type alertDemand struct {
+	ProductID   uuid.UUID `json:"product_id"`
+	Name        string    `json:"name"`
+	SKU         string    `json:"sku"`
+	Stock       int       `json:"stock"`
+	Price       int       `json:"price"`
+	BackInStock int64     `json:"back_in_stock"` // Waiting for stock
+	PriceDrop   int64     `json:"price_drop"`    // Waiting for a lower price
+	Notified    int64     `json:"notified"`      // Alerts already sent
+}
+
+// Subscribe signs an email up to hear when a product, or one variant of it,
+// is back in stock or gets cheaper (public endpoint). A new alert waits for
+// the confirmation link emailed to it. Subscribing again keeps the one
+// alert.
+func (h *ProductAlertHandler) Subscribe(c echo.Context) error {
+	productID, err := uuid.Parse(c.Param("productId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid product ID"})
+	}
+
+	var req ProductAlertRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if !req.Kind.IsValid() {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid kind: use back_in_stock or price_drop"})
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	product, variant, err := models.FindPurchasable(h.db, productID, req.VariantID)
+	if errors.Is(err, models.ErrProductUnavailable) || errors.Is(err, models.ErrVariantMismatch) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	if req.Kind == models.AlertBackInStock && models.InStock(product, variant) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Product is in stock"})
+	}
+	price := models.CurrentPrice(product, variant)
+
+	var alert models.ProductAlert
+	query := h.db.Where("kind = ? AND email = ? AND product_id = ?", req.Kind, email, product.ID)
+	err = models.WhereVariant(query, req.VariantID).First(&alert).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		return h.createAlert(c, models.ProductAlert{Kind: req.Kind, Email: email, ProductID: product.ID, VariantID: req.VariantID, Price: price}, product, variant)
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	if alert.ConfirmedAt == nil {
+		return c.JSON(http.StatusOK, map[string]string{"message": "Check your email to confirm the alert"})
+	}
+
+	// An alert already sent starts waiting again from today's price
+	if alert.NotifiedAt != nil {
+		err := h.db.Model(&alert).Updates(map[string]interface{}{"notified_at": nil, "price": price}).Error
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to subscribe"})
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Subscribed"})
+}
+
+// createAlert saves a new alert and emails its confirmation link, unless
+// the email already has too many alerts waiting for confirmation
+func (h *ProductAlertHandler) createAlert(c echo.Context, alert models.ProductAlert, product *models.Product, variant *models.ProductVariant) error {
+	var pending int64
+	err := h.db.Model(&models.ProductAlert{}).
+		Where("email = ? AND confirmed_at IS NULL AND created_at > ?", alert.Email, time.Now().Add(-24*time.Hour)).
+		Count(&pending).Error
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	if pending >= maxPendingAlerts {
+		return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "Too many unconfirmed alerts for this email; confirm them or try again tomorrow"})
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&alert).Error; err != nil {
+			return err
+		}
+		confirmURL := h.frontendURL + "/alerts/confirm?token=" + alert.Token
+		return h.notifier.AlertConfirmation(tx, &alert, product, variant, confirmURL)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to subscribe"})
+	}
+	return c.JSON(http.StatusCreated, map[string]string{"message": "Check your email to confirm the alert"})
+}
+
+// Confirm activates the alert of a confirmation link (public endpoint). One
+// already due, e.g. restocked since, is sent right away.
+func (h *ProductAlertHandler) Confirm(c echo.Context) error {
+	var req AlertTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var alert models.ProductAlert
+		if err := tx.Clauses(lockForUpdate).Where("token = ?", req.Token).First(&alert).Error; err != nil {
+			return err
+		}
+		if alert.ConfirmedAt != nil {
+			return nil
+		}
+		if err := tx.Model(&alert).Update("confirmed_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return h.notifier.QueueProductAlerts(tx, alert.ProductID)
+	})
+	if err == gorm.ErrRecordNotFound {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Alert not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to confirm alert"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Subscribed"})
+}
+
+// Unsubscribe removes the alert of an unsubscribe link (public endpoint)
+func (h *ProductAlertHandler) Unsubscribe(c echo.Context) error {
+	var req AlertTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	result := h.db.Where("token = ?", req.Token).Delete(&models.ProductAlert{})
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Alert not found"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Unsubscribed"})
+}
+
+// GetAlertDemand lists the products shoppers are waiting on, most wanted
+// first, counting confirmed alerts only. limit caps the rows, 50 by default
+// and at most 200.
+func (h *ProductAlertHandler) GetAlertDemand(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	rows := []alertDemand{}
+	err := h.db.Raw(`
+		SELECT p.id AS product_id, p.name, p.sku, p.stock, p.price,
+			COUNT(*) FILTER (WHERE a.kind = ? AND a.notified_at IS NULL) AS back_in_stock,
+			COUNT(*) FILTER (WHERE a.kind = ? AND a.notified_at IS NULL) AS price_drop,
+			COUNT(*) FILTER (WHERE a.notified_at IS NOT NULL) AS notified
+		FROM product_alerts a
+		JOIN products p ON p.id = a.product_id
+		WHERE a.confirmed_at IS NOT NULL
+		GROUP BY p.id
+		ORDER BY COUNT(*) FILTER (WHERE a.notified_at IS NULL) DESC, notified DESC, p.name
+		LIMIT ?`,
+		models.AlertBackInStock, models.AlertPriceDrop, limit,
+	).Scan(&rows).Error
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count alerts")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"products": rows})
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"easycart/internal/audit"
+	"easycart/internal/catalog"
+	"easycart/internal/models"
+	"easycart/internal/pagination"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type ProductImportHandler struct {
+	db *gorm.DB
+}
+
+func NewProductImportHandler(db *gorm.DB) *ProductImportHandler {
+	return &ProductImportHandler{db: db}
+}
+
+// productImportResponse adds the progress to an import
+type productImportResponse struct {
+	models.ProductImport
+	Progress float64 `json:"progress"`
+}
+
+func newProductImportResponse(imp models.ProductImport) productImportResponse {
+	return productImportResponse{ProductImport: imp, Progress: imp.Progress()}
+}
+
+// ExportProducts downloads the whole catalog as CSV in the import format
+func (h *ProductImportHandler) ExportProducts(c echo.Context) error {
+	filename := "products-" + time.Now().Format("20060102-150405") + ".csv"
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	c.Response().WriteHeader(http.StatusOK)
+
+	return catalog.Export(h.db, c.Response())
+}
+
+// ImportProducts accepts a catalog CSV, either as the "file" field of a
+// multipart form or as the request body, in the format named by the format
+// parameter: easycart (the default), shopify or woocommerce. With dry_run=true
+// it only reports what the import would do and the problems of each row;
+// otherwise the file is imported in the background and can be followed with
+// GetProductImport.
+func (h *ProductImportHandler) ImportProducts(c echo.Context) error {
+	filename, content, err := readImportFile(c)
+	if err != nil {
+		return err
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = c.FormValue("format")
+	}
+	if format == "" {
+		format = catalog.FormatNative
+	}
+
+	batch, err := catalog.Parse(format, bytes.NewReader(content))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Failed to read file: "+err.Error())
+	}
+
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+	if dryRun {
+		preview, err := catalog.Validate(h.db, batch)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to validate import: "+err.Error())
+		}
+		return c.JSON(http.StatusOK, preview)
+	}
+
+	if len(batch.Products) == 0 {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+			"message": "The file has no valid products",
+			"errors":  batch.Errors,
+		})
+	}
+
+	imp := models.ProductImport{
+		Filename:      filename,
+		Format:        format,
+		Content:       string(content),
+		TotalProducts: len(batch.Products),
+	}
+	if userID, ok := c.Get("user_id").(uuid.UUID); ok {
+		imp.CreatedByID = &userID
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&imp).Error; err != nil {
+			return err
+		}
+		if err := catalog.QueueImport(tx, &imp); err != nil {
+			return err
+		}
+		return audit.RecordAction(tx, c, "product.import", "product_import", imp.ID, models.JSONMap{
+			"filename": filename,
+			"format":   format,
+			"products": len(batch.Products),
+		})
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to queue import: "+err.Error())
+	}
+
+	return c.JSON(http.StatusAccepted, newProductImportResponse(imp))
+}
+
+// GetProductImports lists imports, newest first
+func (h *ProductImportHandler) GetProductImports(c echo.Context) error {
+	params, err := pagination.Parse(c, pagination.NewestFirst("imports", 20, 100))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Row errors can be long; they are only returned for a single import
+	page, err := pagination.Find[models.ProductImport](c, h.db.Omit("content", "errors"), params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch imports: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, pagination.Map(page, newProductImportResponse))
+}
+
+// GetProductImport returns the progress and row errors of an import
+func (h *ProductImportHandler) GetProductImport(c echo.Context) error {
+	importID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid import ID")
+	}
+
+	var imp models.ProductImport
+	if err := h.db.Omit("content").First(&imp, "id = ?", importID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Import not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get import: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, newProductImportResponse(imp))
+}
+
+// readImportFile reads the uploaded file, up to catalog.MaxFileSize
+func readImportFile(c echo.Context) (string, []byte, error) {
+	filename := "upload.csv"
+	var reader io.Reader = c.Request().Body
+
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return "", nil, echo.NewHTTPError(http.StatusBadRequest, "Missing file")
+		}
+		if fileHeader.Size > catalog.MaxFileSize {
+			return "", nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "File is too large")
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return "", nil, echo.NewHTTPError(http.StatusBadRequest, "Failed to read file")
+		}
+		defer file.Close()
+		filename = fileHeader.Filename
+		reader = file
+	}
+
+	content, err := io.ReadAll(io.LimitReader(reader, catalog.MaxFileSize+1))
+	if err != nil {
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest, "Failed to read file")
+	}
+	if len(content) > catalog.MaxFileSize {
+		return "", nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "File is too large")
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest, "File is empty")
+	}
+	return filename, content, nil
+}
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"easycart/internal/models"
+	"easycart/internal/reports"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type ReportHandler struct {
+	db *gorm.DB
+}
+
+func NewReportHandler(db *gorm.DB) *ReportHandler {
+	return &ReportHandler{db: db}
+}
+
+// Every report takes from and to dates (inclusive, in the shop time zone),
+// compare=previous to add the period of the same length before, and
+// format=csv to download the report instead of JSON.
+
+// GetSalesReport returns revenue, orders, average order value and units sold
+// bucketed by day, week or month
+func (h *ReportHandler) GetSalesReport(c echo.Context) error {
+	period, err := h.period(c)
+	if err != nil {
+		return err
+	}
+
+	sales, err := reports.SalesOverTime(h.db, period)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute sales: "+err.Error())
+	}
+
+	var previous *reports.Sales
+	if comparePrevious(c) {
+		if previous, err = reports.SalesOverTime(h.db, period.Previous()); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute sales: "+err.Error())
+		}
+	}
+
+	if wantsCSV(c) {
+		header := []string{"bucket", "revenue", "refunded", "net_revenue", "orders", "average_order_value", "units"}
+		if previous != nil {
+			header = append(header, "previous_bucket", "previous_revenue", "previous_net_revenue", "previous_orders", "previous_average_order_value", "previous_units")
+		}
+		var rows [][]string
+		for i, point := range sales.Series {
+			row := []string{
+				point.Bucket, itoa(point.Revenue), itoa(point.Refunded), itoa(point.NetRevenue),
+				itoa(point.Orders), itoa(point.AverageOrderValue), itoa(point.Units),
+			}
+			// Buckets are matched by position; a period may have one more partial week or month
+			if previous != nil && i < len(previous.Series) {
+				prev := previous.Series[i]
+				row = append(row, prev.Bucket, itoa(prev.Revenue), itoa(prev.NetRevenue), itoa(prev.Orders), itoa(prev.AverageOrderValue), itoa(prev.Units))
+			}
+			rows = append(rows, row)
+		}
+		return writeReportCSV(c, "sales", period, header, rows)
+	}
+
+	response := map[string]interface{}{
+		"period": sales.Period,
+		"totals": sales.Totals,
+		"series": sales.Series,
+	}
+	if previous != nil {
+		response["previous"] = previous
+		response["change"] = reports.CompareSales(sales.Totals, previous.Totals)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetTopProducts ranks products by revenue, or by units with sort=units
+func (h *ReportHandler) GetTopProducts(c echo.Context) error {
+	period, err := h.period(c)
+	if err != nil {
+		return err
+	}
+
+	products, err := reports.TopProducts(h.db, period, ranking(c), reportLimit(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute top products: "+err.Error())
+	}
+	compare := comparePrevious(c)
+	if compare {
+		if err := reports.CompareProducts(h.db, period.Previous(), products); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute top products: "+err.Error())
+		}
+	}
+
+	if wantsCSV(c) {
+		header := []string{"product_id", "sku", "name", "units", "revenue", "orders"}
+		if compare {
+			header = append(header, "previous_units", "previous_revenue")
+		}
+		var rows [][]string
+		for _, product := range products {
+			row := []string{product.ProductID.String(), product.SKU, product.Name, itoa(product.Units), itoa(product.Revenue), itoa(product.Orders)}
+			if compare {
+				row = append(row, itoa(*product.PreviousUnits), itoa(*product.PreviousRevenue))
+			}
+			rows = append(rows, row)
+		}
+		return writeReportCSV(c, "top-products", period, header, rows)
+	}
+
+	response := map[string]interface{}{
+		"period":   period.View(),
+		"products": products,
+	}
+	if compare {
+		response["previous_period"] = period.Previous().View()
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetTopCategories ranks categories by revenue, or by units with sort=units
+func (h *ReportHandler) GetTopCategories(c echo.Context) error {
+	period, err := h.period(c)
+	if err != nil {
+		return err
+	}
+
+	categories, err := reports.TopCategories(h.db, period, ranking(c), reportLimit(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute top categories: "+err.Error())
+	}
+	compare := comparePrevious(c)
+	if compare {
+		if err := reports.CompareCategories(h.db, period.Previous(), categories); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute top categories: "+err.Error())
+		}
+	}
+
+	if wantsCSV(c) {
+		header := []string{"category_id", "name", "units", "revenue", "orders"}
+		if compare {
+			header = append(header, "previous_units", "previous_revenue")
+		}
+		var rows [][]string
+		for _, category := range categories {
+			row := []string{uuidString(category.CategoryID), category.Name, itoa(category.Units), itoa(category.Revenue), itoa(category.Orders)}
+			if compare {
+				row = append(row, itoa(*category.PreviousUnits), itoa(*category.PreviousRevenue))
+			}
+			rows = append(rows, row)
+		}
+		return writeReportCSV(c, "top-categories", period, header, rows)
+	}
+
+	response := map[string]interface{}{
+		"period":     period.View(),
+		"categories": categories,
+	}
+	if compare {
+		response["previous_period"] = period.Previous().View()
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetCustomerReport splits buyers into new and returning customers
+func (h *ReportHandler) GetCustomerReport(c echo.Context) error {
+	period, err := h.period(c)
+	if err != nil {
+		return err
+	}
+
+	customers, err := reports.CustomerActivity(h.db, period)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute customer report: "+err.Error())
+	}
+	var previous *reports.Customers
+	if comparePrevious(c) {
+		if previous, err = reports.CustomerActivity(h.db, period.Previous()); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute customer report: "+err.Error())
+		}
+	}
+
+	if wantsCSV(c) {
+		header := []string{"period_from", "period_to", "segment", "customers", "orders", "revenue"}
+		var rows [][]string
+		for _, report := range []*reports.Customers{customers, previous} {
+			if report == nil {
+				continue
+			}
+			for _, segment := range []struct {
+				name string
+				reports.CustomerSegment
+			}{{"new", report.New}, {"returning", report.Returning}} {
+				rows = append(rows, []string{report.Period.From, report.Period.To, segment.name, itoa(segment.Customers), itoa(segment.Orders), itoa(segment.Revenue)})
+			}
+		}
+		return writeReportCSV(c, "customers", period, header, rows)
+	}
+
+	response := map[string]interface{}{
+		"period":         customers.Period,
+		"new":            customers.New,
+		"returning":      customers.Returning,
+		"returning_rate": customers.ReturningRate,
+	}
+	if previous != nil {
+		response["previous"] = previous
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetRefundReport returns the share of paid orders that were refunded
+func (h *ReportHandler) GetRefundReport(c echo.Context) error {
+	period, err := h.period(c)
+	if err != nil {
+		return err
+	}
+
+	refunds, err := reports.RefundRate(h.db, period)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute refund rate: "+err.Error())
+	}
+	var previous *reports.Refunds
+	if comparePrevious(c) {
+		if previous, err = reports.RefundRate(h.db, period.Previous()); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute refund rate: "+err.Error())
+		}
+	}
+
+	if wantsCSV(c) {
+		header := []string{"period_from", "period_to", "paid_orders", "refunded_orders", "paid_amount", "refunded_amount", "refund_rate", "amount_refund_rate"}
+		var rows [][]string
+		for _, report := range []*reports.Refunds{refunds, previous} {
+			if report == nil {
+				continue
+			}
+			rows = append(rows, []string{
+				report.Period.From, report.Period.To,
+				itoa(report.PaidOrders), itoa(report.RefundedOrders), itoa(report.PaidAmount), itoa(report.RefundedAmount),
+				ftoa(report.RefundRate), ftoa(report.AmountRefundRate),
+			})
+		}
+		return writeReportCSV(c, "refunds", period, header, rows)
+	}
+
+	if previous == nil {
+		return c.JSON(http.StatusOK, refunds)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"current":  refunds,
+		"previous": previous,
+	})
+}
+
+// GetFunnelReport follows the period's orders from checkout to delivery
+func (h *ReportHandler) GetFunnelReport(c echo.Context) error {
+	period, err := h.period(c)
+	if err != nil {
+		return err
+	}
+
+	funnel, err := reports.OrderFunnel(h.db, period)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute funnel: "+err.Error())
+	}
+	var previous *reports.Funnel
+	if comparePrevious(c) {
+		if previous, err = reports.OrderFunnel(h.db, period.Previous()); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute funnel: "+err.Error())
+		}
+	}
+
+	if wantsCSV(c) {
+		header := []string{"period_from", "period_to", "stage", "count", "rate", "overall"}
+		var rows [][]string
+		for _, report := range []*reports.Funnel{funnel, previous} {
+			if report == nil {
+				continue
+			}
+			for _, stage := range report.Stages {
+				rows = append(rows, []string{report.Period.From, report.Period.To, stage.Stage, itoa(stage.Count), ftoa(stage.Rate), ftoa(stage.Overall)})
+			}
+		}
+		return writeReportCSV(c, "funnel", period, header, rows)
+	}
+
+	if previous == nil {
+		return c.JSON(http.StatusOK, funnel)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"current":  funnel,
+		"previous": previous,
+	})
+}
+
+// GetWishlistReport ranks the products customers added to wishlists
+func (h *ReportHandler) GetWishlistReport(c echo.Context) error {
+	period, err := h.period(c)
+	if err != nil {
+		return err
+	}
+
+	products, err := reports.MostWishlisted(h.db, period, reportLimit(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compute wishlist report: "+err.Error())
+	}
+
+	if wantsCSV(c) {
+		header := []string{"product_id", "sku", "name", "added", "customers", "saved"}
+		var rows [][]string
+		for _, product := range products {
+			rows = append(rows, []string{product.ProductID.String(), product.SKU, product.Name, itoa(product.Added), itoa(product.Customers), itoa(product.Saved)})
+		}
+		return writeReportCSV(c, "wishlists", period, header, rows)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"period":   period.View(),
+		"products": products,
+	})
+}
+
+// period reads the report period in the shop's time zone
+func (h *ReportHandler) period(c echo.Context) (reports.Period, error) {
+	settings, err := models.GetSettings(h.db)
+	if err != nil {
+		return reports.Period{}, echo.NewHTTPError(http.StatusInternalServerError, "Failed to get settings: "+err.Error())
+	}
+
+	period, err := reports.ParsePeriod(c.QueryParam("from"), c.QueryParam("to"), c.QueryParam("interval"), settings.Location(), time.Now())
+	if err != nil {
+		return reports.Period{}, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return period, nil
+}
+
+func comparePrevious(c echo.Context) bool {
+	return c.QueryParam("compare") == "previous"
+}
+
+func wantsCSV(c echo.Context) bool {
+	return c.QueryParam("format") == "csv"
+}
+
+func ranking(c echo.Context) reports.Ranking {
+	if c.QueryParam("sort") == string(reports.ByUnits) {
+		return reports.ByUnits
+	}
+	return reports.ByRevenue
+}
+
+func reportLimit(c echo.Context) int {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	return limit
+}
+
+func writeReportCSV(c echo.Context, name string, period reports.Period, header []string, rows [][]string) error {
+	view := period.View()
+	filename := name + "-" + view.From + "-" + view.To + ".csv"
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	c.Response().WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(c.Response())
+	writer.Write(header)
+	writer.WriteAll(rows)
+	return writer.Error()
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
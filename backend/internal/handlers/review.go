@@ -0,0 +1,512 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"easycart/internal/audit"
+	"easycart/internal/models"
+	"easycart/internal/pagination"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxReviewTitle = 150
+	maxReviewBody  = 5000
+
+	// reviewDuplicateWindow is how long the same text cannot be posted again
+	reviewDuplicateWindow = 30 * 24 * time.Hour
+)
+
+// ReviewHandler serves product reviews: writing them under /me, the public
+// listing and the moderation queue
+type ReviewHandler struct {
+	db *gorm.DB
+}
+
+func NewReviewHandler(db *gorm.DB) *ReviewHandler {
+	return &ReviewHandler{db: db}
+}
+
+type CreateReviewRequest struct {
+	ProductID uuid.UUID   `json:"product_id" validate:"required"`
+	Rating    int         `json:"rating" validate:"required,min=1,max=5"`
+	Title     string      `json:"title"`
+	Body      string      `json:"body" validate:"required"`
+	ImageIDs  []uuid.UUID `json:"image_ids,omitempty"` // Uploaded with POST /me/review-images
+}
+
+type RejectReviewRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReviewResponseRequest sets the shop's answer; blank removes it
+type ReviewResponseRequest struct {
+	Response string `json:"response"`
+}
+
+// adminReview shows moderators who wrote a review
+type adminReview struct {
+	models.Review
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// reviewSorts are the orders of the public listing
+var reviewSorts = map[string]pagination.Options{
+	"newest": pagination.NewestFirst("reviews", 10, 50),
+	"helpful": {
+		Key:          "reviews",
+		DefaultLimit: 10,
+		MaxLimit:     50,
+		Keys: append([]pagination.Key{{Column: "helpful_count", Field: "HelpfulCount", Type: "integer"}},
+			pagination.Newest...),
+		Desc: true,
+	},
+}
+
+// CreateReview writes the signed-in customer's review of a product. It waits
+// for moderation before it is shown.
+func (h *ReviewHandler) CreateReview(c echo.Context) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	var req CreateReviewRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	req.Body = strings.TrimSpace(req.Body)
+	if len([]rune(req.Title)) > maxReviewTitle || len([]rune(req.Body)) > maxReviewBody {
+		return echo.NewHTTPError(http.StatusBadRequest, "title or body is too long")
+	}
+	if len(req.ImageIDs) > models.MaxReviewImages {
+		return echo.NewHTTPError(http.StatusBadRequest, "too many images")
+	}
+	if err := models.CheckReviewContent(req.Title, req.Body); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "user not found")
+	}
+	var product models.Product
+	if err := h.db.Where("id = ? AND is_active = true", req.ProductID).First(&product).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "product not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch product")
+	}
+
+	var count int64
+	if err := h.db.Model(&models.Review{}).Where("product_id = ? AND user_id = ?", product.ID, userID).Count(&count).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check reviews")
+	}
+	if count > 0 {
+		return echo.NewHTTPError(http.StatusConflict, "you have already reviewed this product")
+	}
+
+	// The same text again, from this customer on any product or from anyone
+	// on this one, is a duplicate
+	fingerprint := models.ReviewFingerprint(req.Title, req.Body)
+	err := h.db.Model(&models.Review{}).
+		Where("fingerprint = ? AND created_at > ?", fingerprint, time.Now().Add(-reviewDuplicateWindow)).
+		Where("user_id = ? OR product_id = ?", userID, product.ID).
+		Count(&count).Error
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check reviews")
+	}
+	if count > 0 {
+		return echo.NewHTTPError(http.StatusConflict, models.ErrReviewDuplicate.Error())
+	}
+
+	verified, err := models.IsVerifiedPurchase(h.db, &user, product.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check purchases")
+	}
+
+	review := models.Review{
+		ProductID:        product.ID,
+		UserID:           userID,
+		AuthorName:       models.ReviewAuthorName(&user),
+		Rating:           req.Rating,
+		Title:            req.Title,
+		Body:             req.Body,
+		VerifiedPurchase: verified,
+		Status:           models.ReviewPending,
+		Fingerprint:      fingerprint,
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Images").Create(&review).Error; err != nil {
+			return err
+		}
+		for i, imageID := range req.ImageIDs {
+			result := tx.Model(&models.ReviewImage{}).
+				Where("id = ? AND user_id = ? AND review_id IS NULL", imageID, userID).
+				Updates(map[string]interface{}{"review_id": review.ID, "sort_order": i})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errReviewImage
+			}
+		}
+		return tx.Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("sort_order ASC") }).
+			First(&review, review.ID).Error
+	})
+	if errors.Is(err, errReviewImage) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	// A second review sent at the same time gets past the check above
+	if isUniqueViolation(err, "idx_reviews_product_user") {
+		return echo.NewHTTPError(http.StatusConflict, "you have already reviewed this product")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save review")
+	}
+
+	return c.JSON(http.StatusCreated, review)
+}
+
+var errReviewImage = errors.New("image not found or already used")
+
+// GetMyReviews lists the signed-in customer's reviews with their status,
+// newest first
+func (h *ReviewHandler) GetMyReviews(c echo.Context) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	params, err := pagination.Parse(c, pagination.NewestFirst("reviews", 20, 100))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	query := h.db.Model(&models.Review{}).Where("user_id = ?", userID).
+		Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("sort_order ASC") })
+	page, err := pagination.Find[models.Review](c, query, params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch reviews")
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// DeleteMyReview removes one of the signed-in customer's reviews
+func (h *ReviewHandler) DeleteMyReview(c echo.Context) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	reviewID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid review ID")
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var review models.Review
+		if err := tx.Where("id = ? AND user_id = ?", reviewID, userID).First(&review).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&review).Error; err != nil {
+			return err
+		}
+		return models.RefreshProductRating(tx, review.ProductID)
+	})
+	if err == gorm.ErrRecordNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, "review not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete review")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// MarkHelpful records that the signed-in customer found an approved review
+// helpful. Voting again changes nothing.
+func (h *ReviewHandler) MarkHelpful(c echo.Context) error {
+	return h.vote(c, true)
+}
+
+// UnmarkHelpful takes the signed-in customer's vote back
+func (h *ReviewHandler) UnmarkHelpful(c echo.Context) error {
+	return h.vote(c, false)
+}
+
+func (h *ReviewHandler) vote(c echo.Context, helpful bool) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	reviewID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid review ID")
+	}
+
+	var review models.Review
+	if err := h.db.Where("id = ? AND status = ?", reviewID, models.ReviewApproved).First(&review).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "review not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch review")
+	}
+	if review.UserID == userID {
+		return echo.NewHTTPError(http.StatusForbidden, "you cannot vote on your own review")
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var result *gorm.DB
+		if helpful {
+			result = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ReviewVote{ReviewID: review.ID, UserID: userID})
+		} else {
+			result = tx.Where("review_id = ? AND user_id = ?", review.ID, userID).Delete(&models.ReviewVote{})
+		}
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.Review{}).Where("id = ?", review.ID).
+			UpdateColumn("helpful_count", tx.Model(&models.ReviewVote{}).Select("COUNT(*)").Where("review_id = ?", review.ID)).Error
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record vote")
+	}
+
+	if err := h.db.First(&review, review.ID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch review")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"helpful_count": review.HelpfulCount})
+}
+
+// GetProductReviews lists the approved reviews of an active product, with
+// the rating summary (public endpoint). sort is newest or helpful; rating
+// shows only reviews with that many stars.
+func (h *ReviewHandler) GetProductReviews(c echo.Context) error {
+	productID, err := uuid.Parse(c.Param("productId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid product ID"})
+	}
+	sort := c.QueryParam("sort")
+	if sort == "" {
+		sort = "newest"
+	}
+	opts, ok := reviewSorts[sort]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid sort: use newest or helpful"})
+	}
+	params, err := pagination.Parse(c, opts)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var product models.Product
+	if err := h.db.Where("id = ? AND is_active = true", productID).First(&product).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Product not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+
+	approved := func() *gorm.DB {
+		return h.db.Model(&models.Review{}).Where("product_id = ? AND status = ?", product.ID, models.ReviewApproved)
+	}
+	query := approved().Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("sort_order ASC") })
+	if value := c.QueryParam("rating"); value != "" {
+		rating, err := strconv.Atoi(value)
+		if err != nil || rating < 1 || rating > 5 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid rating: use 1 to 5"})
+		}
+		query = query.Where("rating = ?", rating)
+	}
+
+	page, err := pagination.Find[models.Review](c, query, params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+
+	// Stars to the number of reviews with them
+	var rows []struct {
+		Rating int
+		Count  int
+	}
+	if err := approved().Select("rating, COUNT(*) AS count").Group("rating").Scan(&rows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	distribution := map[string]int{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0}
+	for _, row := range rows {
+		distribution[strconv.Itoa(row.Rating)] = row.Count
+	}
+
+	return c.JSON(http.StatusOK, pagination.Map(page, func(review models.Review) models.PublicReview {
+		return review.ToPublic()
+	}).Set("sort", sort).Set("summary", map[string]interface{}{
+		"average":      product.RatingAverage,
+		"count":        product.RatingCount,
+		"distribution": distribution,
+	}))
+}
+
+// GetReviews is the moderation queue: reviews with a status, pending by
+// default, oldest first
+func (h *ReviewHandler) GetReviews(c echo.Context) error {
+	params, err := pagination.Parse(c, pagination.Options{Key: "reviews", DefaultLimit: 50, MaxLimit: 100, Keys: pagination.Newest})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	status := models.ReviewStatus(c.QueryParam("status"))
+	if status == "" {
+		status = models.ReviewPending
+	}
+	query := h.db.Model(&models.Review{}).Where("status = ?", status).
+		Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("sort_order ASC") })
+	if value := c.QueryParam("product_id"); value != "" {
+		productID, err := uuid.Parse(value)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid product_id")
+		}
+		query = query.Where("product_id = ?", productID)
+	}
+
+	page, err := pagination.Find[models.Review](c, query, params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch reviews")
+	}
+
+	return c.JSON(http.StatusOK, pagination.Map(page, func(review models.Review) adminReview {
+		return adminReview{Review: review, UserID: review.UserID}
+	}))
+}
+
+func (h *ReviewHandler) GetReview(c echo.Context) error {
+	review, err := h.findReview(h.db, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, adminReview{Review: *review, UserID: review.UserID})
+}
+
+// ApproveReview shows a review and counts it in the product's rating
+func (h *ReviewHandler) ApproveReview(c echo.Context) error {
+	return h.moderate(c, "review.approve", func(review *models.Review) error {
+		review.Status = models.ReviewApproved
+		review.RejectionReason = ""
+		return nil
+	})
+}
+
+// RejectReview hides a review, with a reason the customer can see
+func (h *ReviewHandler) RejectReview(c echo.Context) error {
+	var req RejectReviewRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	return h.moderate(c, "review.reject", func(review *models.Review) error {
+		review.Status = models.ReviewRejected
+		review.RejectionReason = strings.TrimSpace(req.Reason)
+		return nil
+	})
+}
+
+// RespondToReview sets the shop's public answer to a review
+func (h *ReviewHandler) RespondToReview(c echo.Context) error {
+	var req ReviewResponseRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	response := strings.TrimSpace(req.Response)
+	if len([]rune(response)) > maxReviewBody {
+		return echo.NewHTTPError(http.StatusBadRequest, "response is too long")
+	}
+	return h.moderate(c, "review.respond", func(review *models.Review) error {
+		review.Response = response
+		review.RespondedAt = nil
+		if response != "" {
+			now := time.Now()
+			review.RespondedAt = &now
+		}
+		return nil
+	})
+}
+
+// moderate changes a review, records who did and refreshes the product's
+// rating
+func (h *ReviewHandler) moderate(c echo.Context, action string, change func(*models.Review) error) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	var review *models.Review
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if review, err = h.findReview(tx, c.Param("id")); err != nil {
+			return err
+		}
+		before := *review
+		if err := change(review); err != nil {
+			return err
+		}
+		if review.Status != before.Status {
+			now := time.Now()
+			review.ModeratedAt, review.ModeratedBy = &now, &userID
+		}
+		if err := tx.Omit("Images").Save(review).Error; err != nil {
+			return err
+		}
+		if err := models.RefreshProductRating(tx, review.ProductID); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, action, "review", review.ID, before, review)
+	})
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update review")
+	}
+
+	return c.JSON(http.StatusOK, adminReview{Review: *review, UserID: review.UserID})
+}
+
+func (h *ReviewHandler) DeleteReview(c echo.Context) error {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		review, err := h.findReview(tx, c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(review).Error; err != nil {
+			return err
+		}
+		if err := models.RefreshProductRating(tx, review.ProductID); err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "review.delete", "review", review.ID, review, nil)
+	})
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete review")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *ReviewHandler) findReview(tx *gorm.DB, id string) (*models.Review, error) {
+	reviewID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid review ID")
+	}
+	var review models.Review
+	err = tx.Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("sort_order ASC") }).
+		Where("id = ?", reviewID).First(&review).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "review not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch review")
+	}
+	return &review, nil
+}
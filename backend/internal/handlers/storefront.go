@@ -1,22 +1,46 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 
+	"easycart/internal/events"
 	"easycart/internal/models"
+	"easycart/internal/notifications"
+	"easycart/internal/pagination"
+	"easycart/internal/search"
+	"easycart/internal/tokens"
 )
 
 type StorefrontHandler struct {
-	db *gorm.DB
+	db          *gorm.DB
+	jwtSecret   string
+	frontendURL string
+	notifier    *notifications.Notifier
+	search      search.Engine
 }
 
-func NewStorefrontHandler(db *gorm.DB) *StorefrontHandler {
-	return &StorefrontHandler{db: db}
+// orderStatusLinkTTL is how long a signed order status link keeps working
+const orderStatusLinkTTL = 180 * 24 * time.Hour
+
+func NewStorefrontHandler(db *gorm.DB, jwtSecret, frontendURL string, notifier *notifications.Notifier) *StorefrontHandler {
+	return &StorefrontHandler{
+		db:          db,
+		jwtSecret:   jwtSecret,
+		frontendURL: frontendURL,
+		notifier:    notifier,
+		search:      search.NewPostgres(db),
+	}
 }
 
 // GetShop gets the shop settings (public endpoint)
@@ -29,55 +53,252 @@ func (h *StorefrontHandler) GetShop(c echo.Context) error {
 	return c.JSON(http.StatusOK, settings)
 }
 
-// GetShopProducts gets all products (public endpoint)
+// GetShopProducts lists active products, optionally matching the search
+// text, narrowed by filters and with facet counts for a filter sidebar
+// (public endpoint). Filters: category_id, subcategories included,
+// min_price and max_price in cents, in_stock, featured, on_sale,
+// option=Name:Value and tag, both repeatable, and for filterable
+// attributes attribute=code:value, repeatable, and attribute_min and
+// attribute_max=code:number. Sorts: relevance, newest, price_asc,
+// price_desc, name and best_selling. facets=false leaves the counts out.
 func (h *StorefrontHandler) GetShopProducts(c echo.Context) error {
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	if page < 1 {
-		page = 1
+	return h.listProducts(c, nil)
+}
+
+// listProducts lists the active products of the shop, or of a collection,
+// with the filters, sorts and facets of GetShopProducts
+func (h *StorefrontHandler) listProducts(c echo.Context, collection *models.Collection) error {
+	// Search ranks are not a keyset, so shop listings have numbered pages only
+	params, err := pagination.Parse(c, pagination.Options{Key: "products", DefaultLimit: 12, MaxLimit: 50})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	if limit < 1 || limit > 50 {
-		limit = 12
+
+	filters, err := parseProductFilters(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	filters.Collection = collection
+	query := c.QueryParams()
+	var attributes []models.AttributeDefinition
+	if len(query["attribute"])+len(query["attribute_min"])+len(query["attribute_max"]) > 0 {
+		if err := h.db.Where("filterable = true").Find(&attributes).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+		}
+	}
+	if filters.Attributes, err = parseAttributeFilters(query, attributes); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	text := strings.TrimSpace(c.QueryParam("search"))
+	sort := search.SortNewest
+	if text != "" {
+		sort = search.SortRelevance
+	} else if collection != nil {
+		sort = collectionSort(collection)
+	}
+	if value := c.QueryParam("sort"); value != "" {
+		var ok bool
+		if sort, ok = search.ParseSort(value); !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid sort"})
+		}
+	}
+	if sort == search.SortManual && (collection == nil || collection.Type != models.CollectionManual) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "The manual sort is only for manual collections"})
+	}
+	facets := c.QueryParam("facets") != "false"
+
+	result, err := h.search.Search(c.Request().Context(), search.Query{
+		Text:    text,
+		Filters: filters,
+		Sort:    sort,
+		Offset:  params.Offset(),
+		Limit:   params.Limit,
+		Facets:  facets,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Search failed"})
+	}
+
+	products := []models.Product{}
+	if len(result.IDs) > 0 {
+		var found []models.Product
+		if err := h.db.Preload("Category").Preload("Images").Preload("Tags").Where("id IN ?", result.IDs).Find(&found).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+		}
+		byID := make(map[uuid.UUID]models.Product, len(found))
+		for _, product := range found {
+			byID[product.ID] = product
+		}
+		for _, id := range result.IDs {
+			if product, ok := byID[id]; ok {
+				products = append(products, product)
+			}
+		}
+		detailed := make([]*models.Product, len(products))
+		for i := range products {
+			detailed[i] = &products[i]
+		}
+		if err := models.AttachDetails(h.db, detailed...); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+		}
 	}
 
-	search := c.QueryParam("search")
-	categoryID := c.QueryParam("category_id")
+	page := pagination.OffsetPage(c, params, products, result.Total).Set("sort", sort)
+	if collection != nil {
+		page.Set("collection", collection)
+	}
+	if text != "" {
+		page.Set("fuzzy", result.Fuzzy)
+	}
+	if result.Facets != nil {
+		page.Set("facets", result.Facets)
+	}
 
-	query := h.db.Where("is_active = true")
+	return c.JSON(http.StatusOK, page)
+}
 
-	if search != "" {
-		query = query.Where("name ILIKE ? OR description ILIKE ?", "%"+search+"%", "%"+search+"%")
+// parseProductFilters reads the storefront listing filters
+func parseProductFilters(c echo.Context) (search.Filters, error) {
+	var filters search.Filters
+	if value := c.QueryParam("category_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return filters, errors.New("Invalid category_id")
+		}
+		filters.CategoryID = &id
 	}
 
-	if categoryID != "" {
-		if catID, err := uuid.Parse(categoryID); err == nil {
-			query = query.Where("category_id = ?", catID)
+	for name, target := range map[string]**int{"min_price": &filters.MinPrice, "max_price": &filters.MaxPrice} {
+		if value := c.QueryParam(name); value != "" {
+			cents, err := strconv.Atoi(value)
+			if err != nil || cents < 0 {
+				return filters, fmt.Errorf("Invalid %s: use a price in cents", name)
+			}
+			*target = &cents
 		}
 	}
 
-	var total int64
-	query.Model(&models.Product{}).Count(&total)
+	for name, target := range map[string]*bool{"in_stock": &filters.InStock, "featured": &filters.Featured, "on_sale": &filters.OnSale} {
+		if value := c.QueryParam(name); value != "" {
+			on, err := strconv.ParseBool(value)
+			if err != nil {
+				return filters, fmt.Errorf("Invalid %s: use true or false", name)
+			}
+			*target = on
+		}
+	}
 
-	var products []models.Product
-	offset := (page - 1) * limit
-	
-	if err := query.Preload("Category").Preload("Images").Order("created_at DESC").Offset(offset).Limit(limit).Find(&products).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	params := c.QueryParams()
+	for _, value := range params["option"] {
+		name, optionValue, ok := strings.Cut(value, ":")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(optionValue) == "" {
+			return filters, errors.New("Invalid option filter: use option=Name:Value")
+		}
+		if filters.Options == nil {
+			filters.Options = map[string][]string{}
+		}
+		name = strings.TrimSpace(name)
+		filters.Options[name] = append(filters.Options[name], optionValue)
 	}
+	filters.Tags = params["tag"]
+	return filters, nil
+}
 
-	totalPages := int((total + int64(limit) - 1) / int64(limit))
+// parseAttributeFilters reads attribute=code:value and attribute_min and
+// attribute_max=code:number for the given attributes
+func parseAttributeFilters(params url.Values, definitions []models.AttributeDefinition) ([]search.AttributeFilter, error) {
+	filters := []search.AttributeFilter{}
+	index := map[string]int{}
+	// filterOf finds the filter of a parameter value, returning the value
+	// after the code
+	filterOf := func(param, value string) (*search.AttributeFilter, string, error) {
+		code, raw, ok := strings.Cut(value, ":")
+		code = strings.ToLower(strings.TrimSpace(code))
+		if !ok || code == "" || strings.TrimSpace(raw) == "" {
+			return nil, "", fmt.Errorf("Invalid %s filter: use %s=code:value", param, param)
+		}
+		i, ok := index[code]
+		if !ok {
+			for _, definition := range definitions {
+				if definition.Code == code {
+					i, ok = len(filters), true
+					index[code] = i
+					filters = append(filters, search.AttributeFilter{Attribute: definition})
+				}
+			}
+			if !ok {
+				return nil, "", fmt.Errorf("Unknown attribute filter: %s", code)
+			}
+		}
+		return &filters[i], raw, nil
+	}
 
-	response := map[string]interface{}{
-		"products": products,
-		"pagination": map[string]interface{}{
-			"page":        page,
-			"limit":       limit,
-			"total":       total,
-			"total_pages": totalPages,
-		},
+	for _, value := range params["attribute"] {
+		filter, raw, err := filterOf("attribute", value)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := filter.Attribute.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s filter: %s", filter.Attribute.Code, err)
+		}
+		switch filter.Attribute.Type {
+		case models.AttributeNumber:
+			filter.Min, filter.Max = parsed.Number, parsed.Number
+		case models.AttributeBoolean:
+			filter.Boolean = parsed.Boolean
+		default:
+			filter.Values = append(filter.Values, *parsed.Text)
+		}
+	}
+	for _, param := range []string{"attribute_min", "attribute_max"} {
+		for _, value := range params[param] {
+			filter, raw, err := filterOf(param, value)
+			if err != nil {
+				return nil, err
+			}
+			if filter.Attribute.Type != models.AttributeNumber {
+				return nil, fmt.Errorf("Invalid %s filter: %s is not a number attribute", param, filter.Attribute.Code)
+			}
+			parsed, err := filter.Attribute.Parse(raw)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s filter: %s", param, err)
+			}
+			if param == "attribute_min" {
+				filter.Min = parsed.Number
+			} else {
+				filter.Max = parsed.Number
+			}
+		}
+	}
+	return filters, nil
+}
+
+// SearchSuggest completes a partly typed search with matching products and
+// categories (public endpoint)
+func (h *StorefrontHandler) SearchSuggest(c echo.Context) error {
+	text := strings.TrimSpace(c.QueryParam("q"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit < 1 || limit > 20 {
+		limit = 8
+	}
+
+	suggestions := &search.Suggestions{Products: []search.ProductSuggestion{}, Categories: []search.CategorySuggestion{}}
+	// Single letters match too much to be useful
+	if len([]rune(text)) >= 2 {
+		var err error
+		suggestions, err = h.search.Suggest(c.Request().Context(), text, limit)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Search failed"})
+		}
 	}
 
-	return c.JSON(http.StatusOK, response)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"query":      text,
+		"products":   suggestions.Products,
+		"categories": suggestions.Categories,
+	})
 }
 
 // GetShopProduct gets a single product (public endpoint)
@@ -91,43 +312,183 @@ func (h *StorefrontHandler) GetShopProduct(c echo.Context) error {
 	}
 
 	var product models.Product
-	if err := h.db.Preload("Category").Preload("Images").Where("id = ? AND is_active = true", id).First(&product).Error; err != nil {
+	if err := h.db.Preload("Category").Preload("Images").Preload("Tags").Where("id = ? AND is_active = true", id).First(&product).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "Product not found"})
 		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
 	}
 
+	if err := models.AttachDetails(h.db, &product); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+
 	return c.JSON(http.StatusOK, product)
 }
 
+// compareLimit caps the products compared side by side
+const compareLimit = 10
+
+// CompareProducts returns active products side by side with their
+// attributes aligned in rows (public endpoint). ids lists 2 to 10 product
+// IDs, comma separated or repeated, in the order of the columns.
+func (h *StorefrontHandler) CompareProducts(c echo.Context) error {
+	var ids []uuid.UUID
+	seen := map[uuid.UUID]bool{}
+	for _, value := range c.QueryParams()["ids"] {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			id, err := uuid.Parse(part)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid product ID: " + part})
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) < 2 || len(ids) > compareLimit {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Compare 2 to %d products", compareLimit)})
+	}
+
+	var found []models.Product
+	if err := h.db.Preload("Category").Preload("Images").Preload("Tags").Where("id IN ? AND is_active = true", ids).Find(&found).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	byID := make(map[uuid.UUID]*models.Product, len(found))
+	for i := range found {
+		byID[found[i].ID] = &found[i]
+	}
+	products := make([]*models.Product, len(ids))
+	for i, id := range ids {
+		if products[i] = byID[id]; products[i] == nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Product not found: " + id.String()})
+		}
+	}
+
+	var definitions []models.AttributeDefinition
+	if err := h.db.Order("position ASC, name ASC").Find(&definitions).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	if err := models.AttachDetails(h.db, products...); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"products":   products,
+		"attributes": models.CompareAttributes(definitions, products),
+	})
+}
+
+// GetShopCategoryTree returns the active categories nested under their
+// parents, with the number of active products in each subtree (public endpoint)
+func (h *StorefrontHandler) GetShopCategoryTree(c echo.Context) error {
+	tree, err := categoryTree(h.db, true)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"categories": tree})
+}
+
 // GetShopCategories gets categories for a shop (public endpoint)
 func (h *StorefrontHandler) GetShopCategories(c echo.Context) error {
-	var categories []models.Category
-	if err := h.db.Order("name ASC").Find(&categories).Error; err != nil {
+	params, err := pagination.Parse(c, categoryPages)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	page, err := pagination.Find[models.Category](c, h.db, params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetShopCollections lists the active collections by title (public endpoint)
+func (h *StorefrontHandler) GetShopCollections(c echo.Context) error {
+	params, err := pagination.Parse(c, pagination.Options{
+		Key:          "collections",
+		DefaultLimit: 50,
+		MaxLimit:     100,
+		Keys: []pagination.Key{
+			{Column: "title", Field: "Title", Type: "text"},
+			{Column: "id", Field: "ID", Type: "uuid"},
+		},
+	})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	page, err := pagination.Find[models.Collection](c, h.db.Where("is_active = true"), params)
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
 	}
 
-	response := map[string]interface{}{
-		"categories": categories,
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetShopCollection returns an active collection with its SEO fields
+// (public endpoint)
+func (h *StorefrontHandler) GetShopCollection(c echo.Context) error {
+	collection, err := h.findCollection(c.Param("slug"))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+
+	return c.JSON(http.StatusOK, collection)
+}
+
+// GetShopCollectionProducts lists the products of an active collection,
+// with the filters, sorts and facets of GetShopProducts. Manual
+// collections are sorted as the merchant ordered them, unless the shopper
+// picks another sort (public endpoint).
+func (h *StorefrontHandler) GetShopCollectionProducts(c echo.Context) error {
+	collection, err := h.findCollection(c.Param("slug"))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
 	}
 
-	return c.JSON(http.StatusOK, response)
+	return h.listProducts(c, collection)
+}
+
+func (h *StorefrontHandler) findCollection(slug string) (*models.Collection, error) {
+	var collection models.Collection
+	if err := h.db.Where("slug = ? AND is_active = true", slug).First(&collection).Error; err != nil {
+		return nil, err
+	}
+	return &collection, nil
 }
 
 // CreatePublicOrder creates an order from the storefront (public endpoint)
 func (h *StorefrontHandler) CreatePublicOrder(c echo.Context) error {
 
 	var req struct {
-		CustomerEmail   string `json:"customer_email" validate:"required,email"`
-		CustomerName    string `json:"customer_name" validate:"required"`
+		CustomerEmail   string `json:"customer_email" validate:"omitempty,email"`
+		CustomerName    string `json:"customer_name"`
 		CustomerPhone   string `json:"customer_phone"`
-		ShippingAddress string `json:"shipping_address" validate:"required"`
-		ShippingCity    string `json:"shipping_city" validate:"required"`
+		ShippingAddress string `json:"shipping_address"`
+		ShippingCity    string `json:"shipping_city"`
 		ShippingState   string `json:"shipping_state"`
-		ShippingZip     string `json:"shipping_zip" validate:"required"`
+		ShippingZip     string `json:"shipping_zip"`
 		ShippingCountry string `json:"shipping_country"`
-		Items           []struct {
+
+		// Signed-in customers may pick saved addresses; without them their
+		// defaults fill in whatever was left blank
+		ShippingAddressID *uuid.UUID `json:"shipping_address_id"`
+		BillingAddressID  *uuid.UUID `json:"billing_address_id"`
+
+		Items []struct {
 			ProductID uuid.UUID `json:"product_id" validate:"required"`
 			Quantity  int       `json:"quantity" validate:"required,min=1"`
 		} `json:"items" validate:"required,dive"`
@@ -142,19 +503,12 @@ func (h *StorefrontHandler) CreatePublicOrder(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	// Start transaction
-	tx := h.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
 	// Create order
 	order := models.Order{
 		CustomerEmail:   req.CustomerEmail,
 		CustomerName:    req.CustomerName,
 		CustomerPhone:   req.CustomerPhone,
+		IsGuestOrder:    true,
 		ShippingAddress: req.ShippingAddress,
 		ShippingCity:    req.ShippingCity,
 		ShippingState:   req.ShippingState,
@@ -165,12 +519,35 @@ func (h *StorefrontHandler) CreatePublicOrder(c echo.Context) error {
 		PaymentStatus:   models.PaymentStatusPending,
 	}
 
-	if req.ShippingCountry == "" {
+	// Orders placed while signed in belong to the account
+	if userID, ok := c.Get("user_id").(uuid.UUID); ok {
+		if err := h.prefillFromAccount(&order, userID, req.ShippingAddressID, req.BillingAddressID); err != nil {
+			if err == errAddressNotFound {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Address not found"})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+		}
+	}
+
+	if order.CustomerEmail == "" || order.CustomerName == "" || order.ShippingAddress == "" || order.ShippingCity == "" || order.ShippingZip == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "customer_email, customer_name, shipping_address, shipping_city and shipping_zip are required"})
+	}
+
+	if order.ShippingCountry == "" {
 		order.ShippingCountry = "US"
 	}
 
+	// Start transaction
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
 	var subtotal int
 	var orderItems []models.OrderItem
+	var stockChanges []events.StockChanged
 
 	// Process each item
 	for _, item := range req.Items {
@@ -210,11 +587,13 @@ func (h *StorefrontHandler) CreatePublicOrder(c echo.Context) error {
 		subtotal += orderItem.Total
 
 		// Update product stock
+		previousStock := product.Stock
 		product.Stock -= item.Quantity
 		if err := tx.Save(&product).Error; err != nil {
 			tx.Rollback()
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update product stock"})
 		}
+		stockChanges = append(stockChanges, events.NewStockChanged(&product, previousStock, events.StockReasonOrder))
 	}
 
 	// Calculate totals
@@ -236,11 +615,174 @@ func (h *StorefrontHandler) CreatePublicOrder(c echo.Context) error {
 		}
 	}
 
-	tx.Commit()
+	// What a signed-in customer bought leaves their cart
+	if userID, ok := c.Get("user_id").(uuid.UUID); ok {
+		productIDs := make([]uuid.UUID, len(orderItems))
+		for i := range orderItems {
+			productIDs[i] = orderItems[i].ProductID
+		}
+		if err := models.RemoveOrderedFromCart(tx, userID, productIDs); err != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create order"})
+		}
+	}
+
+	// The confirmation page and email carry the status link so guests can come back later
+	statusURL, err := orderStatusURL(tx, h.jwtSecret, h.frontendURL, &order)
+	if err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to sign order link"})
+	}
+
+	// Emails are only queued here; they are sent in the background. A
+	// failure to queue one must not lose the order, so it is undone on its
+	// own: after a failed statement Postgres rejects the rest of the
+	// transaction unless it goes back to a savepoint.
+	order.Items = orderItems
+	if err := tx.SavePoint("order_confirmation").Error; err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create order"})
+	}
+	if err := h.notifier.OrderConfirmation(tx, &order, statusURL); err != nil {
+		log.Printf("Failed to queue confirmation for order %s: %v", order.OrderNumber, err)
+		if err := tx.RollbackTo("order_confirmation").Error; err != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create order"})
+		}
+	}
+
+	// Stock alerts, webhooks and other side effects subscribe to these events
+	for i := range stockChanges {
+		stockChanges[i].OrderID = &order.ID
+		if err := events.Publish(tx, stockChanges[i]); err != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create order"})
+		}
+	}
+	if err := events.Publish(tx, events.OrderPlaced{Order: &order}); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create order"})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create order"})
+	}
 
 	// Load the complete order with items
 	var completeOrder models.Order
 	h.db.Preload("Items").Where("id = ?", order.ID).First(&completeOrder)
 
-	return c.JSON(http.StatusCreated, completeOrder)
-}
\ No newline at end of file
+	return c.JSON(http.StatusCreated, struct {
+		models.Order
+		StatusURL string `json:"status_url"`
+	}{completeOrder, statusURL})
+}
+
+// LookupOrder finds an order by order number and email and returns a
+// redacted view. Failures are indistinguishable so orders cannot be probed.
+func (h *StorefrontHandler) LookupOrder(c echo.Context) error {
+	orderNumber := strings.TrimSpace(c.QueryParam("order_number"))
+	email := strings.TrimSpace(c.QueryParam("email"))
+	if orderNumber == "" || email == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "order_number and email are required"})
+	}
+
+	var order models.Order
+	err := h.db.Preload("Items").
+		Where("order_number = ? AND LOWER(customer_email) = LOWER(?)", orderNumber, email).
+		First(&order).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Order not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+
+	return c.JSON(http.StatusOK, order.RedactedView())
+}
+
+// GetOrderStatus shows an order behind a signed status link, without logging in
+func (h *StorefrontHandler) GetOrderStatus(c echo.Context) error {
+	subject, err := tokens.Verify(h.jwtSecret, tokens.PurposeOrderStatus, c.QueryParam("token"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Invalid or expired order link"})
+	}
+
+	rawID, accessToken, _ := strings.Cut(subject, ":")
+	orderID, err := uuid.Parse(rawID)
+	if err != nil || accessToken == "" {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Invalid or expired order link"})
+	}
+
+	var order models.Order
+	if err := h.db.Preload("Items").Where("id = ? AND access_token = ?", orderID, accessToken).First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Invalid or expired order link"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+
+	return c.JSON(http.StatusOK, order.StatusView())
+}
+
+// orderStatusURL returns a signed link to the order's status page. The link
+// embeds the order's access token, so rotating the token revokes it.
+func orderStatusURL(db *gorm.DB, jwtSecret, frontendURL string, order *models.Order) (string, error) {
+	if err := models.EnsureAccessToken(db, order); err != nil {
+		return "", err
+	}
+	token, err := tokens.Issue(jwtSecret, tokens.PurposeOrderStatus, order.ID.String()+":"+order.AccessToken, time.Now().Add(orderStatusLinkTTL))
+	if err != nil {
+		return "", err
+	}
+	return frontendURL + "/orders/status?token=" + token, nil
+}
+
+// prefillFromAccount links the order to the signed-in user and fills blank
+// contact and address fields from their profile and address book
+func (h *StorefrontHandler) prefillFromAccount(order *models.Order, userID uuid.UUID, shippingAddressID, billingAddressID *uuid.UUID) error {
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	order.CustomerID = &user.ID
+	order.IsGuestOrder = false
+	if order.CustomerEmail == "" {
+		order.CustomerEmail = user.Email
+	}
+	if order.CustomerName == "" {
+		order.CustomerName = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+
+	var shipping *models.Address
+	var err error
+	if shippingAddressID != nil {
+		shipping, err = savedAddress(h.db, user.ID, *shippingAddressID)
+	} else if order.ShippingAddress == "" {
+		shipping, err = defaultAddress(h.db, user.ID, "is_default_shipping")
+	}
+	if err != nil {
+		return err
+	}
+	if shipping != nil {
+		shipping.ApplyShipping(order)
+		if order.CustomerPhone == "" {
+			order.CustomerPhone = shipping.Phone
+		}
+	}
+
+	var billing *models.Address
+	if billingAddressID != nil {
+		billing, err = savedAddress(h.db, user.ID, *billingAddressID)
+	} else {
+		billing, err = defaultAddress(h.db, user.ID, "is_default_billing")
+	}
+	if err != nil {
+		return err
+	}
+	if billing != nil && (shipping == nil || billing.ID != shipping.ID) {
+		billing.ApplyBilling(order)
+	}
+	return nil
+}
@@ -16,7 +16,7 @@ func TestStorefrontHandler_GetShop(t *testing.T) {
 	db, cleanup := testutil.SetupTestDB()
 	defer cleanup()
 	
-	handler := NewStorefrontHandler(db)
+	handler := NewStorefrontHandler(db, "test-secret", "http://localhost:3000", nil)
 	e := echo.New()
 
 	t.Run("get shop by slug successfully", func(t *testing.T) {
@@ -76,7 +76,7 @@ func TestStorefrontHandler_GetShopProducts(t *testing.T) {
 	db, cleanup := testutil.SetupTestDB()
 	defer cleanup()
 	
-	handler := NewStorefrontHandler(db)
+	handler := NewStorefrontHandler(db, "test-secret", "http://localhost:3000", nil)
 	e := echo.New()
 
 	t.Run("get shop products successfully", func(t *testing.T) {
@@ -153,7 +153,7 @@ func TestStorefrontHandler_CreatePublicOrder(t *testing.T) {
 	db, cleanup := testutil.SetupTestDB()
 	defer cleanup()
 	
-	handler := NewStorefrontHandler(db)
+	handler := NewStorefrontHandler(db, "test-secret", "http://localhost:3000", nil)
 	e := echo.New()
 	e.Validator = validator.New()
 
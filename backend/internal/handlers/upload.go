@@ -79,4 +79,46 @@ func (h *UploadHandler) UploadFile(c echo.Context) error {
 	}
 
 	return c.JSON(http.StatusCreated, media)
-}
\ No newline at end of file
+}
+
+// UploadReviewImage stores a customer's photo for a review they are about
+// to write. The image is attached by passing its ID to POST /me/reviews.
+func (h *UploadHandler) UploadReviewImage(c echo.Context) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "no file provided")
+	}
+	if !services.IsValidImageType(file.Header.Get("Content-Type")) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid file type. Only images are allowed")
+	}
+	if file.Size > 5*1024*1024 {
+		return echo.NewHTTPError(http.StatusBadRequest, "file too large. Maximum size is 5MB")
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to open file")
+	}
+	defer src.Close()
+
+	folder := "reviews/" + userID.String()
+	result, err := h.minioService.UploadFile(src, file, folder)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to upload file")
+	}
+
+	image := models.ReviewImage{
+		UserID:   userID,
+		URL:      result.URL,
+		MimeType: result.MimeType,
+		Size:     result.Size,
+	}
+	if err := database.GetDB().Create(&image).Error; err != nil {
+		h.minioService.DeleteFile(folder + "/" + result.Filename)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save file record")
+	}
+
+	return c.JSON(http.StatusCreated, image)
+}
@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"easycart/internal/audit"
+	"easycart/internal/models"
+	"easycart/internal/pagination"
+	"easycart/internal/webhooks"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct {
+	db *gorm.DB
+}
+
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required"`
+	Description string   `json:"description"`
+	Events      []string `json:"events" validate:"required,min=1"`
+}
+
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	Events      []string `json:"events,omitempty"`
+	IsActive    *bool    `json:"is_active,omitempty"`
+}
+
+type WebhookSecretResponse struct {
+	Webhook models.WebhookEndpoint `json:"webhook"`
+	Secret  string                 `json:"secret"` // Only returned when created or rotated
+}
+
+func NewWebhookHandler(db *gorm.DB) *WebhookHandler {
+	return &WebhookHandler{db: db}
+}
+
+// GetWebhookEvents lists the event types endpoints can subscribe to
+func (h *WebhookHandler) GetWebhookEvents(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"events": models.AllWebhookEvents,
+	})
+}
+
+func (h *WebhookHandler) GetWebhooks(c echo.Context) error {
+	var endpoints []models.WebhookEndpoint
+	if err := h.db.Order("created_at DESC").Find(&endpoints).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get webhooks: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"webhooks": endpoints,
+		"total":    len(endpoints),
+	})
+}
+
+func (h *WebhookHandler) GetWebhook(c echo.Context) error {
+	endpoint, err := h.findEndpoint(h.db, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, endpoint)
+}
+
+// CreateWebhook adds an endpoint and returns its signing secret once
+func (h *WebhookHandler) CreateWebhook(c echo.Context) error {
+	var req CreateWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if err := validateWebhookURL(c.Request().Context(), req.URL); err != nil {
+		return err
+	}
+	if err := validateWebhookEvents(req.Events); err != nil {
+		return err
+	}
+
+	endpoint := models.WebhookEndpoint{
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      req.Events,
+		IsActive:    true,
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&endpoint).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "webhook.create", "webhook", endpoint.ID, nil, endpoint)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create webhook: "+err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, WebhookSecretResponse{Webhook: endpoint, Secret: endpoint.Secret})
+}
+
+// UpdateWebhook changes an endpoint; setting is_active re-enables a disabled one
+func (h *WebhookHandler) UpdateWebhook(c echo.Context) error {
+	var req UpdateWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if req.URL != nil {
+		if err := validateWebhookURL(c.Request().Context(), *req.URL); err != nil {
+			return err
+		}
+	}
+	if req.Events != nil {
+		if err := validateWebhookEvents(req.Events); err != nil {
+			return err
+		}
+	}
+
+	var endpoint *models.WebhookEndpoint
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		endpoint, err = h.findEndpoint(tx.Clauses(lockForUpdate), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		before := *endpoint
+
+		if req.URL != nil {
+			endpoint.URL = *req.URL
+		}
+		if req.Description != nil {
+			endpoint.Description = *req.Description
+		}
+		if req.Events != nil {
+			endpoint.Events = req.Events
+		}
+		if req.IsActive != nil {
+			endpoint.IsActive = *req.IsActive
+			if *req.IsActive {
+				endpoint.ConsecutiveFailures = 0
+				endpoint.DisabledAt = nil
+				endpoint.DisabledReason = ""
+			}
+		}
+
+		if err := tx.Save(endpoint).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "webhook.update", "webhook", endpoint.ID, before, *endpoint)
+	})
+	if err != nil {
+		return webhookError(err, "Failed to update webhook")
+	}
+
+	return c.JSON(http.StatusOK, endpoint)
+}
+
+// DeleteWebhook removes an endpoint and its delivery log
+func (h *WebhookHandler) DeleteWebhook(c echo.Context) error {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		endpoint, err := h.findEndpoint(tx, c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("endpoint_id = ?", endpoint.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(endpoint).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, c, "webhook.delete", "webhook", endpoint.ID, *endpoint, nil)
+	})
+	if err != nil {
+		return webhookError(err, "Failed to delete webhook")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// RotateWebhookSecret replaces the signing secret and returns the new one
+func (h *WebhookHandler) RotateWebhookSecret(c echo.Context) error {
+	secret, err := models.GenerateWebhookSecret()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate secret")
+	}
+
+	var endpoint *models.WebhookEndpoint
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		endpoint, err = h.findEndpoint(tx.Clauses(lockForUpdate), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		endpoint.Secret = secret
+		if err := tx.Save(endpoint).Error; err != nil {
+			return err
+		}
+		return audit.RecordAction(tx, c, "webhook.rotate_secret", "webhook", endpoint.ID, nil)
+	})
+	if err != nil {
+		return webhookError(err, "Failed to rotate webhook secret")
+	}
+
+	return c.JSON(http.StatusOK, WebhookSecretResponse{Webhook: *endpoint, Secret: secret})
+}
+
+// TestWebhook queues a ping event to the endpoint
+func (h *WebhookHandler) TestWebhook(c echo.Context) error {
+	var delivery *models.WebhookDelivery
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		endpoint, err := h.findEndpoint(tx, c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if !endpoint.IsActive {
+			return echo.NewHTTPError(http.StatusConflict, "Webhook is disabled")
+		}
+		delivery, err = webhooks.Ping(tx, endpoint)
+		return err
+	})
+	if err != nil {
+		return webhookError(err, "Failed to queue test delivery")
+	}
+
+	return c.JSON(http.StatusAccepted, delivery)
+}
+
+// GetWebhookDeliveries lists an endpoint's delivery log, newest first
+func (h *WebhookHandler) GetWebhookDeliveries(c echo.Context) error {
+	endpoint, err := h.findEndpoint(h.db, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	query := h.db.Model(&models.WebhookDelivery{}).Where("endpoint_id = ?", endpoint.ID)
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.QueryParam("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	params, err := pagination.Parse(c, pagination.NewestFirst("deliveries", 50, 100))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	page, err := pagination.Find[models.WebhookDelivery](c, query, params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch deliveries: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetWebhookDelivery returns one delivery with its request and response details
+func (h *WebhookHandler) GetWebhookDelivery(c echo.Context) error {
+	delivery, err := h.findDelivery(h.db, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, delivery)
+}
+
+// RedeliverWebhook sends a delivery's event again as a new delivery
+func (h *WebhookHandler) RedeliverWebhook(c echo.Context) error {
+	var redelivery *models.WebhookDelivery
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		original, err := h.findDelivery(tx, c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if original.Endpoint == nil || !original.Endpoint.IsActive {
+			return echo.NewHTTPError(http.StatusConflict, "Webhook is disabled; re-enable it before redelivering")
+		}
+
+		redelivery, err = webhooks.Redeliver(tx, original)
+		if err != nil {
+			return err
+		}
+		return audit.RecordAction(tx, c, "webhook.redeliver", "webhook", original.EndpointID, models.JSONMap{
+			"delivery_id":   original.ID,
+			"redelivery_id": redelivery.ID,
+			"event":         original.Event,
+			"event_id":      original.EventID,
+		})
+	})
+	if err != nil {
+		return webhookError(err, "Failed to redeliver webhook")
+	}
+
+	return c.JSON(http.StatusAccepted, redelivery)
+}
+
+func (h *WebhookHandler) findEndpoint(db *gorm.DB, id string) (*models.WebhookEndpoint, error) {
+	endpointID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid webhook ID")
+	}
+
+	var endpoint models.WebhookEndpoint
+	if err := db.First(&endpoint, "id = ?", endpointID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "Webhook not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to get webhook: "+err.Error())
+	}
+	return &endpoint, nil
+}
+
+func (h *WebhookHandler) findDelivery(db *gorm.DB, id string) (*models.WebhookDelivery, error) {
+	deliveryID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid delivery ID")
+	}
+
+	var delivery models.WebhookDelivery
+	if err := db.Preload("Endpoint").First(&delivery, "id = ?", deliveryID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "Delivery not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to get delivery: "+err.Error())
+	}
+	return &delivery, nil
+}
+
+func validateWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Webhook URL must be an absolute http or https URL")
+	}
+	if err := webhooks.CheckHost(ctx, u.Hostname()); err != nil {
+		if errors.Is(err, webhooks.ErrBlockedAddress) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Webhook URL must not point to a private or internal address")
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "Webhook URL host cannot be resolved")
+	}
+	return nil
+}
+
+func validateWebhookEvents(events []string) error {
+	if len(events) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "At least one event is required")
+	}
+	for _, event := range events {
+		if !models.IsValidWebhookEvent(event) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Unknown webhook event: "+event)
+		}
+	}
+	return nil
+}
+
+// webhookError passes HTTP errors from inside a transaction through unchanged
+func webhookError(err error, message string) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		return httpErr
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, message+": "+err.Error())
+}
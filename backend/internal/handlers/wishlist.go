@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const maxWishlistName = 100
+
+// WishlistHandler serves the signed-in customer's wishlists under /me and
+// shared lists in the storefront
+type WishlistHandler struct {
+	db *gorm.DB
+}
+
+func NewWishlistHandler(db *gorm.DB) *WishlistHandler {
+	return &WishlistHandler{db: db}
+}
+
+type WishlistRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type WishlistItemRequest struct {
+	ProductID uuid.UUID  `json:"product_id" validate:"required"`
+	VariantID *uuid.UUID `json:"variant_id,omitempty"`
+}
+
+// MoveToCartRequest lists the items to move; none moves every available one
+type MoveToCartRequest struct {
+	ItemIDs []uuid.UUID `json:"item_ids,omitempty"`
+}
+
+// sharedWishlist is a wishlist as anyone with its link sees it
+type sharedWishlist struct {
+	Name  string                `json:"name"`
+	Items []models.WishlistItem `json:"items"`
+}
+
+// GetWishlists lists the signed-in customer's wishlists with their items,
+// oldest first
+func (h *WishlistHandler) GetWishlists(c echo.Context) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	wishlists := []models.Wishlist{}
+	if err := models.PreloadWishlistItems(h.db).Where("user_id = ?", userID).Order("created_at ASC, id").Find(&wishlists).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch wishlists")
+	}
+	for i := range wishlists {
+		wishlists[i].MarkAvailability()
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"wishlists": wishlists})
+}
+
+func (h *WishlistHandler) GetWishlist(c echo.Context) error {
+	wishlist, err := h.findWishlist(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, wishlist)
+}
+
+func (h *WishlistHandler) CreateWishlist(c echo.Context) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	name, err := bindWishlistName(c)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := h.db.Model(&models.Wishlist{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count wishlists")
+	}
+	if count >= models.MaxWishlists {
+		return echo.NewHTTPError(http.StatusConflict, "too many wishlists")
+	}
+
+	wishlist := models.Wishlist{UserID: userID, Name: name, Items: []models.WishlistItem{}}
+	if err := h.db.Omit("Items").Create(&wishlist).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create wishlist")
+	}
+
+	return c.JSON(http.StatusCreated, wishlist)
+}
+
+// UpdateWishlist renames a wishlist
+func (h *WishlistHandler) UpdateWishlist(c echo.Context) error {
+	name, err := bindWishlistName(c)
+	if err != nil {
+		return err
+	}
+	wishlist, err := h.findWishlist(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.db.Model(wishlist).Update("name", name).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update wishlist")
+	}
+
+	return c.JSON(http.StatusOK, wishlist)
+}
+
+func (h *WishlistHandler) DeleteWishlist(c echo.Context) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	wishlistID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid wishlist ID")
+	}
+
+	result := h.db.Where("id = ? AND user_id = ?", wishlistID, userID).Delete(&models.Wishlist{})
+	if result.Error != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete wishlist")
+	}
+	if result.RowsAffected == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "wishlist not found")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AddWishlistItem saves a product, or one of its variants, to a wishlist.
+// Adding one that is already there changes nothing.
+func (h *WishlistHandler) AddWishlistItem(c echo.Context) error {
+	var req WishlistItemRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	wishlist, err := h.findWishlist(c)
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := models.FindPurchasable(h.db, req.ProductID, req.VariantID); err != nil {
+		if errors.Is(err, models.ErrProductUnavailable) || errors.Is(err, models.ErrVariantMismatch) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch product")
+	}
+
+	var existing int64
+	query := h.db.Model(&models.WishlistItem{}).Where("wishlist_id = ? AND product_id = ?", wishlist.ID, req.ProductID)
+	if err := models.WhereVariant(query, req.VariantID).Count(&existing).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to add item")
+	}
+	if existing == 0 {
+		if len(wishlist.Items) >= models.MaxWishlistItems {
+			return echo.NewHTTPError(http.StatusConflict, "wishlist is full")
+		}
+		item := models.WishlistItem{WishlistID: wishlist.ID, ProductID: req.ProductID, VariantID: req.VariantID}
+		if err := h.db.Create(&item).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to add item")
+		}
+	}
+
+	return h.respond(c, wishlist.ID, http.StatusOK)
+}
+
+func (h *WishlistHandler) RemoveWishlistItem(c echo.Context) error {
+	wishlist, err := h.findWishlist(c)
+	if err != nil {
+		return err
+	}
+	itemID, err := uuid.Parse(c.Param("itemId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid item ID")
+	}
+
+	result := h.db.Where("id = ? AND wishlist_id = ?", itemID, wishlist.ID).Delete(&models.WishlistItem{})
+	if result.Error != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to remove item")
+	}
+	if result.RowsAffected == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "item not found")
+	}
+
+	return h.respond(c, wishlist.ID, http.StatusOK)
+}
+
+// ShareWishlist gives a wishlist a public link, keeping the one it has
+func (h *WishlistHandler) ShareWishlist(c echo.Context) error {
+	wishlist, err := h.findWishlist(c)
+	if err != nil {
+		return err
+	}
+
+	if wishlist.ShareToken == nil {
+		token, err := models.NewShareToken()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to share wishlist")
+		}
+		if err := h.db.Model(wishlist).Update("share_token", token).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to share wishlist")
+		}
+	}
+
+	return h.respond(c, wishlist.ID, http.StatusOK)
+}
+
+// UnshareWishlist removes a wishlist's public link
+func (h *WishlistHandler) UnshareWishlist(c echo.Context) error {
+	wishlist, err := h.findWishlist(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.db.Model(wishlist).Update("share_token", nil).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to unshare wishlist")
+	}
+
+	return h.respond(c, wishlist.ID, http.StatusOK)
+}
+
+// MoveToCart adds wishlist items to the customer's cart, one of each, and
+// takes them off the list. Items that cannot be bought stay on it.
+func (h *WishlistHandler) MoveToCart(c echo.Context) error {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	var req MoveToCartRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	wishlist, err := h.findWishlist(c)
+	if err != nil {
+		return err
+	}
+
+	onList := map[uuid.UUID]bool{}
+	for _, item := range wishlist.Items {
+		onList[item.ID] = true
+	}
+	// Check every item before moving any, so a bad ID changes nothing
+	chosen := map[uuid.UUID]bool{}
+	for _, id := range req.ItemIDs {
+		if !onList[id] {
+			return echo.NewHTTPError(http.StatusNotFound, "item not found")
+		}
+		chosen[id] = true
+	}
+	var moved, skipped []uuid.UUID
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range wishlist.Items {
+			if len(chosen) > 0 && !chosen[item.ID] {
+				continue
+			}
+			if !item.Available {
+				skipped = append(skipped, item.ID)
+				continue
+			}
+			if _, err := models.AddToCart(tx, userID, item.ProductID, item.VariantID, 1); err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.WishlistItem{}, item.ID).Error; err != nil {
+				return err
+			}
+			moved = append(moved, item.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to move items")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"moved":   idsOrEmpty(moved),
+		"skipped": idsOrEmpty(skipped),
+	})
+}
+
+// GetSharedWishlist shows a shared wishlist read-only (public endpoint).
+// Products that are no longer sold are left out.
+func (h *WishlistHandler) GetSharedWishlist(c echo.Context) error {
+	token := c.Param("token")
+
+	var wishlist models.Wishlist
+	if err := models.PreloadWishlistItems(h.db).Where("share_token = ?", token).First(&wishlist).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Wishlist not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Database error"})
+	}
+	wishlist.MarkAvailability()
+
+	shared := sharedWishlist{Name: wishlist.Name, Items: []models.WishlistItem{}}
+	for _, item := range wishlist.Items {
+		if item.Product != nil && item.Product.IsActive {
+			shared.Items = append(shared.Items, item)
+		}
+	}
+
+	return c.JSON(http.StatusOK, shared)
+}
+
+// findWishlist loads the signed-in customer's wishlist named by the id
+// parameter, with its items
+func (h *WishlistHandler) findWishlist(c echo.Context) (*models.Wishlist, error) {
+	userID := c.Get("user_id").(uuid.UUID)
+
+	wishlistID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid wishlist ID")
+	}
+
+	var wishlist models.Wishlist
+	if err := models.PreloadWishlistItems(h.db).Where("id = ? AND user_id = ?", wishlistID, userID).First(&wishlist).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "wishlist not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch wishlist")
+	}
+	wishlist.MarkAvailability()
+	return &wishlist, nil
+}
+
+// respond returns the wishlist as it is now
+func (h *WishlistHandler) respond(c echo.Context, wishlistID uuid.UUID, status int) error {
+	var wishlist models.Wishlist
+	if err := models.PreloadWishlistItems(h.db).First(&wishlist, wishlistID).Error; err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch wishlist")
+	}
+	wishlist.MarkAvailability()
+	return c.JSON(status, wishlist)
+}
+
+func bindWishlistName(c echo.Context) (string, error) {
+	var req WishlistRequest
+	if err := c.Bind(&req); err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > maxWishlistName {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "name is required and at most 100 characters")
+	}
+	return name, nil
+}
+
+func idsOrEmpty(ids []uuid.UUID) []uuid.UUID {
+	if ids == nil {
+		return []uuid.UUID{}
+	}
+	return ids
+}
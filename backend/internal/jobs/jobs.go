@@ -0,0 +1,138 @@
+// Package jobs is a durable background job queue stored in Postgres.
+//
+// Jobs are enqueued inside the transaction that makes the change they are
+// about, so they only exist if that change commits. Workers claim due jobs
+// with SELECT ... FOR UPDATE SKIP LOCKED, which lets any number of server or
+// cmd/worker processes share one queue. Failed jobs are retried with
+// exponential backoff until MaxAttempts, after which they are marked dead and
+// kept for inspection. Recurring jobs are declared with cron expressions.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"easycart/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultMaxAttempts is used when a job is enqueued without MaxAttempts
+const DefaultMaxAttempts = 10
+
+// HandlerFunc runs one job. Returning an error schedules a retry unless the
+// error is wrapped with Permanent.
+type HandlerFunc func(ctx context.Context, job *models.Job) error
+
+// Handle adapts a function taking a typed payload into a HandlerFunc. A
+// payload that does not decode into T fails the job permanently.
+func Handle[T any](fn func(ctx context.Context, payload T) error) HandlerFunc {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return Permanent(fmt.Errorf("invalid %s payload: %w", job.Kind, err))
+		}
+		return fn(ctx, payload)
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying; the job goes straight to dead
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Option customizes an enqueued job
+type Option func(*models.Job)
+
+// RunAt delays the job until t
+func RunAt(t time.Time) Option {
+	return func(j *models.Job) { j.RunAt = t }
+}
+
+// RunIn delays the job by d
+func RunIn(d time.Duration) Option {
+	return RunAt(time.Now().Add(d))
+}
+
+// MaxAttempts sets how many times the job runs before it is marked dead
+func MaxAttempts(n int) Option {
+	return func(j *models.Job) { j.MaxAttempts = n }
+}
+
+// UniqueKey deduplicates the job: while an unfinished job holds key, enqueuing
+// another one with the same key returns the existing job instead
+func UniqueKey(key string) Option {
+	return func(j *models.Job) { j.UniqueKey = &key }
+}
+
+// Enqueue stores a job of kind with payload encoded as JSON. Pass the
+// transaction that makes the change the job is about.
+func Enqueue(tx *gorm.DB, kind string, payload interface{}, opts ...Option) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", kind, err)
+	}
+
+	job := &models.Job{
+		Kind:        kind,
+		Payload:     data,
+		MaxAttempts: DefaultMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	if job.UniqueKey == nil {
+		if err := tx.Create(job).Error; err != nil {
+			return nil, err
+		}
+		return job, nil
+	}
+
+	// ON CONFLICT DO NOTHING keeps a duplicate from aborting the caller's transaction
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return job, nil
+	}
+
+	var existing models.Job
+	if err := tx.Where("unique_key = ? AND finished_at IS NULL", *job.UniqueKey).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Backoff returns the wait before retry number attempt: 30s, 1m, 2m, ... capped at 1h
+func Backoff(attempt int) time.Duration {
+	const maxBackoff = time.Hour
+	delay := 30 * time.Second
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
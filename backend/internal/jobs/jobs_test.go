@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"easycart/internal/models"
+)
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) expected an error", spec)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 7, 30, 0, time.UTC) // A Wednesday
+
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 31, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 31, 10, 15, 0, 0, time.UTC)},
+		{"17 3 * * *", time.Date(2024, 2, 1, 3, 17, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"30 8 1,15 * *", time.Date(2024, 2, 1, 8, 30, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matching is enough
+		{"0 12 15 * fri", time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range cases {
+		s, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q) error = %v", tc.spec, err)
+		}
+		if got := s.Next(from); !got.Equal(tc.want) {
+			t.Errorf("%q.Next(%v) = %v, want %v", tc.spec, from, got, tc.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		30: time.Hour,
+	}
+	for attempt, want := range cases {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestHandle(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+	}
+
+	var got string
+	handler := Handle(func(ctx context.Context, p payload) error {
+		got = p.Name
+		return nil
+	})
+
+	if err := handler(context.Background(), &models.Job{Kind: "test", Payload: []byte(`{"name":"widget"}`)}); err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+	if got != "widget" {
+		t.Errorf("Expected decoded payload, got %q", got)
+	}
+
+	err := handler(context.Background(), &models.Job{Kind: "test", Payload: []byte(`[1,2]`)})
+	if !IsPermanent(err) {
+		t.Errorf("Expected a bad payload to fail permanently, got %v", err)
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	base := errors.New("boom")
+	err := Permanent(base)
+	if !IsPermanent(err) || !errors.Is(err, base) {
+		t.Errorf("Expected a permanent error wrapping the cause, got %v", err)
+	}
+	if IsPermanent(base) {
+		t.Error("Expected a plain error not to be permanent")
+	}
+	if Permanent(nil) != nil {
+		t.Error("Expected Permanent(nil) to be nil")
+	}
+}
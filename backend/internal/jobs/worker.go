@@ -0,0 +1,346 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"easycart/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// jobTimeout bounds a single run; the lease adds slack so a slow job is
+	// not reclaimed by another worker while it is still being cancelled
+	jobTimeout = 5 * time.Minute
+	claimLease = jobTimeout + time.Minute
+
+	pollInterval     = 2 * time.Second
+	scheduleInterval = 30 * time.Second
+
+	defaultConcurrency = 4
+
+	// KindPrune deletes finished jobs; it runs nightly on every worker setup
+	KindPrune = "jobs.prune"
+
+	keepFinished = 7 * 24 * time.Hour
+	keepDead     = 30 * 24 * time.Hour
+)
+
+type schedule struct {
+	name    string
+	spec    string
+	kind    string
+	payload interface{}
+	cron    *Schedule
+}
+
+// Worker runs registered job handlers and enqueues recurring jobs. Any number
+// of workers may share the database; each only claims kinds it can handle.
+type Worker struct {
+	db          *gorm.DB
+	id          string
+	handlers    map[string]HandlerFunc
+	schedules   []schedule
+	concurrency int
+}
+
+func NewWorker(db *gorm.DB) *Worker {
+	hostname, _ := os.Hostname()
+	w := &Worker{
+		db:          db,
+		id:          fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		handlers:    map[string]HandlerFunc{},
+		concurrency: defaultConcurrency,
+	}
+	w.Register(KindPrune, w.prune)
+	if err := w.Schedule(KindPrune, "17 3 * * *", KindPrune, nil); err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// SetConcurrency sets how many jobs the worker runs at once
+func (w *Worker) SetConcurrency(n int) {
+	if n > 0 {
+		w.concurrency = n
+	}
+}
+
+// Register sets the handler for jobs of kind
+func (w *Worker) Register(kind string, fn HandlerFunc) {
+	w.handlers[kind] = fn
+}
+
+// Schedule enqueues a job of kind with payload whenever spec fires. A run is
+// skipped if the previous one for the same schedule has not finished.
+func (w *Worker) Schedule(name, spec, kind string, payload interface{}) error {
+	cron, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("schedule %s: %w", name, err)
+	}
+	w.schedules = append(w.schedules, schedule{name: name, spec: spec, kind: kind, payload: payload, cron: cron})
+	return nil
+}
+
+// Run processes jobs until ctx is cancelled, then waits for running jobs.
+// Jobs interrupted by shutdown go back to the queue without using an attempt.
+func (w *Worker) Run(ctx context.Context) error {
+	if err := w.syncSchedules(); err != nil {
+		return fmt.Errorf("failed to register job schedules: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.runScheduler(ctx)
+	}()
+
+	slots := make(chan struct{}, w.concurrency)
+	finished := make(chan struct{}, w.concurrency)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		free := w.concurrency - len(slots)
+		claimed := 0
+		if free > 0 && ctx.Err() == nil {
+			jobs, err := w.claim(free)
+			if err != nil {
+				log.Printf("Job queue error: %v", err)
+			}
+			claimed = len(jobs)
+			for i := range jobs {
+				job := jobs[i]
+				slots <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					w.execute(ctx, &job)
+					<-slots
+					select {
+					case finished <- struct{}{}:
+					default:
+					}
+				}()
+			}
+		}
+
+		// Keep claiming without waiting while there is a backlog and room for it
+		if claimed > 0 && claimed == free {
+			select {
+			case <-ctx.Done():
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case <-ticker.C:
+		case <-finished:
+		}
+	}
+}
+
+func (w *Worker) kinds() []string {
+	kinds := make([]string, 0, len(w.handlers))
+	for kind := range w.handlers {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// claim locks up to limit due jobs, including running jobs whose lease ran out
+func (w *Worker) claim(limit int) ([]models.Job, error) {
+	var jobs []models.Job
+	now := time.Now()
+
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("kind IN ?", w.kinds()).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)",
+				models.JobStatusPending, now, models.JobStatusRunning, now).
+			Order("run_at ASC").
+			Limit(limit).
+			Find(&jobs).Error; err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+
+		ids := make([]interface{}, len(jobs))
+		for i, job := range jobs {
+			ids[i] = job.ID
+		}
+		lockedUntil := now.Add(claimLease)
+		if err := tx.Model(&models.Job{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":       models.JobStatusRunning,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"locked_by":    w.id,
+			"locked_until": lockedUntil,
+		}).Error; err != nil {
+			return err
+		}
+
+		for i := range jobs {
+			jobs[i].Status = models.JobStatusRunning
+			jobs[i].Attempts++
+			jobs[i].LockedBy = w.id
+			jobs[i].LockedUntil = &lockedUntil
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+func (w *Worker) execute(ctx context.Context, job *models.Job) {
+	var err error
+	if job.Attempts > job.MaxAttempts {
+		// Reclaimed after its final attempt's worker disappeared
+		err = Permanent(fmt.Errorf("lease expired on final attempt"))
+	} else {
+		jobCtx, cancel := context.WithTimeout(ctx, jobTimeout)
+		err = w.invoke(jobCtx, job)
+		cancel()
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"locked_by":    "",
+		"locked_until": nil,
+	}
+	switch {
+	case err == nil:
+		updates["status"] = models.JobStatusSucceeded
+		updates["finished_at"] = now
+		updates["last_error"] = ""
+	case ctx.Err() != nil:
+		// Shutting down; put the job back as it was
+		updates["status"] = models.JobStatusPending
+		updates["attempts"] = job.Attempts - 1
+		updates["run_at"] = now
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		updates["status"] = models.JobStatusDead
+		updates["finished_at"] = now
+		updates["last_error"] = err.Error()
+		log.Printf("Job %s (%s) is dead after %d attempts: %v", job.ID, job.Kind, job.Attempts, err)
+	default:
+		updates["status"] = models.JobStatusPending
+		updates["run_at"] = now.Add(Backoff(job.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	// The attempts check keeps a worker that lost its lease from overwriting
+	// the outcome of whichever worker reclaimed the job
+	result := w.db.Model(&models.Job{}).
+		Where("id = ? AND status = ? AND attempts = ?", job.ID, models.JobStatusRunning, job.Attempts).
+		Updates(updates)
+	if result.Error != nil {
+		log.Printf("Failed to record result of job %s: %v", job.ID, result.Error)
+	}
+}
+
+// invoke runs the handler, turning a panic into an error
+func (w *Worker) invoke(ctx context.Context, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	handler, ok := w.handlers[job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler registered for %s", job.Kind))
+	}
+	return handler(ctx, job)
+}
+
+func (w *Worker) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.enqueueScheduled(time.Now()); err != nil {
+			log.Printf("Job scheduler error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncSchedules creates or updates the job_schedules row of each schedule
+func (w *Worker) syncSchedules() error {
+	now := time.Now()
+	for _, s := range w.schedules {
+		row := models.JobSchedule{Name: s.name, Spec: s.spec, Kind: s.kind, NextRunAt: s.cron.Next(now)}
+		if err := w.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return err
+		}
+		// A changed expression takes effect from now rather than from the old next run
+		if err := w.db.Model(&models.JobSchedule{}).
+			Where("name = ? AND (spec <> ? OR kind <> ?)", s.name, s.spec, s.kind).
+			Updates(map[string]interface{}{"spec": s.spec, "kind": s.kind, "next_run_at": s.cron.Next(now)}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueueScheduled enqueues every schedule that is due. The row lock makes
+// sure only one worker enqueues each run.
+func (w *Worker) enqueueScheduled(now time.Time) error {
+	if len(w.schedules) == 0 {
+		return nil
+	}
+	byName := make(map[string]schedule, len(w.schedules))
+	names := make([]string, 0, len(w.schedules))
+	for _, s := range w.schedules {
+		byName[s.name] = s
+		names = append(names, s.name)
+	}
+
+	return w.db.Transaction(func(tx *gorm.DB) error {
+		var due []models.JobSchedule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("name IN ? AND next_run_at <= ?", names, now).
+			Find(&due).Error; err != nil {
+			return err
+		}
+
+		for _, row := range due {
+			s := byName[row.Name]
+			if _, err := Enqueue(tx, s.kind, s.payload, UniqueKey("schedule:"+s.name)); err != nil {
+				return err
+			}
+			if err := tx.Model(&row).Updates(map[string]interface{}{
+				"next_run_at": s.cron.Next(now),
+				"last_run_at": now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (w *Worker) prune(ctx context.Context, job *models.Job) error {
+	now := time.Now()
+	return w.db.WithContext(ctx).
+		Where("(status IN ? AND finished_at < ?) OR (status = ? AND finished_at < ?)",
+			[]models.JobStatus{models.JobStatusSucceeded, models.JobStatusCancelled}, now.Add(-keepFinished),
+			models.JobStatusDead, now.Add(-keepDead)).
+		Delete(&models.Job{}).Error
+}
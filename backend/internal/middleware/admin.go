@@ -29,9 +29,30 @@ func AdminMiddleware() echo.MiddlewareFunc {
 				return echo.NewHTTPError(http.StatusForbidden, "Access denied: admin or manager role required")
 			}
 
-			// Set user in context for handlers that need it
+			permissions, err := user.EffectivePermissions(database.DB)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load permissions")
+			}
+
+			// API keys can never exceed their scopes, nor the permissions of their owner
+			if scopes, ok := c.Get("api_key_scopes").([]models.Permission); ok {
+				permissions = intersectPermissions(permissions, scopes)
+			}
+
+			// Set user and permissions in context for handlers that need them
 			c.Set("user", &user)
+			c.Set("permissions", permissions)
 			return next(c)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func intersectPermissions(granted, scopes []models.Permission) []models.Permission {
+	result := []models.Permission{}
+	for _, permission := range granted {
+		if models.HasPermission(scopes, permission) {
+			result = append(result, permission)
+		}
+	}
+	return result
+}
@@ -3,7 +3,10 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
+	"easycart/internal/database"
+	"easycart/internal/models"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -12,6 +15,10 @@ import (
 type JWTClaims struct {
 	UserID uuid.UUID `json:"user_id"`
 	Email  string    `json:"email"`
+
+	// Set on impersonation tokens: the staff user acting as UserID
+	ImpersonatorID *uuid.UUID `json:"impersonator_id,omitempty"`
+
 	jwt.RegisteredClaims
 }
 
@@ -24,6 +31,9 @@ func JWTMiddleware(jwtSecret string) echo.MiddlewareFunc {
 			}
 
 			bearerToken := strings.Split(authHeader, " ")
+			if len(bearerToken) == 2 && bearerToken[0] == "ApiKey" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "API keys are only accepted by the admin API")
+			}
 			if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
 				return echo.NewHTTPError(http.StatusUnauthorized, "invalid authorization format")
 			}
@@ -44,7 +54,83 @@ func JWTMiddleware(jwtSecret string) echo.MiddlewareFunc {
 
 			c.Set("user_id", claims.UserID)
 			c.Set("email", claims.Email)
+			if claims.ImpersonatorID != nil {
+				c.Set("impersonator_id", *claims.ImpersonatorID)
+			}
 			return next(c)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// OptionalJWTMiddleware authenticates the request when credentials are sent
+// and lets anonymous requests through, e.g. for checkout
+func OptionalJWTMiddleware(jwtSecret string) echo.MiddlewareFunc {
+	required := JWTMiddleware(jwtSecret)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		authenticated := required(next)
+		return func(c echo.Context) error {
+			if c.Request().Header.Get("Authorization") == "" {
+				return next(c)
+			}
+			return authenticated(c)
+		}
+	}
+}
+
+// AdminAuthMiddleware authenticates admin API requests with a JWT or an
+// "Authorization: ApiKey ..." credential. API keys are accepted only here,
+// in front of AdminMiddleware, which limits them to their scopes.
+func AdminAuthMiddleware(jwtSecret string) echo.MiddlewareFunc {
+	required := JWTMiddleware(jwtSecret)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		authenticated := required(next)
+		return func(c echo.Context) error {
+			scheme, key, ok := strings.Cut(c.Request().Header.Get("Authorization"), " ")
+			if !ok || scheme != "ApiKey" {
+				return authenticated(c)
+			}
+			if err := authenticateAPIKey(c, key); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// apiKeyTouchInterval limits how often last-used tracking writes to the database
+const apiKeyTouchInterval = time.Minute
+
+// authenticateAPIKey validates an "Authorization: ApiKey ..." credential and
+// populates the same context values as a JWT, plus the key's scopes
+func authenticateAPIKey(c echo.Context, key string) error {
+	prefix, secret, err := models.ParseAPIKey(key)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key")
+	}
+
+	var apiKey models.APIKey
+	if err := database.DB.Preload("User").Where("prefix = ?", prefix).First(&apiKey).Error; err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key")
+	}
+
+	now := time.Now()
+	if !apiKey.CheckSecret(secret) || !apiKey.IsUsable(now) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key")
+	}
+	if apiKey.User == nil || !apiKey.User.IsActive {
+		return echo.NewHTTPError(http.StatusUnauthorized, "API key owner is inactive")
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		database.DB.Model(&apiKey).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": c.RealIP(),
+		})
+	}
+
+	c.Set("user_id", apiKey.UserID)
+	c.Set("email", apiKey.User.Email)
+	c.Set("api_key_id", apiKey.ID)
+	c.Set("api_key_scopes", apiKey.Scopes)
+	return nil
+}
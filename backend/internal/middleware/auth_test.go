@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestJWTMiddleware_RejectsAPIKeys(t *testing.T) {
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	handler := JWTMiddleware("test-secret")(ok)
+
+	req := httptest.NewRequest(http.MethodPut, "/me/profile", nil)
+	req.Header.Set("Authorization", "ApiKey ec_abc.secret")
+	rec := httptest.NewRecorder()
+
+	err := handler(e.NewContext(req, rec))
+	httpErr, isHTTP := err.(*echo.HTTPError)
+	if !isHTTP || httpErr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 for an API key outside the admin API, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"easycart/internal/audit"
+	"easycart/internal/database"
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// ImpersonatorID returns the staff user behind an impersonation token, if any
+func ImpersonatorID(c echo.Context) (uuid.UUID, bool) {
+	id, ok := c.Get("impersonator_id").(uuid.UUID)
+	return id, ok
+}
+
+// BlockImpersonation rejects requests made with an impersonation token.
+// Use it on sensitive actions such as password or payment method changes.
+func BlockImpersonation() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := ImpersonatorID(c); ok {
+				return echo.NewHTTPError(http.StatusForbidden, "not allowed while impersonating")
+			}
+			return next(c)
+		}
+	}
+}
+
+// AuditImpersonation writes an audit event for every request made with an
+// impersonation token. It must run after JWTMiddleware or OptionalJWTMiddleware.
+func AuditImpersonation() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			impersonatorID, ok := ImpersonatorID(c)
+			if !ok {
+				return next(c)
+			}
+
+			err := next(c)
+
+			status := c.Response().Status
+			if httpErr, isHTTPErr := err.(*echo.HTTPError); isHTTPErr {
+				status = httpErr.Code
+			}
+
+			userID, _ := c.Get("user_id").(uuid.UUID)
+			metadata := models.JSONMap{
+				"method": c.Request().Method,
+				"path":   c.Request().URL.Path,
+				"status": status,
+			}
+			if dbErr := audit.RecordAction(database.DB, c, "impersonation.request", "user", userID, metadata); dbErr != nil {
+				log.Printf("Failed to write impersonation audit event for %s: %v", impersonatorID, dbErr)
+			}
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func signTestToken(t *testing.T, claims *JWTClaims) string {
+	t.Helper()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return token
+}
+
+func TestBlockImpersonation(t *testing.T) {
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	handler := JWTMiddleware("test-secret")(BlockImpersonation()(ok))
+
+	t.Run("regular token passes", func(t *testing.T) {
+		token := signTestToken(t, &JWTClaims{UserID: uuid.New(), Email: "jane@example.com"})
+
+		req := httptest.NewRequest(http.MethodPut, "/me/password", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+
+		if err := handler(e.NewContext(req, rec)); err != nil {
+			t.Fatalf("Expected request to pass, got %v", err)
+		}
+	})
+
+	t.Run("impersonation token is blocked", func(t *testing.T) {
+		impersonatorID := uuid.New()
+		token := signTestToken(t, &JWTClaims{UserID: uuid.New(), Email: "jane@example.com", ImpersonatorID: &impersonatorID})
+
+		req := httptest.NewRequest(http.MethodPut, "/me/password", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+
+		err := handler(e.NewContext(req, rec))
+		httpErr, isHTTPErr := err.(*echo.HTTPError)
+		if !isHTTPErr || httpErr.Code != http.StatusForbidden {
+			t.Errorf("Expected forbidden error, got %v", err)
+		}
+	})
+}
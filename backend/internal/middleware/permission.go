@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+
+	"easycart/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// RequirePermission only lets the request through if the caller holds the given
+// permission. It must run after AdminMiddleware, which loads the permissions.
+func RequirePermission(permission models.Permission) echo.MiddlewareFunc {
+	return RequireAnyPermission(permission)
+}
+
+// RequireAnyPermission only lets the request through if the caller holds at
+// least one of the given permissions
+func RequireAnyPermission(permissions ...models.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for _, permission := range permissions {
+				if HasPermission(c, permission) {
+					return next(c)
+				}
+			}
+			return echo.NewHTTPError(http.StatusForbidden, "Access denied: missing permission "+string(permissions[0]))
+		}
+	}
+}
+
+// Permissions returns the caller's effective permissions loaded by AdminMiddleware
+func Permissions(c echo.Context) []models.Permission {
+	permissions, _ := c.Get("permissions").([]models.Permission)
+	return permissions
+}
+
+// HasPermission reports whether the caller holds the given permission
+func HasPermission(c echo.Context, permission models.Permission) bool {
+	return models.HasPermission(Permissions(c), permission)
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPExtractor(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.5:4321"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 203.0.113.7")
+
+	direct, err := IPExtractor(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := direct(req); got != "10.0.0.5" {
+		t.Errorf("Without trusted proxies got %s, want the peer address", got)
+	}
+
+	proxied, err := IPExtractor([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := proxied(req); got != "203.0.113.7" {
+		t.Errorf("Behind a trusted proxy got %s, want the last untrusted address", got)
+	}
+
+	if _, err := IPExtractor([]string{"not-an-ip"}); err == nil {
+		t.Error("Expected an invalid proxy to be rejected")
+	}
+}
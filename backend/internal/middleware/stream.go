@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"easycart/internal/tokens"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// StreamTicketMiddleware authenticates event stream requests. Browsers'
+// EventSource cannot send an Authorization header, so a short-lived ticket in
+// the ticket query parameter is accepted instead of the usual JWT or API key.
+func StreamTicketMiddleware(jwtSecret string) echo.MiddlewareFunc {
+	required := AdminAuthMiddleware(jwtSecret)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		authenticated := required(next)
+		return func(c echo.Context) error {
+			ticket := c.QueryParam("ticket")
+			if ticket == "" {
+				return authenticated(c)
+			}
+
+			subject, err := tokens.Verify(jwtSecret, tokens.PurposeEventStream, ticket)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired ticket")
+			}
+			userID, err := uuid.Parse(subject)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired ticket")
+			}
+			c.Set("user_id", userID)
+			return next(c)
+		}
+	}
+}
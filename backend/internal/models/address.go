@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Address is an entry in a customer's address book
+type Address struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Label     string    `json:"label"` // e.g. "Home", "Office"
+	FirstName string    `json:"first_name" gorm:"not null"`
+	LastName  string    `json:"last_name" gorm:"not null"`
+	Company   string    `json:"company"`
+	Address   string    `json:"address" gorm:"not null"`
+	Address2  string    `json:"address2"`
+	City      string    `json:"city" gorm:"not null"`
+	State     string    `json:"state"`
+	Zip       string    `json:"zip" gorm:"not null"`
+	Country   string    `json:"country" gorm:"not null;default:'US'"`
+	Phone     string    `json:"phone"`
+
+	// At most one address per user carries each default flag
+	IsDefaultShipping bool `json:"is_default_shipping" gorm:"default:false"`
+	IsDefaultBilling  bool `json:"is_default_billing" gorm:"default:false"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	User *User `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (a *Address) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// ApplyShipping copies the address into the order's shipping fields
+func (a *Address) ApplyShipping(o *Order) {
+	o.ShippingFirstName = a.FirstName
+	o.ShippingLastName = a.LastName
+	o.ShippingAddress = a.Address
+	o.ShippingAddress2 = a.Address2
+	o.ShippingCity = a.City
+	o.ShippingState = a.State
+	o.ShippingZip = a.Zip
+	o.ShippingCountry = a.Country
+}
+
+// ApplyBilling copies the address into the order's billing fields
+func (a *Address) ApplyBilling(o *Order) {
+	o.BillingFirstName = a.FirstName
+	o.BillingLastName = a.LastName
+	o.BillingAddress = a.Address
+	o.BillingAddress2 = a.Address2
+	o.BillingCity = a.City
+	o.BillingState = a.State
+	o.BillingZip = a.Zip
+	o.BillingCountry = a.Country
+	o.SameAsBilling = false
+}
@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestAddress_Apply(t *testing.T) {
+	home := Address{FirstName: "Jane", LastName: "Doe", Address: "1 Main St", City: "Springfield", Zip: "12345", Country: "US"}
+	office := Address{FirstName: "Jane", LastName: "Doe", Address: "9 Work Rd", City: "Shelbyville", Zip: "54321", Country: "CA"}
+
+	order := Order{SameAsBilling: true}
+	home.ApplyShipping(&order)
+	if order.ShippingAddress != "1 Main St" || order.ShippingCity != "Springfield" || order.ShippingFirstName != "Jane" {
+		t.Errorf("Shipping address not applied: %+v", order)
+	}
+	if !order.SameAsBilling {
+		t.Error("Applying shipping should not change SameAsBilling")
+	}
+
+	office.ApplyBilling(&order)
+	if order.BillingAddress != "9 Work Rd" || order.BillingCountry != "CA" {
+		t.Errorf("Billing address not applied: %+v", order)
+	}
+	if order.SameAsBilling {
+		t.Error("Expected SameAsBilling to be cleared by a separate billing address")
+	}
+}
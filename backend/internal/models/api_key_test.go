@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAPIKey_GenerateAndParse(t *testing.T) {
+	var key APIKey
+	plaintext, err := key.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() error = %v", err)
+	}
+
+	prefix, secret, err := ParseAPIKey(plaintext)
+	if err != nil {
+		t.Fatalf("ParseAPIKey() error = %v", err)
+	}
+	if prefix != key.Prefix {
+		t.Errorf("Expected prefix %q, got %q", key.Prefix, prefix)
+	}
+	if !key.CheckSecret(secret) {
+		t.Error("Expected generated secret to match stored hash")
+	}
+	if key.CheckSecret(secret + "x") {
+		t.Error("Expected modified secret not to match")
+	}
+}
+
+func TestParseAPIKey_Invalid(t *testing.T) {
+	for _, input := range []string{"", "abc", "ec_onlyprefix", "xx_prefix_secret", "ec__secret"} {
+		if _, _, err := ParseAPIKey(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestAPIKey_IsUsable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	if !(&APIKey{}).IsUsable(now) {
+		t.Error("Expected key without expiry to be usable")
+	}
+	if !(&APIKey{ExpiresAt: &future}).IsUsable(now) {
+		t.Error("Expected unexpired key to be usable")
+	}
+	if (&APIKey{ExpiresAt: &past}).IsUsable(now) {
+		t.Error("Expected expired key not to be usable")
+	}
+	if (&APIKey{RevokedAt: &past}).IsUsable(now) {
+		t.Error("Expected revoked key not to be usable")
+	}
+}
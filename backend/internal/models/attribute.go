@@ -0,0 +1,467 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AttributeType is the kind of value an attribute holds
+type AttributeType string
+
+const (
+	AttributeText    AttributeType = "text"
+	AttributeNumber  AttributeType = "number" // With an optional unit, e.g. "in"
+	AttributeBoolean AttributeType = "boolean"
+	AttributeEnum    AttributeType = "enum" // One of the definition's options
+)
+
+var attributeCodePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_]*$`)
+
+// AttributeDefinition is a specification products can have, such as the
+// screen size of laptops. Values are set on products, or on categories for
+// every product below them that has none of its own.
+type AttributeDefinition struct {
+	ID   uuid.UUID     `json:"id" gorm:"type:uuid;primary_key"`
+	Code string        `json:"code" gorm:"not null;uniqueIndex"` // Names the attribute in filters and import columns
+	Name string        `json:"name" gorm:"not null"`
+	Type AttributeType `json:"type" gorm:"not null"`
+	Unit string        `json:"unit"`
+	// Options are the choices of an enum, in display order
+	Options    []string  `json:"options" gorm:"serializer:json;type:text"`
+	Filterable bool      `json:"filterable" gorm:"default:false"` // Offered as a storefront filter
+	Position   int       `json:"position" gorm:"not null;default:0"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// AttributeValue is the value of an attribute on either a product or a
+// category. Only the column of the attribute's type is set; enums use Text.
+type AttributeValue struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	AttributeID uuid.UUID  `json:"attribute_id" gorm:"type:uuid;not null;uniqueIndex:idx_attribute_values_product;uniqueIndex:idx_attribute_values_category"`
+	ProductID   *uuid.UUID `json:"product_id,omitempty" gorm:"type:uuid;uniqueIndex:idx_attribute_values_product"`
+	CategoryID  *uuid.UUID `json:"category_id,omitempty" gorm:"type:uuid;uniqueIndex:idx_attribute_values_category"`
+	Text        *string    `json:"text,omitempty" gorm:"column:text_value"`
+	Number      *float64   `json:"number,omitempty" gorm:"column:number_value"`
+	Boolean     *bool      `json:"boolean,omitempty" gorm:"column:bool_value"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Attribute AttributeDefinition `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Product   *Product            `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Category  *Category           `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// ProductAttribute is an attribute value as products show it
+type ProductAttribute struct {
+	Code    string        `json:"code"`
+	Name    string        `json:"name"`
+	Type    AttributeType `json:"type"`
+	Unit    string        `json:"unit,omitempty"`
+	Value   interface{}   `json:"value"`
+	Display string        `json:"display"` // e.g. "15.6 in" or "Yes"
+
+	// CategoryID is set when the value is inherited from a category
+	CategoryID *uuid.UUID `json:"category_id,omitempty"`
+}
+
+// AttributeError is an invalid attribute value, named by its code
+type AttributeError struct {
+	Code    string
+	Message string
+}
+
+func (e *AttributeError) Error() string {
+	return "attribute " + e.Code + ": " + e.Message
+}
+
+func (d *AttributeDefinition) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+func (v *AttributeValue) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == uuid.Nil {
+		v.ID = uuid.New()
+	}
+	return nil
+}
+
+// Validate normalizes and checks a definition
+func (d *AttributeDefinition) Validate() error {
+	d.Code = strings.ToLower(strings.TrimSpace(d.Code))
+	d.Name = strings.TrimSpace(d.Name)
+	d.Unit = strings.TrimSpace(d.Unit)
+	if !attributeCodePattern.MatchString(d.Code) {
+		return errors.New("code must be lower-case letters, digits and underscores")
+	}
+	if d.Name == "" {
+		return errors.New("name is required")
+	}
+
+	switch d.Type {
+	case AttributeNumber:
+		d.Options = nil
+		return nil
+	case AttributeEnum:
+		options := []string{}
+		seen := map[string]bool{}
+		for _, option := range d.Options {
+			option = strings.TrimSpace(option)
+			if key := strings.ToLower(option); option != "" && !seen[key] {
+				seen[key] = true
+				options = append(options, option)
+			}
+		}
+		if len(options) == 0 {
+			return errors.New("enum attributes need options")
+		}
+		d.Options = options
+	case AttributeText, AttributeBoolean:
+		d.Options = nil
+	default:
+		return errors.New("type must be text, number, boolean or enum")
+	}
+	if d.Unit != "" {
+		return errors.New("only number attributes have a unit")
+	}
+	return nil
+}
+
+// Parse reads a value from JSON or from a CSV cell. Numbers and booleans may
+// be given as text.
+func (d *AttributeDefinition) Parse(raw interface{}) (AttributeValue, error) {
+	value := AttributeValue{AttributeID: d.ID}
+	text, isText := raw.(string)
+	text = strings.TrimSpace(text)
+
+	switch d.Type {
+	case AttributeText:
+		if !isText || text == "" {
+			return value, errors.New("must be text")
+		}
+		value.Text = &text
+	case AttributeNumber:
+		var number float64
+		switch v := raw.(type) {
+		case float64:
+			number = v
+		case int:
+			number = float64(v)
+		case string:
+			// Units are accepted after the number, e.g. "15.6 in"
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(text, d.Unit)), 64)
+			if err != nil {
+				return value, errors.New("must be a number")
+			}
+			number = parsed
+		default:
+			return value, errors.New("must be a number")
+		}
+		value.Number = &number
+	case AttributeBoolean:
+		var b bool
+		switch v := raw.(type) {
+		case bool:
+			b = v
+		case string:
+			switch strings.ToLower(text) {
+			case "true", "yes", "1":
+				b = true
+			case "false", "no", "0":
+			default:
+				return value, errors.New("must be true or false")
+			}
+		default:
+			return value, errors.New("must be true or false")
+		}
+		value.Boolean = &b
+	case AttributeEnum:
+		for _, option := range d.Options {
+			if isText && strings.EqualFold(option, text) {
+				option := option
+				value.Text = &option
+				return value, nil
+			}
+		}
+		return value, fmt.Errorf("must be one of %s", strings.Join(d.Options, ", "))
+	}
+	return value, nil
+}
+
+// Value is the typed value of v, nil when it has none
+func (d *AttributeDefinition) Value(v AttributeValue) interface{} {
+	switch {
+	case d.Type == AttributeNumber && v.Number != nil:
+		return *v.Number
+	case d.Type == AttributeBoolean && v.Boolean != nil:
+		return *v.Boolean
+	case v.Text != nil:
+		return *v.Text
+	}
+	return nil
+}
+
+// Text writes v as Parse reads it, for exports
+func (d *AttributeDefinition) Text(v AttributeValue) string {
+	switch value := d.Value(v).(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case string:
+		return value
+	}
+	return ""
+}
+
+// Display writes v for shoppers, with the unit of numbers
+func (d *AttributeDefinition) Display(v AttributeValue) string {
+	switch value := d.Value(v).(type) {
+	case float64:
+		display := strconv.FormatFloat(value, 'f', -1, 64)
+		if d.Unit != "" {
+			display += " " + d.Unit
+		}
+		return display
+	case bool:
+		if value {
+			return "Yes"
+		}
+		return "No"
+	case string:
+		return value
+	}
+	return ""
+}
+
+// SetProductAttributes sets attribute values of a product by code. A nil
+// value removes the product's own value; codes not given are left alone.
+// Invalid values return an *AttributeError.
+func SetProductAttributes(tx *gorm.DB, productID uuid.UUID, values map[string]interface{}) (bool, error) {
+	return setAttributeValues(tx, "product_id", productID, values)
+}
+
+// SetCategoryAttributes sets the values a category gives the products
+// below it, as SetProductAttributes does for one product
+func SetCategoryAttributes(tx *gorm.DB, categoryID uuid.UUID, values map[string]interface{}) (bool, error) {
+	return setAttributeValues(tx, "category_id", categoryID, values)
+}
+
+func setAttributeValues(tx *gorm.DB, ownerColumn string, ownerID uuid.UUID, values map[string]interface{}) (bool, error) {
+	if len(values) == 0 {
+		return false, nil
+	}
+	codes := make([]string, 0, len(values))
+	for code := range values {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var definitions []AttributeDefinition
+	if err := tx.Where("code IN ?", codes).Find(&definitions).Error; err != nil {
+		return false, err
+	}
+	byCode := make(map[string]*AttributeDefinition, len(definitions))
+	for i := range definitions {
+		byCode[definitions[i].Code] = &definitions[i]
+	}
+
+	changed := false
+	for _, code := range codes {
+		definition, ok := byCode[code]
+		if !ok {
+			return false, &AttributeError{Code: code, Message: "no such attribute"}
+		}
+		var current AttributeValue
+		err := tx.Where("attribute_id = ? AND "+ownerColumn+" = ?", definition.ID, ownerID).Take(&current).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return false, err
+		}
+		exists := err == nil
+
+		if values[code] == nil {
+			if exists {
+				if err := tx.Delete(&current).Error; err != nil {
+					return false, err
+				}
+				changed = true
+			}
+			continue
+		}
+
+		value, err := definition.Parse(values[code])
+		if err != nil {
+			return false, &AttributeError{Code: code, Message: err.Error()}
+		}
+		if exists && definition.Text(current) == definition.Text(value) {
+			continue
+		}
+		if exists {
+			value.ID = current.ID
+			value.CreatedAt = current.CreatedAt
+		}
+		if ownerColumn == "product_id" {
+			value.ProductID = &ownerID
+		} else {
+			value.CategoryID = &ownerID
+		}
+		if err := tx.Omit("Attribute", "Product", "Category").Save(&value).Error; err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// CategoryAttributes returns the values set on a category, by code
+func CategoryAttributes(tx *gorm.DB, categoryID uuid.UUID) (map[string]interface{}, error) {
+	var values []AttributeValue
+	if err := tx.Preload("Attribute").Where("category_id = ?", categoryID).Find(&values).Error; err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]interface{}, len(values))
+	for _, value := range values {
+		attributes[value.Attribute.Code] = value.Attribute.Value(value)
+	}
+	return attributes, nil
+}
+
+// AttachAttributes sets the attributes of products whose category is
+// preloaded: each product's own values, else the value of its nearest
+// category that has one, in the definitions' order
+func AttachAttributes(tx *gorm.DB, products ...*Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	var definitions []AttributeDefinition
+	if err := tx.Order("position ASC, name ASC").Find(&definitions).Error; err != nil {
+		return err
+	}
+	if len(definitions) == 0 {
+		return nil
+	}
+
+	productIDs := make([]uuid.UUID, len(products))
+	var categoryIDs []uuid.UUID
+	for i, product := range products {
+		productIDs[i] = product.ID
+		if product.Category != nil {
+			categoryIDs = append(categoryIDs, product.Category.AncestorIDs()...)
+			categoryIDs = append(categoryIDs, product.Category.ID)
+		}
+	}
+	query := tx.Where("product_id IN ?", productIDs)
+	if len(categoryIDs) > 0 {
+		query = query.Or("category_id IN ?", categoryIDs)
+	}
+	var values []AttributeValue
+	if err := query.Find(&values).Error; err != nil {
+		return err
+	}
+
+	type key struct{ owner, attribute uuid.UUID }
+	own := map[key]AttributeValue{}
+	inherited := map[key]AttributeValue{}
+	for _, value := range values {
+		if value.ProductID != nil {
+			own[key{*value.ProductID, value.AttributeID}] = value
+		} else if value.CategoryID != nil {
+			inherited[key{*value.CategoryID, value.AttributeID}] = value
+		}
+	}
+
+	for _, product := range products {
+		// Nearest category first
+		var chain []uuid.UUID
+		if product.Category != nil {
+			chain = append([]uuid.UUID{product.Category.ID}, reversed(product.Category.AncestorIDs())...)
+		}
+		product.Attributes = []ProductAttribute{}
+		for i := range definitions {
+			definition := &definitions[i]
+			value, ok := own[key{product.ID, definition.ID}]
+			var from *uuid.UUID
+			for j := 0; !ok && j < len(chain); j++ {
+				if value, ok = inherited[key{chain[j], definition.ID}]; ok {
+					from = &chain[j]
+				}
+			}
+			if !ok {
+				continue
+			}
+			product.Attributes = append(product.Attributes, ProductAttribute{
+				Code:       definition.Code,
+				Name:       definition.Name,
+				Type:       definition.Type,
+				Unit:       definition.Unit,
+				Value:      definition.Value(value),
+				Display:    definition.Display(value),
+				CategoryID: from,
+			})
+		}
+	}
+	return nil
+}
+
+func reversed(ids []uuid.UUID) []uuid.UUID {
+	out := make([]uuid.UUID, len(ids))
+	for i, id := range ids {
+		out[len(ids)-1-i] = id
+	}
+	return out
+}
+
+// AttributeComparison is a row of a product comparison: the value of one
+// attribute for each product, nil where a product has none
+type AttributeComparison struct {
+	Code    string        `json:"code"`
+	Name    string        `json:"name"`
+	Type    AttributeType `json:"type"`
+	Unit    string        `json:"unit,omitempty"`
+	Values  []interface{} `json:"values"`
+	Display []string      `json:"display"`
+	Differs bool          `json:"differs"` // The products do not all agree
+}
+
+// CompareAttributes aligns the attached attributes of products into rows,
+// in the order of definitions, leaving out attributes none of them have
+func CompareAttributes(definitions []AttributeDefinition, products []*Product) []AttributeComparison {
+	rows := []AttributeComparison{}
+	for _, definition := range definitions {
+		row := AttributeComparison{
+			Code:    definition.Code,
+			Name:    definition.Name,
+			Type:    definition.Type,
+			Unit:    definition.Unit,
+			Values:  make([]interface{}, len(products)),
+			Display: make([]string, len(products)),
+		}
+		found := false
+		for i, product := range products {
+			for _, attribute := range product.Attributes {
+				if attribute.Code == definition.Code {
+					row.Values[i], row.Display[i] = attribute.Value, attribute.Display
+					found = true
+				}
+			}
+			if i > 0 && row.Display[i] != row.Display[0] {
+				row.Differs = true
+			}
+		}
+		if found {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestAttributeDefinition_Validate(t *testing.T) {
+	enum := AttributeDefinition{Code: " Color ", Name: "Color", Type: AttributeEnum, Options: []string{"Black", " black", "", "Silver"}}
+	if err := enum.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if enum.Code != "color" || len(enum.Options) != 2 || enum.Options[1] != "Silver" {
+		t.Errorf("normalized to %+v, want code color and options Black, Silver", enum)
+	}
+
+	invalid := []AttributeDefinition{
+		{Code: "screen size", Name: "Screen size", Type: AttributeNumber},
+		{Code: "size", Name: " ", Type: AttributeNumber},
+		{Code: "size", Name: "Size", Type: "date"},
+		{Code: "color", Name: "Color", Type: AttributeEnum},
+		{Code: "wifi", Name: "Wi-Fi", Type: AttributeBoolean, Unit: "in"},
+	}
+	for _, definition := range invalid {
+		if err := definition.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want an error", definition)
+		}
+	}
+}
+
+func TestAttributeDefinition_Parse(t *testing.T) {
+	size := AttributeDefinition{Type: AttributeNumber, Unit: "in"}
+	for _, raw := range []interface{}{15.6, "15.6", "15.6 in"} {
+		value, err := size.Parse(raw)
+		if err != nil || *value.Number != 15.6 {
+			t.Errorf("Parse(%v) = %v, %v; want 15.6", raw, value.Number, err)
+		}
+	}
+	value, _ := size.Parse(15.6)
+	if size.Display(value) != "15.6 in" || size.Text(value) != "15.6" {
+		t.Errorf("Display, Text = %q, %q; want 15.6 in, 15.6", size.Display(value), size.Text(value))
+	}
+
+	wifi := AttributeDefinition{Type: AttributeBoolean}
+	if value, err := wifi.Parse("yes"); err != nil || !*value.Boolean || wifi.Display(value) != "Yes" {
+		t.Errorf("Parse(yes) = %v, %v; want true", value.Boolean, err)
+	}
+
+	color := AttributeDefinition{Type: AttributeEnum, Options: []string{"Black", "Silver"}}
+	if value, err := color.Parse("silver"); err != nil || *value.Text != "Silver" {
+		t.Errorf("Parse(silver) = %v, %v; want the option's own spelling", value.Text, err)
+	}
+
+	invalid := []struct {
+		definition AttributeDefinition
+		raw        interface{}
+	}{
+		{size, "large"},
+		{size, true},
+		{wifi, "maybe"},
+		{color, "Gold"},
+		{AttributeDefinition{Type: AttributeText}, " "},
+	}
+	for _, c := range invalid {
+		if _, err := c.definition.Parse(c.raw); err == nil {
+			t.Errorf("%s Parse(%v) = nil error, want one", c.definition.Type, c.raw)
+		}
+	}
+}
+
+func TestCompareAttributes(t *testing.T) {
+	definitions := []AttributeDefinition{
+		{ID: uuid.New(), Code: "screen_size", Name: "Screen size", Type: AttributeNumber, Unit: "in"},
+		{ID: uuid.New(), Code: "color", Name: "Color", Type: AttributeEnum},
+		{ID: uuid.New(), Code: "weight", Name: "Weight", Type: AttributeNumber},
+	}
+	laptop := &Product{Attributes: []ProductAttribute{
+		{Code: "screen_size", Value: 15.6, Display: "15.6 in"},
+		{Code: "color", Value: "Silver", Display: "Silver"},
+	}}
+	other := &Product{Attributes: []ProductAttribute{
+		{Code: "color", Value: "Silver", Display: "Silver"},
+	}}
+
+	rows := CompareAttributes(definitions, []*Product{laptop, other})
+	if len(rows) != 2 || rows[0].Code != "screen_size" || rows[1].Code != "color" {
+		t.Fatalf("rows = %+v, want screen_size then color", rows)
+	}
+	if rows[0].Values[0] != 15.6 || rows[0].Values[1] != nil || !rows[0].Differs {
+		t.Errorf("screen_size row = %+v, want a value for the first product only", rows[0])
+	}
+	if rows[1].Differs || rows[1].Display[1] != "Silver" {
+		t.Errorf("color row = %+v, want the same value for both", rows[1])
+	}
+}
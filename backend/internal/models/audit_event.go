@@ -0,0 +1,47 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrAuditEventImmutable = errors.New("audit events are append-only")
+
+// AuditEvent records who did what. Events are only ever inserted; updates and
+// deletes are rejected here and by a database trigger.
+type AuditEvent struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	ActorID        *uuid.UUID `json:"actor_id,omitempty" gorm:"type:uuid;index"`
+	ImpersonatorID *uuid.UUID `json:"impersonator_id,omitempty" gorm:"type:uuid;index"` // Set when the actor was being impersonated
+	APIKeyID       *uuid.UUID `json:"api_key_id,omitempty" gorm:"type:uuid"`            // Set when the actor used an API key
+	Action         string     `json:"action" gorm:"not null;index"`
+	EntityType     string     `json:"entity_type" gorm:"index:idx_audit_entity"`
+	EntityID       string     `json:"entity_id" gorm:"index:idx_audit_entity"`
+	Changes        JSONMap    `json:"changes,omitempty" gorm:"serializer:json;type:text"` // Changed fields with before/after values
+	Metadata       JSONMap    `json:"metadata,omitempty" gorm:"serializer:json;type:text"`
+	IP             string     `json:"ip"`
+	UserAgent      string     `json:"user_agent"`
+	RequestID      string     `json:"request_id" gorm:"index"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"index"`
+}
+
+// JSONMap is a free-form JSON object stored in a text column
+type JSONMap map[string]interface{}
+
+func (e *AuditEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+func (e *AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
+
+func (e *AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
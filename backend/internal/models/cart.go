@@ -0,0 +1,108 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxCartQuantity caps the quantity of one cart line
+const MaxCartQuantity = 99
+
+// CartLineSQL is the key of the unique index on cart_items: one line per
+// product and variant in a cart, with no variant counted as one value
+const CartLineSQL = "cart_id, product_id, COALESCE(variant_id, '00000000-0000-0000-0000-000000000000'::uuid)"
+
+// Cart is a signed-in customer's shopping cart, kept on the server so it
+// follows them between devices. Each customer has one.
+type Cart struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `json:"-" gorm:"type:uuid;not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	User  *User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Items []CartItem `json:"items" gorm:"foreignKey:CartID"`
+}
+
+// CartItem is a quantity of a product, or one variant of it, in a cart
+type CartItem struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	CartID    uuid.UUID  `json:"cart_id" gorm:"type:uuid;not null;index"`
+	ProductID uuid.UUID  `json:"product_id" gorm:"type:uuid;not null"`
+	VariantID *uuid.UUID `json:"variant_id,omitempty" gorm:"type:uuid"`
+	Quantity  int        `json:"quantity" gorm:"not null"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	Cart    *Cart           `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Product *Product        `json:"product,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	Variant *ProductVariant `json:"variant,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (c *Cart) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+func (i *CartItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// UnitPrice is the variant's price when it has one, else the product's, in
+// cents
+func (i *CartItem) UnitPrice() int {
+	if i.Product == nil {
+		return 0
+	}
+	return CurrentPrice(i.Product, i.Variant)
+}
+
+// CartFor returns the customer's cart, creating it the first time
+func CartFor(tx *gorm.DB, userID uuid.UUID) (*Cart, error) {
+	cart := Cart{UserID: userID}
+	if err := tx.Where("user_id = ?", userID).FirstOrCreate(&cart).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// AddToCart adds quantity of a product or variant to the customer's cart,
+// on the line it already has if any. Quantities are capped at
+// MaxCartQuantity. Concurrent adds of the same product meet on the unique
+// index rather than creating two lines.
+func AddToCart(tx *gorm.DB, userID, productID uuid.UUID, variantID *uuid.UUID, quantity int) (*CartItem, error) {
+	cart, err := CartFor(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var item CartItem
+	err = tx.Raw(`
+		INSERT INTO cart_items (id, cart_id, product_id, variant_id, quantity, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (`+CartLineSQL+`)
+		DO UPDATE SET quantity = LEAST(cart_items.quantity + EXCLUDED.quantity, ?), updated_at = EXCLUDED.updated_at
+		RETURNING *`,
+		uuid.New(), cart.ID, productID, variantID, min(quantity, MaxCartQuantity), now, now, MaxCartQuantity,
+	).Scan(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, tx.Model(cart).Update("updated_at", now).Error
+}
+
+// RemoveOrderedFromCart removes the lines of the products a customer just
+// ordered from their cart. Orders are of whole products, so lines for a
+// variant stay.
+func RemoveOrderedFromCart(tx *gorm.DB, userID uuid.UUID, productIDs []uuid.UUID) error {
+	return tx.Where("cart_id IN (SELECT id FROM carts WHERE user_id = ?) AND product_id IN ? AND variant_id IS NULL", userID, productIDs).
+		Delete(&CartItem{}).Error
+}
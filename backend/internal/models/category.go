@@ -1,22 +1,43 @@
 package models
 
 import (
+	"errors"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// CategorySubtreeSQL selects the IDs of a category, given as its only
+// argument, and of every category below it
+const CategorySubtreeSQL = "SELECT sub.id FROM categories sub WHERE sub.path LIKE (SELECT root.path FROM categories root WHERE root.id = ?) || '%'"
+
+var ErrCategoryCycle = errors.New("a category cannot be moved below itself")
+
+// categoryTreeLock is the advisory lock key held while the tree is reshaped
+const categoryTreeLock = 7_310_584_101
+
+
 type Category struct {
-	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
-	Name        string    `json:"name" gorm:"not null"`
-	Slug        string    `json:"slug" gorm:"not null;index"`
-	Description string    `json:"description"`
-	ImageURL    string    `json:"image_url"`
-	IsActive    bool      `json:"is_active" gorm:"default:true"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	ParentID    *uuid.UUID `json:"parent_id" gorm:"type:uuid;index"`
+	Name        string     `json:"name" gorm:"not null"`
+	Slug        string     `json:"slug" gorm:"not null;index"`
+	Description string     `json:"description"`
+	ImageURL    string     `json:"image_url"`
+	IsActive    bool       `json:"is_active" gorm:"default:true"`
+	Position    int        `json:"position" gorm:"not null;default:0"` // Order among its siblings
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Path lists the IDs from the root down to the category, e.g.
+	// "/<root id>/<child id>/", so a subtree is every path with its prefix
+	Path  string `json:"path" gorm:"not null;default:'';index"`
+	Depth int    `json:"depth" gorm:"not null;default:0"` // 0 for roots
 
+	Parent   *Category  `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
 	Products []*Product `json:"products,omitempty" gorm:"foreignKey:CategoryID"`
 }
 
@@ -24,5 +45,247 @@ func (c *Category) BeforeCreate(tx *gorm.DB) error {
 	if c.ID == uuid.Nil {
 		c.ID = uuid.New()
 	}
+	if c.Path == "" {
+		c.PlaceUnder(nil)
+	}
+	return nil
+}
+
+// PlaceUnder sets the parent, path and depth of the category; nil makes it
+// a root. Moving a saved category also moves its subtree, see MoveCategory.
+func (c *Category) PlaceUnder(parent *Category) {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	if parent == nil {
+		c.ParentID, c.Path, c.Depth = nil, "/"+c.ID.String()+"/", 0
+		return
+	}
+	parentID := parent.ID
+	c.ParentID, c.Path, c.Depth = &parentID, parent.Path+c.ID.String()+"/", parent.Depth+1
+}
+
+// Contains reports whether other is the category or below it
+func (c *Category) Contains(other *Category) bool {
+	return strings.HasPrefix(other.Path, c.Path)
+}
+
+// AncestorIDs lists the categories above this one, root first
+func (c *Category) AncestorIDs() []uuid.UUID {
+	var ids []uuid.UUID
+	for _, part := range strings.Split(strings.Trim(c.Path, "/"), "/") {
+		if id, err := uuid.Parse(part); err == nil && id != c.ID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// LockCategoryTree holds off other moves and deletes until tx ends. Row locks
+// alone are not enough: two moves that each lock their own rows can still
+// make a cycle through categories neither of them touched. Read the
+// categories to move after taking it, so their paths are current.
+func LockCategoryTree(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", categoryTreeLock).Error
+}
+
+// MoveCategory moves a category and its subtree below parent, or to the
+// roots when parent is nil, at position among its new siblings. A nil
+// position puts it last. The siblings it leaves and joins are renumbered.
+func MoveCategory(tx *gorm.DB, c *Category, parent *Category, position *int) error {
+	if parent != nil && c.Contains(parent) {
+		return ErrCategoryCycle
+	}
+
+	oldParentID, oldPath, oldDepth := c.ParentID, c.Path, c.Depth
+	c.PlaceUnder(parent)
+	if c.Path != oldPath {
+		err := tx.Exec("UPDATE categories SET path = ? || substr(path, ?), depth = depth + ?, updated_at = ? WHERE path LIKE ? AND id <> ?",
+			c.Path, len(oldPath)+1, c.Depth-oldDepth, time.Now(), oldPath+"%", c.ID).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	var siblings []Category
+	if err := childrenOf(tx, c.ParentID).Where("id <> ?", c.ID).Order("position ASC, name ASC").Find(&siblings).Error; err != nil {
+		return err
+	}
+	at := len(siblings)
+	if position != nil && *position >= 0 && *position < at {
+		at = *position
+	}
+	ids := make([]uuid.UUID, 0, len(siblings)+1)
+	for _, sibling := range siblings[:at] {
+		ids = append(ids, sibling.ID)
+	}
+	ids = append(ids, c.ID)
+	for _, sibling := range siblings[at:] {
+		ids = append(ids, sibling.ID)
+	}
+	if err := renumber(tx, ids); err != nil {
+		return err
+	}
+	c.Position = at
+
+	if err := tx.Model(c).Select("parent_id", "path", "depth", "position").Updates(c).Error; err != nil {
+		return err
+	}
+	if !sameParent(oldParentID, c.ParentID) {
+		return RenumberCategories(tx, oldParentID)
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// RenumberCategories closes the gaps in the positions of the children of
+// parentID, or of the roots when it is nil
+func RenumberCategories(tx *gorm.DB, parentID *uuid.UUID) error {
+	var ids []uuid.UUID
+	if err := childrenOf(tx, parentID).Order("position ASC, name ASC").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	return renumber(tx, ids)
+}
+
+func childrenOf(tx *gorm.DB, parentID *uuid.UUID) *gorm.DB {
+	if parentID == nil {
+		return tx.Model(&Category{}).Where("parent_id IS NULL")
+	}
+	return tx.Model(&Category{}).Where("parent_id = ?", *parentID)
+}
+
+// renumber sets the positions of categories to their order in ids
+func renumber(tx *gorm.DB, ids []uuid.UUID) error {
+	for i, id := range ids {
+		if err := tx.Model(&Category{}).Where("id = ? AND position <> ?", id, i).Update("position", i).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameParent(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// NextCategoryPosition is the position after the last child of parentID
+func NextCategoryPosition(tx *gorm.DB, parentID *uuid.UUID) (int, error) {
+	var count int64
+	err := childrenOf(tx, parentID).Count(&count).Error
+	return int(count), err
+}
+
+// Breadcrumb is a category on the way from the root to a product's category
+type Breadcrumb struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Slug string    `json:"slug"`
+}
+
+// AttachBreadcrumbs sets the breadcrumbs of products whose category is
+// preloaded, loading every ancestor in one query
+func AttachBreadcrumbs(tx *gorm.DB, products ...*Product) error {
+	var ids []uuid.UUID
+	for _, product := range products {
+		if product.Category != nil {
+			ids = append(ids, product.Category.AncestorIDs()...)
+		}
+	}
+	ancestors := map[uuid.UUID]Category{}
+	if len(ids) > 0 {
+		var found []Category
+		if err := tx.Select("id", "name", "slug").Where("id IN ?", ids).Find(&found).Error; err != nil {
+			return err
+		}
+		for _, category := range found {
+			ancestors[category.ID] = category
+		}
+	}
+
+	for _, product := range products {
+		if product.Category == nil {
+			continue
+		}
+		product.Breadcrumbs = []Breadcrumb{}
+		for _, id := range product.Category.AncestorIDs() {
+			if ancestor, ok := ancestors[id]; ok {
+				product.Breadcrumbs = append(product.Breadcrumbs, Breadcrumb{ID: ancestor.ID, Name: ancestor.Name, Slug: ancestor.Slug})
+			}
+		}
+		product.Breadcrumbs = append(product.Breadcrumbs, Breadcrumb{ID: product.Category.ID, Name: product.Category.Name, Slug: product.Category.Slug})
+	}
+	return nil
+}
+
+// CategoryNode is a category in a tree
+type CategoryNode struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	ImageURL    string    `json:"image_url"`
+	IsActive    bool      `json:"is_active"`
+	Position    int       `json:"position"`
+	Depth       int       `json:"depth"`
+
+	// ProductCount counts the products of the category and its subtree
+	ProductCount int64           `json:"product_count"`
+	Children     []*CategoryNode `json:"children"`
+}
+
+// CategoryTree nests categories under their parents, ordered by position
+// then name. Categories whose parent is missing from the list are left out
+// with their subtree, so filtering out a category hides everything below
+// it. counts are the products directly in each category.
+func CategoryTree(categories []Category, counts map[uuid.UUID]int64) []*CategoryNode {
+	sorted := make([]Category, len(categories))
+	copy(sorted, categories)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+
+	roots := []*CategoryNode{}
+	nodes := map[uuid.UUID]*CategoryNode{}
+	var added []*CategoryNode
+	for _, category := range sorted {
+		node := &CategoryNode{
+			ID:           category.ID,
+			Name:         category.Name,
+			Slug:         category.Slug,
+			Description:  category.Description,
+			ImageURL:     category.ImageURL,
+			IsActive:     category.IsActive,
+			Position:     category.Position,
+			Depth:        category.Depth,
+			ProductCount: counts[category.ID],
+			Children:     []*CategoryNode{},
+		}
+		if category.ParentID == nil {
+			roots = append(roots, node)
+		} else if parent, ok := nodes[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			continue
+		}
+		nodes[category.ID] = node
+		added = append(added, node)
+	}
+
+	// Deepest first, so children are summed before their parents
+	for i := len(added) - 1; i >= 0; i-- {
+		for _, child := range added[i].Children {
+			added[i].ProductCount += child.ProductCount
+		}
+	}
+	return roots
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCategory_PlaceUnder(t *testing.T) {
+	root := Category{ID: uuid.New()}
+	root.PlaceUnder(nil)
+	child := Category{ID: uuid.New()}
+	child.PlaceUnder(&root)
+	grandchild := Category{ID: uuid.New()}
+	grandchild.PlaceUnder(&child)
+
+	if want := "/" + root.ID.String() + "/" + child.ID.String() + "/" + grandchild.ID.String() + "/"; grandchild.Path != want {
+		t.Errorf("Path = %q, want %q", grandchild.Path, want)
+	}
+	if grandchild.Depth != 2 || *grandchild.ParentID != child.ID {
+		t.Errorf("Depth, ParentID = %d, %v; want 2, %v", grandchild.Depth, *grandchild.ParentID, child.ID)
+	}
+
+	ancestors := grandchild.AncestorIDs()
+	if len(ancestors) != 2 || ancestors[0] != root.ID || ancestors[1] != child.ID {
+		t.Errorf("AncestorIDs() = %v, want root then child", ancestors)
+	}
+	if len(root.AncestorIDs()) != 0 {
+		t.Errorf("root AncestorIDs() = %v, want none", root.AncestorIDs())
+	}
+
+	if !root.Contains(&grandchild) || !child.Contains(&child) {
+		t.Error("Expected a category to contain itself and its descendants")
+	}
+	if grandchild.Contains(&root) {
+		t.Error("Expected a category not to contain its ancestors")
+	}
+}
+
+func TestCategoryTree(t *testing.T) {
+	clothing := Category{ID: uuid.New(), Name: "Clothing", Position: 1}
+	clothing.PlaceUnder(nil)
+	books := Category{ID: uuid.New(), Name: "Books", Position: 0}
+	books.PlaceUnder(nil)
+	shirts := Category{ID: uuid.New(), Name: "Shirts", Position: 1}
+	shirts.PlaceUnder(&clothing)
+	hats := Category{ID: uuid.New(), Name: "Hats", Position: 0}
+	hats.PlaceUnder(&clothing)
+	polos := Category{ID: uuid.New(), Name: "Polos"}
+	polos.PlaceUnder(&shirts)
+	orphan := Category{ID: uuid.New(), Name: "Orphan"}
+	orphan.PlaceUnder(&Category{ID: uuid.New(), Path: "/missing/"})
+
+	counts := map[uuid.UUID]int64{clothing.ID: 1, shirts.ID: 2, polos.ID: 3, hats.ID: 4, books.ID: 5}
+	tree := CategoryTree([]Category{polos, shirts, orphan, clothing, hats, books}, counts)
+
+	if len(tree) != 2 || tree[0].ID != books.ID || tree[1].ID != clothing.ID {
+		t.Fatalf("roots = %+v, want Books then Clothing", tree)
+	}
+	children := tree[1].Children
+	if len(children) != 2 || children[0].ID != hats.ID || children[1].ID != shirts.ID {
+		t.Fatalf("Clothing children = %+v, want Hats then Shirts", children)
+	}
+	if len(children[1].Children) != 1 || children[1].Children[0].ID != polos.ID {
+		t.Errorf("Shirts children = %+v, want Polos", children[1].Children)
+	}
+	if tree[1].ProductCount != 10 || children[1].ProductCount != 5 {
+		t.Errorf("ProductCount of Clothing, Shirts = %d, %d; want 10, 5", tree[1].ProductCount, children[1].ProductCount)
+	}
+}
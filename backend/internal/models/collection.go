@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CollectionType is how a collection picks its products
+type CollectionType string
+
+const (
+	// CollectionManual lists hand-picked products in the merchant's order
+	CollectionManual CollectionType = "manual"
+	// CollectionAutomatic lists every active product matching its rules,
+	// evaluated whenever it is shown
+	CollectionAutomatic CollectionType = "automatic"
+)
+
+// Collection groups products for a landing page, such as "Summer sale" or
+// "Under $50"
+type Collection struct {
+	ID             uuid.UUID       `json:"id" gorm:"type:uuid;primary_key"`
+	Title          string          `json:"title" gorm:"not null"`
+	Slug           string          `json:"slug" gorm:"not null;uniqueIndex"`
+	Description    string          `json:"description"`
+	ImageURL       string          `json:"image_url"`
+	SEOTitle       string          `json:"seo_title"`
+	SEODescription string          `json:"seo_description"`
+	Type           CollectionType  `json:"type" gorm:"not null;default:'manual'"`
+	Rules          CollectionRules `json:"rules" gorm:"serializer:json;type:text"`
+	// Sort is the default order of the products, a search sort or "manual"
+	// for the order of a manual collection
+	Sort      string    `json:"sort" gorm:"not null;default:''"`
+	IsActive  bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Products []CollectionProduct `json:"-" gorm:"foreignKey:CollectionID"`
+}
+
+// CollectionRules are the conditions of an automatic collection; products
+// must match all that are set
+type CollectionRules struct {
+	MinPrice     *int       `json:"min_price,omitempty"` // In cents
+	MaxPrice     *int       `json:"max_price,omitempty"`
+	CategoryID   *uuid.UUID `json:"category_id,omitempty"` // Includes the categories below it
+	Tags         []string   `json:"tags,omitempty"`        // Any of them
+	OnSale       bool       `json:"on_sale,omitempty"`     // Compare price above the price
+	CreatedAfter *time.Time `json:"created_after,omitempty"`
+	InStock      bool       `json:"in_stock,omitempty"`
+}
+
+// CollectionProduct is a product hand-picked into a manual collection
+type CollectionProduct struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	CollectionID uuid.UUID `json:"collection_id" gorm:"type:uuid;not null;uniqueIndex:idx_collection_products_collection_product"`
+	ProductID    uuid.UUID `json:"product_id" gorm:"type:uuid;not null;uniqueIndex:idx_collection_products_collection_product;index"`
+	Position     int       `json:"position" gorm:"not null;default:0"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	Collection Collection `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Product    Product    `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+var ErrEmptyCollectionRules = errors.New("an automatic collection needs at least one rule")
+
+func (c *Collection) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+func (p *CollectionProduct) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// Empty reports whether no rule is set, which would match every product
+func (r CollectionRules) Empty() bool {
+	return r.MinPrice == nil && r.MaxPrice == nil && r.CategoryID == nil && len(NormalizeTags(r.Tags)) == 0 &&
+		!r.OnSale && r.CreatedAfter == nil && !r.InStock
+}
+
+// Validate checks that the rules can match something
+func (r CollectionRules) Validate() error {
+	if r.MinPrice != nil && *r.MinPrice < 0 || r.MaxPrice != nil && *r.MaxPrice < 0 {
+		return errors.New("rule prices cannot be negative")
+	}
+	if r.MinPrice != nil && r.MaxPrice != nil && *r.MinPrice > *r.MaxPrice {
+		return errors.New("rule min_price is above max_price")
+	}
+	if r.Empty() {
+		return ErrEmptyCollectionRules
+	}
+	return nil
+}
+
+// SetCollectionProducts replaces the products of a manual collection, in order
+func SetCollectionProducts(tx *gorm.DB, collectionID uuid.UUID, productIDs []uuid.UUID) error {
+	if err := tx.Where("collection_id = ?", collectionID).Delete(&CollectionProduct{}).Error; err != nil {
+		return err
+	}
+	seen := map[uuid.UUID]bool{}
+	position := 0
+	for _, productID := range productIDs {
+		if seen[productID] {
+			continue
+		}
+		seen[productID] = true
+		entry := CollectionProduct{CollectionID: collectionID, ProductID: productID, Position: position}
+		if err := tx.Omit("Collection", "Product").Create(&entry).Error; err != nil {
+			return err
+		}
+		position++
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCollectionRules_Validate(t *testing.T) {
+	low, high := 1000, 5000
+	categoryID := uuid.New()
+
+	tests := []struct {
+		name    string
+		rules   CollectionRules
+		wantErr bool
+	}{
+		{"no rules", CollectionRules{}, true},
+		{"blank tags only", CollectionRules{Tags: []string{" ", ""}}, true},
+		{"price range", CollectionRules{MinPrice: &low, MaxPrice: &high}, false},
+		{"reversed price range", CollectionRules{MinPrice: &high, MaxPrice: &low}, true},
+		{"category", CollectionRules{CategoryID: &categoryID}, false},
+		{"in stock", CollectionRules{InStock: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rules.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EmailTemplate overrides the built-in template for one kind of email.
+// Keys without a row use the defaults shipped in the notifications package.
+type EmailTemplate struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	Key       string    `json:"key" gorm:"uniqueIndex;not null"` // e.g. "order_confirmation"
+	Subject   string    `json:"subject" gorm:"not null"`
+	HTMLBody  string    `json:"html_body" gorm:"type:text"`
+	TextBody  string    `json:"text_body" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type EmailStatus string
+
+const (
+	EmailStatusPending EmailStatus = "pending"
+	EmailStatusSent    EmailStatus = "sent"
+	EmailStatusFailed  EmailStatus = "failed" // Gave up after the maximum number of attempts
+)
+
+// EmailMessage is a rendered email waiting in, or done with, the delivery queue
+type EmailMessage struct {
+	ID            uuid.UUID   `json:"id" gorm:"type:uuid;primary_key"`
+	TemplateKey   string      `json:"template_key" gorm:"index"`
+	To            string      `json:"to" gorm:"not null"`
+	Subject       string      `json:"subject" gorm:"not null"`
+	HTMLBody      string      `json:"html_body" gorm:"type:text"`
+	TextBody      string      `json:"text_body" gorm:"type:text"`
+	Status        EmailStatus `json:"status" gorm:"type:varchar(20);default:'pending';not null;index:idx_email_due"`
+	Attempts      int         `json:"attempts" gorm:"default:0"`
+	NextAttemptAt time.Time   `json:"next_attempt_at" gorm:"index:idx_email_due"`
+	LastError     string      `json:"last_error,omitempty"`
+	SentAt        *time.Time  `json:"sent_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+func (t *EmailTemplate) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+func (m *EmailMessage) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	if m.Status == "" {
+		m.Status = EmailStatusPending
+	}
+	if m.NextAttemptAt.IsZero() {
+		m.NextAttemptAt = time.Now()
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "pending" // Waiting for RunAt, including retries
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusDead      JobStatus = "dead" // Gave up; kept for inspection and manual retry
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+// Job is one unit of background work in the jobs queue
+type Job struct {
+	ID          uuid.UUID       `json:"id" gorm:"type:uuid;primary_key"`
+	Kind        string          `json:"kind" gorm:"not null;index"` // Selects the registered handler, e.g. "notifications.low_stock_alert"
+	Payload     json.RawMessage `json:"payload" gorm:"type:jsonb;not null;default:'{}'"`
+	Status      JobStatus       `json:"status" gorm:"type:varchar(20);default:'pending';not null;index:idx_jobs_due"`
+	RunAt       time.Time       `json:"run_at" gorm:"index:idx_jobs_due"`
+	Attempts    int             `json:"attempts" gorm:"default:0"`
+	MaxAttempts int             `json:"max_attempts" gorm:"default:10"`
+	// UniqueKey deduplicates jobs: only one unfinished job may hold a key
+	UniqueKey   *string    `json:"unique_key,omitempty" gorm:"uniqueIndex:idx_jobs_unique_key,where:finished_at IS NULL"`
+	LockedBy    string     `json:"locked_by,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	LastError   string     `json:"last_error,omitempty" gorm:"type:text"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"` // Set once the job succeeds, dies or is cancelled
+	CreatedAt   time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// JobSchedule tracks when a recurring job registered in code fires next.
+// Workers advance NextRunAt under a row lock so each run is enqueued once.
+type JobSchedule struct {
+	Name      string     `json:"name" gorm:"primary_key"`
+	Spec      string     `json:"spec" gorm:"not null"` // Cron expression, e.g. "0 3 * * *"
+	Kind      string     `json:"kind" gorm:"not null"`
+	NextRunAt time.Time  `json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (j *Job) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	if j.Status == "" {
+		j.Status = JobStatusPending
+	}
+	if j.RunAt.IsZero() {
+		j.RunAt = time.Now()
+	}
+	if len(j.Payload) == 0 {
+		j.Payload = json.RawMessage("{}")
+	}
+	return nil
+}
+
+// IsFinished reports whether the job will not run again without a manual retry
+func (j *Job) IsFinished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusDead || j.Status == JobStatusCancelled
+}
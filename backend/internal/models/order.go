@@ -1,7 +1,10 @@
 package models
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -68,6 +71,15 @@ type Order struct {
 	Status        OrderStatus   `json:"status" gorm:"type:varchar(20);default:'pending'"`
 	PaymentStatus PaymentStatus `json:"payment_status" gorm:"type:varchar(20);default:'pending'"`
 
+	// Shipment tracking, set when the order ships
+	TrackingCarrier string     `json:"tracking_carrier"`
+	TrackingNumber  string     `json:"tracking_number"`
+	ShippedAt       *time.Time `json:"shipped_at,omitempty"`
+
+	// AccessToken is the unguessable secret behind signed order status links;
+	// rotating it revokes every link issued for the order
+	AccessToken string `json:"-" gorm:"uniqueIndex"`
+
 	// Metadata
 	Notes     string    `json:"notes"`
 	CreatedAt time.Time `json:"created_at"`
@@ -110,6 +122,14 @@ func (o *Order) BeforeCreate(tx *gorm.DB) error {
 	if o.OrderNumber == "" {
 		o.OrderNumber = generateOrderNumber()
 	}
+
+	if o.AccessToken == "" {
+		token, err := generateAccessToken()
+		if err != nil {
+			return err
+		}
+		o.AccessToken = token
+	}
 	
 	return nil
 }
@@ -127,4 +147,147 @@ func generateOrderNumber() string {
 	// Simple order number generation - could be made more sophisticated
 	timestamp := time.Now().Unix()
 	return fmt.Sprintf("ORD-%d", timestamp)
-}
\ No newline at end of file
+}
+func generateAccessToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// EnsureAccessToken gives orders created before access tokens existed a token
+func EnsureAccessToken(tx *gorm.DB, o *Order) error {
+	if o.AccessToken != "" {
+		return nil
+	}
+	token, err := generateAccessToken()
+	if err != nil {
+		return err
+	}
+	result := tx.Model(o).Where("access_token IS NULL OR access_token = ''").Update("access_token", token)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Another request got there first
+		return tx.Model(&Order{}).Select("access_token").Where("id = ?", o.ID).Scan(&o.AccessToken).Error
+	}
+	o.AccessToken = token
+	return nil
+}
+
+// OrderShipment describes how an order was shipped
+type OrderShipment struct {
+	Carrier        string     `json:"carrier"`
+	TrackingNumber string     `json:"tracking_number"`
+	ShippedAt      *time.Time `json:"shipped_at,omitempty"`
+}
+
+// OrderItemView is an order line without internal IDs
+type OrderItemView struct {
+	ProductName  string `json:"product_name"`
+	ProductImage string `json:"product_image"`
+	UnitPrice    int    `json:"unit_price"`
+	Quantity     int    `json:"quantity"`
+	Total        int    `json:"total"`
+}
+
+// OrderStatusView is what an anonymous holder of an order link or lookup
+// details may see. Redacted views mask personal data and omit the street address.
+type OrderStatusView struct {
+	OrderNumber     string          `json:"order_number"`
+	Status          OrderStatus     `json:"status"`
+	PaymentStatus   PaymentStatus   `json:"payment_status"`
+	CustomerName    string          `json:"customer_name"`
+	CustomerEmail   string          `json:"customer_email"`
+	ShippingAddress string          `json:"shipping_address,omitempty"`
+	ShippingCity    string          `json:"shipping_city"`
+	ShippingCountry string          `json:"shipping_country"`
+	Items           []OrderItemView `json:"items"`
+	Subtotal        int             `json:"subtotal"`
+	TaxAmount       int             `json:"tax_amount"`
+	ShippingCost    int             `json:"shipping_cost"`
+	Total           int             `json:"total"`
+	Shipments       []OrderShipment `json:"shipments"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// StatusView returns the order as shown behind a signed status link
+func (o *Order) StatusView() OrderStatusView {
+	view := OrderStatusView{
+		OrderNumber:     o.OrderNumber,
+		Status:          o.Status,
+		PaymentStatus:   o.PaymentStatus,
+		CustomerName:    o.CustomerName,
+		CustomerEmail:   o.CustomerEmail,
+		ShippingAddress: strings.TrimSpace(o.ShippingAddress + " " + o.ShippingAddress2),
+		ShippingCity:    o.ShippingCity,
+		ShippingCountry: o.ShippingCountry,
+		Items:           make([]OrderItemView, len(o.Items)),
+		Subtotal:        o.Subtotal,
+		TaxAmount:       o.TaxAmount,
+		ShippingCost:    o.ShippingCost,
+		Total:           o.Total,
+		Shipments:       []OrderShipment{},
+		CreatedAt:       o.CreatedAt,
+	}
+	for i, item := range o.Items {
+		view.Items[i] = OrderItemView{
+			ProductName:  item.ProductName,
+			ProductImage: item.ProductImage,
+			UnitPrice:    item.UnitPrice,
+			Quantity:     item.Quantity,
+			Total:        item.Total,
+		}
+	}
+	if o.ShippedAt != nil || o.TrackingNumber != "" {
+		view.Shipments = append(view.Shipments, OrderShipment{
+			Carrier:        o.TrackingCarrier,
+			TrackingNumber: o.TrackingNumber,
+			ShippedAt:      o.ShippedAt,
+		})
+	}
+	return view
+}
+
+// RedactedView returns the status view with personal data masked, for
+// lookups that only prove knowledge of the order number and email
+func (o *Order) RedactedView() OrderStatusView {
+	view := o.StatusView()
+	view.CustomerName = maskName(o.CustomerName)
+	view.CustomerEmail = maskEmail(o.CustomerEmail)
+	view.ShippingAddress = ""
+	return view
+}
+
+// maskName keeps the first name and the initial of the last: "Jane D."
+func maskName(name string) string {
+	parts := strings.Fields(name)
+	if len(parts) == 0 {
+		return ""
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	last := []rune(parts[len(parts)-1])
+	return parts[0] + " " + string(last[0]) + "."
+}
+
+// maskEmail keeps the first character of the local part: "j***@example.com"
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return "***"
+	}
+	return string([]rune(local)[0]) + "***@" + domain
+}
+
+// LinkGuestOrders attaches guest orders placed with the user's email to the
+// user. Only call it once the user has verified they own the address.
+func LinkGuestOrders(tx *gorm.DB, user *User) (int64, error) {
+	result := tx.Model(&Order{}).
+		Where("customer_id IS NULL AND LOWER(customer_email) = LOWER(?)", user.Email).
+		Update("customer_id", user.ID)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrder_RedactedView(t *testing.T) {
+	shippedAt := time.Now()
+	order := Order{
+		OrderNumber:     "ORD-1",
+		CustomerName:    "Jane Doe",
+		CustomerEmail:   "jane@example.com",
+		ShippingAddress: "1 Main St",
+		ShippingCity:    "Springfield",
+		TrackingNumber:  "1Z999",
+		ShippedAt:       &shippedAt,
+		AccessToken:     "secret",
+		Items:           []OrderItem{{ProductName: "Mug", Quantity: 2, UnitPrice: 500, Total: 1000}},
+	}
+
+	view := order.RedactedView()
+	if view.CustomerName != "Jane D." {
+		t.Errorf("Expected masked name, got %q", view.CustomerName)
+	}
+	if view.CustomerEmail != "j***@example.com" {
+		t.Errorf("Expected masked email, got %q", view.CustomerEmail)
+	}
+	if view.ShippingAddress != "" {
+		t.Errorf("Expected street address to be omitted, got %q", view.ShippingAddress)
+	}
+	if len(view.Items) != 1 || view.Items[0].Total != 1000 {
+		t.Errorf("Unexpected items %+v", view.Items)
+	}
+	if len(view.Shipments) != 1 || view.Shipments[0].TrackingNumber != "1Z999" {
+		t.Errorf("Unexpected shipments %+v", view.Shipments)
+	}
+
+	full := order.StatusView()
+	if full.CustomerEmail != "jane@example.com" || full.ShippingAddress != "1 Main St" {
+		t.Errorf("Status view should not be redacted: %+v", full)
+	}
+}
+
+func TestMaskEmail(t *testing.T) {
+	for input, want := range map[string]string{
+		"jane@example.com": "j***@example.com",
+		"not-an-email":     "***",
+		"@example.com":     "***",
+	} {
+		if got := maskEmail(input); got != want {
+			t.Errorf("maskEmail(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
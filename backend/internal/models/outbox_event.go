@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OutboxEvent is a domain event stored in the same transaction as the change
+// it describes. The events dispatcher hands it to subscribers after commit.
+type OutboxEvent struct {
+	ID            uuid.UUID       `json:"id" gorm:"type:uuid;primary_key"`
+	Type          string          `json:"type" gorm:"not null;index"` // e.g. "order.placed"
+	AggregateType string          `json:"aggregate_type" gorm:"not null;index:idx_outbox_aggregate"`
+	AggregateID   string          `json:"aggregate_id" gorm:"not null;index:idx_outbox_aggregate"`
+	Payload       json.RawMessage `json:"payload" gorm:"type:jsonb;not null"`
+	Attempts      int             `json:"attempts" gorm:"default:0"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" gorm:"index:idx_outbox_pending"`
+	LastError     string          `json:"last_error,omitempty" gorm:"type:text"`
+	DispatchedAt  *time.Time      `json:"dispatched_at,omitempty" gorm:"index:idx_outbox_pending"`
+	FailedAt      *time.Time      `json:"failed_at,omitempty"` // Gave up; retry from the admin API
+	CreatedAt     time.Time       `json:"created_at" gorm:"index"`
+}
+
+func (e *OutboxEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.NextAttemptAt.IsZero() {
+		e.NextAttemptAt = time.Now()
+	}
+	return nil
+}
@@ -22,6 +22,10 @@ type Product struct {
 	Weight      *float64   `json:"weight,omitempty"` // Weight in grams
 	IsActive    bool       `json:"is_active" gorm:"default:true"`
 	IsFeatured  bool       `json:"is_featured" gorm:"default:false"`
+	// The approved reviews, kept by RefreshProductRating; the average is
+	// rounded to one decimal and 0 without reviews
+	RatingAverage float64  `json:"rating_average" gorm:"not null;default:0"`
+	RatingCount   int      `json:"rating_count" gorm:"not null;default:0"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 
@@ -29,6 +33,12 @@ type Product struct {
 	Images   []*Media         `json:"images,omitempty" gorm:"foreignKey:ProductID"`
 	Options  []ProductOption  `json:"options,omitempty" gorm:"foreignKey:ProductID"`
 	Variants []ProductVariant `json:"variants,omitempty" gorm:"foreignKey:ProductID"`
+	Tags     []ProductTag     `json:"tags,omitempty" gorm:"foreignKey:ProductID"`
+
+	// Breadcrumbs lead from the root category to Category, see AttachBreadcrumbs
+	Breadcrumbs []Breadcrumb `json:"breadcrumbs,omitempty" gorm:"-"`
+	// Attributes are the product's specifications, see AttachAttributes
+	Attributes []ProductAttribute `json:"attributes,omitempty" gorm:"-"`
 }
 
 type ProductResponse struct {
@@ -47,12 +57,17 @@ type ProductResponse struct {
 	Weight       *float64   `json:"weight,omitempty"`
 	IsActive     bool       `json:"is_active"`
 	IsFeatured   bool       `json:"is_featured"`
+	RatingAverage float64   `json:"rating_average"`
+	RatingCount  int        `json:"rating_count"`
 	CreatedAt    time.Time              `json:"created_at"`
 	UpdatedAt    time.Time              `json:"updated_at"`
 	Category     *Category              `json:"category,omitempty"`
 	Images       []*Media               `json:"images,omitempty"`
 	Options      []ProductOptionResponse  `json:"options,omitempty"`
 	Variants     []ProductVariantResponse `json:"variants,omitempty"`
+	Tags         []string                 `json:"tags,omitempty"`
+	Breadcrumbs  []Breadcrumb             `json:"breadcrumbs,omitempty"`
+	Attributes   []ProductAttribute       `json:"attributes,omitempty"`
 }
 
 func (p *Product) BeforeCreate(tx *gorm.DB) error {
@@ -71,21 +86,25 @@ func (p *Product) ToResponse() ProductResponse {
 		Description: p.Description,
 		SKU:         p.SKU,
 		Price:       p.Price,
-		PriceDisplay: formatPrice(p.Price),
+		PriceDisplay: FormatPrice(p.Price),
 		Stock:       p.Stock,
 		MinStock:    p.MinStock,
 		Weight:      p.Weight,
 		IsActive:    p.IsActive,
 		IsFeatured:  p.IsFeatured,
+		RatingAverage: p.RatingAverage,
+		RatingCount: p.RatingCount,
 		CreatedAt:   p.CreatedAt,
 		UpdatedAt:   p.UpdatedAt,
 		Category:    p.Category,
 		Images:      p.Images,
+		Breadcrumbs: p.Breadcrumbs,
+		Attributes:  p.Attributes,
 	}
 
 	if p.ComparePrice != nil {
 		response.ComparePrice = p.ComparePrice
-		comparePriceDisplay := formatPrice(*p.ComparePrice)
+		comparePriceDisplay := FormatPrice(*p.ComparePrice)
 		response.ComparePriceDisplay = &comparePriceDisplay
 	}
 
@@ -105,10 +124,23 @@ func (p *Product) ToResponse() ProductResponse {
 		}
 	}
 
+	if len(p.Tags) > 0 {
+		response.Tags = TagNames(p.Tags)
+	}
+
 	return response
 }
 
-func formatPrice(priceInCents int) string {
+// FormatPrice renders a price in cents for display, e.g. "$12.50"
+func FormatPrice(priceInCents int) string {
 	dollars := float64(priceInCents) / 100
 	return "$" + fmt.Sprintf("%.2f", dollars)
-}
\ No newline at end of file
+}
+// AttachDetails sets the breadcrumbs and attributes of products whose
+// category is preloaded
+func AttachDetails(tx *gorm.DB, products ...*Product) error {
+	if err := AttachBreadcrumbs(tx, products...); err != nil {
+		return err
+	}
+	return AttachAttributes(tx, products...)
+}
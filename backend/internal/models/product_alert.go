@@ -0,0 +1,98 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductAlertKind is what a shopper wants to hear about
+type ProductAlertKind string
+
+const (
+	AlertBackInStock ProductAlertKind = "back_in_stock"
+	AlertPriceDrop   ProductAlertKind = "price_drop"
+)
+
+// ProductAlert is an email subscribed to hear when a product, or one
+// variant of it, is back in stock or gets cheaper. Nothing is sent until
+// the shopper follows the confirmation link, so nobody can sign someone
+// else up. It is sent once; the alert is kept with NotifiedAt set so demand
+// can still be counted.
+type ProductAlert struct {
+	ID        uuid.UUID        `json:"id" gorm:"type:uuid;primary_key"`
+	Kind      ProductAlertKind `json:"kind" gorm:"type:varchar(20);not null"`
+	Email     string           `json:"email" gorm:"not null;index"`
+	ProductID uuid.UUID        `json:"product_id" gorm:"type:uuid;not null;index"`
+	VariantID *uuid.UUID       `json:"variant_id,omitempty" gorm:"type:uuid;index"`
+	// Price is the price when subscribing; a price drop alert is sent once
+	// the price is below it
+	Price int `json:"price"`
+	// Token identifies the alert in its confirmation and unsubscribe links
+	Token       string     `json:"-" gorm:"not null;uniqueIndex"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	NotifiedAt  *time.Time `json:"notified_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Product *Product        `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Variant *ProductVariant `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (a *ProductAlert) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	a.Email = strings.ToLower(strings.TrimSpace(a.Email))
+	if a.Token == "" {
+		token, err := generateAccessToken()
+		if err != nil {
+			return err
+		}
+		a.Token = token
+	}
+	return nil
+}
+
+// IsValid reports whether k is a known alert kind
+func (k ProductAlertKind) IsValid() bool {
+	return k == AlertBackInStock || k == AlertPriceDrop
+}
+
+// CurrentPrice is the price of variant when it has its own, else of
+// product, in cents
+func CurrentPrice(product *Product, variant *ProductVariant) int {
+	if variant != nil && variant.Price != nil {
+		return *variant.Price
+	}
+	return product.Price
+}
+
+// InStock reports whether product, or variant when set, is for sale and has
+// stock
+func InStock(product *Product, variant *ProductVariant) bool {
+	if !product.IsActive {
+		return false
+	}
+	if variant != nil {
+		return variant.IsActive && variant.Stock > 0
+	}
+	return product.Stock > 0
+}
+
+// Due reports whether the alert should be sent now for product and its
+// variant, which is nil for an alert on the whole product
+func (a *ProductAlert) Due(product *Product, variant *ProductVariant) bool {
+	if a.NotifiedAt != nil {
+		return false
+	}
+	switch a.Kind {
+	case AlertBackInStock:
+		return InStock(product, variant)
+	case AlertPriceDrop:
+		return product.IsActive && (variant == nil || variant.IsActive) && CurrentPrice(product, variant) < a.Price
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProductAlert_Due(t *testing.T) {
+	price := 800
+	now := time.Now()
+	cases := []struct {
+		name    string
+		alert   ProductAlert
+		product Product
+		variant *ProductVariant
+		want    bool
+	}{
+		{"restocked", ProductAlert{Kind: AlertBackInStock}, Product{IsActive: true, Stock: 3}, nil, true},
+		{"still out of stock", ProductAlert{Kind: AlertBackInStock}, Product{IsActive: true}, nil, false},
+		{"inactive", ProductAlert{Kind: AlertBackInStock}, Product{Stock: 3}, nil, false},
+		{"already sent", ProductAlert{Kind: AlertBackInStock, NotifiedAt: &now}, Product{IsActive: true, Stock: 3}, nil, false},
+		{"variant restocked", ProductAlert{Kind: AlertBackInStock}, Product{IsActive: true}, &ProductVariant{IsActive: true, Stock: 1}, true},
+		{"variant out of stock", ProductAlert{Kind: AlertBackInStock}, Product{IsActive: true, Stock: 9}, &ProductVariant{IsActive: true}, false},
+		{"cheaper", ProductAlert{Kind: AlertPriceDrop, Price: 1000}, Product{IsActive: true, Price: 900}, nil, true},
+		{"same price", ProductAlert{Kind: AlertPriceDrop, Price: 1000}, Product{IsActive: true, Price: 1000}, nil, false},
+		{"variant cheaper", ProductAlert{Kind: AlertPriceDrop, Price: 1000}, Product{IsActive: true, Price: 1000}, &ProductVariant{IsActive: true, Price: &price}, true},
+		{"variant follows product", ProductAlert{Kind: AlertPriceDrop, Price: 1000}, Product{IsActive: true, Price: 900}, &ProductVariant{IsActive: true}, true},
+	}
+	for _, c := range cases {
+		if got := c.alert.Due(&c.product, c.variant); got != c.want {
+			t.Errorf("%s: Due() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ProductImportStatus string
+
+const (
+	ProductImportPending   ProductImportStatus = "pending"
+	ProductImportRunning   ProductImportStatus = "running"
+	ProductImportCompleted ProductImportStatus = "completed"
+	ProductImportFailed    ProductImportStatus = "failed" // The file could not be processed at all
+)
+
+// ImportRowError is a problem with one row of an imported file. Row counts
+// the header as row 1, like a spreadsheet.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	SKU     string `json:"sku,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ProductImport tracks a catalog file being imported in the background
+type ProductImport struct {
+	ID       uuid.UUID           `json:"id" gorm:"type:uuid;primary_key"`
+	Filename string              `json:"filename"`
+	Format   string              `json:"format" gorm:"not null;default:'easycart'"`
+	Content  string              `json:"-" gorm:"type:text;not null"` // The uploaded file
+	Status   ProductImportStatus `json:"status" gorm:"type:varchar(20);default:'pending';not null;index"`
+
+	// TotalProducts is known once the file is parsed; Processed counts the
+	// products handled so far and is where a resumed import continues
+	TotalProducts int              `json:"total_products"`
+	Processed     int              `json:"processed"`
+	Created       int              `json:"created"`
+	Updated       int              `json:"updated"`
+	Failed        int              `json:"failed"`
+	Errors        []ImportRowError `json:"errors" gorm:"serializer:json;type:text"`
+	Error         string           `json:"error,omitempty" gorm:"type:text"`
+
+	CreatedByID *uuid.UUID `json:"created_by_id,omitempty" gorm:"type:uuid"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (i *ProductImport) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	if i.Status == "" {
+		i.Status = ProductImportPending
+	}
+	return nil
+}
+
+// Progress is the share of products processed, from 0 to 1
+func (i *ProductImport) Progress() float64 {
+	if i.TotalProducts == 0 {
+		if i.Status == ProductImportCompleted {
+			return 1
+		}
+		return 0
+	}
+	return float64(i.Processed) / float64(i.TotalProducts)
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxTagLength caps a single tag
+const maxTagLength = 50
+
+// ProductTag is a free-form label on a product, such as "summer" or "organic"
+type ProductTag struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	ProductID uuid.UUID `json:"product_id" gorm:"type:uuid;not null;uniqueIndex:idx_product_tags_product_tag"`
+	Tag       string    `json:"tag" gorm:"not null;uniqueIndex:idx_product_tags_product_tag;index"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Product Product `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (t *ProductTag) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// MarshalJSON writes a tag as its text, so products list tags as strings
+func (t ProductTag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Tag)
+}
+
+// NormalizeTags trims tags and drops blanks and case-insensitive duplicates,
+// keeping the first spelling
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(tag), " ")
+		if runes := []rune(tag); len(runes) > maxTagLength {
+			tag = string(runes[:maxTagLength])
+		}
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// TagNames lists the text of tags
+func TagNames(tags []ProductTag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Tag
+	}
+	return names
+}
+
+// SetProductTags replaces the tags of a product, reporting whether they changed
+func SetProductTags(tx *gorm.DB, productID uuid.UUID, tags []string) (bool, error) {
+	tags = NormalizeTags(tags)
+
+	var current []ProductTag
+	if err := tx.Where("product_id = ?", productID).Order("tag ASC").Find(&current).Error; err != nil {
+		return false, err
+	}
+	currentSet := map[string]bool{}
+	for _, tag := range current {
+		currentSet[tag.Tag] = true
+	}
+	if len(current) == len(tags) {
+		same := true
+		for _, tag := range tags {
+			if !currentSet[tag] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return false, nil
+		}
+	}
+
+	if err := tx.Where("product_id = ?", productID).Delete(&ProductTag{}).Error; err != nil {
+		return false, err
+	}
+	for _, tag := range tags {
+		if err := tx.Omit("Product").Create(&ProductTag{ProductID: productID, Tag: tag}).Error; err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
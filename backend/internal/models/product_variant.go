@@ -171,12 +171,12 @@ func (pv *ProductVariant) ToResponse() ProductVariantResponse {
 
 	// Format prices if they exist
 	if pv.Price != nil {
-		priceDisplay := formatPrice(*pv.Price)
+		priceDisplay := FormatPrice(*pv.Price)
 		response.PriceDisplay = &priceDisplay
 	}
 	
 	if pv.ComparePrice != nil {
-		comparePriceDisplay := formatPrice(*pv.ComparePrice)
+		comparePriceDisplay := FormatPrice(*pv.ComparePrice)
 		response.ComparePriceDisplay = &comparePriceDisplay
 	}
 
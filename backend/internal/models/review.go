@@ -0,0 +1,259 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReviewStatus is where a review is in moderation
+type ReviewStatus string
+
+const (
+	ReviewPending  ReviewStatus = "pending" // Waiting in the moderation queue
+	ReviewApproved ReviewStatus = "approved"
+	ReviewRejected ReviewStatus = "rejected"
+)
+
+const (
+	// MaxReviewImages caps the images of one review
+	MaxReviewImages = 5
+	// maxReviewLinks is how many links a review may hold before it is
+	// taken for spam
+	maxReviewLinks = 1
+)
+
+// Review is a customer's rating of a product. Only approved reviews are
+// shown and counted in the product's rating.
+type Review struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	ProductID uuid.UUID `json:"product_id" gorm:"type:uuid;not null;uniqueIndex:idx_reviews_product_user;index:idx_reviews_product_status"`
+	UserID    uuid.UUID `json:"-" gorm:"type:uuid;not null;uniqueIndex:idx_reviews_product_user"` // One review per customer and product
+	// AuthorName is shown with the review, e.g. "Jane D."
+	AuthorName string `json:"author_name" gorm:"not null"`
+	Rating     int    `json:"rating" gorm:"not null"` // 1 to 5
+	Title      string `json:"title"`
+	Body       string `json:"body" gorm:"type:text"`
+
+	// VerifiedPurchase is set when the customer has a paid or shipped order
+	// with the product, see IsVerifiedPurchase
+	VerifiedPurchase bool `json:"verified_purchase" gorm:"default:false"`
+
+	Status          ReviewStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending';index:idx_reviews_product_status"`
+	RejectionReason string       `json:"rejection_reason,omitempty"`
+	ModeratedAt     *time.Time   `json:"moderated_at,omitempty"`
+	ModeratedBy     *uuid.UUID   `json:"moderated_by,omitempty" gorm:"type:uuid"`
+
+	// Response is the shop's public answer to the review
+	Response    string     `json:"response,omitempty" gorm:"type:text"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+
+	HelpfulCount int `json:"helpful_count" gorm:"not null;default:0"`
+
+	// Fingerprint identifies the text, so the same text posted again is
+	// caught as a duplicate
+	Fingerprint string    `json:"-" gorm:"index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Product *Product      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	User    *User         `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Images  []ReviewImage `json:"images,omitempty" gorm:"foreignKey:ReviewID"`
+}
+
+// ReviewImage is a photo a customer uploaded for a review. It is attached
+// when the review is written; unattached images belong to the uploader.
+type ReviewImage struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	ReviewID  *uuid.UUID `json:"-" gorm:"type:uuid;index"`
+	UserID    uuid.UUID  `json:"-" gorm:"type:uuid;not null;index"`
+	URL       string     `json:"url" gorm:"not null"`
+	MimeType  string     `json:"mime_type"`
+	Size      int64      `json:"size"`
+	SortOrder int        `json:"sort_order" gorm:"default:0"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	Review *Review `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	User   *User   `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// ReviewVote is a customer finding a review helpful
+type ReviewVote struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	ReviewID  uuid.UUID `json:"review_id" gorm:"type:uuid;not null;uniqueIndex:idx_review_votes_review_user"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_review_votes_review_user"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Review *Review `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	User   *User   `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+var (
+	ErrReviewSpam      = errors.New("the review looks like spam")
+	ErrReviewDuplicate = errors.New("the same review was already posted")
+
+	reviewLinkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+)
+
+func (r *Review) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+func (i *ReviewImage) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+func (v *ReviewVote) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == uuid.Nil {
+		v.ID = uuid.New()
+	}
+	return nil
+}
+
+// ReviewAuthorName is how a customer is shown on reviews: their first name
+// and last initial
+func ReviewAuthorName(u *User) string {
+	name := strings.TrimSpace(u.FirstName)
+	if last := []rune(strings.TrimSpace(u.LastName)); len(last) > 0 {
+		name += " " + string(unicode.ToUpper(last[0])) + "."
+	}
+	if name == "" {
+		return "Customer"
+	}
+	return name
+}
+
+// CheckReviewContent turns away text that looks like spam: links, or text
+// that is mostly capitals or one repeated character
+func CheckReviewContent(title, body string) error {
+	text := title + " " + body
+	if len(reviewLinkPattern.FindAllString(text, -1)) > maxReviewLinks {
+		return ErrReviewSpam
+	}
+
+	letters, upper, run, longest := 0, 0, 0, 0
+	var previous rune
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+		if r == previous && !unicode.IsSpace(r) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+		previous = r
+	}
+	if letters >= 20 && upper*10 > letters*7 {
+		return ErrReviewSpam
+	}
+	if longest > 10 {
+		return ErrReviewSpam
+	}
+	return nil
+}
+
+// ReviewFingerprint identifies review text regardless of case, spacing and
+// punctuation
+func ReviewFingerprint(title, body string) string {
+	words := strings.FieldsFunc(strings.ToLower(title+" "+body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sum := sha256.Sum256([]byte(strings.Join(words, " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsVerifiedPurchase reports whether the customer bought the product in an
+// order that was paid or has shipped and was not cancelled. Guest orders
+// placed with the customer's email count once the email is verified.
+func IsVerifiedPurchase(tx *gorm.DB, user *User, productID uuid.UUID) (bool, error) {
+	customer := tx.Where("orders.customer_id = ?", user.ID)
+	if user.EmailVerifiedAt != nil {
+		customer = customer.Or("lower(orders.customer_email) = lower(?)", user.Email)
+	}
+
+	var count int64
+	err := tx.Model(&OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.product_id = ?", productID).
+		Where(customer).
+		Where("orders.status <> ? AND orders.payment_status <> ?", OrderStatusCancelled, PaymentStatusRefunded).
+		Where("orders.payment_status = ? OR orders.status IN ?", PaymentStatusPaid, []OrderStatus{OrderStatusShipped, OrderStatusDelivered}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// PublicReview is a review as shoppers see it
+type PublicReview struct {
+	ID               uuid.UUID     `json:"id"`
+	ProductID        uuid.UUID     `json:"product_id"`
+	AuthorName       string        `json:"author_name"`
+	Rating           int           `json:"rating"`
+	Title            string        `json:"title"`
+	Body             string        `json:"body"`
+	VerifiedPurchase bool          `json:"verified_purchase"`
+	Response         string        `json:"response,omitempty"`
+	RespondedAt      *time.Time    `json:"responded_at,omitempty"`
+	HelpfulCount     int           `json:"helpful_count"`
+	Images           []ReviewImage `json:"images"`
+	CreatedAt        time.Time     `json:"created_at"`
+}
+
+func (r *Review) ToPublic() PublicReview {
+	images := r.Images
+	if images == nil {
+		images = []ReviewImage{}
+	}
+	return PublicReview{
+		ID:               r.ID,
+		ProductID:        r.ProductID,
+		AuthorName:       r.AuthorName,
+		Rating:           r.Rating,
+		Title:            r.Title,
+		Body:             r.Body,
+		VerifiedPurchase: r.VerifiedPurchase,
+		Response:         r.Response,
+		RespondedAt:      r.RespondedAt,
+		HelpfulCount:     r.HelpfulCount,
+		Images:           images,
+		CreatedAt:        r.CreatedAt,
+	}
+}
+
+// RefreshProductRating recounts the approved reviews of a product into its
+// rating columns
+func RefreshProductRating(tx *gorm.DB, productID uuid.UUID) error {
+	var summary struct {
+		Average float64
+		Count   int
+	}
+	err := tx.Model(&Review{}).Select("COALESCE(ROUND(AVG(rating)::numeric, 1), 0) AS average, COUNT(*) AS count").
+		Where("product_id = ? AND status = ?", productID, ReviewApproved).
+		Scan(&summary).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&Product{}).Where("id = ?", productID).UpdateColumns(map[string]interface{}{
+		"rating_average": summary.Average,
+		"rating_count":   summary.Count,
+	}).Error
+}
@@ -0,0 +1,53 @@
+package models
+
+import "testing"
+
+func TestCheckReviewContent(t *testing.T) {
+	valid := []struct{ title, body string }{
+		{"Great laptop", "Fast, quiet and the battery lasts all day. See www.example.com for the specs."},
+		{"OK", "Works."},
+		{"Loved it", "So good!!!"},
+	}
+	for _, c := range valid {
+		if err := CheckReviewContent(c.title, c.body); err != nil {
+			t.Errorf("CheckReviewContent(%q, %q) = %v, want nil", c.title, c.body, err)
+		}
+	}
+
+	spam := []struct{ title, body string }{
+		{"Cheap", "Buy at https://a.example and http://b.example"},
+		{"BEST PRODUCT EVER", "I LOVE IT SO MUCH BUY IT NOW"},
+		{"Wow", "Amazing!!!!!!!!!!!!"},
+	}
+	for _, c := range spam {
+		if err := CheckReviewContent(c.title, c.body); err != ErrReviewSpam {
+			t.Errorf("CheckReviewContent(%q, %q) = %v, want ErrReviewSpam", c.title, c.body, err)
+		}
+	}
+}
+
+func TestReviewFingerprint(t *testing.T) {
+	a := ReviewFingerprint("Great laptop", "Fast and quiet.")
+	if b := ReviewFingerprint("great  LAPTOP!", "fast and quiet"); a != b {
+		t.Error("fingerprints differ for the same words")
+	}
+	if b := ReviewFingerprint("Great laptop", "Fast but loud."); a == b {
+		t.Error("fingerprints match for different words")
+	}
+}
+
+func TestReviewAuthorName(t *testing.T) {
+	cases := []struct {
+		user User
+		want string
+	}{
+		{User{FirstName: "Jane", LastName: "doe"}, "Jane D."},
+		{User{FirstName: " Jane "}, "Jane"},
+		{User{}, "Customer"},
+	}
+	for _, c := range cases {
+		if got := ReviewAuthorName(&c.user); got != c.want {
+			t.Errorf("ReviewAuthorName(%q, %q) = %q, want %q", c.user.FirstName, c.user.LastName, got, c.want)
+		}
+	}
+}
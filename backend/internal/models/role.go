@@ -0,0 +1,118 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Permission is a named capability checked by the admin routes (e.g. "products.write")
+type Permission string
+
+const (
+	PermissionProductsRead     Permission = "products.read"
+	PermissionProductsWrite    Permission = "products.write"
+	PermissionCategoriesRead   Permission = "categories.read"
+	PermissionCategoriesWrite  Permission = "categories.write"
+	PermissionOrdersRead       Permission = "orders.read"
+	PermissionOrdersWrite      Permission = "orders.write"
+	PermissionOrdersRefund     Permission = "orders.refund"
+	PermissionCustomersRead    Permission = "customers.read"
+	PermissionUsersRead        Permission = "users.read"
+	PermissionUsersWrite       Permission = "users.write"
+	PermissionUsersImpersonate Permission = "users.impersonate"
+	PermissionRolesRead        Permission = "roles.read"
+	PermissionRolesWrite       Permission = "roles.write"
+	PermissionSettingsRead     Permission = "settings.read"
+	PermissionSettingsWrite    Permission = "settings.write"
+	PermissionMediaWrite       Permission = "media.write"
+	PermissionAPIKeysManage    Permission = "api_keys.manage"
+	PermissionAuditRead        Permission = "audit.read"
+	PermissionJobsManage       Permission = "jobs.manage"
+	PermissionWebhooksManage   Permission = "webhooks.manage"
+	PermissionReportsRead      Permission = "reports.read"
+	PermissionReviewsModerate  Permission = "reviews.moderate"
+)
+
+// AllPermissions lists every permission known to the system, in display order
+var AllPermissions = []Permission{
+	PermissionProductsRead,
+	PermissionProductsWrite,
+	PermissionCategoriesRead,
+	PermissionCategoriesWrite,
+	PermissionOrdersRead,
+	PermissionOrdersWrite,
+	PermissionOrdersRefund,
+	PermissionCustomersRead,
+	PermissionUsersRead,
+	PermissionUsersWrite,
+	PermissionUsersImpersonate,
+	PermissionRolesRead,
+	PermissionRolesWrite,
+	PermissionSettingsRead,
+	PermissionSettingsWrite,
+	PermissionMediaWrite,
+	PermissionAPIKeysManage,
+	PermissionAuditRead,
+	PermissionJobsManage,
+	PermissionWebhooksManage,
+	PermissionReportsRead,
+	PermissionReviewsModerate,
+}
+
+// DefaultManagerPermissions is used for managers without a custom role.
+// It matches what managers could do before permissions existed: everything
+// except managing staff, roles and settings. Reports only aggregate orders
+// they can already read.
+var DefaultManagerPermissions = []Permission{
+	PermissionProductsRead,
+	PermissionProductsWrite,
+	PermissionCategoriesRead,
+	PermissionCategoriesWrite,
+	PermissionOrdersRead,
+	PermissionOrdersWrite,
+	PermissionOrdersRefund,
+	PermissionCustomersRead,
+	PermissionSettingsRead,
+	PermissionMediaWrite,
+	PermissionReportsRead,
+	PermissionReviewsModerate,
+}
+
+// Role bundles a set of permissions that can be assigned to staff users
+type Role struct {
+	ID          uuid.UUID    `json:"id" gorm:"type:uuid;primary_key"`
+	Name        string       `json:"name" gorm:"uniqueIndex;not null"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions" gorm:"serializer:json;type:text;not null"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+func (r *Role) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsValidPermission reports whether p is a known permission
+func IsValidPermission(p Permission) bool {
+	for _, known := range AllPermissions {
+		if known == p {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether p is contained in perms
+func HasPermission(perms []Permission, p Permission) bool {
+	for _, granted := range perms {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
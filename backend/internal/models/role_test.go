@@ -0,0 +1,61 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestUser_EffectivePermissions(t *testing.T) {
+	t.Run("admin holds every permission", func(t *testing.T) {
+		user := User{Role: UserRoleAdmin, IsActive: true}
+		perms, err := user.EffectivePermissions(nil)
+		if err != nil {
+			t.Fatalf("EffectivePermissions() error = %v", err)
+		}
+		if len(perms) != len(AllPermissions) {
+			t.Errorf("Expected %d permissions, got %d", len(AllPermissions), len(perms))
+		}
+	})
+
+	t.Run("manager without role gets defaults", func(t *testing.T) {
+		user := User{Role: UserRoleManager, IsActive: true}
+		perms, _ := user.EffectivePermissions(nil)
+		if !HasPermission(perms, PermissionProductsWrite) {
+			t.Error("Expected manager to have products.write")
+		}
+		if HasPermission(perms, PermissionSettingsWrite) || HasPermission(perms, PermissionUsersWrite) {
+			t.Error("Expected manager not to manage settings or users")
+		}
+	})
+
+	t.Run("manager with custom role uses role permissions", func(t *testing.T) {
+		role := &Role{ID: uuid.New(), Permissions: []Permission{PermissionOrdersRead}}
+		user := User{Role: UserRoleManager, IsActive: true, StaffRoleID: &role.ID, StaffRole: role}
+		perms, _ := user.EffectivePermissions(nil)
+		if len(perms) != 1 || perms[0] != PermissionOrdersRead {
+			t.Errorf("Expected only orders.read, got %v", perms)
+		}
+	})
+
+	t.Run("customer and inactive users get nothing", func(t *testing.T) {
+		for _, user := range []User{
+			{Role: UserRoleCustomer, IsActive: true},
+			{Role: UserRoleAdmin, IsActive: false},
+		} {
+			perms, _ := user.EffectivePermissions(nil)
+			if len(perms) != 0 {
+				t.Errorf("Expected no permissions for %s (active=%v), got %v", user.Role, user.IsActive, perms)
+			}
+		}
+	})
+}
+
+func TestIsValidPermission(t *testing.T) {
+	if !IsValidPermission(PermissionOrdersRefund) {
+		t.Error("Expected orders.refund to be valid")
+	}
+	if IsValidPermission(Permission("orders.delete_everything")) {
+		t.Error("Expected unknown permission to be invalid")
+	}
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -28,6 +30,9 @@ type Settings struct {
 	ZipCode string `json:"zip_code"`
 	Country string `json:"country" gorm:"default:'US'"`
 
+	// Timezone is an IANA name such as "Europe/Berlin"; reports group days in it
+	Timezone string `json:"timezone" gorm:"not null;default:'UTC'"`
+
 	// SEO
 	MetaTitle       string `json:"meta_title"`
 	MetaDescription string `json:"meta_description"`
@@ -47,6 +52,40 @@ func (s *Settings) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+var ErrInvalidTimezone = errors.New("invalid timezone: use an IANA name such as Europe/Berlin")
+
+// ValidateTimezone checks that name is an IANA zone known to both Go and
+// Postgres, since reports group days by it in SQL. "Local" is refused: it
+// would follow whatever zone the server runs in.
+func ValidateTimezone(db *gorm.DB, name string) error {
+	if strings.TrimSpace(name) == "" || name == "Local" {
+		return ErrInvalidTimezone
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return ErrInvalidTimezone
+	}
+	var count int64
+	if err := db.Raw("SELECT COUNT(*) FROM pg_timezone_names WHERE name = ?", name).Scan(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidTimezone
+	}
+	return nil
+}
+
+// Location returns the shop's time zone, falling back to UTC
+func (s *Settings) Location() *time.Location {
+	if s.Timezone == "" || s.Timezone == "Local" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
 // GetSettings returns the single settings record
 func GetSettings(db *gorm.DB) (*Settings, error) {
 	var settings Settings
@@ -62,6 +101,7 @@ func GetSettings(db *gorm.DB) (*Settings, error) {
 				EnableGuestCheckout: true,
 				EnableRegistration:  true,
 				Country:             "US",
+				Timezone:            "UTC",
 			}
 			if createErr := db.Create(&settings).Error; createErr != nil {
 				return nil, createErr
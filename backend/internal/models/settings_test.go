@@ -0,0 +1,26 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateTimezone_RejectsWithoutQuerying(t *testing.T) {
+	// A nil database would panic if these reached the pg_timezone_names check
+	for _, name := range []string{"   ", "Local", "Not/A_Zone"} {
+		if err := ValidateTimezone(nil, name); err != ErrInvalidTimezone {
+			t.Errorf("ValidateTimezone(%q) = %v, want ErrInvalidTimezone", name, err)
+		}
+	}
+}
+
+func TestSettings_Location(t *testing.T) {
+	for _, name := range []string{"", "Local", "Not/A_Zone"} {
+		if loc := (&Settings{Timezone: name}).Location(); loc != time.UTC {
+			t.Errorf("Location() for %q = %v, want UTC", name, loc)
+		}
+	}
+	if loc := (&Settings{Timezone: "Europe/Berlin"}).Location(); loc.String() != "Europe/Berlin" {
+		t.Errorf("Location() = %v, want Europe/Berlin", loc)
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type InvitationStatus string
+
+const (
+	InvitationStatusPending  InvitationStatus = "pending"
+	InvitationStatusAccepted InvitationStatus = "accepted"
+	InvitationStatusRevoked  InvitationStatus = "revoked"
+	InvitationStatusExpired  InvitationStatus = "expired"
+)
+
+// StaffInvitation lets an admin invite a staff member by email; the invitee
+// chooses their own name and password when accepting the signed link
+type StaffInvitation struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	Email       string     `json:"email" gorm:"not null;index"`
+	Role        UserRole   `json:"role" gorm:"type:varchar(20);not null"`
+	StaffRoleID *uuid.UUID `json:"staff_role_id,omitempty" gorm:"type:uuid"`
+	InvitedByID *uuid.UUID `json:"invited_by_id,omitempty" gorm:"type:uuid"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+
+	// Acceptance record
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	AcceptedUserID *uuid.UUID `json:"accepted_user_id,omitempty" gorm:"type:uuid"`
+	AcceptedIP     string     `json:"accepted_ip,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	InvitedBy *User `json:"invited_by,omitempty" gorm:"foreignKey:InvitedByID;constraint:OnDelete:SET NULL"`
+	StaffRole *Role `json:"staff_role,omitempty" gorm:"foreignKey:StaffRoleID;constraint:OnDelete:SET NULL"`
+}
+
+func (i *StaffInvitation) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// Status derives the invitation state from its timestamps
+func (i *StaffInvitation) Status(now time.Time) InvitationStatus {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationStatusAccepted
+	case i.RevokedAt != nil:
+		return InvitationStatusRevoked
+	case now.After(i.ExpiresAt):
+		return InvitationStatusExpired
+	default:
+		return InvitationStatusPending
+	}
+}
@@ -26,6 +26,13 @@ type User struct {
 	IsActive  bool      `json:"is_active" gorm:"default:true"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Set once the user has proven they own Email
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	// Custom staff role; when set it replaces the default manager permissions
+	StaffRoleID *uuid.UUID `json:"staff_role_id,omitempty" gorm:"type:uuid;index"`
+	StaffRole   *Role      `json:"staff_role,omitempty" gorm:"constraint:OnDelete:SET NULL"`
 }
 
 type UserResponse struct {
@@ -36,6 +43,11 @@ type UserResponse struct {
 	Role      UserRole  `json:"role"`
 	IsActive  bool      `json:"is_active"`
 	CreatedAt time.Time `json:"created_at"`
+
+	EmailVerified bool `json:"email_verified"`
+
+	StaffRoleID *uuid.UUID `json:"staff_role_id,omitempty"`
+	StaffRole   *Role      `json:"staff_role,omitempty"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) error {
@@ -67,6 +79,11 @@ func (u *User) ToResponse() UserResponse {
 		Role:      u.Role,
 		IsActive:  u.IsActive,
 		CreatedAt: u.CreatedAt,
+
+		EmailVerified: u.EmailVerifiedAt != nil,
+
+		StaffRoleID: u.StaffRoleID,
+		StaffRole:   u.StaffRole,
 	}
 }
 
@@ -81,4 +98,32 @@ func (u *User) IsManager() bool {
 
 func (u *User) IsCustomer() bool {
 	return u.Role == UserRoleCustomer
+}
+
+// EffectivePermissions returns the permissions granted to the user.
+// Admins always hold every permission; managers get their custom staff role
+// or DefaultManagerPermissions; customers and inactive users get none.
+func (u *User) EffectivePermissions(db *gorm.DB) ([]Permission, error) {
+	if !u.IsActive {
+		return []Permission{}, nil
+	}
+
+	switch u.Role {
+	case UserRoleAdmin:
+		return AllPermissions, nil
+	case UserRoleManager:
+		if u.StaffRoleID == nil {
+			return DefaultManagerPermissions, nil
+		}
+		if u.StaffRole == nil || u.StaffRole.ID != *u.StaffRoleID {
+			var role Role
+			if err := db.First(&role, *u.StaffRoleID).Error; err != nil {
+				return nil, err
+			}
+			u.StaffRole = &role
+		}
+		return u.StaffRole.Permissions, nil
+	default:
+		return []Permission{}, nil
+	}
 }
\ No newline at end of file
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to an account at an external OpenID Connect provider
+type UserIdentity struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Provider  string    `json:"provider" gorm:"not null;uniqueIndex:idx_user_identity_subject"`
+	Subject   string    `json:"subject" gorm:"not null;uniqueIndex:idx_user_identity_subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	User *User `json:"user,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// OIDCLoginState holds the secrets of a sign-in that is in progress at a provider.
+// It is keyed by the OAuth state parameter and deleted when the callback is handled.
+// BindingHash is the SHA-256 of the cookie set on the browser that started it.
+type OIDCLoginState struct {
+	State        string    `gorm:"primary_key"`
+	Provider     string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	Nonce        string    `gorm:"not null"`
+	BindingHash  string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
+
+func (i *UserIdentity) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
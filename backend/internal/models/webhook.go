@@ -0,0 +1,133 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook event types sent to subscribed endpoints
+const (
+	WebhookEventOrderCreated       = "order.created"
+	WebhookEventOrderStatusChanged = "order.status_changed"
+	WebhookEventProductUpdated     = "product.updated"
+	WebhookEventInventoryLow       = "inventory.low"
+
+	// WebhookEventPing is only sent on request from the admin to test an endpoint
+	WebhookEventPing = "ping"
+)
+
+// AllWebhookEvents lists the events an endpoint can subscribe to
+var AllWebhookEvents = []string{
+	WebhookEventOrderCreated,
+	WebhookEventOrderStatusChanged,
+	WebhookEventProductUpdated,
+	WebhookEventInventoryLow,
+}
+
+// IsValidWebhookEvent reports whether event can be subscribed to
+func IsValidWebhookEvent(event string) bool {
+	for _, known := range AllWebhookEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEndpoint is a URL that receives signed POSTs for the events it subscribes to
+type WebhookEndpoint struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	URL         string    `json:"url" gorm:"not null"`
+	Description string    `json:"description"`
+	Secret      string    `json:"-" gorm:"not null"` // Signs deliveries; only shown when created or rotated
+	Events      []string  `json:"events" gorm:"serializer:json;type:text;not null"`
+	IsActive    bool      `json:"is_active" gorm:"default:true"`
+	// ConsecutiveFailures counts failed delivery attempts since the last
+	// success; the endpoint is disabled when it reaches a limit
+	ConsecutiveFailures int        `json:"consecutive_failures" gorm:"default:0"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason      string     `json:"disabled_reason,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending" // Queued or waiting to retry
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery records one event sent to one endpoint, with the details of
+// the latest attempt
+type WebhookDelivery struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	EndpointID uuid.UUID `json:"endpoint_id" gorm:"type:uuid;not null;index"`
+	// EventID is shared by every delivery of the same event, including
+	// redeliveries, so receivers can ignore duplicates
+	EventID         uuid.UUID             `json:"event_id" gorm:"type:uuid;not null;index"`
+	Event           string                `json:"event" gorm:"not null;index"`
+	Payload         string                `json:"payload" gorm:"type:text;not null"`
+	Status          WebhookDeliveryStatus `json:"status" gorm:"type:varchar(20);default:'pending';not null;index"`
+	Attempts        int                   `json:"attempts" gorm:"default:0"`
+	RequestHeaders  JSONMap               `json:"request_headers,omitempty" gorm:"serializer:json;type:text"`
+	ResponseStatus  int                   `json:"response_status,omitempty"`
+	ResponseHeaders JSONMap               `json:"response_headers,omitempty" gorm:"serializer:json;type:text"`
+	ResponseBody    string                `json:"response_body,omitempty" gorm:"type:text"`
+	DurationMs      int64                 `json:"duration_ms,omitempty"`
+	Error           string                `json:"error,omitempty" gorm:"type:text"`
+	RedeliveryOf    *uuid.UUID            `json:"redelivery_of,omitempty" gorm:"type:uuid"`
+	DeliveredAt     *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt       time.Time             `json:"created_at" gorm:"index"`
+	UpdatedAt       time.Time             `json:"updated_at"`
+
+	Endpoint *WebhookEndpoint `json:"endpoint,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (w *WebhookEndpoint) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	if w.Secret == "" {
+		secret, err := GenerateWebhookSecret()
+		if err != nil {
+			return err
+		}
+		w.Secret = secret
+	}
+	return nil
+}
+
+// Subscribes reports whether the endpoint wants event
+func (w *WebhookEndpoint) Subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateWebhookSecret returns a random signing secret
+func GenerateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	if d.Status == "" {
+		d.Status = WebhookDeliveryPending
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxWishlists caps the lists of one customer
+	MaxWishlists = 20
+	// MaxWishlistItems caps the items of one list
+	MaxWishlistItems = 200
+)
+
+var (
+	ErrProductUnavailable = errors.New("product not found or not for sale")
+	ErrVariantMismatch    = errors.New("variant not found for this product")
+)
+
+// Wishlist is a customer's named list of products saved for later. It can be
+// shared read-only through its ShareToken.
+type Wishlist struct {
+	ID     uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	UserID uuid.UUID `json:"-" gorm:"type:uuid;not null;index"`
+	Name   string    `json:"name" gorm:"not null"`
+	// ShareToken is set while the list is shared; unsharing clears it, so
+	// old links stop working
+	ShareToken *string   `json:"share_token,omitempty" gorm:"uniqueIndex"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	User  *User          `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Items []WishlistItem `json:"items" gorm:"foreignKey:WishlistID"`
+}
+
+// WishlistItem is a product, or one variant of it, on a wishlist
+type WishlistItem struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	WishlistID uuid.UUID  `json:"wishlist_id" gorm:"type:uuid;not null;index"`
+	ProductID  uuid.UUID  `json:"product_id" gorm:"type:uuid;not null;index"`
+	VariantID  *uuid.UUID `json:"variant_id,omitempty" gorm:"type:uuid"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	Wishlist *Wishlist       `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Product  *Product        `json:"product,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	Variant  *ProductVariant `json:"variant,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+
+	// Available is whether the product, and variant, can be bought now
+	Available bool `json:"available" gorm:"-"`
+}
+
+func (w *Wishlist) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return nil
+}
+
+func (i *WishlistItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// NewShareToken returns a random token for a wishlist's public link
+func NewShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// FindPurchasable loads an active product and, when variantID is set, its
+// active variant. It returns ErrProductUnavailable or ErrVariantMismatch
+// when they cannot be bought.
+func FindPurchasable(tx *gorm.DB, productID uuid.UUID, variantID *uuid.UUID) (*Product, *ProductVariant, error) {
+	var product Product
+	if err := tx.Where("id = ? AND is_active = true", productID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrProductUnavailable
+		}
+		return nil, nil, err
+	}
+	if variantID == nil {
+		return &product, nil, nil
+	}
+	var variant ProductVariant
+	if err := tx.Where("id = ? AND product_id = ? AND is_active = true", *variantID, productID).First(&variant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrVariantMismatch
+		}
+		return nil, nil, err
+	}
+	return &product, &variant, nil
+}
+
+// WhereVariant matches rows with variantID, or without a variant when it
+// is nil
+func WhereVariant(query *gorm.DB, variantID *uuid.UUID) *gorm.DB {
+	if variantID == nil {
+		return query.Where("variant_id IS NULL")
+	}
+	return query.Where("variant_id = ?", *variantID)
+}
+
+// PreloadWishlistItems loads a wishlist's items, newest first, with their
+// products and variants
+func PreloadWishlistItems(query *gorm.DB) *gorm.DB {
+	return query.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC, id")
+	}).Preload("Items.Product.Images").Preload("Items.Variant")
+}
+
+// MarkAvailability sets Available on each item
+func (w *Wishlist) MarkAvailability() {
+	for i := range w.Items {
+		item := &w.Items[i]
+		item.Available = item.Product != nil && item.Product.IsActive &&
+			(item.VariantID == nil || (item.Variant != nil && item.Variant.IsActive))
+	}
+}
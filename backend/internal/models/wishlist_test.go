@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWishlist_MarkAvailability(t *testing.T) {
+	variantID := uuid.New()
+	wishlist := Wishlist{Items: []WishlistItem{
+		{Product: &Product{IsActive: true}},
+		{Product: &Product{IsActive: false}},
+		{Product: &Product{IsActive: true}, VariantID: &variantID, Variant: &ProductVariant{IsActive: true}},
+		{Product: &Product{IsActive: true}, VariantID: &variantID, Variant: &ProductVariant{IsActive: false}},
+		{Product: &Product{IsActive: true}, VariantID: &variantID},
+	}}
+	wishlist.MarkAvailability()
+
+	want := []bool{true, false, true, false, false}
+	for i, item := range wishlist.Items {
+		if item.Available != want[i] {
+			t.Errorf("item %d Available = %v, want %v", i, item.Available, want[i])
+		}
+	}
+}
+
+func TestCartItem_UnitPrice(t *testing.T) {
+	price := 1500
+	cases := []struct {
+		item CartItem
+		want int
+	}{
+		{CartItem{Product: &Product{Price: 1000}}, 1000},
+		{CartItem{Product: &Product{Price: 1000}, Variant: &ProductVariant{}}, 1000},
+		{CartItem{Product: &Product{Price: 1000}, Variant: &ProductVariant{Price: &price}}, 1500},
+	}
+	for i, c := range cases {
+		if got := c.item.UnitPrice(); got != c.want {
+			t.Errorf("case %d UnitPrice() = %d, want %d", i, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package notifications
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"easycart/internal/events"
+	"easycart/internal/jobs"
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// JobLowStockAlert checks a product's stock and emails the shop if it is still low
+	JobLowStockAlert = "notifications.low_stock_alert"
+
+	// JobProductAlerts emails the shoppers waiting for a product to come
+	// back in stock or drop in price
+	JobProductAlerts = "notifications.product_alerts"
+)
+
+type lowStockPayload struct {
+	ProductID uuid.UUID `json:"product_id"`
+}
+
+type productAlertsPayload struct {
+	ProductID uuid.UUID `json:"product_id"`
+}
+
+// QueueLowStockAlert schedules a LowStockAlert in the background. Alerts for
+// the same product are deduplicated until the pending one has run.
+func (n *Notifier) QueueLowStockAlert(tx *gorm.DB, productID uuid.UUID) error {
+	if n == nil {
+		return nil
+	}
+	_, err := jobs.Enqueue(tx, JobLowStockAlert, lowStockPayload{ProductID: productID},
+		jobs.UniqueKey("low_stock_alert:"+productID.String()))
+	return err
+}
+
+// QueueProductAlerts schedules sending the product's due alerts in the
+// background, deduplicated like QueueLowStockAlert
+func (n *Notifier) QueueProductAlerts(tx *gorm.DB, productID uuid.UUID) error {
+	if n == nil {
+		return nil
+	}
+	_, err := jobs.Enqueue(tx, JobProductAlerts, productAlertsPayload{ProductID: productID},
+		jobs.UniqueKey("product_alerts:"+productID.String()))
+	return err
+}
+
+// Subscribe queues a low stock alert whenever a product falls to its minimum
+// stock, and the shoppers' alerts whenever a product or variant is restocked
+// or gets cheaper. Edits of active products queue them too, since putting a
+// product or variant back on sale changes no stock or price.
+func (n *Notifier) Subscribe(d *events.Dispatcher) {
+	d.Subscribe("notifications", func(ctx context.Context, tx *gorm.DB, event events.Event) error {
+		var changed events.StockChanged
+		if err := event.Decode(&changed); err != nil {
+			return err
+		}
+		if !changed.FellBelowMinimum() {
+			return nil
+		}
+		return n.QueueLowStockAlert(tx, changed.ProductID)
+	}, events.TypeStockChanged)
+
+	d.Subscribe("product_alerts", func(ctx context.Context, tx *gorm.DB, event events.Event) error {
+		switch event.Type {
+		case events.TypeStockChanged:
+			var changed events.StockChanged
+			if err := event.Decode(&changed); err != nil {
+				return err
+			}
+			if changed.Restocked() {
+				return n.QueueProductAlerts(tx, changed.ProductID)
+			}
+		case events.TypePriceChanged:
+			var changed events.PriceChanged
+			if err := event.Decode(&changed); err != nil {
+				return err
+			}
+			if changed.Dropped() {
+				return n.QueueProductAlerts(tx, changed.ProductID)
+			}
+		case events.TypeProductUpdated:
+			var updated events.ProductUpdated
+			if err := event.Decode(&updated); err != nil {
+				return err
+			}
+			if updated.Product.IsActive {
+				return n.QueueProductAlerts(tx, updated.Product.ID)
+			}
+		}
+		return nil
+	}, events.TypeStockChanged, events.TypePriceChanged, events.TypeProductUpdated)
+}
+
+// RegisterJobs adds the notification job handlers to w. Links in shoppers'
+// emails start with frontendURL.
+func (n *Notifier) RegisterJobs(w *jobs.Worker, db *gorm.DB, frontendURL string) {
+	w.Register(JobLowStockAlert, jobs.Handle(func(ctx context.Context, payload lowStockPayload) error {
+		return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var product models.Product
+			if err := tx.First(&product, "id = ?", payload.ProductID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return nil
+				}
+				return err
+			}
+			// Restocked since the alert was queued
+			if product.Stock > product.MinStock {
+				return nil
+			}
+			return n.LowStockAlert(tx, &product)
+		})
+	}))
+
+	w.Register(JobProductAlerts, jobs.Handle(func(ctx context.Context, payload productAlertsPayload) error {
+		return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return n.sendProductAlerts(tx, payload.ProductID, frontendURL)
+		})
+	}))
+}
+
+// sendProductAlerts emails every confirmed alert on the product that is due
+// and marks it sent
+func (n *Notifier) sendProductAlerts(tx *gorm.DB, productID uuid.UUID, frontendURL string) error {
+	var product models.Product
+	if err := tx.First(&product, "id = ?", productID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	var alerts []models.ProductAlert
+	if err := tx.Preload("Variant").Where("product_id = ? AND confirmed_at IS NOT NULL AND notified_at IS NULL", product.ID).Find(&alerts).Error; err != nil {
+		return err
+	}
+	productURL := frontendURL + "/products/" + url.PathEscape(product.Slug)
+	now := time.Now()
+	for i := range alerts {
+		alert := &alerts[i]
+		if alert.VariantID != nil && alert.Variant == nil {
+			continue
+		}
+		if !alert.Due(&product, alert.Variant) {
+			continue
+		}
+		unsubscribeURL := frontendURL + "/alerts/unsubscribe?token=" + alert.Token
+		if err := n.ProductAlert(tx, alert, &product, alert.Variant, productURL, unsubscribeURL); err != nil {
+			return err
+		}
+		if err := tx.Model(alert).Update("notified_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package notifications
+
+import (
+	"context"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultTemplatesRender(t *testing.T) {
+	for _, key := range Keys() {
+		tmpl, err := Default(key)
+		if err != nil {
+			t.Fatalf("Default(%q) error = %v", key, err)
+		}
+		msg, err := tmpl.Render(SampleData(key))
+		if err != nil {
+			t.Errorf("%s does not render: %v", key, err)
+			continue
+		}
+		if msg.Subject == "" || msg.TextBody == "" || msg.HTMLBody == "" {
+			t.Errorf("%s rendered an empty part: %+v", key, msg)
+		}
+	}
+}
+
+func TestTemplate_Render(t *testing.T) {
+	t.Run("order confirmation lists items and totals", func(t *testing.T) {
+		tmpl, _ := Default(TemplateOrderConfirmation)
+		msg, err := tmpl.Render(SampleData(TemplateOrderConfirmation))
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		if !strings.Contains(msg.Subject, "ORD-1001") {
+			t.Errorf("Expected order number in subject, got %q", msg.Subject)
+		}
+		if !strings.Contains(msg.TextBody, "2 x USB-C Cable: $25.00") || !strings.Contains(msg.TextBody, "Total: $45.00") {
+			t.Errorf("Unexpected text body:\n%s", msg.TextBody)
+		}
+	})
+
+	t.Run("html body escapes variables", func(t *testing.T) {
+		tmpl := Template{Key: "test", Subject: "Hi", TextBody: "{{.Name}}", HTMLBody: "<p>{{.Name}}</p>"}
+		msg, err := tmpl.Render(map[string]interface{}{"Name": "<script>"})
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		if strings.Contains(msg.HTMLBody, "<script>") {
+			t.Errorf("Expected escaped HTML, got %q", msg.HTMLBody)
+		}
+	})
+
+	t.Run("unknown variables are rejected", func(t *testing.T) {
+		tmpl, _ := Default(TemplatePasswordReset)
+		tmpl.TextBody = "{{.Order.OrderNumber}}"
+		if err := tmpl.Validate(); err == nil {
+			t.Error("Expected validation to fail for a variable the template does not get")
+		}
+	})
+
+	t.Run("model fields outside the views are rejected", func(t *testing.T) {
+		tmpl, _ := Default(TemplatePasswordReset)
+		tmpl.TextBody = "{{.User.Password}}"
+		if err := tmpl.Validate(); err == nil {
+			t.Error("Expected validation to fail for the password hash")
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		4:  8 * time.Minute,
+		20: 6 * time.Hour,
+	}
+	for attempt, want := range cases {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	from, _ := mail.ParseAddress("Shop <shop@example.com>")
+	mailer, err := NewFileMailer(dir, from)
+	if err != nil {
+		t.Fatalf("NewFileMailer() error = %v", err)
+	}
+
+	err = mailer.Send(context.Background(), Message{
+		To:       "jane@example.com",
+		Subject:  "Grüße",
+		TextBody: "Hello Jane",
+		HTMLBody: "<p>Hello Jane</p>",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one .eml file, got %v", files)
+	}
+
+	f, _ := os.Open(files[0])
+	defer f.Close()
+	parsed, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatalf("written email does not parse: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != "Grüße" {
+		t.Errorf("Expected decoded subject, got %q", subject)
+	}
+	if !strings.HasPrefix(parsed.Header.Get("Content-Type"), "multipart/alternative") {
+		t.Errorf("Unexpected content type %q", parsed.Header.Get("Content-Type"))
+	}
+}
+
+func TestBuildMIME_RejectsBadRecipient(t *testing.T) {
+	from, _ := mail.ParseAddress("shop@example.com")
+	if _, err := buildMIME(from, Message{To: "not an address", Subject: "x", TextBody: "x"}, time.Now()); err == nil {
+		t.Error("Expected invalid recipient to be rejected")
+	}
+}
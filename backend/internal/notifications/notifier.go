@@ -0,0 +1,127 @@
+package notifications
+
+import (
+	"fmt"
+
+	"easycart/internal/models"
+	"gorm.io/gorm"
+)
+
+// Notifier renders and queues the shop's transactional emails. Each method
+// takes the transaction making the change the email is about. A nil Notifier
+// sends nothing, which keeps handler tests free of mail setup.
+type Notifier struct{}
+
+func NewNotifier() *Notifier {
+	return &Notifier{}
+}
+
+// OrderConfirmation is sent to the customer when an order is placed
+func (n *Notifier) OrderConfirmation(tx *gorm.DB, order *models.Order, statusURL string) error {
+	return n.send(tx, TemplateOrderConfirmation, order.CustomerEmail, map[string]interface{}{
+		"Order":     newOrderView(order),
+		"StatusURL": statusURL,
+	})
+}
+
+// ShippingNotice is sent to the customer when an order ships
+func (n *Notifier) ShippingNotice(tx *gorm.DB, order *models.Order, statusURL string) error {
+	return n.send(tx, TemplateShippingNotice, order.CustomerEmail, map[string]interface{}{
+		"Order":     newOrderView(order),
+		"StatusURL": statusURL,
+	})
+}
+
+// PasswordReset sends a password reset link
+func (n *Notifier) PasswordReset(tx *gorm.DB, user *models.User, resetURL string) error {
+	return n.send(tx, TemplatePasswordReset, user.Email, map[string]interface{}{
+		"User":     newUserView(user),
+		"ResetURL": resetURL,
+	})
+}
+
+// EmailVerification sends a link that confirms the user owns their email
+func (n *Notifier) EmailVerification(tx *gorm.DB, user *models.User, verifyURL string) error {
+	return n.send(tx, TemplateEmailVerification, user.Email, map[string]interface{}{
+		"User":      newUserView(user),
+		"VerifyURL": verifyURL,
+	})
+}
+
+// StaffInvitation sends an invite link to a new staff member
+func (n *Notifier) StaffInvitation(tx *gorm.DB, invitation *models.StaffInvitation, inviteURL string) error {
+	return n.send(tx, TemplateStaffInvitation, invitation.Email, map[string]interface{}{
+		"Invitation": newInvitationView(invitation),
+		"InviteURL":  inviteURL,
+	})
+}
+
+// LowStockAlert tells the shop's contact address that a product needs restocking
+func (n *Notifier) LowStockAlert(tx *gorm.DB, product *models.Product) error {
+	if n == nil {
+		return nil
+	}
+	settings, err := models.GetSettings(tx)
+	if err != nil {
+		return err
+	}
+	if settings.ContactEmail == "" {
+		return nil
+	}
+	return n.send(tx, TemplateLowStockAlert, settings.ContactEmail, map[string]interface{}{
+		"Product": newProductView(product),
+	})
+}
+
+// ProductAlert tells a shopper that a product they subscribed to is back in
+// stock or cheaper. variant is nil for an alert on the whole product.
+func (n *Notifier) ProductAlert(tx *gorm.DB, alert *models.ProductAlert, product *models.Product, variant *models.ProductVariant, productURL, unsubscribeURL string) error {
+	key := TemplateBackInStock
+	if alert.Kind == models.AlertPriceDrop {
+		key = TemplatePriceDrop
+	}
+	return n.send(tx, key, alert.Email, map[string]interface{}{
+		"Product":        newProductView(product),
+		"Variant":        newVariantView(variant),
+		"Alert":          newAlertView(alert),
+		"Price":          models.CurrentPrice(product, variant),
+		"ProductURL":     productURL,
+		"UnsubscribeURL": unsubscribeURL,
+	})
+}
+
+// AlertConfirmation asks a shopper to confirm a new product alert before
+// anything else is sent to them
+func (n *Notifier) AlertConfirmation(tx *gorm.DB, alert *models.ProductAlert, product *models.Product, variant *models.ProductVariant, confirmURL string) error {
+	return n.send(tx, TemplateAlertConfirmation, alert.Email, map[string]interface{}{
+		"Product":    newProductView(product),
+		"Variant":    newVariantView(variant),
+		"Alert":      newAlertView(alert),
+		"ConfirmURL": confirmURL,
+	})
+}
+
+func (n *Notifier) send(tx *gorm.DB, key, to string, data map[string]interface{}) error {
+	if n == nil {
+		return nil
+	}
+
+	if _, ok := data["Shop"]; !ok {
+		settings, err := models.GetSettings(tx)
+		if err != nil {
+			return err
+		}
+		data["Shop"] = NewShopView(settings)
+	}
+
+	tmpl, _, err := Load(tx, key)
+	if err != nil {
+		return err
+	}
+	msg, err := tmpl.Render(data)
+	if err != nil {
+		return fmt.Errorf("failed to render %s email: %w", key, err)
+	}
+	msg.To = to
+	return Enqueue(tx, key, msg)
+}
@@ -0,0 +1,151 @@
+package notifications
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"easycart/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxAttempts is how many times delivery is tried before an email is marked failed
+	maxAttempts = 8
+
+	// claimLease hides claimed messages from other workers while they are sent;
+	// a worker that dies mid-send releases them when the lease runs out
+	claimLease = 5 * time.Minute
+
+	pollInterval = 5 * time.Second
+	batchSize    = 20
+)
+
+// Queue delivers queued EmailMessages with retries. Several servers may run
+// a Queue against the same database; rows are claimed with SKIP LOCKED.
+type Queue struct {
+	db     *gorm.DB
+	mailer Mailer
+}
+
+func NewQueue(db *gorm.DB, mailer Mailer) *Queue {
+	return &Queue{db: db, mailer: mailer}
+}
+
+// Enqueue stores msg for delivery. Pass the transaction that makes the change
+// the email is about, so the email is only sent if that change commits.
+func Enqueue(tx *gorm.DB, templateKey string, msg Message) error {
+	return tx.Create(&models.EmailMessage{
+		TemplateKey: templateKey,
+		To:          msg.To,
+		Subject:     msg.Subject,
+		HTMLBody:    msg.HTMLBody,
+		TextBody:    msg.TextBody,
+	}).Error
+}
+
+// Run delivers due messages until ctx is cancelled
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going without waiting while there is a backlog
+		for {
+			n, err := q.ProcessBatch(ctx)
+			if err != nil {
+				log.Printf("Email queue error: %v", err)
+				break
+			}
+			if n < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessBatch claims and sends up to batchSize due messages and returns how many it claimed
+func (q *Queue) ProcessBatch(ctx context.Context) (int, error) {
+	var messages []models.EmailMessage
+	now := time.Now()
+
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.EmailStatusPending, now).
+			Order("next_attempt_at ASC").
+			Limit(batchSize).
+			Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ids := make([]interface{}, len(messages))
+		for i, msg := range messages {
+			ids[i] = msg.ID
+		}
+		return tx.Model(&models.EmailMessage{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(claimLease)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range messages {
+		if ctx.Err() != nil {
+			break
+		}
+		q.deliver(ctx, &messages[i])
+	}
+	return len(messages), nil
+}
+
+func (q *Queue) deliver(ctx context.Context, msg *models.EmailMessage) {
+	err := q.mailer.Send(ctx, Message{
+		To:       msg.To,
+		Subject:  msg.Subject,
+		HTMLBody: msg.HTMLBody,
+		TextBody: msg.TextBody,
+	})
+
+	now := time.Now()
+	updates := map[string]interface{}{"attempts": msg.Attempts + 1}
+	switch {
+	case err == nil:
+		updates["status"] = models.EmailStatusSent
+		updates["sent_at"] = now
+		updates["last_error"] = ""
+	case msg.Attempts+1 >= maxAttempts:
+		updates["status"] = models.EmailStatusFailed
+		updates["last_error"] = err.Error()
+		log.Printf("Giving up on email %s to %s: %v", msg.ID, msg.To, err)
+	default:
+		updates["next_attempt_at"] = now.Add(Backoff(msg.Attempts + 1))
+		updates["last_error"] = err.Error()
+	}
+
+	if dbErr := q.db.Model(msg).Updates(updates).Error; dbErr != nil {
+		log.Printf("Failed to record delivery of email %s: %v", msg.ID, dbErr)
+	}
+}
+
+// Backoff returns the wait before retry number attempt: 1m, 2m, 4m, ... capped at 6h
+func Backoff(attempt int) time.Duration {
+	const maxBackoff = 6 * time.Hour
+	delay := time.Minute
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
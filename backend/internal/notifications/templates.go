@@ -0,0 +1,333 @@
+package notifications
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	texttemplate "text/template"
+	"time"
+
+	"easycart/internal/models"
+	"gorm.io/gorm"
+)
+
+// Template keys
+const (
+	TemplateOrderConfirmation = "order_confirmation"
+	TemplateShippingNotice    = "shipping_notice"
+	TemplatePasswordReset     = "password_reset"
+	TemplateEmailVerification = "email_verification"
+	TemplateStaffInvitation   = "staff_invitation"
+	TemplateLowStockAlert     = "low_stock_alert"
+	TemplateBackInStock       = "back_in_stock"
+	TemplatePriceDrop         = "price_drop"
+	TemplateAlertConfirmation = "alert_confirmation"
+)
+
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// Template is the editable content of one kind of email. Subject and TextBody
+// use text/template; HTMLBody uses html/template so variables are escaped.
+type Template struct {
+	Key       string   `json:"key"`
+	Subject   string   `json:"subject"`
+	HTMLBody  string   `json:"html_body"`
+	TextBody  string   `json:"text_body"`
+	Variables []string `json:"variables"` // Top-level variables available to the template
+}
+
+var templateFuncs = map[string]interface{}{
+	"money": models.FormatPrice,
+	"date": func(t time.Time) string {
+		return t.Format("January 2, 2006")
+	},
+}
+
+const defaultFooterText = `
+--
+{{.Shop.ShopName}}{{if .Shop.ContactEmail}} · {{.Shop.ContactEmail}}{{end}}`
+
+const defaultFooterHTML = `
+<hr>
+<p style="color:#64748B;font-size:12px">{{.Shop.ShopName}}{{if .Shop.ContactEmail}} · {{.Shop.ContactEmail}}{{end}}</p>`
+
+// defaults are used for every key without a customized row in email_templates
+var defaults = map[string]Template{
+	TemplateOrderConfirmation: {
+		Subject: `Your {{.Shop.ShopName}} order {{.Order.OrderNumber}}`,
+		TextBody: `Hi {{.Order.CustomerName}},
+
+Thanks for your order! We'll let you know when it ships.
+
+Order {{.Order.OrderNumber}}
+{{range .Order.Items}}- {{.Quantity}} x {{.ProductName}}: {{money .Total}}
+{{end}}
+Total: {{money .Order.Total}}
+
+Track your order: {{.StatusURL}}
+` + defaultFooterText,
+		HTMLBody: `<p>Hi {{.Order.CustomerName}},</p>
+<p>Thanks for your order! We'll let you know when it ships.</p>
+<h3>Order {{.Order.OrderNumber}}</h3>
+<table>
+{{range .Order.Items}}<tr><td>{{.Quantity}} × {{.ProductName}}</td><td align="right">{{money .Total}}</td></tr>
+{{end}}<tr><td><strong>Total</strong></td><td align="right"><strong>{{money .Order.Total}}</strong></td></tr>
+</table>
+<p><a href="{{.StatusURL}}">Track your order</a></p>` + defaultFooterHTML,
+		Variables: []string{"Shop", "Order", "StatusURL"},
+	},
+	TemplateShippingNotice: {
+		Subject: `Your order {{.Order.OrderNumber}} has shipped`,
+		TextBody: `Hi {{.Order.CustomerName}},
+
+Good news: your order {{.Order.OrderNumber}} is on its way.
+{{if .Order.TrackingNumber}}
+Carrier: {{.Order.TrackingCarrier}}
+Tracking number: {{.Order.TrackingNumber}}
+{{end}}
+Order status: {{.StatusURL}}
+` + defaultFooterText,
+		HTMLBody: `<p>Hi {{.Order.CustomerName}},</p>
+<p>Good news: your order {{.Order.OrderNumber}} is on its way.</p>
+{{if .Order.TrackingNumber}}<p>Carrier: {{.Order.TrackingCarrier}}<br>Tracking number: {{.Order.TrackingNumber}}</p>{{end}}
+<p><a href="{{.StatusURL}}">View order status</a></p>` + defaultFooterHTML,
+		Variables: []string{"Shop", "Order", "StatusURL"},
+	},
+	TemplatePasswordReset: {
+		Subject: `Reset your {{.Shop.ShopName}} password`,
+		TextBody: `Hi {{.User.FirstName}},
+
+Someone asked to reset the password for your account. If it was you, open this link within an hour:
+
+{{.ResetURL}}
+
+If you didn't ask for this, you can ignore this email.
+` + defaultFooterText,
+		HTMLBody: `<p>Hi {{.User.FirstName}},</p>
+<p>Someone asked to reset the password for your account. If it was you, use this link within an hour:</p>
+<p><a href="{{.ResetURL}}">Reset password</a></p>
+<p>If you didn't ask for this, you can ignore this email.</p>` + defaultFooterHTML,
+		Variables: []string{"Shop", "User", "ResetURL"},
+	},
+	TemplateEmailVerification: {
+		Subject: `Confirm your email for {{.Shop.ShopName}}`,
+		TextBody: `Hi {{.User.FirstName}},
+
+Please confirm your email address:
+
+{{.VerifyURL}}
+` + defaultFooterText,
+		HTMLBody: `<p>Hi {{.User.FirstName}},</p>
+<p>Please confirm your email address.</p>
+<p><a href="{{.VerifyURL}}">Confirm email</a></p>` + defaultFooterHTML,
+		Variables: []string{"Shop", "User", "VerifyURL"},
+	},
+	TemplateStaffInvitation: {
+		Subject: `You're invited to manage {{.Shop.ShopName}}`,
+		TextBody: `Hello,
+
+You've been invited to join {{.Shop.ShopName}} as {{.Invitation.Role}}. Accept the invitation before {{date .Invitation.ExpiresAt}}:
+
+{{.InviteURL}}
+` + defaultFooterText,
+		HTMLBody: `<p>Hello,</p>
+<p>You've been invited to join {{.Shop.ShopName}} as {{.Invitation.Role}}. Accept the invitation before {{date .Invitation.ExpiresAt}}.</p>
+<p><a href="{{.InviteURL}}">Accept invitation</a></p>` + defaultFooterHTML,
+		Variables: []string{"Shop", "Invitation", "InviteURL"},
+	},
+	TemplateLowStockAlert: {
+		Subject: `Low stock: {{.Product.Name}} ({{.Product.Stock}} left)`,
+		TextBody: `{{.Product.Name}} (SKU {{.Product.SKU}}) is down to {{.Product.Stock}} in stock; the minimum is {{.Product.MinStock}}.
+` + defaultFooterText,
+		HTMLBody:  `<p><strong>{{.Product.Name}}</strong> (SKU {{.Product.SKU}}) is down to {{.Product.Stock}} in stock; the minimum is {{.Product.MinStock}}.</p>` + defaultFooterHTML,
+		Variables: []string{"Shop", "Product"},
+	},
+	TemplateBackInStock: {
+		Subject: `{{.Product.Name}} is back in stock`,
+		TextBody: `Good news: {{.Product.Name}}{{if .Variant}} ({{.Variant.SKU}}){{end}} is back in stock.
+
+Get it before it's gone: {{.ProductURL}}
+
+You asked us to tell you. Unsubscribe: {{.UnsubscribeURL}}
+` + defaultFooterText,
+		HTMLBody: `<p>Good news: <strong>{{.Product.Name}}</strong>{{if .Variant}} ({{.Variant.SKU}}){{end}} is back in stock.</p>
+<p><a href="{{.ProductURL}}">Get it before it's gone</a></p>
+<p style="color:#64748B;font-size:12px">You asked us to tell you. <a href="{{.UnsubscribeURL}}">Unsubscribe</a></p>` + defaultFooterHTML,
+		Variables: []string{"Shop", "Product", "Variant", "Alert", "Price", "ProductURL", "UnsubscribeURL"},
+	},
+	TemplatePriceDrop: {
+		Subject: `Price drop: {{.Product.Name}} is now {{money .Price}}`,
+		TextBody: `{{.Product.Name}}{{if .Variant}} ({{.Variant.SKU}}){{end}} is now {{money .Price}}, down from {{money .Alert.Price}}.
+
+Take a look: {{.ProductURL}}
+
+You asked us to tell you. Unsubscribe: {{.UnsubscribeURL}}
+` + defaultFooterText,
+		HTMLBody: `<p><strong>{{.Product.Name}}</strong>{{if .Variant}} ({{.Variant.SKU}}){{end}} is now <strong>{{money .Price}}</strong>, down from {{money .Alert.Price}}.</p>
+<p><a href="{{.ProductURL}}">Take a look</a></p>
+<p style="color:#64748B;font-size:12px">You asked us to tell you. <a href="{{.UnsubscribeURL}}">Unsubscribe</a></p>` + defaultFooterHTML,
+		Variables: []string{"Shop", "Product", "Variant", "Alert", "Price", "ProductURL", "UnsubscribeURL"},
+	},
+	TemplateAlertConfirmation: {
+		Subject: `Confirm your alert for {{.Product.Name}}`,
+		TextBody: `Please confirm that you want an email when {{.Product.Name}}{{if .Variant}} ({{.Variant.SKU}}){{end}} {{if eq .Alert.Kind "price_drop"}}gets cheaper{{else}}is back in stock{{end}}:
+
+{{.ConfirmURL}}
+
+If you didn't ask for this, ignore this email and you won't hear from us again.
+` + defaultFooterText,
+		HTMLBody: `<p>Please confirm that you want an email when <strong>{{.Product.Name}}</strong>{{if .Variant}} ({{.Variant.SKU}}){{end}} {{if eq .Alert.Kind "price_drop"}}gets cheaper{{else}}is back in stock{{end}}.</p>
+<p><a href="{{.ConfirmURL}}">Confirm alert</a></p>
+<p style="color:#64748B;font-size:12px">If you didn't ask for this, ignore this email and you won't hear from us again.</p>` + defaultFooterHTML,
+		Variables: []string{"Shop", "Product", "Variant", "Alert", "ConfirmURL"},
+	},
+}
+
+// Keys lists every template key in a stable order
+func Keys() []string {
+	keys := make([]string, 0, len(defaults))
+	for key := range defaults {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Default returns the built-in template for key
+func Default(key string) (Template, error) {
+	t, ok := defaults[key]
+	if !ok {
+		return Template{}, ErrUnknownTemplate
+	}
+	t.Key = key
+	return t, nil
+}
+
+// Load returns the template for key, preferring a customized row
+func Load(db *gorm.DB, key string) (Template, bool, error) {
+	t, err := Default(key)
+	if err != nil {
+		return Template{}, false, err
+	}
+
+	var custom models.EmailTemplate
+	err = db.Where("key = ?", key).First(&custom).Error
+	if err == gorm.ErrRecordNotFound {
+		return t, false, nil
+	}
+	if err != nil {
+		return Template{}, false, err
+	}
+
+	t.Subject = custom.Subject
+	t.HTMLBody = custom.HTMLBody
+	t.TextBody = custom.TextBody
+	return t, true, nil
+}
+
+// Validate parses the template and renders it against sample data, so a
+// broken customization is rejected when saved rather than when sent
+func (t Template) Validate() error {
+	_, err := t.Render(SampleData(t.Key))
+	return err
+}
+
+// Render executes the template with data
+func (t Template) Render(data interface{}) (Message, error) {
+	var msg Message
+
+	subject, err := renderText(t.Key+".subject", t.Subject, data)
+	if err != nil {
+		return msg, err
+	}
+	text, err := renderText(t.Key+".text", t.TextBody, data)
+	if err != nil {
+		return msg, err
+	}
+
+	html := ""
+	if t.HTMLBody != "" {
+		tmpl, err := htmltemplate.New(t.Key + ".html").Funcs(templateFuncs).Option("missingkey=error").Parse(t.HTMLBody)
+		if err != nil {
+			return msg, fmt.Errorf("html body: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return msg, fmt.Errorf("html body: %w", err)
+		}
+		html = buf.String()
+	}
+
+	msg.Subject = subject
+	msg.TextBody = text
+	msg.HTMLBody = html
+	return msg, nil
+}
+
+func renderText(name, source string, data interface{}) (string, error) {
+	tmpl, err := texttemplate.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// SampleData returns made-up variables for previews and validation
+func SampleData(key string) map[string]interface{} {
+	now := time.Now()
+	shop := NewShopView(&models.Settings{ShopName: "Demo Store", ContactEmail: "hello@example.com"})
+	order := newOrderView(&models.Order{
+		OrderNumber:     "ORD-1001",
+		CustomerName:    "Jane Doe",
+		CustomerEmail:   "jane@example.com",
+		Subtotal:        4500,
+		Total:           4500,
+		Status:          models.OrderStatusShipped,
+		TrackingCarrier: "UPS",
+		TrackingNumber:  "1Z999AA10123456784",
+		ShippedAt:       &now,
+		CreatedAt:       now,
+		Items: []models.OrderItem{
+			{ProductName: "Wireless Mouse", UnitPrice: 2000, Quantity: 1, Total: 2000},
+			{ProductName: "USB-C Cable", UnitPrice: 1250, Quantity: 2, Total: 2500},
+		},
+	})
+	user := UserView{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"}
+
+	data := map[string]interface{}{"Shop": shop}
+	switch key {
+	case TemplateOrderConfirmation, TemplateShippingNotice:
+		data["Order"] = order
+		data["StatusURL"] = "https://shop.example.com/orders/status?token=sample"
+	case TemplatePasswordReset:
+		data["User"] = user
+		data["ResetURL"] = "https://shop.example.com/reset-password?token=sample"
+	case TemplateEmailVerification:
+		data["User"] = user
+		data["VerifyURL"] = "https://shop.example.com/verify-email?token=sample"
+	case TemplateStaffInvitation:
+		data["Invitation"] = InvitationView{Email: "new.staff@example.com", Role: models.UserRoleManager, ExpiresAt: now.Add(7 * 24 * time.Hour)}
+		data["InviteURL"] = "https://shop.example.com/admin/invite?token=sample"
+	case TemplateLowStockAlert:
+		data["Product"] = ProductView{Name: "Wireless Mouse", SKU: "WM-001", Stock: 2, MinStock: 5}
+	case TemplateBackInStock, TemplatePriceDrop:
+		data["Product"] = ProductView{Name: "Wireless Mouse", Slug: "wireless-mouse", SKU: "WM-001", Price: 1800, Stock: 12}
+		data["Variant"] = &VariantView{SKU: "WM-001-BLK"}
+		data["Alert"] = AlertView{Kind: models.ProductAlertKind(key), Email: "jane@example.com", Price: 2000}
+		data["Price"] = 1800
+		data["ProductURL"] = "https://shop.example.com/products/wireless-mouse"
+		data["UnsubscribeURL"] = "https://shop.example.com/alerts/unsubscribe?token=sample"
+	case TemplateAlertConfirmation:
+		data["Product"] = ProductView{Name: "Wireless Mouse", Slug: "wireless-mouse", SKU: "WM-001", Price: 2000}
+		data["Variant"] = &VariantView{SKU: "WM-001-BLK"}
+		data["Alert"] = AlertView{Kind: models.AlertBackInStock, Email: "jane@example.com", Price: 2000}
+		data["ConfirmURL"] = "https://shop.example.com/alerts/confirm?token=sample"
+	}
+	return data
+}
@@ -0,0 +1,180 @@
+package notifications
+
+import (
+	"time"
+
+	"easycart/internal/models"
+)
+
+// Templates are edited by staff, so they only ever see these views of the
+// models: whatever is left out, such as password hashes and access tokens,
+// cannot be printed into an email.
+
+type ShopView struct {
+	ShopName     string
+	Description  string
+	Logo         string
+	ContactEmail string
+	ContactPhone string
+	Address      string
+	City         string
+	State        string
+	ZipCode      string
+	Country      string
+}
+
+type OrderView struct {
+	OrderNumber       string
+	CustomerName      string
+	CustomerEmail     string
+	CustomerPhone     string
+	ShippingFirstName string
+	ShippingLastName  string
+	ShippingAddress   string
+	ShippingAddress2  string
+	ShippingCity      string
+	ShippingState     string
+	ShippingZip       string
+	ShippingCountry   string
+	Subtotal          int
+	TaxAmount         int
+	ShippingCost      int
+	Total             int
+	Status            models.OrderStatus
+	PaymentStatus     models.PaymentStatus
+	TrackingCarrier   string
+	TrackingNumber    string
+	ShippedAt         *time.Time
+	Notes             string
+	CreatedAt         time.Time
+	Items             []OrderItemView
+}
+
+type OrderItemView struct {
+	ProductName string
+	ProductSKU  string
+	UnitPrice   int
+	Quantity    int
+	Total       int
+}
+
+type UserView struct {
+	FirstName string
+	LastName  string
+	Email     string
+}
+
+type InvitationView struct {
+	Email     string
+	Role      models.UserRole
+	ExpiresAt time.Time
+}
+
+type ProductView struct {
+	Name         string
+	Slug         string
+	SKU          string
+	Price        int
+	ComparePrice *int
+	Stock        int
+	MinStock     int
+}
+
+type VariantView struct {
+	SKU   string
+	Price *int
+	Stock int
+}
+
+type AlertView struct {
+	Kind  models.ProductAlertKind
+	Email string
+	Price int
+}
+
+// NewShopView is the Shop every template gets
+func NewShopView(s *models.Settings) ShopView {
+	return ShopView{
+		ShopName:     s.ShopName,
+		Description:  s.Description,
+		Logo:         s.Logo,
+		ContactEmail: s.ContactEmail,
+		ContactPhone: s.ContactPhone,
+		Address:      s.Address,
+		City:         s.City,
+		State:        s.State,
+		ZipCode:      s.ZipCode,
+		Country:      s.Country,
+	}
+}
+
+func newOrderView(o *models.Order) OrderView {
+	view := OrderView{
+		OrderNumber:       o.OrderNumber,
+		CustomerName:      o.CustomerName,
+		CustomerEmail:     o.CustomerEmail,
+		CustomerPhone:     o.CustomerPhone,
+		ShippingFirstName: o.ShippingFirstName,
+		ShippingLastName:  o.ShippingLastName,
+		ShippingAddress:   o.ShippingAddress,
+		ShippingAddress2:  o.ShippingAddress2,
+		ShippingCity:      o.ShippingCity,
+		ShippingState:     o.ShippingState,
+		ShippingZip:       o.ShippingZip,
+		ShippingCountry:   o.ShippingCountry,
+		Subtotal:          o.Subtotal,
+		TaxAmount:         o.TaxAmount,
+		ShippingCost:      o.ShippingCost,
+		Total:             o.Total,
+		Status:            o.Status,
+		PaymentStatus:     o.PaymentStatus,
+		TrackingCarrier:   o.TrackingCarrier,
+		TrackingNumber:    o.TrackingNumber,
+		ShippedAt:         o.ShippedAt,
+		Notes:             o.Notes,
+		CreatedAt:         o.CreatedAt,
+		Items:             make([]OrderItemView, len(o.Items)),
+	}
+	for i, item := range o.Items {
+		view.Items[i] = OrderItemView{
+			ProductName: item.ProductName,
+			ProductSKU:  item.ProductSKU,
+			UnitPrice:   item.UnitPrice,
+			Quantity:    item.Quantity,
+			Total:       item.Total,
+		}
+	}
+	return view
+}
+
+func newUserView(u *models.User) UserView {
+	return UserView{FirstName: u.FirstName, LastName: u.LastName, Email: u.Email}
+}
+
+func newInvitationView(i *models.StaffInvitation) InvitationView {
+	return InvitationView{Email: i.Email, Role: i.Role, ExpiresAt: i.ExpiresAt}
+}
+
+func newProductView(p *models.Product) ProductView {
+	return ProductView{
+		Name:         p.Name,
+		Slug:         p.Slug,
+		SKU:          p.SKU,
+		Price:        p.Price,
+		ComparePrice: p.ComparePrice,
+		Stock:        p.Stock,
+		MinStock:     p.MinStock,
+	}
+}
+
+// newVariantView is nil for a nil variant, so templates can test {{if .Variant}}
+func newVariantView(v *models.ProductVariant) *VariantView {
+	if v == nil {
+		return nil
+	}
+	return &VariantView{SKU: v.SKU, Price: v.Price, Stock: v.Stock}
+}
+
+func newAlertView(a *models.ProductAlert) AlertView {
+	return AlertView{Kind: a.Kind, Email: a.Email, Price: a.Price}
+}
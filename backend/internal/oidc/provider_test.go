@@ -0,0 +1,258 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeProvider is a minimal in-process OpenID Connect provider
+type fakeProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	secret   string
+
+	mu    sync.Mutex
+	codes map[string]fakeGrant
+
+	// Overrides for negative tests
+	audience string
+	email    string
+	verified bool
+}
+
+type fakeGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	f := &fakeProvider{
+		key:      key,
+		clientID: "client-123",
+		secret:   "secret-456",
+		codes:    make(map[string]fakeGrant),
+		email:    "Jane@Example.com",
+		verified: true,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                f.server.URL,
+			AuthorizationEndpoint: f.server.URL + "/authorize",
+			TokenEndpoint:         f.server.URL + "/token",
+			JWKSURI:               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", f.handleToken)
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// authorize simulates the user signing in at the provider and returns the code
+func (f *fakeProvider) authorize(t *testing.T, authURL string) string {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("invalid authorization URL: %v", err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != f.clientID {
+		t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	code := "code-" + q.Get("state")
+	f.codes[code] = fakeGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	return code
+}
+
+func (f *fakeProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != f.clientID || secret != f.secret {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+
+	r.ParseForm()
+	f.mu.Lock()
+	grant, ok := f.codes[r.Form.Get("code")]
+	delete(f.codes, r.Form.Get("code"))
+	f.mu.Unlock()
+
+	if !ok || CodeChallenge(r.Form.Get("code_verifier")) != grant.challenge {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	audience := f.clientID
+	if f.audience != "" {
+		audience = f.audience
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            f.server.URL,
+		"sub":            "user-789",
+		"aud":            audience,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          grant.nonce,
+		"email":          f.email,
+		"email_verified": f.verified,
+		"given_name":     "Jane",
+		"family_name":    "Doe",
+	})
+	token.Header["kid"] = "test-key"
+	idToken, _ := token.SignedString(f.key)
+
+	json.NewEncoder(w).Encode(TokenResponse{
+		AccessToken: "access",
+		TokenType:   "Bearer",
+		IDToken:     idToken,
+		ExpiresIn:   3600,
+	})
+}
+
+func (f *fakeProvider) config() Config {
+	return Config{
+		Name:         "fake",
+		Issuer:       f.server.URL,
+		ClientID:     f.clientID,
+		ClientSecret: f.secret,
+		RedirectURL:  "http://localhost:3000/auth/callback/fake",
+	}
+}
+
+func signIn(t *testing.T, f *fakeProvider, provider *Provider, nonce string) (*Claims, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	verifier, _ := NewCodeVerifier()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+
+	code := f.authorize(t, authURL)
+	token, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	return provider.VerifyIDToken(ctx, token.IDToken, nonce)
+}
+
+func TestProvider_AuthorizationCodeFlow(t *testing.T) {
+	t.Run("successful sign-in", func(t *testing.T) {
+		f := newFakeProvider(t)
+		provider := NewProvider(f.config(), f.server.Client())
+
+		claims, err := signIn(t, f, provider, "nonce-1")
+		if err != nil {
+			t.Fatalf("VerifyIDToken() error = %v", err)
+		}
+		if claims.Subject != "user-789" {
+			t.Errorf("Expected subject user-789, got %q", claims.Subject)
+		}
+		if claims.Email != "Jane@Example.com" || !bool(claims.EmailVerified) {
+			t.Errorf("Expected verified email, got %q (verified=%v)", claims.Email, claims.EmailVerified)
+		}
+	})
+
+	t.Run("wrong code verifier is rejected", func(t *testing.T) {
+		f := newFakeProvider(t)
+		provider := NewProvider(f.config(), f.server.Client())
+		ctx := context.Background()
+
+		verifier, _ := NewCodeVerifier()
+		authURL, _ := provider.AuthCodeURL(ctx, "state-2", "nonce", verifier)
+		code := f.authorize(t, authURL)
+
+		otherVerifier, _ := NewCodeVerifier()
+		if _, err := provider.Exchange(ctx, code, otherVerifier); err == nil {
+			t.Error("Expected exchange with wrong verifier to fail")
+		}
+	})
+
+	t.Run("token for another client is rejected", func(t *testing.T) {
+		f := newFakeProvider(t)
+		f.audience = "someone-else"
+		provider := NewProvider(f.config(), f.server.Client())
+
+		if _, err := signIn(t, f, provider, "nonce-3"); !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("Expected ErrInvalidIDToken, got %v", err)
+		}
+	})
+
+	t.Run("nonce mismatch is rejected", func(t *testing.T) {
+		f := newFakeProvider(t)
+		provider := NewProvider(f.config(), f.server.Client())
+		ctx := context.Background()
+
+		verifier, _ := NewCodeVerifier()
+		authURL, _ := provider.AuthCodeURL(ctx, "state-4", "nonce-a", verifier)
+		code := f.authorize(t, authURL)
+		token, err := provider.Exchange(ctx, code, verifier)
+		if err != nil {
+			t.Fatalf("Exchange() error = %v", err)
+		}
+
+		if _, err := provider.VerifyIDToken(ctx, token.IDToken, "nonce-b"); !errors.Is(err, ErrNonceMismatch) {
+			t.Errorf("Expected ErrNonceMismatch, got %v", err)
+		}
+	})
+
+	t.Run("issuer mismatch fails discovery", func(t *testing.T) {
+		f := newFakeProvider(t)
+		config := f.config()
+		config.Issuer = f.server.URL + "/tenant"
+		provider := NewProvider(config, f.server.Client())
+
+		if _, err := provider.AuthCodeURL(context.Background(), "s", "n", "v"); err == nil {
+			t.Error("Expected discovery to fail for unexpected issuer")
+		}
+	})
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// Test vector from RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	if got := CodeChallenge(verifier); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("CodeChallenge() = %q", got)
+	}
+}
@@ -0,0 +1,363 @@
+// Package pagination pages list endpoints. A request either asks for a
+// numbered page (page, limit), which is counted so clients can show page
+// numbers, or continues from an opaque cursor (cursor, limit), which seeks
+// past the last row seen with the list's keyset order. Cursors skip the
+// count and the offset, so deep pages of large tables stay fast, and rows
+// inserted meanwhile never shift the next page.
+//
+// Lists respond with the same envelope, e.g.
+//
+//	{"orders": [...], "pagination": {"page": 2, "limit": 20, "total": 95, ...}}
+//
+// and a Link header with the first, prev, next and last pages.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Key is a column of a keyset order
+type Key struct {
+	Column string // Column in SQL, e.g. "created_at"
+	Field  string // Struct field holding the column's value, e.g. "CreatedAt"
+	Type   string // SQL type cursor values are cast to, e.g. "timestamptz"
+}
+
+// Newest orders rows newest first, with ties broken by ID
+var Newest = []Key{
+	{Column: "created_at", Field: "CreatedAt", Type: "timestamptz"},
+	{Column: "id", Field: "ID", Type: "uuid"},
+}
+
+// Options describe one list endpoint
+type Options struct {
+	Key          string // Name of the items in the response, e.g. "orders"
+	DefaultLimit int
+	MaxLimit     int
+
+	// Keys order the list and must be unique together. Without keys the
+	// list has numbered pages only.
+	Keys []Key
+	Desc bool
+}
+
+// NewestFirst is a list ordered by Newest
+func NewestFirst(key string, defaultLimit, maxLimit int) Options {
+	return Options{Key: key, DefaultLimit: defaultLimit, MaxLimit: maxLimit, Keys: Newest, Desc: true}
+}
+
+// Params are the pagination of one request
+type Params struct {
+	Options
+	Page   int
+	Limit  int
+	Cursor *Cursor // Set when continuing from a cursor
+}
+
+// Offset is the number of rows before a numbered page
+func (p Params) Offset() int {
+	return (p.Page - 1) * p.Limit
+}
+
+// Cursor is the keyset position of a row. Before cursors page backwards.
+type Cursor struct {
+	Values []string `json:"v"`
+	Before bool     `json:"b,omitempty"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Encode makes the cursor opaque to clients
+func (cur Cursor) Encode() string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads an encoded cursor
+func DecodeCursor(value string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cur Cursor
+	if err := json.Unmarshal(data, &cur); err != nil || len(cur.Values) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cur, nil
+}
+
+// Parse reads page, limit and cursor. Out-of-range limits fall back to the
+// default, as they always have; malformed cursors are errors.
+func Parse(c echo.Context, opts Options) (Params, error) {
+	p := Params{Options: opts, Page: 1, Limit: opts.DefaultLimit}
+	if page, _ := strconv.Atoi(c.QueryParam("page")); page > 1 {
+		p.Page = page
+	}
+	if limit, _ := strconv.Atoi(c.QueryParam("limit")); limit >= 1 && limit <= opts.MaxLimit {
+		p.Limit = limit
+	}
+
+	value := c.QueryParam("cursor")
+	if value == "" {
+		return p, nil
+	}
+	if len(opts.Keys) == 0 {
+		return p, errors.New("this list does not support cursors; use page")
+	}
+	cur, err := DecodeCursor(value)
+	if err != nil || len(cur.Values) != len(opts.Keys) {
+		return p, ErrInvalidCursor
+	}
+	for i, key := range opts.Keys {
+		if !key.valid(cur.Values[i]) {
+			return p, ErrInvalidCursor
+		}
+	}
+	p.Cursor = cur
+	return p, nil
+}
+
+// valid reports whether Postgres can cast value to the key's type, so a
+// tampered cursor is a bad request rather than a failed query
+func (key Key) valid(value string) bool {
+	switch key.Type {
+	case "timestamptz":
+		_, err := time.Parse(time.RFC3339Nano, value)
+		return err == nil
+	case "uuid":
+		_, err := uuid.Parse(value)
+		return err == nil && len(value) == 36
+	case "integer":
+		_, err := strconv.ParseInt(value, 10, 32)
+		return err == nil
+	case "bigint":
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	}
+	// Text: Postgres refuses NUL bytes and invalid UTF-8
+	return utf8.ValidString(value) && !strings.ContainsRune(value, 0)
+}
+
+// Meta is the pagination object of a response. Page, total and total pages
+// are only counted for numbered pages.
+type Meta struct {
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	Total      *int64 `json:"total,omitempty"`
+	TotalPages *int64 `json:"total_pages,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// Page is one page of a list
+type Page[T any] struct {
+	Items []T
+	Meta  Meta
+
+	key   string
+	extra map[string]interface{}
+}
+
+// Set adds a field next to the items and pagination, such as facets
+func (p *Page[T]) Set(name string, value interface{}) *Page[T] {
+	if p.extra == nil {
+		p.extra = map[string]interface{}{}
+	}
+	p.extra[name] = value
+	return p
+}
+
+func (p *Page[T]) MarshalJSON() ([]byte, error) {
+	body := make(map[string]interface{}, len(p.extra)+2)
+	for name, value := range p.extra {
+		body[name] = value
+	}
+	items := p.Items
+	if items == nil {
+		items = []T{}
+	}
+	body[p.key] = items
+	body["pagination"] = p.Meta
+	return json.Marshal(body)
+}
+
+// Map converts the items of a page, e.g. models to their responses
+func Map[T, R any](p *Page[T], convert func(T) R) *Page[R] {
+	items := make([]R, len(p.Items))
+	for i, item := range p.Items {
+		items[i] = convert(item)
+	}
+	return &Page[R]{Items: items, Meta: p.Meta, key: p.key, extra: p.extra}
+}
+
+// Find loads the requested page of query, ordered by the keys, and sets
+// the Link header
+func Find[T any](c echo.Context, query *gorm.DB, p Params) (*Page[T], error) {
+	if p.Cursor != nil {
+		return findAfter[T](c, query, p)
+	}
+
+	// Counting needs none of the preloaded associations
+	counted := query.Session(&gorm.Session{}).Model(new(T))
+	counted.Statement.Preloads = nil
+	var total int64
+	if err := counted.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	var items []T
+	if total > int64(p.Offset()) {
+		if err := query.Order(p.order(false)).Offset(p.Offset()).Limit(p.Limit).Find(&items).Error; err != nil {
+			return nil, err
+		}
+	}
+	page := OffsetPage(c, p, items, total)
+	// Clients can switch to cursors from any numbered page
+	if page.Meta.HasMore && len(p.Keys) > 0 && len(items) > 0 {
+		page.Meta.NextCursor = p.cursorOf(items[len(items)-1], false)
+		page.setLinks(c)
+	}
+	return page, nil
+}
+
+// OffsetPage is a numbered page of items found elsewhere, such as by a
+// search engine, out of total
+func OffsetPage[T any](c echo.Context, p Params, items []T, total int64) *Page[T] {
+	totalPages := (total + int64(p.Limit) - 1) / int64(p.Limit)
+	page := &Page[T]{
+		Items: items,
+		Meta: Meta{
+			Page:       p.Page,
+			Limit:      p.Limit,
+			Total:      &total,
+			TotalPages: &totalPages,
+			HasMore:    int64(p.Page) < totalPages,
+		},
+		key: p.Key,
+	}
+	page.setLinks(c)
+	return page
+}
+
+// findAfter seeks past the cursor. Before cursors are read in reverse order
+// and turned back around.
+func findAfter[T any](c echo.Context, query *gorm.DB, p Params) (*Page[T], error) {
+	before := p.Cursor.Before
+	op := ">"
+	if p.Desc != before {
+		op = "<"
+	}
+	columns := make([]string, len(p.Keys))
+	casts := make([]string, len(p.Keys))
+	args := make([]interface{}, len(p.Keys))
+	for i, key := range p.Keys {
+		columns[i] = key.Column
+		casts[i] = "CAST(? AS " + key.Type + ")"
+		args[i] = p.Cursor.Values[i]
+	}
+	where := "(" + strings.Join(columns, ", ") + ") " + op + " (" + strings.Join(casts, ", ") + ")"
+
+	var items []T
+	if err := query.Where(where, args...).Order(p.order(before)).Limit(p.Limit + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	more := len(items) > p.Limit
+	if more {
+		items = items[:p.Limit]
+	}
+	if before {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := &Page[T]{Items: items, Meta: Meta{Limit: p.Limit}, key: p.Key}
+	if len(items) > 0 {
+		// Paging forward left rows behind and paging back came from some
+		if more || before {
+			page.Meta.NextCursor = p.cursorOf(items[len(items)-1], false)
+		}
+		if more || !before {
+			page.Meta.PrevCursor = p.cursorOf(items[0], true)
+		}
+	}
+	page.Meta.HasMore = page.Meta.NextCursor != ""
+	page.setLinks(c)
+	return page, nil
+}
+
+func (p Params) order(reverse bool) string {
+	direction := " ASC"
+	if p.Desc != reverse {
+		direction = " DESC"
+	}
+	columns := make([]string, len(p.Keys))
+	for i, key := range p.Keys {
+		columns[i] = key.Column + direction
+	}
+	return strings.Join(columns, ", ")
+}
+
+// cursorOf is the cursor at item, read from the keys' struct fields
+func (p Params) cursorOf(item interface{}, before bool) string {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	values := make([]string, len(p.Keys))
+	for i, key := range p.Keys {
+		values[i] = formatValue(v.FieldByName(key.Field).Interface())
+	}
+	return Cursor{Values: values, Before: before}.Encode()
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// setLinks writes the Link header of the page, relative to the request
+func (p *Page[T]) setLinks(c echo.Context) {
+	u := *c.Request().URL
+	link := func(rel string, set map[string]string) string {
+		query := u.Query()
+		query.Del("page")
+		query.Del("cursor")
+		for name, value := range set {
+			query.Set(name, value)
+		}
+		target := url.URL{Path: u.Path, RawQuery: query.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, target.String(), rel)
+	}
+
+	links := []string{link("first", nil)}
+	if p.Meta.PrevCursor != "" {
+		links = append(links, link("prev", map[string]string{"cursor": p.Meta.PrevCursor}))
+	} else if p.Meta.Page > 1 {
+		links = append(links, link("prev", map[string]string{"page": strconv.Itoa(p.Meta.Page - 1)}))
+	}
+	if p.Meta.Page > 0 && p.Meta.HasMore {
+		links = append(links, link("next", map[string]string{"page": strconv.Itoa(p.Meta.Page + 1)}))
+	} else if p.Meta.NextCursor != "" {
+		links = append(links, link("next", map[string]string{"cursor": p.Meta.NextCursor}))
+	}
+	if p.Meta.TotalPages != nil && *p.Meta.TotalPages > 1 {
+		links = append(links, link("last", map[string]string{"page": strconv.FormatInt(*p.Meta.TotalPages, 10)}))
+	}
+	c.Response().Header().Set("Link", strings.Join(links, ", "))
+}
@@ -0,0 +1,157 @@
+package pagination
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func newContext(target string) echo.Context {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cur := Cursor{Values: []string{"2024-05-01T10:00:00.123456Z", uuid.NewString()}, Before: true}
+	decoded, err := DecodeCursor(cur.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if !decoded.Before || len(decoded.Values) != 2 || decoded.Values[1] != cur.Values[1] {
+		t.Errorf("DecodeCursor() = %+v, want %+v", decoded, cur)
+	}
+
+	for _, bad := range []string{"not base64!", "bm90IGpzb24", Cursor{}.Encode()} {
+		if _, err := DecodeCursor(bad); err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", bad, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	opts := NewestFirst("orders", 20, 100)
+
+	p, err := Parse(newContext("/orders?page=3&limit=50"), opts)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if p.Page != 3 || p.Limit != 50 || p.Offset() != 100 || p.Cursor != nil {
+		t.Errorf("Parse() = %+v", p)
+	}
+
+	p, _ = Parse(newContext("/orders?page=-1&limit=500"), opts)
+	if p.Page != 1 || p.Limit != 20 {
+		t.Errorf("out of range page and limit = %d, %d; want 1, 20", p.Page, p.Limit)
+	}
+
+	cursor := Cursor{Values: []string{"2024-05-01T10:00:00Z", uuid.NewString()}}.Encode()
+	p, err = Parse(newContext("/orders?cursor="+cursor), opts)
+	if err != nil || p.Cursor == nil {
+		t.Fatalf("Parse(cursor) = %+v, %v", p, err)
+	}
+
+	short := Cursor{Values: []string{"2024-05-01T10:00:00Z"}}.Encode()
+	if _, err := Parse(newContext("/orders?cursor="+short), opts); err != ErrInvalidCursor {
+		t.Errorf("cursor with missing keys error = %v, want ErrInvalidCursor", err)
+	}
+	if _, err := Parse(newContext("/orders?cursor="+cursor), Options{Key: "products", DefaultLimit: 12, MaxLimit: 50}); err == nil {
+		t.Error("cursor on a list without keys was accepted")
+	}
+
+	// Values Postgres could not cast would fail the query
+	for _, values := range [][]string{
+		{"yesterday", uuid.NewString()},
+		{"2024-05-01T10:00:00Z", "not-a-uuid"},
+		{"2024-05-01T10:00:00Z", "{" + uuid.NewString() + "}"},
+	} {
+		tampered := Cursor{Values: values}.Encode()
+		if _, err := Parse(newContext("/orders?cursor="+tampered), opts); err != ErrInvalidCursor {
+			t.Errorf("cursor %v error = %v, want ErrInvalidCursor", values, err)
+		}
+	}
+	ranked := Options{Key: "reviews", DefaultLimit: 10, MaxLimit: 50, Keys: []Key{
+		{Column: "helpful_count", Field: "HelpfulCount", Type: "integer"},
+		{Column: "title", Field: "Title", Type: "text"},
+	}}
+	for _, values := range [][]string{{"1.5", "a"}, {"99999999999", "a"}, {"3", "a\x00b"}} {
+		tampered := Cursor{Values: values}.Encode()
+		if _, err := Parse(newContext("/reviews?cursor="+tampered), ranked); err != ErrInvalidCursor {
+			t.Errorf("cursor %q error = %v, want ErrInvalidCursor", values, err)
+		}
+	}
+}
+
+func TestOffsetPage(t *testing.T) {
+	c := newContext("/api/v1/orders?status=paid&page=2&limit=10")
+	p, _ := Parse(c, NewestFirst("orders", 20, 100))
+
+	page := OffsetPage(c, p, []string{"a", "b"}, 35)
+	if *page.Meta.TotalPages != 4 || !page.Meta.HasMore {
+		t.Errorf("Meta = %+v, want 4 pages with more", page.Meta)
+	}
+
+	link := c.Response().Header().Get("Link")
+	for _, want := range []string{
+		`</api/v1/orders?limit=10&status=paid>; rel="first"`,
+		`</api/v1/orders?limit=10&page=1&status=paid>; rel="prev"`,
+		`</api/v1/orders?limit=10&page=3&status=paid>; rel="next"`,
+		`</api/v1/orders?limit=10&page=4&status=paid>; rel="last"`,
+	} {
+		if !strings.Contains(link, want) {
+			t.Errorf("Link = %s, missing %s", link, want)
+		}
+	}
+
+	data, err := json.Marshal(page.Set("sort", "newest"))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var body map[string]interface{}
+	json.Unmarshal(data, &body)
+	meta := body["pagination"].(map[string]interface{})
+	if len(body["orders"].([]interface{})) != 2 || body["sort"] != "newest" || meta["total"] != float64(35) || meta["page"] != float64(2) {
+		t.Errorf("body = %s", data)
+	}
+}
+
+func TestEmptyPageListsNoItems(t *testing.T) {
+	c := newContext("/api/v1/users")
+	p, _ := Parse(c, NewestFirst("users", 50, 100))
+	data, _ := json.Marshal(Map(OffsetPage[int](c, p, nil, 0), func(n int) string { return "" }))
+	if !strings.Contains(string(data), `"users":[]`) {
+		t.Errorf("body = %s, want an empty users list", data)
+	}
+	if link := c.Response().Header().Get("Link"); strings.Contains(link, "next") || strings.Contains(link, "last") {
+		t.Errorf("Link = %s, want only the first page", link)
+	}
+}
+
+func TestKeysetOrder(t *testing.T) {
+	p := Params{Options: NewestFirst("orders", 20, 100)}
+	if got := p.order(false); got != "created_at DESC, id DESC" {
+		t.Errorf("order(false) = %q", got)
+	}
+	if got := p.order(true); got != "created_at ASC, id ASC" {
+		t.Errorf("order(true) = %q", got)
+	}
+
+	type row struct {
+		ID        uuid.UUID
+		CreatedAt time.Time
+	}
+	id := uuid.New()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 123456000, time.FixedZone("CEST", 2*60*60))
+	cur, err := DecodeCursor(p.cursorOf(row{ID: id, CreatedAt: created}, true))
+	if err != nil {
+		t.Fatalf("cursorOf() did not decode: %v", err)
+	}
+	if cur.Values[0] != "2024-05-01T10:00:00.123456Z" || cur.Values[1] != id.String() || !cur.Before {
+		t.Errorf("cursorOf() = %+v", cur)
+	}
+}
@@ -0,0 +1,110 @@
+package reports
+
+import (
+	"easycart/internal/models"
+	"gorm.io/gorm"
+)
+
+// CustomerSegment is the activity of new or returning customers in a period
+type CustomerSegment struct {
+	Customers int64 `json:"customers"`
+	Orders    int64 `json:"orders"`
+	Revenue   int64 `json:"revenue"`
+}
+
+// Customers splits the period's buyers into those placing their first order
+// and those who had ordered before. Customers are told apart by email, so
+// guest orders and account orders of the same person count as one customer.
+type Customers struct {
+	Period        PeriodView      `json:"period"`
+	New           CustomerSegment `json:"new"`
+	Returning     CustomerSegment `json:"returning"`
+	ReturningRate float64         `json:"returning_rate"` // Share of customers who are returning
+}
+
+func CustomerActivity(db *gorm.DB, p Period) (*Customers, error) {
+	var rows []struct {
+		Segment   string
+		Customers int64
+		Orders    int64
+		Revenue   int64
+	}
+	err := db.Raw(`
+		WITH sales AS (
+			SELECT LOWER(o.customer_email) AS customer, o.created_at, o.total
+			FROM orders o
+			WHERE `+salesScope+`
+		), firsts AS (
+			SELECT customer, MIN(created_at) AS first_order FROM sales GROUP BY customer
+		)
+		SELECT CASE WHEN f.first_order >= ? THEN 'new' ELSE 'returning' END AS segment,
+			COUNT(DISTINCT s.customer) AS customers,
+			COUNT(*) AS orders,
+			COALESCE(SUM(s.total), 0) AS revenue
+		FROM sales s
+		JOIN firsts f ON f.customer = s.customer
+		WHERE s.created_at >= ? AND s.created_at < ?
+		GROUP BY 1`,
+		p.From, p.From, p.To,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Customers{Period: p.View()}
+	for _, row := range rows {
+		segment := CustomerSegment{Customers: row.Customers, Orders: row.Orders, Revenue: row.Revenue}
+		if row.Segment == "new" {
+			result.New = segment
+		} else {
+			result.Returning = segment
+		}
+	}
+	result.ReturningRate = Ratio(result.Returning.Customers, result.New.Customers+result.Returning.Customers)
+	return result, nil
+}
+
+// Refunds is the share of paid orders in a period that were refunded. Orders
+// are counted in the period they were placed, not when they were refunded.
+type Refunds struct {
+	Period           PeriodView `json:"period"`
+	PaidOrders       int64      `json:"paid_orders"`
+	RefundedOrders   int64      `json:"refunded_orders"`
+	PaidAmount       int64      `json:"paid_amount"`
+	RefundedAmount   int64      `json:"refunded_amount"`
+	RefundRate       float64    `json:"refund_rate"`
+	AmountRefundRate float64    `json:"amount_refund_rate"`
+}
+
+func RefundRate(db *gorm.DB, p Period) (*Refunds, error) {
+	var row struct {
+		PaidOrders     int64
+		RefundedOrders int64
+		PaidAmount     int64
+		RefundedAmount int64
+	}
+	err := db.Raw(`
+		SELECT COUNT(*) AS paid_orders,
+			COUNT(*) FILTER (WHERE o.payment_status = ?) AS refunded_orders,
+			COALESCE(SUM(o.total), 0) AS paid_amount,
+			COALESCE(SUM(o.total) FILTER (WHERE o.payment_status = ?), 0) AS refunded_amount
+		FROM orders o
+		WHERE o.payment_status IN ? AND o.created_at >= ? AND o.created_at < ?`,
+		models.PaymentStatusRefunded, models.PaymentStatusRefunded,
+		[]models.PaymentStatus{models.PaymentStatusPaid, models.PaymentStatusRefunded},
+		p.From, p.To,
+	).Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &Refunds{
+		Period:           p.View(),
+		PaidOrders:       row.PaidOrders,
+		RefundedOrders:   row.RefundedOrders,
+		PaidAmount:       row.PaidAmount,
+		RefundedAmount:   row.RefundedAmount,
+		RefundRate:       Ratio(row.RefundedOrders, row.PaidOrders),
+		AmountRefundRate: Ratio(row.RefundedAmount, row.PaidAmount),
+	}, nil
+}
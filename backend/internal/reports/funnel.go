@@ -0,0 +1,69 @@
+package reports
+
+import (
+	"easycart/internal/models"
+	"gorm.io/gorm"
+)
+
+// FunnelStage is how many orders reached a stage. Rate is relative to the
+// stage before it and Overall to the first stage.
+type FunnelStage struct {
+	Stage   string  `json:"stage"`
+	Count   int64   `json:"count"`
+	Rate    float64 `json:"rate"`
+	Overall float64 `json:"overall"`
+}
+
+type Funnel struct {
+	Period PeriodView    `json:"period"`
+	Stages []FunnelStage `json:"stages"`
+}
+
+// OrderFunnel follows the orders placed in the period through payment,
+// shipping and delivery. It starts at checkout: orders are not linked to
+// the cart they came from, so there is no added-to-cart stage.
+func OrderFunnel(db *gorm.DB, p Period) (*Funnel, error) {
+	var row struct {
+		Placed    int64
+		Paid      int64
+		Shipped   int64
+		Delivered int64
+	}
+	err := db.Raw(`
+		SELECT COUNT(*) AS placed,
+			COUNT(*) FILTER (WHERE o.payment_status IN ?) AS paid,
+			COUNT(*) FILTER (WHERE o.status IN ? OR o.shipped_at IS NOT NULL) AS shipped,
+			COUNT(*) FILTER (WHERE o.status = ?) AS delivered
+		FROM orders o
+		WHERE o.created_at >= ? AND o.created_at < ?`,
+		[]models.PaymentStatus{models.PaymentStatusPaid, models.PaymentStatusRefunded},
+		[]models.OrderStatus{models.OrderStatusShipped, models.OrderStatusDelivered},
+		models.OrderStatusDelivered,
+		p.From, p.To,
+	).Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &Funnel{
+		Period: p.View(),
+		Stages: buildFunnel([]FunnelStage{
+			{Stage: "placed", Count: row.Placed},
+			{Stage: "paid", Count: row.Paid},
+			{Stage: "shipped", Count: row.Shipped},
+			{Stage: "delivered", Count: row.Delivered},
+		}),
+	}, nil
+}
+
+func buildFunnel(stages []FunnelStage) []FunnelStage {
+	for i := range stages {
+		if i == 0 {
+			stages[i].Rate = Ratio(stages[i].Count, stages[i].Count)
+		} else {
+			stages[i].Rate = Ratio(stages[i].Count, stages[i-1].Count)
+		}
+		stages[i].Overall = Ratio(stages[i].Count, stages[0].Count)
+	}
+	return stages
+}
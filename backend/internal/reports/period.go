@@ -0,0 +1,172 @@
+// Package reports computes sales analytics for the admin dashboard from
+// orders and their items. Periods and buckets are calendar days, weeks or
+// months in the shop's time zone.
+package reports
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+type Interval string
+
+const (
+	Day   Interval = "day"
+	Week  Interval = "week" // Weeks start on Monday, as in Postgres date_trunc
+	Month Interval = "month"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	// defaultDays is the length of the period when no range is given
+	defaultDays = 30
+
+	// maxBuckets caps the series length; use a longer interval for more
+	maxBuckets = 1000
+)
+
+var (
+	ErrInvalidInterval = errors.New("interval must be day, week or month")
+	ErrInvalidDate     = errors.New("dates must be YYYY-MM-DD")
+	ErrInvalidRange    = errors.New("from must not be after to")
+	ErrRangeTooLong    = errors.New("range has too many buckets for the interval")
+)
+
+// Period is a range of whole days in a time zone, split into buckets
+type Period struct {
+	From     time.Time // Local midnight of the first day
+	To       time.Time // Local midnight after the last day (exclusive)
+	Interval Interval
+	Location *time.Location
+}
+
+// PeriodView is how a period is shown in responses, with the last day inclusive
+type PeriodView struct {
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Interval Interval `json:"interval"`
+	Timezone string   `json:"timezone"`
+}
+
+// ParsePeriod reads a from/to range of dates, both inclusive, in loc. Missing
+// bounds default to the last 30 days up to today; interval defaults to day.
+func ParsePeriod(from, to, interval string, loc *time.Location, now time.Time) (Period, error) {
+	p := Period{Interval: Interval(interval), Location: loc}
+	if p.Interval == "" {
+		p.Interval = Day
+	}
+	if p.Interval != Day && p.Interval != Week && p.Interval != Month {
+		return Period{}, ErrInvalidInterval
+	}
+
+	today := midnight(now.In(loc))
+	lastDay := today
+	if to != "" {
+		t, err := time.ParseInLocation(dateLayout, to, loc)
+		if err != nil {
+			return Period{}, ErrInvalidDate
+		}
+		lastDay = t
+	}
+	p.To = lastDay.AddDate(0, 0, 1)
+
+	if from != "" {
+		t, err := time.ParseInLocation(dateLayout, from, loc)
+		if err != nil {
+			return Period{}, ErrInvalidDate
+		}
+		p.From = t
+	} else {
+		p.From = p.To.AddDate(0, 0, -defaultDays)
+	}
+
+	if !p.From.Before(p.To) {
+		return Period{}, ErrInvalidRange
+	}
+	if len(p.Buckets()) > maxBuckets {
+		return Period{}, ErrRangeTooLong
+	}
+	return p, nil
+}
+
+// Days is the number of calendar days in the period
+func (p Period) Days() int {
+	// Rounding absorbs the 23 and 25 hour days of DST changes
+	return int(math.Round(p.To.Sub(p.From).Hours() / 24))
+}
+
+// Previous is the period of the same number of days just before p
+func (p Period) Previous() Period {
+	prev := p
+	prev.To = p.From
+	prev.From = p.From.AddDate(0, 0, -p.Days())
+	return prev
+}
+
+// Buckets returns the start of every bucket overlapping the period, formatted
+// as dates. The first may start before the period, e.g. mid-week.
+func (p Period) Buckets() []string {
+	var buckets []string
+	for b := bucketStart(p.From, p.Interval); b.Before(p.To); b = nextBucket(b, p.Interval) {
+		buckets = append(buckets, b.Format(dateLayout))
+		if len(buckets) > maxBuckets {
+			break
+		}
+	}
+	return buckets
+}
+
+func (p Period) View() PeriodView {
+	return PeriodView{
+		From:     p.From.Format(dateLayout),
+		To:       p.To.AddDate(0, 0, -1).Format(dateLayout),
+		Interval: p.Interval,
+		Timezone: p.Location.String(),
+	}
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func bucketStart(t time.Time, interval Interval) time.Time {
+	t = midnight(t)
+	switch interval {
+	case Week:
+		offset := (int(t.Weekday()) + 6) % 7 // Days since Monday
+		return t.AddDate(0, 0, -offset)
+	case Month:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+	return t
+}
+
+func nextBucket(t time.Time, interval Interval) time.Time {
+	switch interval {
+	case Week:
+		return t.AddDate(0, 0, 7)
+	case Month:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// PercentChange is the change from previous to current in percent, rounded
+// to one decimal, or nil when there is nothing to compare against
+func PercentChange(current, previous int64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := math.Round(float64(current-previous)/float64(previous)*1000) / 10
+	return &change
+}
+
+// Ratio is part/whole rounded to four decimals, or 0 when whole is 0
+func Ratio(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*10000) / 10000
+}
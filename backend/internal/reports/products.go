@@ -0,0 +1,170 @@
+package reports
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Ranking orders top product and category lists
+type Ranking string
+
+const (
+	ByRevenue Ranking = "revenue"
+	ByUnits   Ranking = "units"
+)
+
+// ProductSales is one product's sales in a period. Previous* are set when
+// compared with another period.
+type ProductSales struct {
+	ProductID       uuid.UUID `json:"product_id"`
+	Name            string    `json:"name"`
+	SKU             string    `json:"sku"`
+	Units           int64     `json:"units"`
+	Revenue         int64     `json:"revenue"`
+	Orders          int64     `json:"orders"`
+	PreviousUnits   *int64    `json:"previous_units,omitempty"`
+	PreviousRevenue *int64    `json:"previous_revenue,omitempty"`
+	RevenueChange   *float64  `json:"revenue_change,omitempty"`
+}
+
+// CategorySales is one category's sales in a period; products without a
+// category are grouped under a nil CategoryID
+type CategorySales struct {
+	CategoryID      *uuid.UUID `json:"category_id"`
+	Name            string     `json:"name"`
+	Units           int64      `json:"units"`
+	Revenue         int64      `json:"revenue"`
+	Orders          int64      `json:"orders"`
+	PreviousUnits   *int64     `json:"previous_units,omitempty"`
+	PreviousRevenue *int64     `json:"previous_revenue,omitempty"`
+	RevenueChange   *float64   `json:"revenue_change,omitempty"`
+}
+
+func (r Ranking) orderBy() string {
+	if r == ByUnits {
+		return "units DESC, revenue DESC"
+	}
+	return "revenue DESC, units DESC"
+}
+
+// TopProducts ranks the products sold in the period. Names and SKUs are the
+// ones on the order lines, so deleted products still show up.
+func TopProducts(db *gorm.DB, p Period, ranking Ranking, limit int) ([]ProductSales, error) {
+	rows := []ProductSales{}
+	err := db.Raw(`
+		SELECT oi.product_id,
+			MAX(oi.product_name) AS name,
+			MAX(oi.product_sku) AS sku,
+			COALESCE(SUM(oi.quantity), 0) AS units,
+			COALESCE(SUM(oi.total), 0) AS revenue,
+			COUNT(DISTINCT o.id) AS orders
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		WHERE `+salesScope+` AND o.created_at >= ? AND o.created_at < ?
+		GROUP BY oi.product_id
+		ORDER BY `+ranking.orderBy()+`
+		LIMIT ?`,
+		p.From, p.To, limit,
+	).Scan(&rows).Error
+	return rows, err
+}
+
+// CompareProducts fills in the previous period's figures for rows
+func CompareProducts(db *gorm.DB, previous Period, rows []ProductSales) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ProductID
+	}
+
+	var prevRows []ProductSales
+	err := db.Raw(`
+		SELECT oi.product_id, COALESCE(SUM(oi.quantity), 0) AS units, COALESCE(SUM(oi.total), 0) AS revenue
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		WHERE `+salesScope+` AND o.created_at >= ? AND o.created_at < ? AND oi.product_id IN ?
+		GROUP BY oi.product_id`,
+		previous.From, previous.To, ids,
+	).Scan(&prevRows).Error
+	if err != nil {
+		return err
+	}
+
+	byID := map[uuid.UUID]ProductSales{}
+	for _, row := range prevRows {
+		byID[row.ProductID] = row
+	}
+	for i := range rows {
+		prev := byID[rows[i].ProductID]
+		rows[i].PreviousUnits = &prev.Units
+		rows[i].PreviousRevenue = &prev.Revenue
+		rows[i].RevenueChange = PercentChange(rows[i].Revenue, prev.Revenue)
+	}
+	return nil
+}
+
+// TopCategories ranks categories by the sales of their current products
+func TopCategories(db *gorm.DB, p Period, ranking Ranking, limit int) ([]CategorySales, error) {
+	rows := []CategorySales{}
+	err := db.Raw(`
+		SELECT c.id AS category_id,
+			COALESCE(MAX(c.name), 'Uncategorized') AS name,
+			COALESCE(SUM(oi.quantity), 0) AS units,
+			COALESCE(SUM(oi.total), 0) AS revenue,
+			COUNT(DISTINCT o.id) AS orders
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		LEFT JOIN products p ON p.id = oi.product_id
+		LEFT JOIN categories c ON c.id = p.category_id
+		WHERE `+salesScope+` AND o.created_at >= ? AND o.created_at < ?
+		GROUP BY c.id
+		ORDER BY `+ranking.orderBy()+`
+		LIMIT ?`,
+		p.From, p.To, limit,
+	).Scan(&rows).Error
+	return rows, err
+}
+
+// CompareCategories fills in the previous period's figures for rows
+func CompareCategories(db *gorm.DB, previous Period, rows []CategorySales) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var prevRows []CategorySales
+	err := db.Raw(`
+		SELECT c.id AS category_id, COALESCE(SUM(oi.quantity), 0) AS units, COALESCE(SUM(oi.total), 0) AS revenue
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		LEFT JOIN products p ON p.id = oi.product_id
+		LEFT JOIN categories c ON c.id = p.category_id
+		WHERE `+salesScope+` AND o.created_at >= ? AND o.created_at < ?
+		GROUP BY c.id`,
+		previous.From, previous.To,
+	).Scan(&prevRows).Error
+	if err != nil {
+		return err
+	}
+
+	byID := map[uuid.UUID]CategorySales{}
+	for _, row := range prevRows {
+		key := uuid.Nil
+		if row.CategoryID != nil {
+			key = *row.CategoryID
+		}
+		byID[key] = row
+	}
+	for i := range rows {
+		key := uuid.Nil
+		if rows[i].CategoryID != nil {
+			key = *rows[i].CategoryID
+		}
+		prev := byID[key]
+		rows[i].PreviousUnits = &prev.Units
+		rows[i].PreviousRevenue = &prev.Revenue
+		rows[i].RevenueChange = PercentChange(rows[i].Revenue, prev.Revenue)
+	}
+	return nil
+}
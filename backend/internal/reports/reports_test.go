@@ -0,0 +1,134 @@
+package reports
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestParsePeriod(t *testing.T) {
+	loc := mustLocation(t, "Europe/Berlin")
+	// 23:30 UTC on Jan 31 is already Feb 1 in Berlin
+	now := time.Date(2024, 1, 31, 23, 30, 0, 0, time.UTC)
+
+	p, err := ParsePeriod("", "", "", loc, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := p.View()
+	if view.From != "2024-01-03" || view.To != "2024-02-01" || view.Interval != Day {
+		t.Errorf("unexpected default period %+v", view)
+	}
+	if p.Days() != 30 {
+		t.Errorf("Days() = %d, want 30", p.Days())
+	}
+	if !p.From.Equal(time.Date(2024, 1, 2, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("From = %v, want local midnight", p.From.UTC())
+	}
+
+	if _, err := ParsePeriod("2024-02-10", "2024-02-01", "day", loc, now); err != ErrInvalidRange {
+		t.Errorf("expected ErrInvalidRange, got %v", err)
+	}
+	if _, err := ParsePeriod("2024-02-01", "", "hour", loc, now); err != ErrInvalidInterval {
+		t.Errorf("expected ErrInvalidInterval, got %v", err)
+	}
+	if _, err := ParsePeriod("02/01/2024", "", "day", loc, now); err != ErrInvalidDate {
+		t.Errorf("expected ErrInvalidDate, got %v", err)
+	}
+	if _, err := ParsePeriod("2000-01-01", "2024-01-01", "day", loc, now); err != ErrRangeTooLong {
+		t.Errorf("expected ErrRangeTooLong, got %v", err)
+	}
+	if _, err := ParsePeriod("2000-01-01", "2024-01-01", "month", loc, now); err != nil {
+		t.Errorf("monthly buckets over 24 years should be allowed: %v", err)
+	}
+}
+
+func TestPeriodPrevious(t *testing.T) {
+	loc := mustLocation(t, "Europe/Berlin")
+	// Spans the switch to summer time on March 31
+	p, err := ParsePeriod("2024-03-25", "2024-04-07", "day", loc, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := p.Previous().View()
+	if prev.From != "2024-03-11" || prev.To != "2024-03-24" {
+		t.Errorf("unexpected previous period %+v", prev)
+	}
+}
+
+func TestPeriodBuckets(t *testing.T) {
+	tests := []struct {
+		from, to string
+		interval Interval
+		want     []string
+	}{
+		{"2024-03-30", "2024-04-01", Day, []string{"2024-03-30", "2024-03-31", "2024-04-01"}},
+		// Weeks start on Monday; Jan 3 2024 is a Wednesday
+		{"2024-01-03", "2024-01-15", Week, []string{"2024-01-01", "2024-01-08", "2024-01-15"}},
+		{"2024-01-31", "2024-03-01", Month, []string{"2024-01-01", "2024-02-01", "2024-03-01"}},
+	}
+	loc := mustLocation(t, "Europe/Berlin")
+	for _, tt := range tests {
+		p, err := ParsePeriod(tt.from, tt.to, string(tt.interval), loc, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Buckets(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s buckets %s..%s = %v, want %v", tt.interval, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestBuildSales(t *testing.T) {
+	p, err := ParsePeriod("2024-01-01", "2024-01-03", "day", time.UTC, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sales := buildSales(p, map[string]*SalesTotals{
+		"2024-01-01": {Orders: 2, Revenue: 3000, Units: 5},
+		"2024-01-03": {Orders: 1, Revenue: 1000, Refunded: 1000, Units: 1},
+	})
+
+	if len(sales.Series) != 3 || sales.Series[1].Orders != 0 {
+		t.Fatalf("expected an empty middle bucket, got %+v", sales.Series)
+	}
+	if sales.Series[0].AverageOrderValue != 1500 {
+		t.Errorf("bucket AOV = %d, want 1500", sales.Series[0].AverageOrderValue)
+	}
+	want := SalesTotals{Revenue: 4000, Refunded: 1000, NetRevenue: 3000, Orders: 3, AverageOrderValue: 1333, Units: 6}
+	if sales.Totals != want {
+		t.Errorf("totals = %+v, want %+v", sales.Totals, want)
+	}
+}
+
+func TestPercentChange(t *testing.T) {
+	if PercentChange(150, 0) != nil {
+		t.Error("change from zero should be nil")
+	}
+	if got := *PercentChange(150, 120); got != 25 {
+		t.Errorf("PercentChange(150, 120) = %v, want 25", got)
+	}
+	if got := *PercentChange(1, 3); got != -66.7 {
+		t.Errorf("PercentChange(1, 3) = %v, want -66.7", got)
+	}
+}
+
+func TestBuildFunnel(t *testing.T) {
+	stages := buildFunnel([]FunnelStage{
+		{Stage: "placed", Count: 10},
+		{Stage: "paid", Count: 8},
+		{Stage: "shipped", Count: 4},
+	})
+	if stages[0].Rate != 1 || stages[1].Rate != 0.8 || stages[2].Rate != 0.5 || stages[2].Overall != 0.4 {
+		t.Errorf("unexpected funnel %+v", stages)
+	}
+}
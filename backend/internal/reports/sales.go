@@ -0,0 +1,140 @@
+package reports
+
+import (
+	"easycart/internal/models"
+	"gorm.io/gorm"
+)
+
+// salesScope selects the orders that count as sales: everything placed except
+// cancelled orders and failed payments. Refunded orders stay in revenue and
+// are reported separately, so net revenue is revenue minus refunded.
+const salesScope = "o.status <> '" + string(models.OrderStatusCancelled) + "' AND o.payment_status <> '" + string(models.PaymentStatusFailed) + "'"
+
+// bucketColumn formats the local start of an order's bucket as a date; its
+// arguments are the interval and the time zone name
+const bucketColumn = "to_char(date_trunc(?, o.created_at AT TIME ZONE ?), 'YYYY-MM-DD')"
+
+// SalesTotals are in cents, except the counts
+type SalesTotals struct {
+	Revenue           int64 `json:"revenue"`
+	Refunded          int64 `json:"refunded"`
+	NetRevenue        int64 `json:"net_revenue"`
+	Orders            int64 `json:"orders"`
+	AverageOrderValue int64 `json:"average_order_value"`
+	Units             int64 `json:"units"`
+}
+
+func (t *SalesTotals) add(other SalesTotals) {
+	t.Revenue += other.Revenue
+	t.Refunded += other.Refunded
+	t.Orders += other.Orders
+	t.Units += other.Units
+	t.finish()
+}
+
+func (t *SalesTotals) finish() {
+	t.NetRevenue = t.Revenue - t.Refunded
+	t.AverageOrderValue = 0
+	if t.Orders > 0 {
+		t.AverageOrderValue = t.Revenue / t.Orders
+	}
+}
+
+// SalesPoint is one bucket of the series; Bucket is its first day
+type SalesPoint struct {
+	Bucket string `json:"bucket"`
+	SalesTotals
+}
+
+type Sales struct {
+	Period PeriodView   `json:"period"`
+	Totals SalesTotals  `json:"totals"`
+	Series []SalesPoint `json:"series"`
+}
+
+// SalesChange is the percent change of each total against a previous period
+type SalesChange struct {
+	Revenue           *float64 `json:"revenue"`
+	NetRevenue        *float64 `json:"net_revenue"`
+	Orders            *float64 `json:"orders"`
+	AverageOrderValue *float64 `json:"average_order_value"`
+	Units             *float64 `json:"units"`
+}
+
+func CompareSales(current, previous SalesTotals) SalesChange {
+	return SalesChange{
+		Revenue:           PercentChange(current.Revenue, previous.Revenue),
+		NetRevenue:        PercentChange(current.NetRevenue, previous.NetRevenue),
+		Orders:            PercentChange(current.Orders, previous.Orders),
+		AverageOrderValue: PercentChange(current.AverageOrderValue, previous.AverageOrderValue),
+		Units:             PercentChange(current.Units, previous.Units),
+	}
+}
+
+// SalesOverTime returns revenue, orders, average order value and units sold
+// in each bucket of the period, including empty buckets
+func SalesOverTime(db *gorm.DB, p Period) (*Sales, error) {
+	var orderRows []struct {
+		Bucket   string
+		Orders   int64
+		Revenue  int64
+		Refunded int64
+	}
+	err := db.Raw(`
+		SELECT `+bucketColumn+` AS bucket,
+			COUNT(*) AS orders,
+			COALESCE(SUM(o.total), 0) AS revenue,
+			COALESCE(SUM(o.total) FILTER (WHERE o.payment_status = ?), 0) AS refunded
+		FROM orders o
+		WHERE `+salesScope+` AND o.created_at >= ? AND o.created_at < ?
+		GROUP BY 1`,
+		string(p.Interval), p.Location.String(), models.PaymentStatusRefunded, p.From, p.To,
+	).Scan(&orderRows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var unitRows []struct {
+		Bucket string
+		Units  int64
+	}
+	err = db.Raw(`
+		SELECT `+bucketColumn+` AS bucket, COALESCE(SUM(oi.quantity), 0) AS units
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		WHERE `+salesScope+` AND o.created_at >= ? AND o.created_at < ?
+		GROUP BY 1`,
+		string(p.Interval), p.Location.String(), p.From, p.To,
+	).Scan(&unitRows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byBucket := map[string]*SalesTotals{}
+	for _, row := range orderRows {
+		byBucket[row.Bucket] = &SalesTotals{Orders: row.Orders, Revenue: row.Revenue, Refunded: row.Refunded}
+	}
+	for _, row := range unitRows {
+		if totals, ok := byBucket[row.Bucket]; ok {
+			totals.Units = row.Units
+		}
+	}
+
+	return buildSales(p, byBucket), nil
+}
+
+// buildSales fills in empty buckets and adds up the totals
+func buildSales(p Period, byBucket map[string]*SalesTotals) *Sales {
+	sales := &Sales{Period: p.View(), Series: []SalesPoint{}}
+	for _, bucket := range p.Buckets() {
+		point := SalesPoint{Bucket: bucket}
+		if totals, ok := byBucket[bucket]; ok {
+			point.SalesTotals = *totals
+		}
+		point.finish()
+		sales.Series = append(sales.Series, point)
+		sales.Totals.add(point.SalesTotals)
+	}
+	sales.Totals.finish()
+	return sales
+}
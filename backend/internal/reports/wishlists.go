@@ -0,0 +1,40 @@
+package reports
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WishlistedProduct is how often a product was saved to wishlists
+type WishlistedProduct struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Name      string    `json:"name"`
+	SKU       string    `json:"sku"`
+	Added     int64     `json:"added"`     // Times added in the period
+	Customers int64     `json:"customers"` // Customers who added it in the period
+	Saved     int64     `json:"saved"`     // Wishlists holding it now
+}
+
+// MostWishlisted ranks the products added to wishlists in the period, by
+// the customers who added them. Items since removed or moved to a cart are
+// not counted.
+func MostWishlisted(db *gorm.DB, p Period, limit int) ([]WishlistedProduct, error) {
+	rows := []WishlistedProduct{}
+	err := db.Raw(`
+		SELECT wi.product_id,
+			MAX(pr.name) AS name,
+			MAX(pr.sku) AS sku,
+			COUNT(*) FILTER (WHERE wi.created_at >= ? AND wi.created_at < ?) AS added,
+			COUNT(DISTINCT w.user_id) FILTER (WHERE wi.created_at >= ? AND wi.created_at < ?) AS customers,
+			COUNT(DISTINCT wi.wishlist_id) AS saved
+		FROM wishlist_items wi
+		JOIN wishlists w ON w.id = wi.wishlist_id
+		JOIN products pr ON pr.id = wi.product_id
+		GROUP BY wi.product_id
+		HAVING COUNT(*) FILTER (WHERE wi.created_at >= ? AND wi.created_at < ?) > 0
+		ORDER BY customers DESC, added DESC, saved DESC
+		LIMIT ?`,
+		p.From, p.To, p.From, p.To, p.From, p.To, limit,
+	).Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,255 @@
+package search
+
+import (
+	"sort"
+	"strings"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Keys of the conditions, so a facet can leave its own filter out
+const (
+	keyCategory = "category"
+	keyPrice    = "price"
+	keyInStock  = "in_stock"
+	keyFeatured = "featured"
+	keyOnSale   = "on_sale"
+	keyTags     = "tags"
+
+	// A collection is the set being browsed, so no facet leaves it out
+	keyCollection = "collection"
+)
+
+func optionKey(name string) string {
+	return "option:" + name
+}
+
+func attributeKey(code string) string {
+	return "attribute:" + code
+}
+
+// attributeValueSQL selects the attribute_values row giving a product its
+// value of an attribute: its own, else that of its nearest category with
+// one. It takes the attribute ID.
+const attributeValueSQL = `(SELECT av.id FROM attribute_values av
+	LEFT JOIN categories avc ON avc.id = av.category_id
+	WHERE av.attribute_id = ? AND (av.product_id = products.id OR
+		(SELECT own.path FROM categories own WHERE own.id = products.category_id) LIKE avc.path || '%')
+	ORDER BY av.product_id IS NULL, avc.depth DESC LIMIT 1)`
+
+// condition is one SQL condition on the products table
+type condition struct {
+	key  string
+	sql  string
+	args []interface{}
+}
+
+// filterSet is the SQL for a query's text and filters
+type filterSet struct {
+	text       *condition
+	conditions []condition
+	options    map[string][]string // Lower-cased names to lower-cased values
+	manual     *uuid.UUID          // The manual collection, for SortManual
+}
+
+func newFilterSet(f Filters) *filterSet {
+	s := &filterSet{options: map[string][]string{}}
+	add := func(key, sql string, args ...interface{}) {
+		s.conditions = append(s.conditions, condition{key: key, sql: sql, args: args})
+	}
+
+	if f.CategoryID != nil {
+		add(keyCategory, "products.category_id IN ("+models.CategorySubtreeSQL+")", *f.CategoryID)
+	}
+	switch {
+	case f.MinPrice != nil && f.MaxPrice != nil:
+		add(keyPrice, "products.price BETWEEN ? AND ?", *f.MinPrice, *f.MaxPrice)
+	case f.MinPrice != nil:
+		add(keyPrice, "products.price >= ?", *f.MinPrice)
+	case f.MaxPrice != nil:
+		add(keyPrice, "products.price <= ?", *f.MaxPrice)
+	}
+	if f.InStock {
+		add(keyInStock, "products.stock > 0")
+	}
+	if f.Featured {
+		add(keyFeatured, "products.is_featured = true")
+	}
+	if f.OnSale {
+		add(keyOnSale, "products.compare_price > products.price")
+	}
+	if tags := lowerAll(f.Tags); len(tags) > 0 {
+		add(keyTags, "EXISTS (SELECT 1 FROM product_tags ft WHERE ft.product_id = products.id AND lower(ft.tag) IN ?)", tags)
+	}
+	if f.Collection != nil {
+		s.addCollection(f.Collection, add)
+	}
+	for _, filter := range f.Attributes {
+		if sql, args := attributeMatch(filter); sql != "" {
+			add(attributeKey(filter.Attribute.Code),
+				"EXISTS (SELECT 1 FROM attribute_values fav WHERE fav.id = "+attributeValueSQL+" AND "+sql+")",
+				append([]interface{}{filter.Attribute.ID}, args...)...)
+		}
+	}
+	for name, values := range f.Options {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if values := lowerAll(values); name != "" && len(values) > 0 {
+			s.options[name] = append(s.options[name], values...)
+		}
+	}
+	return s
+}
+
+// addCollection limits the products to a collection: the members of a
+// manual one, or the matches of an automatic one's rules
+func (s *filterSet) addCollection(c *models.Collection, add func(key, sql string, args ...interface{})) {
+	if c.Type == models.CollectionManual {
+		add(keyCollection, "EXISTS (SELECT 1 FROM collection_products cp WHERE cp.product_id = products.id AND cp.collection_id = ?)", c.ID)
+		id := c.ID
+		s.manual = &id
+		return
+	}
+
+	r := c.Rules
+	if r.MinPrice != nil {
+		add(keyCollection, "products.price >= ?", *r.MinPrice)
+	}
+	if r.MaxPrice != nil {
+		add(keyCollection, "products.price <= ?", *r.MaxPrice)
+	}
+	if r.CategoryID != nil {
+		add(keyCollection, "products.category_id IN ("+models.CategorySubtreeSQL+")", *r.CategoryID)
+	}
+	if tags := lowerAll(r.Tags); len(tags) > 0 {
+		add(keyCollection, "EXISTS (SELECT 1 FROM product_tags ct WHERE ct.product_id = products.id AND lower(ct.tag) IN ?)", tags)
+	}
+	if r.OnSale {
+		add(keyCollection, "products.compare_price > products.price")
+	}
+	if r.CreatedAfter != nil {
+		add(keyCollection, "products.created_at >= ?", *r.CreatedAfter)
+	}
+	if r.InStock {
+		add(keyCollection, "products.stock > 0")
+	}
+}
+
+// attributeMatch is the condition on the value row fav of an attribute filter
+func attributeMatch(f AttributeFilter) (string, []interface{}) {
+	var parts []string
+	var args []interface{}
+	if values := lowerAll(f.Values); len(values) > 0 {
+		parts = append(parts, "lower(fav.text_value) IN ?")
+		args = append(args, values)
+	}
+	if f.Boolean != nil {
+		parts = append(parts, "fav.bool_value = ?")
+		args = append(args, *f.Boolean)
+	}
+	if f.Min != nil {
+		parts = append(parts, "fav.number_value >= ?")
+		args = append(args, *f.Min)
+	}
+	if f.Max != nil {
+		parts = append(parts, "fav.number_value <= ?")
+		args = append(args, *f.Max)
+	}
+	return strings.Join(parts, " AND "), args
+}
+
+// apply adds every condition but the one with key skip to query
+func (s *filterSet) apply(query *gorm.DB, skip string) *gorm.DB {
+	query = query.Where("products.is_active = true")
+	if s.text != nil {
+		query = query.Where(s.text.sql, s.text.args...)
+	}
+	for _, c := range s.conditions {
+		if c.key != skip {
+			query = query.Where(c.sql, c.args...)
+		}
+	}
+	if c, ok := s.variantCondition("products.id", skip); ok {
+		query = query.Where(c.sql, c.args...)
+	}
+	return query
+}
+
+// variantCondition requires an active variant of product that has a
+// chosen value of each filtered option
+func (s *filterSet) variantCondition(product, skip string) (condition, bool) {
+	sql, args := s.optionMatch("fv.id", skip)
+	if sql == "" {
+		return condition{}, false
+	}
+	return condition{
+		sql:  "EXISTS (SELECT 1 FROM product_variants fv WHERE fv.product_id = " + product + " AND fv.is_active = true AND " + sql + ")",
+		args: args,
+	}, true
+}
+
+// optionMatch requires the variant to have a chosen value of each filtered
+// option except skip
+func (s *filterSet) optionMatch(variant, skip string) (string, []interface{}) {
+	var parts []string
+	var args []interface{}
+	for _, name := range s.optionNames() {
+		if optionKey(name) == skip {
+			continue
+		}
+		parts = append(parts, `EXISTS (SELECT 1 FROM product_variant_option_values fvov
+	JOIN product_option_values fov ON fov.id = fvov.option_value_id
+	JOIN product_options fo ON fo.id = fov.option_id
+	WHERE fvov.variant_id = `+variant+` AND lower(fo.name) = ? AND lower(fov.value) IN ?)`)
+		args = append(args, name, s.options[name])
+	}
+	return strings.Join(parts, " AND "), args
+}
+
+func (s *filterSet) optionNames() []string {
+	names := make([]string, 0, len(s.options))
+	for name := range s.options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortOrder is the ORDER BY of a sort, with ties broken by ID so pages
+// never overlap
+func sortOrder(sort Sort, text *condition, rank string) (string, []interface{}) {
+	switch sort {
+	case SortRelevance:
+		if text != nil {
+			return rank + " DESC, products.created_at DESC, products.id", text.args
+		}
+	case SortPriceAsc:
+		return "products.price ASC, products.id", nil
+	case SortPriceDesc:
+		return "products.price DESC, products.id", nil
+	case SortName:
+		return "lower(products.name) ASC, products.id", nil
+	case SortBestSelling:
+		return `(SELECT COALESCE(SUM(oi.quantity), 0) FROM order_items oi JOIN orders o ON o.id = oi.order_id
+	WHERE oi.product_id = products.id AND o.status <> ?) DESC, products.created_at DESC, products.id`,
+			[]interface{}{models.OrderStatusCancelled}
+	}
+	return "products.created_at DESC, products.id", nil
+}
+
+// manualOrder is the merchant's order of a manual collection
+func manualOrder(collectionID uuid.UUID) (string, []interface{}) {
+	return "(SELECT cp.position FROM collection_products cp WHERE cp.collection_id = ? AND cp.product_id = products.id) ASC, products.id",
+		[]interface{}{collectionID}
+}
+
+func lowerAll(values []string) []string {
+	var lowered []string
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			lowered = append(lowered, value)
+		}
+	}
+	return lowered
+}
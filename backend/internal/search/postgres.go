@@ -0,0 +1,341 @@
+package search
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// categorySuggestions caps the categories suggested next to products
+	categorySuggestions = 3
+	// tagFacets caps the tags, and the values of each attribute, counted in
+	// facets, most used first
+	tagFacets = 30
+)
+
+// Postgres searches the products table's search_vector, kept current by the
+// triggers of database.SetupProductSearch. Queries that match no words fall
+// back to pg_trgm similarity of product names, which forgives typos.
+type Postgres struct {
+	db *gorm.DB
+}
+
+func NewPostgres(db *gorm.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// Full-text and fuzzy matches with the expressions that rank them
+const (
+	fullTextMatch = "products.search_vector @@ websearch_to_tsquery('english', ?)"
+	fullTextRank  = "ts_rank(products.search_vector, websearch_to_tsquery('english', ?))"
+
+	// The operators use pg_trgm's thresholds and, unlike the functions, the
+	// trigram index: % compares whole names, <% finds the text within one
+	fuzzyMatch = "(products.name % ? OR ? <% products.name)"
+	fuzzyRank  = "GREATEST(similarity(products.name, ?), word_similarity(?, products.name))"
+)
+
+func (p *Postgres) Search(ctx context.Context, q Query) (*Result, error) {
+	db := p.db.WithContext(ctx)
+	filters := newFilterSet(q.Filters)
+	products := func() *gorm.DB { return db.Table("products") }
+	result := &Result{IDs: []uuid.UUID{}}
+
+	rank := ""
+	if text := strings.TrimSpace(q.Text); text != "" {
+		filters.text = &condition{sql: fullTextMatch, args: []interface{}{text}}
+		rank = fullTextRank
+		if err := filters.apply(products(), "").Count(&result.Total).Error; err != nil {
+			return nil, err
+		}
+		if result.Total == 0 {
+			fuzzy := &condition{sql: fuzzyMatch, args: []interface{}{text, text}}
+			if err := filters.apply(products(), "").Where(fuzzy.sql, fuzzy.args...).Count(&result.Total).Error; err != nil {
+				return nil, err
+			}
+			if result.Total > 0 {
+				filters.text, rank, result.Fuzzy = fuzzy, fuzzyRank, true
+			}
+		}
+	} else if err := filters.apply(products(), "").Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	if result.Total > 0 {
+		order := q.Sort
+		if order == "" {
+			order = SortRelevance
+		}
+		sql, args := sortOrder(order, filters.text, rank)
+		if order == SortManual && filters.manual != nil {
+			sql, args = manualOrder(*filters.manual)
+		}
+		err := filters.apply(products(), "").Order(orderBy(sql, args...)).
+			Offset(q.Offset).Limit(q.Limit).Pluck("products.id", &result.IDs).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if q.Facets {
+		facets, err := p.facets(db, filters)
+		if err != nil {
+			return nil, err
+		}
+		result.Facets = facets
+	}
+	return result, nil
+}
+
+func (p *Postgres) facets(db *gorm.DB, filters *filterSet) (*Facets, error) {
+	facets := &Facets{Categories: []CategoryFacet{}, Options: []OptionFacet{}, Tags: []ValueFacet{}, Attributes: []AttributeFacet{}}
+	products := func(skip string) *gorm.DB { return filters.apply(db.Table("products"), skip) }
+
+	err := products(keyCategory).
+		Select("categories.id, categories.name, categories.slug, COUNT(*) AS count").
+		Joins("JOIN categories ON categories.id = products.category_id AND categories.is_active = true").
+		Group("categories.id, categories.name, categories.slug").
+		Order("count DESC, categories.name ASC").Scan(&facets.Categories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = products(keyPrice).
+		Select("COALESCE(MIN(products.price), 0) AS min, COALESCE(MAX(products.price), 0) AS max").
+		Scan(&facets.Price).Error
+	if err != nil {
+		return nil, err
+	}
+
+	toggles := []struct {
+		key   string
+		sql   string
+		count *int64
+	}{
+		{keyInStock, "products.stock > 0", &facets.InStock},
+		{keyFeatured, "products.is_featured = true", &facets.Featured},
+		{keyOnSale, "products.compare_price > products.price", &facets.OnSale},
+	}
+	for _, toggle := range toggles {
+		if err := products(toggle.key).Where(toggle.sql).Count(toggle.count).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	err = products(keyTags).
+		Select("MIN(t.tag) AS value, COUNT(DISTINCT products.id) AS count").
+		Joins("JOIN product_tags t ON t.product_id = products.id").
+		Group("lower(t.tag)").Order("count DESC, value ASC").Limit(tagFacets).
+		Scan(&facets.Tags).Error
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := p.optionFacets(db, filters)
+	if err != nil {
+		return nil, err
+	}
+	facets.Options = options
+
+	attributes, err := p.attributeFacets(db, filters)
+	if err != nil {
+		return nil, err
+	}
+	facets.Attributes = attributes
+	return facets, nil
+}
+
+// attributeFacets counts the values of each filterable attribute, or finds
+// the range of number ones, without the attribute's own filter
+func (p *Postgres) attributeFacets(db *gorm.DB, filters *filterSet) ([]AttributeFacet, error) {
+	var definitions []models.AttributeDefinition
+	if err := db.Where("filterable = true").Order("position ASC, name ASC").Find(&definitions).Error; err != nil {
+		return nil, err
+	}
+
+	facets := []AttributeFacet{}
+	for _, definition := range definitions {
+		facet := AttributeFacet{Code: definition.Code, Name: definition.Name, Type: definition.Type, Unit: definition.Unit}
+		query := filters.apply(db.Table("products"), attributeKey(definition.Code)).
+			Joins("JOIN attribute_values fa ON fa.id = "+attributeValueSQL, definition.ID)
+
+		switch definition.Type {
+		case models.AttributeNumber:
+			var r struct {
+				Count int64
+				Min   float64
+				Max   float64
+			}
+			err := query.Select("COUNT(*) AS count, COALESCE(MIN(fa.number_value), 0) AS min, COALESCE(MAX(fa.number_value), 0) AS max").
+				Scan(&r).Error
+			if err != nil {
+				return nil, err
+			}
+			if r.Count == 0 {
+				continue
+			}
+			facet.Range = &RangeFacet{Min: r.Min, Max: r.Max}
+		case models.AttributeBoolean:
+			err := query.Select("fa.bool_value::text AS value, COUNT(*) AS count").
+				Group("fa.bool_value").Order("count DESC, value ASC").Scan(&facet.Values).Error
+			if err != nil {
+				return nil, err
+			}
+		default:
+			err := query.Select("MIN(fa.text_value) AS value, COUNT(*) AS count").
+				Group("lower(fa.text_value)").Order("count DESC, value ASC").Limit(tagFacets).
+				Scan(&facet.Values).Error
+			if err != nil {
+				return nil, err
+			}
+		}
+		if facet.Range == nil && len(facet.Values) == 0 {
+			continue
+		}
+		facets = append(facets, facet)
+	}
+	return facets, nil
+}
+
+// optionFacets counts products by the option values of their active
+// variants. Filtered options are counted apart, each without its own
+// filter; the other options are counted together with every filter.
+func (p *Postgres) optionFacets(db *gorm.DB, filters *filterSet) ([]OptionFacet, error) {
+	type row struct {
+		Name  string
+		Value string
+		Count int64
+	}
+	count := func(skip string, names func(*gorm.DB) *gorm.DB) ([]row, error) {
+		query := filters.apply(db.Table("products"), skip).
+			Select("MIN(o.name) AS name, MIN(ov.value) AS value, COUNT(DISTINCT products.id) AS count").
+			Joins("JOIN product_variants v ON v.product_id = products.id AND v.is_active = true").
+			Joins("JOIN product_variant_option_values vov ON vov.variant_id = v.id").
+			Joins("JOIN product_option_values ov ON ov.id = vov.option_value_id").
+			Joins("JOIN product_options o ON o.id = ov.option_id")
+		// The counted variant itself must have the other chosen values
+		if sql, args := filters.optionMatch("v.id", skip); sql != "" {
+			query = query.Where(sql, args...)
+		}
+		var rows []row
+		err := names(query).Group("lower(o.name), lower(ov.value)").Scan(&rows).Error
+		return rows, err
+	}
+
+	filtered := filters.optionNames()
+	rows, err := count("", func(query *gorm.DB) *gorm.DB {
+		if len(filtered) > 0 {
+			query = query.Where("lower(o.name) NOT IN ?", filtered)
+		}
+		return query
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range filtered {
+		name := name
+		more, err := count(optionKey(name), func(query *gorm.DB) *gorm.DB {
+			return query.Where("lower(o.name) = ?", name)
+		})
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, more...)
+	}
+
+	facets := []OptionFacet{}
+	index := map[string]int{}
+	for _, r := range rows {
+		key := strings.ToLower(r.Name)
+		i, ok := index[key]
+		if !ok {
+			i = len(facets)
+			index[key] = i
+			facets = append(facets, OptionFacet{Name: r.Name})
+		}
+		facets[i].Values = append(facets[i].Values, ValueFacet{Value: r.Value, Count: r.Count})
+	}
+	sort.Slice(facets, func(i, j int) bool { return strings.ToLower(facets[i].Name) < strings.ToLower(facets[j].Name) })
+	for _, facet := range facets {
+		values := facet.Values
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+	}
+	return facets, nil
+}
+
+func (p *Postgres) Suggest(ctx context.Context, text string, limit int) (*Suggestions, error) {
+	suggestions := &Suggestions{Products: []ProductSuggestion{}, Categories: []CategorySuggestion{}}
+	words := Words(text)
+	if len(words) == 0 {
+		return suggestions, nil
+	}
+	db := p.db.WithContext(ctx)
+
+	// Every word may be the start of a longer one
+	prefix := PrefixQuery(words)
+	err := db.Model(&models.Product{}).Select("id, name, slug").
+		Where("is_active = true AND search_vector @@ to_tsquery('english', ?)", prefix).
+		Order(orderBy("ts_rank(search_vector, to_tsquery('english', ?)) DESC, name ASC", prefix)).
+		Limit(limit).Scan(&suggestions.Products).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(suggestions.Products) < limit {
+		phrase := strings.Join(words, " ")
+		query := db.Model(&models.Product{}).Select("id, name, slug").
+			Where("is_active = true AND ? <% name", phrase)
+		if len(suggestions.Products) > 0 {
+			ids := make([]uuid.UUID, len(suggestions.Products))
+			for i, product := range suggestions.Products {
+				ids[i] = product.ID
+			}
+			query = query.Where("id NOT IN ?", ids)
+		}
+		var similar []ProductSuggestion
+		err := query.Order(orderBy("word_similarity(?, name) DESC, name ASC", phrase)).
+			Limit(limit - len(suggestions.Products)).Scan(&similar).Error
+		if err != nil {
+			return nil, err
+		}
+		suggestions.Products = append(suggestions.Products, similar...)
+	}
+
+	err = db.Model(&models.Category{}).Select("id, name, slug").
+		Where("is_active = true AND name ILIKE ?", escapeLike(strings.TrimSpace(text))+"%").
+		Order("name ASC").Limit(categorySuggestions).Scan(&suggestions.Categories).Error
+	if err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// PrefixQuery builds a tsquery matching words that start with each of words
+func PrefixQuery(words []string) string {
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = word + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
+
+func orderBy(sql string, vars ...interface{}) clause.OrderBy {
+	return clause.OrderBy{Expression: clause.Expr{SQL: sql, Vars: vars, WithoutParentheses: true}}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(text string) string {
+	return likeEscaper.Replace(text)
+}
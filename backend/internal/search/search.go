@@ -0,0 +1,177 @@
+// Package search finds storefront products for shopper queries. Handlers use
+// the Engine interface; Postgres is the built-in engine.
+package search
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+)
+
+// Engine runs product searches and storefront listings
+type Engine interface {
+	// Search returns one page of active products matching q
+	Search(ctx context.Context, q Query) (*Result, error)
+	// Suggest completes a partly typed query
+	Suggest(ctx context.Context, text string, limit int) (*Suggestions, error)
+}
+
+// Sort orders search results
+type Sort string
+
+const (
+	SortRelevance   Sort = "relevance" // The default with search text
+	SortNewest      Sort = "newest"    // The default without
+	SortPriceAsc    Sort = "price_asc"
+	SortPriceDesc   Sort = "price_desc"
+	SortName        Sort = "name"
+	SortBestSelling Sort = "best_selling"
+	SortManual      Sort = "manual" // A manual collection's order
+)
+
+// ParseSort reads a sort parameter, reporting unknown values
+func ParseSort(value string) (Sort, bool) {
+	switch sort := Sort(value); sort {
+	case SortRelevance, SortNewest, SortPriceAsc, SortPriceDesc, SortName, SortBestSelling, SortManual:
+		return sort, true
+	}
+	return "", false
+}
+
+// Query is a product search. Text may be empty to list products.
+type Query struct {
+	Text    string
+	Filters Filters
+	Sort    Sort
+	Offset  int
+	Limit   int
+
+	// Facets asks for the counts of each filter value
+	Facets bool
+}
+
+// Filters narrow a search. Values of one option or of tags match any of
+// them; different filters must all match.
+type Filters struct {
+	CategoryID *uuid.UUID // Includes the categories below it
+	MinPrice   *int       // In cents
+	MaxPrice   *int
+	InStock    bool
+	Featured   bool
+	OnSale     bool // Compare price above the price
+
+	// Options are variant option values by option name, e.g. Color: Red,
+	// Blue; one active variant must have a value of each option
+	Options map[string][]string
+	Tags    []string
+
+	// Collection limits the products to a collection's, before any other
+	// filter and in every facet
+	Collection *models.Collection
+
+	// Attributes match the value products have of an attribute, their own
+	// or their category's
+	Attributes []AttributeFilter
+}
+
+// AttributeFilter narrows products by one attribute
+type AttributeFilter struct {
+	Attribute models.AttributeDefinition
+	Values    []string // Any of them, for text and enum attributes
+	Boolean   *bool
+	Min       *float64 // For number attributes
+	Max       *float64
+}
+
+// Result is a page of matching products
+type Result struct {
+	IDs   []uuid.UUID // Product IDs in the requested order
+	Total int64
+
+	// Fuzzy is set when nothing matched the words and the products are
+	// similar spellings instead
+	Fuzzy bool
+
+	Facets *Facets // Only when requested
+}
+
+// Facets count the products each filter value would leave. The count of a
+// value applies every other filter but not the filter's own current value,
+// so choices of one filter can be switched between.
+type Facets struct {
+	Categories []CategoryFacet  `json:"categories"`
+	Price      PriceFacet       `json:"price"`
+	InStock    int64            `json:"in_stock"`
+	Featured   int64            `json:"featured"`
+	OnSale     int64            `json:"on_sale"`
+	Options    []OptionFacet    `json:"options"`
+	Tags       []ValueFacet     `json:"tags"`
+	Attributes []AttributeFacet `json:"attributes"` // Of filterable attributes
+}
+
+type CategoryFacet struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Slug  string    `json:"slug"`
+	Count int64     `json:"count"`
+}
+
+// PriceFacet is the price range of the products, in cents
+type PriceFacet struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+type OptionFacet struct {
+	Name   string       `json:"name"`
+	Values []ValueFacet `json:"values"`
+}
+
+// AttributeFacet counts the values of an attribute, or gives the range of
+// a number attribute
+type AttributeFacet struct {
+	Code   string               `json:"code"`
+	Name   string               `json:"name"`
+	Type   models.AttributeType `json:"type"`
+	Unit   string               `json:"unit,omitempty"`
+	Values []ValueFacet         `json:"values,omitempty"`
+	Range  *RangeFacet          `json:"range,omitempty"`
+}
+
+type RangeFacet struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+type ValueFacet struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Suggestions complete a query in the search box
+type Suggestions struct {
+	Products   []ProductSuggestion  `json:"products"`
+	Categories []CategorySuggestion `json:"categories"`
+}
+
+type ProductSuggestion struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Slug string    `json:"slug"`
+}
+
+type CategorySuggestion struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Slug string    `json:"slug"`
+}
+
+// Words splits text into the words worth searching for
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
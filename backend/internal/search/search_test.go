@@ -0,0 +1,155 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestWords(t *testing.T) {
+	got := strings.Join(Words("  Red T-Shirt & 'crème' (XL)!"), ",")
+	if got != "red,t,shirt,crème,xl" {
+		t.Errorf("Words() = %s", got)
+	}
+	if len(Words(" :*&| ")) != 0 {
+		t.Error("punctuation alone has no words")
+	}
+}
+
+func TestPrefixQuery(t *testing.T) {
+	if got := PrefixQuery(Words("blue hoo")); got != "blue:* & hoo:*" {
+		t.Errorf("PrefixQuery() = %s", got)
+	}
+	// Query syntax in the input never reaches the tsquery
+	if got := PrefixQuery(Words("a:* | !b")); got != "a:* & b:*" {
+		t.Errorf("PrefixQuery() = %s", got)
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got := escapeLike(`50%_off\`); got != `50\%\_off\\` {
+		t.Errorf("escapeLike() = %s", got)
+	}
+}
+
+func TestFilterSet(t *testing.T) {
+	min, max := 1000, 5000
+	filters := newFilterSet(Filters{
+		MinPrice: &min,
+		MaxPrice: &max,
+		OnSale:   true,
+		Tags:     []string{" Summer ", ""},
+		Options:  map[string][]string{"Size": {"M"}, " Color ": {"Red", "BLUE"}, "Empty": {" "}},
+	})
+
+	keys := []string{}
+	for _, c := range filters.conditions {
+		keys = append(keys, c.key)
+	}
+	if strings.Join(keys, ",") != "price,on_sale,tags" {
+		t.Errorf("condition keys = %v", keys)
+	}
+	if args := filters.conditions[0].args; len(args) != 2 || args[0] != 1000 || args[1] != 5000 {
+		t.Errorf("price args = %v", args)
+	}
+	if tags := filters.conditions[2].args[0].([]string); len(tags) != 1 || tags[0] != "summer" {
+		t.Errorf("tags should be trimmed and lower-cased, got %v", tags)
+	}
+
+	if names := filters.optionNames(); strings.Join(names, ",") != "color,size" {
+		t.Fatalf("option names = %v", names)
+	}
+	sql, args := filters.optionMatch("v.id", "")
+	if strings.Count(sql, "EXISTS") != 2 || len(args) != 4 || args[0] != "color" || args[2] != "size" {
+		t.Errorf("unexpected option match %s %v", sql, args)
+	}
+	if values := args[1].([]string); values[1] != "blue" {
+		t.Errorf("option values should be lower-cased, got %v", values)
+	}
+
+	// A facet leaves its own option out
+	sql, args = filters.optionMatch("v.id", optionKey("color"))
+	if strings.Count(sql, "EXISTS") != 1 || args[0] != "size" {
+		t.Errorf("unexpected option match without color %s %v", sql, args)
+	}
+	if _, ok := newFilterSet(Filters{}).variantCondition("products.id", ""); ok {
+		t.Error("no option filters need no variant condition")
+	}
+}
+
+func TestFilterSetCollection(t *testing.T) {
+	max := 5000
+	automatic := &models.Collection{
+		ID:    uuid.New(),
+		Type:  models.CollectionAutomatic,
+		Rules: models.CollectionRules{MaxPrice: &max, Tags: []string{"Summer"}, InStock: true},
+	}
+	filters := newFilterSet(Filters{Collection: automatic, InStock: true})
+	rules := 0
+	for _, c := range filters.conditions {
+		if c.key == keyCollection {
+			rules++
+		}
+	}
+	// The shopper's in-stock filter stays apart, so its facet can leave it out
+	if rules != 3 || len(filters.conditions) != 4 || filters.manual != nil {
+		t.Errorf("automatic collection conditions = %+v", filters.conditions)
+	}
+
+	manual := &models.Collection{ID: uuid.New(), Type: models.CollectionManual, Rules: automatic.Rules}
+	filters = newFilterSet(Filters{Collection: manual})
+	if len(filters.conditions) != 1 || filters.manual == nil || *filters.manual != manual.ID {
+		t.Errorf("manual collection conditions = %+v", filters.conditions)
+	}
+	if sql, args := manualOrder(manual.ID); !strings.HasSuffix(sql, "products.id") || args[0] != manual.ID {
+		t.Errorf("manual order = %s %v", sql, args)
+	}
+}
+
+func TestFilterSetAttributes(t *testing.T) {
+	size := models.AttributeDefinition{ID: uuid.New(), Code: "screen_size", Type: models.AttributeNumber}
+	color := models.AttributeDefinition{ID: uuid.New(), Code: "color", Type: models.AttributeEnum}
+	min, max := 13.0, 15.6
+	filters := newFilterSet(Filters{Attributes: []AttributeFilter{
+		{Attribute: size, Min: &min, Max: &max},
+		{Attribute: color, Values: []string{" Black ", "Silver"}},
+		{Attribute: color}, // Nothing to match
+	}})
+
+	if len(filters.conditions) != 2 {
+		t.Fatalf("conditions = %+v, want 2", filters.conditions)
+	}
+	number := filters.conditions[0]
+	if number.key != attributeKey("screen_size") || !strings.Contains(number.sql, "fav.number_value >= ? AND fav.number_value <= ?") {
+		t.Errorf("number condition = %+v", number)
+	}
+	if len(number.args) != 3 || number.args[0] != size.ID || number.args[1] != min || number.args[2] != max {
+		t.Errorf("number args = %v, want the attribute ID then the range", number.args)
+	}
+	values := filters.conditions[1].args[1].([]string)
+	if len(values) != 2 || values[0] != "black" || values[1] != "silver" {
+		t.Errorf("enum values = %v, want lower-cased", values)
+	}
+}
+
+func TestSortOrder(t *testing.T) {
+	text := &condition{sql: fullTextMatch, args: []interface{}{"shirt"}}
+	sql, args := sortOrder(SortRelevance, text, fullTextRank)
+	if !strings.HasPrefix(sql, fullTextRank+" DESC") || len(args) != 1 {
+		t.Errorf("relevance = %s %v", sql, args)
+	}
+	// Without search text relevance falls back to the newest products
+	if sql, _ := sortOrder(SortRelevance, nil, ""); !strings.HasPrefix(sql, "products.created_at DESC") {
+		t.Errorf("relevance without text = %s", sql)
+	}
+	for _, sort := range []Sort{SortNewest, SortPriceAsc, SortPriceDesc, SortName, SortBestSelling} {
+		if sql, _ := sortOrder(sort, nil, ""); !strings.HasSuffix(sql, "products.id") {
+			t.Errorf("%s should end with the ID tie-breaker: %s", sort, sql)
+		}
+	}
+	if _, ok := ParseSort("cheapest"); ok {
+		t.Error("unknown sorts should be rejected")
+	}
+}
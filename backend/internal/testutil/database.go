@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"easycart/internal/config"
+	"easycart/internal/database"
 	"easycart/internal/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -28,7 +29,9 @@ func SetupTestDB() (*gorm.DB, func()) {
 
 	// Auto-migrate models for testing
 	err = db.AutoMigrate(
+		&models.Role{},
 		&models.User{},
+		&models.APIKey{},
 		&models.Shop{},
 		&models.Category{},
 		&models.Product{},
@@ -39,6 +42,9 @@ func SetupTestDB() (*gorm.DB, func()) {
 	if err != nil {
 		log.Fatalf("Failed to migrate test database: %v", err)
 	}
+	if err := database.SetupProductSearch(db); err != nil {
+		log.Fatalf("Failed to set up product search: %v", err)
+	}
 
 	// Return cleanup function
 	cleanup := func() {
@@ -49,7 +55,9 @@ func SetupTestDB() (*gorm.DB, func()) {
 		db.Exec("DROP TABLE IF EXISTS products CASCADE")
 		db.Exec("DROP TABLE IF EXISTS categories CASCADE")
 		db.Exec("DROP TABLE IF EXISTS shops CASCADE")
+		db.Exec("DROP TABLE IF EXISTS api_keys CASCADE")
 		db.Exec("DROP TABLE IF EXISTS users CASCADE")
+		db.Exec("DROP TABLE IF EXISTS roles CASCADE")
 
 		sqlDB, _ := db.DB()
 		sqlDB.Close()
@@ -66,5 +74,7 @@ func CleanupDB(db *gorm.DB) {
 	db.Exec("DELETE FROM products")
 	db.Exec("DELETE FROM categories")
 	db.Exec("DELETE FROM shops")
+	db.Exec("DELETE FROM api_keys")
 	db.Exec("DELETE FROM users")
+	db.Exec("DELETE FROM roles")
 }
\ No newline at end of file
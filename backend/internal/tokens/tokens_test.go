@@ -0,0 +1,35 @@
+package tokens
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIssueAndVerify(t *testing.T) {
+	token, err := Issue("secret", PurposeStaffInvite, "invite-1", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	subject, err := Verify("secret", PurposeStaffInvite, token)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if subject != "invite-1" {
+		t.Errorf("Expected subject invite-1, got %q", subject)
+	}
+
+	if _, err := Verify("other-secret", PurposeStaffInvite, token); err == nil {
+		t.Error("Expected token signed with another secret to be rejected")
+	}
+	if _, err := Verify("secret", "other_purpose", token); err == nil {
+		t.Error("Expected token for another purpose to be rejected")
+	}
+}
+
+func TestVerify_Expired(t *testing.T) {
+	token, _ := Issue("secret", PurposeStaffInvite, "invite-1", time.Now().Add(-time.Minute))
+	if _, err := Verify("secret", PurposeStaffInvite, token); err != ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken, got %v", err)
+	}
+}
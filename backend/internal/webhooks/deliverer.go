@@ -0,0 +1,183 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"easycart/internal/jobs"
+	"easycart/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+
+	// maxResponseBody is how much of a response body is kept in the delivery log
+	maxResponseBody = 4096
+
+	// disableAfter consecutive failed attempts switches an endpoint off
+	disableAfter = 25
+)
+
+// Deliverer sends queued webhook deliveries from the jobs worker
+type Deliverer struct {
+	db     *gorm.DB
+	client *http.Client
+}
+
+func NewDeliverer(db *gorm.DB) *Deliverer {
+	return &Deliverer{
+		db: db,
+		client: &http.Client{
+			Timeout: requestTimeout,
+			// No proxy, so every connection passes dialControl
+			Transport: &http.Transport{
+				DialContext:         (&net.Dialer{Timeout: requestTimeout, Control: dialControl}).DialContext,
+				TLSHandshakeTimeout: requestTimeout,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			// A redirect is reported as a failure rather than followed, so a
+			// signed body is never replayed to a URL the merchant didn't configure
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Register adds the delivery job handler to w
+func (d *Deliverer) Register(w *jobs.Worker) {
+	w.Register(JobDeliver, d.deliver)
+}
+
+func (d *Deliverer) deliver(ctx context.Context, job *models.Job) error {
+	var payload deliverPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	var delivery models.WebhookDelivery
+	if err := d.db.Preload("Endpoint").First(&delivery, "id = ?", payload.DeliveryID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil // Endpoint deleted along with its deliveries
+		}
+		return err
+	}
+	if delivery.Status != models.WebhookDeliveryPending {
+		return nil
+	}
+	if delivery.Endpoint == nil || !delivery.Endpoint.IsActive {
+		return d.db.Model(&delivery).Updates(map[string]interface{}{
+			"status": models.WebhookDeliveryFailed,
+			"error":  "endpoint is disabled",
+		}).Error
+	}
+
+	sendErr := d.send(ctx, delivery.Endpoint, &delivery)
+	delivery.Attempts = job.Attempts
+
+	final := job.Attempts >= job.MaxAttempts
+	disabled := false
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if sendErr == nil {
+			now := time.Now()
+			delivery.Status = models.WebhookDeliverySucceeded
+			delivery.DeliveredAt = &now
+			if err := tx.Model(&models.WebhookEndpoint{}).Where("id = ?", delivery.EndpointID).
+				Update("consecutive_failures", 0).Error; err != nil {
+				return err
+			}
+			return tx.Omit("Endpoint").Save(&delivery).Error
+		}
+
+		var endpoint models.WebhookEndpoint
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&endpoint, "id = ?", delivery.EndpointID).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"consecutive_failures": endpoint.ConsecutiveFailures + 1}
+		if endpoint.IsActive && endpoint.ConsecutiveFailures+1 >= disableAfter {
+			disabled = true
+			updates["is_active"] = false
+			updates["disabled_at"] = time.Now()
+			updates["disabled_reason"] = fmt.Sprintf("%d consecutive failed deliveries", disableAfter)
+		}
+		if err := tx.Model(&endpoint).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if final || disabled {
+			delivery.Status = models.WebhookDeliveryFailed
+		}
+		return tx.Omit("Endpoint").Save(&delivery).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if disabled {
+		log.Printf("Disabled webhook endpoint %s after %d consecutive failures", delivery.EndpointID, disableAfter)
+		return jobs.Permanent(sendErr)
+	}
+	return sendErr
+}
+
+// send makes one attempt and records the request and response on delivery.
+// It returns an error unless the endpoint answered with a 2xx status.
+func (d *Deliverer) send(ctx context.Context, endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+	delivery.ResponseStatus = 0
+	delivery.ResponseHeaders = nil
+	delivery.ResponseBody = ""
+	delivery.Error = ""
+
+	err := func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "EasyCart-Webhooks/1.0")
+		req.Header.Set(EventHeader, delivery.Event)
+		req.Header.Set(DeliveryHeader, delivery.ID.String())
+		req.Header.Set(SignatureHeader, Sign(endpoint.Secret, time.Now(), body))
+		delivery.RequestHeaders = headerMap(req.Header)
+
+		start := time.Now()
+		resp, err := d.client.Do(req)
+		delivery.DurationMs = time.Since(start).Milliseconds()
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+		delivery.ResponseStatus = resp.StatusCode
+		delivery.ResponseHeaders = headerMap(resp.Header)
+		delivery.ResponseBody = string(respBody)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+		}
+		return nil
+	}()
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	return err
+}
+
+func headerMap(h http.Header) models.JSONMap {
+	m := models.JSONMap{}
+	for key := range h {
+		m[key] = h.Get(key)
+	}
+	return m
+}
@@ -0,0 +1,54 @@
+package webhooks
+
+import (
+	"context"
+
+	"easycart/internal/events"
+	"easycart/internal/models"
+	"gorm.io/gorm"
+)
+
+// Subscribe forwards the domain events merchants can receive to their webhook endpoints
+func Subscribe(d *events.Dispatcher) {
+	d.Subscribe("webhooks", func(ctx context.Context, tx *gorm.DB, event events.Event) error {
+		switch event.Type {
+		case events.TypeOrderPlaced:
+			var placed events.OrderPlaced
+			if err := event.Decode(&placed); err != nil {
+				return err
+			}
+			return Publish(tx, event.ID, models.WebhookEventOrderCreated, placed.Order)
+
+		case events.TypeOrderStatusChanged:
+			var changed events.OrderStatusChanged
+			if err := event.Decode(&changed); err != nil {
+				return err
+			}
+			return Publish(tx, event.ID, models.WebhookEventOrderStatusChanged, changed)
+
+		case events.TypeProductUpdated:
+			var updated events.ProductUpdated
+			if err := event.Decode(&updated); err != nil {
+				return err
+			}
+			return Publish(tx, event.ID, models.WebhookEventProductUpdated, updated.Product)
+
+		case events.TypeStockChanged:
+			var changed events.StockChanged
+			if err := event.Decode(&changed); err != nil {
+				return err
+			}
+			if !changed.FellBelowMinimum() {
+				return nil
+			}
+			return Publish(tx, event.ID, models.WebhookEventInventoryLow, InventoryLevel{
+				ProductID: changed.ProductID,
+				SKU:       changed.SKU,
+				Name:      changed.Name,
+				Stock:     changed.Stock,
+				MinStock:  changed.MinStock,
+			})
+		}
+		return nil
+	}, events.TypeOrderPlaced, events.TypeOrderStatusChanged, events.TypeProductUpdated, events.TypeStockChanged)
+}
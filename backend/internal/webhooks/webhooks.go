@@ -0,0 +1,211 @@
+// Package webhooks sends signed event notifications to merchant endpoints.
+//
+// Domain events from the events package are translated into webhook events;
+// for each one Publish stores a WebhookDelivery per subscribed endpoint and
+// queues a job to send it. Each request carries
+//
+//	X-EasyCart-Event:     the event type, e.g. order.created
+//	X-EasyCart-Delivery:  the delivery ID
+//	X-EasyCart-Signature: t=<unix timestamp>,v1=<hex HMAC-SHA256>
+//
+// where the HMAC is computed with the endpoint secret over "<timestamp>.<body>".
+// Failed deliveries are retried with backoff; an endpoint is disabled after
+// too many consecutive failures.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"easycart/internal/jobs"
+	"easycart/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// JobDeliver sends one WebhookDelivery
+	JobDeliver = "webhooks.deliver"
+
+	SignatureHeader = "X-EasyCart-Signature"
+	EventHeader     = "X-EasyCart-Event"
+	DeliveryHeader  = "X-EasyCart-Delivery"
+
+	// maxAttempts spreads retries over roughly six hours with the jobs backoff
+	maxAttempts = 12
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrSignatureExpired = errors.New("webhook signature timestamp is too old")
+)
+
+// Envelope is the JSON body of every webhook request
+type Envelope struct {
+	ID        uuid.UUID   `json:"id"` // Event ID; the same for redeliveries
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type deliverPayload struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+// Publish queues event for every active endpoint subscribed to it. eventID
+// identifies the event to receivers; pass the domain event's ID so a
+// re-dispatched event is recognisable as a duplicate.
+func Publish(tx *gorm.DB, eventID uuid.UUID, event string, data interface{}) error {
+	var endpoints []models.WebhookEndpoint
+	if err := tx.Where("is_active = ?", true).Find(&endpoints).Error; err != nil {
+		return err
+	}
+
+	var subscribed []models.WebhookEndpoint
+	for _, endpoint := range endpoints {
+		if endpoint.Subscribes(event) {
+			subscribed = append(subscribed, endpoint)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	envelope := Envelope{ID: eventID, Type: event, CreatedAt: time.Now().UTC(), Data: data}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event, err)
+	}
+
+	for _, endpoint := range subscribed {
+		delivery := models.WebhookDelivery{
+			EndpointID: endpoint.ID,
+			EventID:    envelope.ID,
+			Event:      event,
+			Payload:    string(body),
+		}
+		if err := enqueue(tx, &delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping queues a test event for endpoint, whatever it subscribes to
+func Ping(tx *gorm.DB, endpoint *models.WebhookEndpoint) (*models.WebhookDelivery, error) {
+	envelope := Envelope{
+		ID:        uuid.New(),
+		Type:      models.WebhookEventPing,
+		CreatedAt: time.Now().UTC(),
+		Data:      map[string]interface{}{"endpoint_id": endpoint.ID},
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := models.WebhookDelivery{
+		EndpointID: endpoint.ID,
+		EventID:    envelope.ID,
+		Event:      envelope.Type,
+		Payload:    string(body),
+	}
+	if err := enqueue(tx, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Redeliver queues a new delivery with the same event and body as original
+func Redeliver(tx *gorm.DB, original *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		EndpointID:   original.EndpointID,
+		EventID:      original.EventID,
+		Event:        original.Event,
+		Payload:      original.Payload,
+		RedeliveryOf: &original.ID,
+	}
+	if err := enqueue(tx, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func enqueue(tx *gorm.DB, delivery *models.WebhookDelivery) error {
+	if err := tx.Create(delivery).Error; err != nil {
+		return err
+	}
+	_, err := jobs.Enqueue(tx, JobDeliver, deliverPayload{DeliveryID: delivery.ID}, jobs.MaxAttempts(maxAttempts))
+	return err
+}
+
+// Sign returns the signature header value for body sent at timestamp
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + ts + ",v1=" + computeMAC(secret, ts, body)
+}
+
+// Verify checks a signature header the way receivers should: the MAC must
+// match and the timestamp must be within tolerance of now
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	expected := computeMAC(secret, ts, body)
+	valid := false
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	age := now.Sub(time.Unix(unix, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+func computeMAC(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// InventoryLevel is the data of an inventory.low event
+type InventoryLevel struct {
+	ProductID uuid.UUID `json:"product_id"`
+	SKU       string    `json:"sku"`
+	Name      string    `json:"name"`
+	Stock     int       `json:"stock"`
+	MinStock  int       `json:"min_stock"`
+}
@@ -0,0 +1,120 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"easycart/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"type":"order.created"}`)
+	now := time.Unix(1700000000, 0)
+	header := Sign(secret, now, body)
+
+	if !strings.HasPrefix(header, "t=1700000000,v1=") {
+		t.Fatalf("Unexpected signature header %q", header)
+	}
+	if err := Verify(secret, header, body, 5*time.Minute, now.Add(time.Minute)); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := Verify("other", header, body, 5*time.Minute, now); err != ErrInvalidSignature {
+		t.Errorf("Expected wrong secret to fail, got %v", err)
+	}
+	if err := Verify(secret, header, []byte(`{"type":"tampered"}`), 5*time.Minute, now); err != ErrInvalidSignature {
+		t.Errorf("Expected tampered body to fail, got %v", err)
+	}
+	if err := Verify(secret, header, body, 5*time.Minute, now.Add(time.Hour)); err != ErrSignatureExpired {
+		t.Errorf("Expected old timestamp to fail, got %v", err)
+	}
+	if err := Verify(secret, "garbage", body, 5*time.Minute, now); err != ErrInvalidSignature {
+		t.Errorf("Expected malformed header to fail, got %v", err)
+	}
+}
+
+func TestDeliverer_Send(t *testing.T) {
+	var gotSignature, gotEvent string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSignature = r.Header.Get(SignatureHeader)
+		gotEvent = r.Header.Get(EventHeader)
+		gotBody, _ = io.ReadAll(r.Body)
+		if r.URL.Path == "/fail" {
+			http.Error(w, "nope", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	d := NewDeliverer(nil)
+	d.client.Transport = http.DefaultTransport // The test server is on loopback
+	endpoint := &models.WebhookEndpoint{ID: uuid.New(), URL: server.URL + "/ok", Secret: "whsec_test"}
+	delivery := &models.WebhookDelivery{ID: uuid.New(), Event: models.WebhookEventOrderCreated, Payload: `{"id":"1"}`}
+
+	if err := d.send(context.Background(), endpoint, delivery); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	if gotEvent != models.WebhookEventOrderCreated || delivery.ResponseStatus != http.StatusOK || delivery.ResponseBody != "ok" {
+		t.Errorf("Unexpected delivery record: %+v", delivery)
+	}
+	if err := Verify(endpoint.Secret, gotSignature, gotBody, time.Minute, time.Now()); err != nil {
+		t.Errorf("Request signature does not verify: %v", err)
+	}
+
+	endpoint.URL = server.URL + "/fail"
+	if err := d.send(context.Background(), endpoint, delivery); err == nil {
+		t.Error("Expected a 500 response to fail the attempt")
+	}
+	if delivery.ResponseStatus != http.StatusInternalServerError || delivery.Error == "" {
+		t.Errorf("Expected failure details to be recorded, got %+v", delivery)
+	}
+}
+
+func TestDeliverer_RefusesInternalAddresses(t *testing.T) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	d := NewDeliverer(nil)
+	endpoint := &models.WebhookEndpoint{ID: uuid.New(), URL: server.URL, Secret: "whsec_test"}
+	delivery := &models.WebhookDelivery{ID: uuid.New(), Event: models.WebhookEventOrderCreated, Payload: `{}`}
+
+	if err := d.send(context.Background(), endpoint, delivery); err == nil || called {
+		t.Error("Expected a delivery to loopback to be refused")
+	}
+}
+
+func TestIsBlockedIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"192.168.0.10", true},
+		{"169.254.169.254", true},
+		{"fd00:ec2::254", true},
+		{"100.100.100.200", true},
+		{"0.0.0.0", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:2800:220:1::1", false},
+	}
+	for _, tt := range tests {
+		if got := IsBlockedIP(net.ParseIP(tt.ip)); got != tt.blocked {
+			t.Errorf("IsBlockedIP(%s) = %v, want %v", tt.ip, got, tt.blocked)
+		}
+	}
+}